@@ -19,11 +19,14 @@ func main() {
 	configura.LoadEnvironment(cfg, ponrunner.SERVER_PORT, 8080)                                // Fallback port 8080
 	configura.LoadEnvironment(cfg, ponrunner.SERVER_WRITE_TIMEOUT, int64(5))                   // Fallback write timeout 5 seconds
 	configura.LoadEnvironment(cfg, ponrunner.SERVER_READ_TIMEOUT, int64(5))                    // Fallback read timeout 5 seconds
+	configura.LoadEnvironment(cfg, ponrunner.SERVER_IDLE_TIMEOUT, int64(120))                  // Fallback idle timeout 120 seconds
+	configura.LoadEnvironment(cfg, ponrunner.SERVER_READ_HEADER_TIMEOUT, int64(5))             // Fallback read header timeout 5 seconds
 	configura.LoadEnvironment(cfg, ponrunner.SERVER_REQUEST_TIMEOUT, int64(5))                 // Fallback request timeout 5 seconds
 	configura.LoadEnvironment(cfg, ponrunner.SERVER_SHUTDOWN_TIMEOUT, int64(5))                // Fallback shutdown timeout 5 seconds
 	configura.LoadEnvironment(cfg, ponrunner.SERVER_OPENFEATURE_PROVIDER_NAME, "NoopProvider") // Fallback to NoopProvider
 	configura.LoadEnvironment(cfg, ponrunner.SERVER_OPENFEATURE_PROVIDER_URL, "")              // No URL for NoopProvider
 	configura.LoadEnvironment(cfg, ponrunner.OTEL_ENABLED, false)                              // Disable OpenTelemetry by default
+	configura.LoadEnvironment(cfg, ponrunner.OTEL_SDK_DISABLED, false)                         // Honor the standard kill switch
 	configura.LoadEnvironment(cfg, ponrunner.OTEL_LOGS_ENABLED, true)                          // Enable OpenTelemetry logs by default
 	configura.LoadEnvironment(cfg, ponrunner.OTEL_METRICS_ENABLED, true)
 	configura.LoadEnvironment(cfg, ponrunner.OTEL_TRACES_ENABLED, true)
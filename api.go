@@ -0,0 +1,155 @@
+package ponrunner
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"strings"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/ponrove/configura"
+)
+
+const (
+	API_JSON_INDENT             configura.Variable[bool] = "API_JSON_INDENT"             // Indent JSON responses, compact by default
+	API_JSON_ESCAPE_HTML        configura.Variable[bool] = "API_JSON_ESCAPE_HTML"        // Escape <, > and & in JSON responses, disabled by default
+	API_SKIP_OPENAPI_VALIDATION configura.Variable[bool] = "API_SKIP_OPENAPI_VALIDATION" // Skip generating the OpenAPI document at startup
+
+	MAX_REGISTERED_OPERATIONS configura.Variable[int64] = "MAX_REGISTERED_OPERATIONS" // Most API operations that may be registered, unlimited by default
+
+	OPENAPI_SECURITY_SCHEME               configura.Variable[string] = "OPENAPI_SECURITY_SCHEME"               // Security scheme required by all operations, only bearer is supported, empty disables
+	OPENAPI_SECURITY_SCHEME_NAME          configura.Variable[string] = "OPENAPI_SECURITY_SCHEME_NAME"          // Name of the security scheme in the OpenAPI components, defaults to bearer
+	OPENAPI_SECURITY_SCHEME_BEARER_FORMAT configura.Variable[string] = "OPENAPI_SECURITY_SCHEME_BEARER_FORMAT" // Format of the bearer tokens, e.g. JWT, documentation only
+)
+
+var (
+	// ErrInvalidOpenAPI is returned by Start when the OpenAPI document of the registered operations can't be generated.
+	ErrInvalidOpenAPI = errors.New("invalid OpenAPI document")
+	// ErrTooManyOperations is returned by Start when more operations are registered than MAX_REGISTERED_OPERATIONS.
+	ErrTooManyOperations = errors.New("too many registered operations")
+	// ErrUnsupportedSecurityScheme is returned by Start when OPENAPI_SECURITY_SCHEME is not a supported scheme.
+	ErrUnsupportedSecurityScheme = errors.New("unsupported OpenAPI security scheme")
+)
+
+// newHumaConfig returns the huma configuration of the API. It is huma's default configuration, with the JSON format
+// adjusted to API_JSON_INDENT and API_JSON_ESCAPE_HTML. Unset, the JSON output is the same as huma's.
+func newHumaConfig(cfg configura.Config) huma.Config {
+	config := huma.DefaultConfig("Ponrove Backend API", "1.0.0")
+
+	indent := cfg.Bool(API_JSON_INDENT)
+	escapeHTML := cfg.Bool(API_JSON_ESCAPE_HTML)
+	if !indent && !escapeHTML {
+		return config
+	}
+
+	jsonFormat := huma.Format{
+		Marshal: func(w io.Writer, v any) error {
+			enc := json.NewEncoder(w)
+			enc.SetEscapeHTML(escapeHTML)
+			if indent {
+				enc.SetIndent("", "  ")
+			}
+			return enc.Encode(v)
+		},
+		Unmarshal: json.Unmarshal,
+	}
+
+	// huma.DefaultFormats is shared, so the formats are copied rather than modified in place.
+	config.Formats = maps.Clone(config.Formats)
+	config.Formats["application/json"] = jsonFormat
+	config.Formats["json"] = jsonFormat
+	return config
+}
+
+// applySecurityScheme declares the security scheme of OPENAPI_SECURITY_SCHEME in the OpenAPI components, under
+// OPENAPI_SECURITY_SCHEME_NAME, and requires it globally, so bundles don't each declare it. Operations open to anyone
+// can opt out with an empty requirement, Security: []map[string][]string{{}}. The document is left as is if
+// OPENAPI_SECURITY_SCHEME is unset. The scheme is only documented, requests are not authenticated.
+func applySecurityScheme(cfg configura.Config, oapi *huma.OpenAPI) error {
+	schemeType := strings.ToLower(cfg.String(OPENAPI_SECURITY_SCHEME))
+	if schemeType == "" {
+		return nil
+	}
+	if schemeType != "bearer" {
+		return fmt.Errorf("%w: %q (supported: bearer)", ErrUnsupportedSecurityScheme, schemeType)
+	}
+
+	name := configura.Fallback(cfg.String(OPENAPI_SECURITY_SCHEME_NAME), "bearer")
+	if oapi.Components == nil {
+		oapi.Components = &huma.Components{}
+	}
+	if oapi.Components.SecuritySchemes == nil {
+		oapi.Components.SecuritySchemes = make(map[string]*huma.SecurityScheme)
+	}
+	oapi.Components.SecuritySchemes[name] = &huma.SecurityScheme{
+		Type:         "http",
+		Scheme:       "bearer",
+		BearerFormat: cfg.String(OPENAPI_SECURITY_SCHEME_BEARER_FORMAT),
+	}
+	oapi.Security = append(oapi.Security, map[string][]string{name: {}})
+	return nil
+}
+
+// validateOpenAPI generates the OpenAPI document of the API, in all the forms huma serves it (JSON, YAML and the
+// downgraded 3.0 document), so misdefined operations are caught at startup rather than when the document is first
+// requested.
+func validateOpenAPI(api huma.API) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("%w: %v", ErrInvalidOpenAPI, p)
+		}
+	}()
+
+	oapi := api.OpenAPI()
+	if _, err := oapi.YAML(); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidOpenAPI, err)
+	}
+	if _, err := oapi.Downgrade(); err != nil {
+		return fmt.Errorf("%w: downgrade to OpenAPI 3.0: %w", ErrInvalidOpenAPI, err)
+	}
+	return nil
+}
+
+// countOperations returns the number of operations in the OpenAPI document.
+func countOperations(oapi *huma.OpenAPI) int {
+	count := 0
+	for _, item := range oapi.Paths {
+		for _, op := range []*huma.Operation{item.Get, item.Put, item.Post, item.Delete, item.Options, item.Head, item.Patch, item.Trace} {
+			if op != nil {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+// checkOperationLimit guards against accidentally registering an excessive number of operations, e.g. in a loop, by
+// failing when the API has more operations than MAX_REGISTERED_OPERATIONS. Unset, the number is unlimited.
+func checkOperationLimit(cfg configura.Config, api huma.API) error {
+	limit := cfg.Int64(MAX_REGISTERED_OPERATIONS)
+	if limit <= 0 {
+		return nil
+	}
+	if count := countOperations(api.OpenAPI()); int64(count) > limit {
+		return fmt.Errorf("%w: %d operations registered, %s allows at most %d", ErrTooManyOperations, count, MAX_REGISTERED_OPERATIONS, limit)
+	}
+	return nil
+}
+
+// NewScope returns a huma.Group of the API, for bundles registering many operations under the same base path and
+// authentication. Every operation registered through it has its path prefixed with basePath and, unless it declares
+// its own, the security requirements, e.g. map[string][]string{"bearer": {}}. The security schemes themselves must be
+// declared in the OpenAPI components.
+func NewScope(api huma.API, basePath string, security ...map[string][]string) *huma.Group {
+	group := huma.NewGroup(api, basePath)
+	if len(security) > 0 {
+		group.UseSimpleModifier(func(op *huma.Operation) {
+			if op.Security == nil {
+				op.Security = security
+			}
+		})
+	}
+	return group
+}
@@ -0,0 +1,254 @@
+package ponrunner
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/danielgtaylor/huma/v2/adapters/humachi"
+	"github.com/go-chi/chi/v5"
+	"github.com/ponrove/configura"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type jsonFormatOutput struct {
+	Body struct {
+		Text string `json:"text"`
+	}
+}
+
+func TestNewHumaConfig_JSONFormat(t *testing.T) {
+	tests := []struct {
+		name       string
+		indent     bool
+		escapeHTML bool
+		expected   string
+	}{
+		{
+			name:     "Default matches huma",
+			expected: "{\"text\":\"Tom & Jerry <3\"}\n",
+		},
+		{
+			name:     "Indented",
+			indent:   true,
+			expected: "{\n  \"text\": \"Tom & Jerry <3\"\n}\n",
+		},
+		{
+			name:       "HTML escaped",
+			escapeHTML: true,
+			expected:   "{\"text\":\"Tom \\u0026 Jerry \\u003c3\"}\n",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := configura.NewConfigImpl()
+			err := configura.WriteConfiguration(cfg, map[configura.Variable[bool]]bool{
+				API_JSON_INDENT:      tc.indent,
+				API_JSON_ESCAPE_HTML: tc.escapeHTML,
+			})
+			require.NoError(t, err)
+
+			config := newHumaConfig(cfg)
+			config.CreateHooks = nil // Leave out the $schema links, to compare the encoding only.
+			r := chi.NewRouter()
+			api := humachi.New(r, config)
+			huma.Get(api, "/text", func(ctx context.Context, input *struct{}) (*jsonFormatOutput, error) {
+				out := &jsonFormatOutput{}
+				out.Body.Text = "Tom & Jerry <3"
+				return out, nil
+			})
+
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/text", nil))
+
+			assert.Equal(t, http.StatusOK, rr.Code)
+			assert.Equal(t, tc.expected, rr.Body.String())
+		})
+	}
+
+	// huma's shared formats should not have been modified by the indented configuration.
+	var buf bytes.Buffer
+	require.NoError(t, huma.DefaultFormats["application/json"].Marshal(&buf, map[string]string{"a": "b"}))
+	assert.Equal(t, "{\"a\":\"b\"}\n", buf.String())
+}
+
+func TestStart_InvalidOpenAPI(t *testing.T) {
+	t.Parallel()
+
+	freePort, err := getFreePort()
+	require.NoError(t, err, "Failed to get free port")
+
+	emptyCfg := configura.NewConfigImpl()
+	err = configura.WriteConfiguration(emptyCfg, map[configura.Variable[int64]]int64{
+		SERVER_PORT: int64(freePort),
+	})
+	require.NoError(t, err, "Failed to write free port to configuration")
+	finalCfg := configura.Merge(newDefaultCfg(), emptyCfg)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	runErr := Start(ctx, finalCfg, chi.NewRouter(), func(cfg configura.Config, r chi.Router, api huma.API) error {
+		// Registering succeeds, but the extension can't be encoded when the document is generated.
+		huma.Register(api, huma.Operation{
+			OperationID: "get-ratio",
+			Method:      http.MethodGet,
+			Path:        "/ratio",
+			Extensions:  map[string]any{"x-max-ratio": math.Inf(1)},
+		}, func(ctx context.Context, input *struct{}) (*jsonFormatOutput, error) {
+			return &jsonFormatOutput{}, nil
+		})
+		return nil
+	})
+
+	require.Error(t, runErr, "Start should fail at boot for an invalid operation")
+	assert.ErrorIs(t, runErr, ErrInvalidOpenAPI)
+	assert.Contains(t, runErr.Error(), "unsupported value")
+}
+
+func TestStart_TooManyOperations(t *testing.T) {
+	t.Parallel()
+
+	freePort, err := getFreePort()
+	require.NoError(t, err, "Failed to get free port")
+
+	emptyCfg := configura.NewConfigImpl()
+	err = configura.WriteConfiguration(emptyCfg, map[configura.Variable[int64]]int64{
+		SERVER_PORT:               int64(freePort),
+		MAX_REGISTERED_OPERATIONS: 3,
+	})
+	require.NoError(t, err, "Failed to write configuration")
+	finalCfg := configura.Merge(newDefaultCfg(), emptyCfg)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	runErr := Start(ctx, finalCfg, chi.NewRouter(), func(cfg configura.Config, r chi.Router, api huma.API) error {
+		for i := range 4 {
+			huma.Get(api, fmt.Sprintf("/items/%d", i), func(ctx context.Context, input *struct{}) (*jsonFormatOutput, error) {
+				return &jsonFormatOutput{}, nil
+			})
+		}
+		return nil
+	})
+
+	require.Error(t, runErr, "Start should fail when more operations are registered than the limit")
+	assert.ErrorIs(t, runErr, ErrTooManyOperations)
+	assert.Contains(t, runErr.Error(), "4 operations registered, MAX_REGISTERED_OPERATIONS allows at most 3")
+}
+
+func TestNewScope(t *testing.T) {
+	t.Parallel()
+
+	r := chi.NewRouter()
+	api := humachi.New(r, huma.DefaultConfig("Test API", "1.0.0"))
+	scope := NewScope(api, "/v1", map[string][]string{"bearer": {}})
+
+	huma.Get(scope, "/items", func(ctx context.Context, input *struct{}) (*jsonFormatOutput, error) {
+		return &jsonFormatOutput{}, nil
+	})
+	huma.Register(scope, huma.Operation{
+		OperationID: "list-public-items",
+		Method:      http.MethodGet,
+		Path:        "/public",
+		Security:    []map[string][]string{},
+	}, func(ctx context.Context, input *struct{}) (*jsonFormatOutput, error) {
+		return &jsonFormatOutput{}, nil
+	})
+
+	paths := api.OpenAPI().Paths
+	require.Contains(t, paths, "/v1/items", "The operation should be registered under the base path")
+	assert.Equal(t, []map[string][]string{{"bearer": {}}}, paths["/v1/items"].Get.Security)
+	require.Contains(t, paths, "/v1/public")
+	assert.Empty(t, paths["/v1/public"].Get.Security, "An operation's own security should be kept")
+	assert.NotContains(t, paths, "/items")
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/v1/items", nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+}
+
+func TestApplySecurityScheme(t *testing.T) {
+	t.Parallel()
+	cfg := configura.NewConfigImpl()
+	err := configura.WriteConfiguration(cfg, map[configura.Variable[string]]string{
+		OPENAPI_SECURITY_SCHEME:               "bearer",
+		OPENAPI_SECURITY_SCHEME_BEARER_FORMAT: "JWT",
+	})
+	require.NoError(t, err)
+
+	config := newHumaConfig(cfg)
+	require.NoError(t, applySecurityScheme(cfg, config.OpenAPI))
+	r := chi.NewRouter()
+	api := humachi.New(r, config)
+	huma.Get(api, "/text", func(ctx context.Context, input *struct{}) (*jsonFormatOutput, error) {
+		return &jsonFormatOutput{}, nil
+	})
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	var spec struct {
+		Security   []map[string][]string `json:"security"`
+		Components struct {
+			SecuritySchemes map[string]struct {
+				Type         string `json:"type"`
+				Scheme       string `json:"scheme"`
+				BearerFormat string `json:"bearerFormat"`
+			} `json:"securitySchemes"`
+		} `json:"components"`
+	}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &spec))
+	require.Contains(t, spec.Components.SecuritySchemes, "bearer")
+	scheme := spec.Components.SecuritySchemes["bearer"]
+	assert.Equal(t, "http", scheme.Type)
+	assert.Equal(t, "bearer", scheme.Scheme)
+	assert.Equal(t, "JWT", scheme.BearerFormat)
+	assert.Equal(t, []map[string][]string{{"bearer": {}}}, spec.Security, "The scheme should be required globally")
+}
+
+func TestApplySecurityScheme_CustomName(t *testing.T) {
+	t.Parallel()
+	cfg := configura.NewConfigImpl()
+	err := configura.WriteConfiguration(cfg, map[configura.Variable[string]]string{
+		OPENAPI_SECURITY_SCHEME:      "Bearer",
+		OPENAPI_SECURITY_SCHEME_NAME: "accessToken",
+	})
+	require.NoError(t, err)
+
+	oapi := newHumaConfig(cfg).OpenAPI
+	require.NoError(t, applySecurityScheme(cfg, oapi))
+	assert.Contains(t, oapi.Components.SecuritySchemes, "accessToken")
+	assert.Equal(t, []map[string][]string{{"accessToken": {}}}, oapi.Security)
+}
+
+func TestApplySecurityScheme_Disabled(t *testing.T) {
+	t.Parallel()
+	oapi := newHumaConfig(configura.NewConfigImpl()).OpenAPI
+	require.NoError(t, applySecurityScheme(configura.NewConfigImpl(), oapi))
+	assert.Empty(t, oapi.Components.SecuritySchemes)
+	assert.Empty(t, oapi.Security)
+}
+
+func TestApplySecurityScheme_Unsupported(t *testing.T) {
+	t.Parallel()
+	cfg := configura.NewConfigImpl()
+	err := configura.WriteConfiguration(cfg, map[configura.Variable[string]]string{
+		OPENAPI_SECURITY_SCHEME: "basic",
+	})
+	require.NoError(t, err)
+
+	err = applySecurityScheme(cfg, newHumaConfig(cfg).OpenAPI)
+	assert.ErrorIs(t, err, ErrUnsupportedSecurityScheme)
+	assert.ErrorContains(t, err, `"basic"`)
+}
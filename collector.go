@@ -0,0 +1,97 @@
+package ponrunner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/ponrove/configura"
+)
+
+const (
+	OTEL_REQUIRE_COLLECTOR configura.Variable[bool] = "OTEL_REQUIRE_COLLECTOR" // Fail the startup if a configured OTLP collector can't be reached, off by default
+)
+
+// ErrCollectorUnreachable is returned by setupOTelSDK when OTEL_REQUIRE_COLLECTOR is set and the OTLP collector of an
+// enabled signal can't be reached.
+var ErrCollectorUnreachable = errors.New("OTLP collector unreachable")
+
+// collectorAddress returns the host:port to connect to for an OTLP endpoint. URLs without a port use the default port
+// of their scheme, bare hosts the default OTLP gRPC port.
+func collectorAddress(endpoint string) (string, error) {
+	if !otlpEndpointIsURL(endpoint) {
+		if _, _, err := net.SplitHostPort(endpoint); err != nil {
+			return net.JoinHostPort(endpoint, "4317"), nil
+		}
+		return endpoint, nil
+	}
+
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", err
+	}
+	if u.Port() != "" {
+		return u.Host, nil
+	}
+	port := "80"
+	if strings.EqualFold(u.Scheme, "https") {
+		port = "443"
+	}
+	return net.JoinHostPort(u.Hostname(), port), nil
+}
+
+// checkCollectors connects to the OTLP collector of every enabled signal with an endpoint, within the signal's export
+// timeout, so a collector that can't be reached fails the startup rather than the telemetry being dropped on every
+// export. Only the TCP connection is checked, which a collector accepts before any TLS or OTLP exchange. Each address
+// is checked once, and all unreachable ones are reported in a single error.
+func checkCollectors(ctx context.Context, cfg configura.Config) error {
+	var errs []error
+	var checked []string
+	for _, signal := range []struct {
+		enabled     configura.Variable[bool]
+		endpointKey configura.Variable[string]
+		timeoutKey  configura.Variable[int64]
+	}{
+		{enabled: OTEL_TRACES_ENABLED, endpointKey: OTEL_EXPORTER_OTLP_TRACES_ENDPOINT, timeoutKey: OTEL_EXPORTER_OTLP_TRACES_TIMEOUT},
+		{enabled: OTEL_METRICS_ENABLED, endpointKey: OTEL_EXPORTER_OTLP_METRICS_ENDPOINT, timeoutKey: OTEL_EXPORTER_OTLP_METRICS_TIMEOUT},
+		{enabled: OTEL_LOGS_ENABLED, endpointKey: OTEL_EXPORTER_OTLP_LOGS_ENDPOINT, timeoutKey: OTEL_EXPORTER_OTLP_LOGS_TIMEOUT},
+	} {
+		if !cfg.Bool(signal.enabled) {
+			continue
+		}
+		if signal.enabled == OTEL_METRICS_ENABLED && strings.EqualFold(cfg.String(OTEL_METRICS_EXPORTER), metricsExporterPrometheus) {
+			continue // The metrics are scraped by Prometheus, there is no collector to reach.
+		}
+		endpoint, err := otlpEndpoint(cfg, signal.endpointKey)
+		if err != nil {
+			return err
+		}
+		if endpoint == "" {
+			continue // Exported to stdout.
+		}
+		address, err := collectorAddress(endpoint)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%w: invalid endpoint %q: %w", ErrCollectorUnreachable, endpoint, err))
+			continue
+		}
+		if slices.Contains(checked, address) {
+			continue
+		}
+		checked = append(checked, address)
+
+		dialer := net.Dialer{Timeout: otlpTimeout(cfg, signal.timeoutKey)}
+		conn, err := dialer.DialContext(ctx, "tcp", address)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%w: %s: %w", ErrCollectorUnreachable, address, err))
+			continue
+		}
+		_ = conn.Close()
+		slog.DebugContext(ctx, "OTLP collector is reachable.", slog.String("address", address))
+	}
+	return errors.Join(errs...)
+}
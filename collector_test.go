@@ -0,0 +1,137 @@
+package ponrunner
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/go-chi/chi/v5"
+	"github.com/ponrove/configura"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+)
+
+func TestCollectorAddress(t *testing.T) {
+	tests := []struct {
+		endpoint string
+		expected string
+	}{
+		{endpoint: "http://collector:4318/v1/traces", expected: "collector:4318"},
+		{endpoint: "http://collector/v1/traces", expected: "collector:80"},
+		{endpoint: "https://collector", expected: "collector:443"},
+		{endpoint: "collector:4317", expected: "collector:4317"},
+		{endpoint: "collector", expected: "collector:4317"},
+		{endpoint: "http://[::1]:4317", expected: "[::1]:4317"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.endpoint, func(t *testing.T) {
+			address, err := collectorAddress(tc.endpoint)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, address)
+		})
+	}
+}
+
+func TestSetupOTelSDK_RequireCollector(t *testing.T) {
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer collector.Close()
+
+	// An address nothing listens on anymore.
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	unreachable := "http://" + listener.Addr().String()
+	require.NoError(t, listener.Close())
+
+	tests := []struct {
+		name             string
+		endpoint         string
+		requireCollector bool
+		expectErr        bool
+	}{
+		{name: "Unreachable collector required", endpoint: unreachable, requireCollector: true, expectErr: true},
+		{name: "Unreachable collector not required", endpoint: unreachable},
+		{name: "Reachable collector required", endpoint: collector.URL, requireCollector: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := configura.NewConfigImpl()
+			err := configura.WriteConfiguration(cfg, map[configura.Variable[bool]]bool{
+				OTEL_ENABLED:           true,
+				OTEL_TRACES_ENABLED:    true,
+				OTEL_METRICS_ENABLED:   false,
+				OTEL_LOGS_ENABLED:      false,
+				OTEL_REQUIRE_COLLECTOR: tc.requireCollector,
+			})
+			require.NoError(t, err)
+			err = configura.WriteConfiguration(cfg, map[configura.Variable[string]]string{
+				OTEL_EXPORTER_OTLP_TRACES_ENDPOINT: tc.endpoint + "/v1/traces",
+				OTEL_EXPORTER_OTLP_TRACES_PROTOCOL: "http/protobuf",
+			})
+			require.NoError(t, err)
+			finalCfg := configura.Merge(newDefaultCfg(), cfg)
+
+			originalSlogLogger := slog.Default()
+			slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+			originalTracerProvider := otel.GetTracerProvider()
+			t.Cleanup(func() {
+				slog.SetDefault(originalSlogLogger)
+				otel.SetTracerProvider(originalTracerProvider)
+			})
+
+			shutdown, err := setupOTelSDK(context.Background(), finalCfg, nil)
+			if tc.expectErr {
+				assert.ErrorIs(t, err, ErrCollectorUnreachable)
+				assert.Nil(t, shutdown, "No shutdown function should be returned when the collector is unreachable")
+				assert.Equal(t, originalTracerProvider, otel.GetTracerProvider(), "No exporter should have been created")
+				return
+			}
+			require.NoError(t, err)
+			require.NotNil(t, shutdown)
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			_ = shutdown(shutdownCtx)
+		})
+	}
+}
+
+func TestStart_RequireCollector(t *testing.T) {
+	// Not parallel, the default logger is global.
+	originalSlogLogger := slog.Default()
+	t.Cleanup(func() { slog.SetDefault(originalSlogLogger) })
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	unreachable := "http://" + listener.Addr().String()
+	require.NoError(t, listener.Close())
+
+	cfg := configura.NewConfigImpl()
+	err = configura.WriteConfiguration(cfg, map[configura.Variable[bool]]bool{
+		OTEL_ENABLED:           true,
+		OTEL_TRACES_ENABLED:    true,
+		OTEL_REQUIRE_COLLECTOR: true,
+	})
+	require.NoError(t, err)
+	err = configura.WriteConfiguration(cfg, map[configura.Variable[string]]string{
+		OTEL_EXPORTER_OTLP_ENDPOINT: unreachable,
+		OTEL_EXPORTER_OTLP_PROTOCOL: "http/protobuf",
+	})
+	require.NoError(t, err)
+
+	serverListener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	err = StartWithListener(context.Background(), configura.Merge(newDefaultCfg(), cfg), chi.NewRouter(), func(c configura.Config, r chi.Router, a huma.API) error {
+		return nil
+	}, serverListener)
+	assert.ErrorIs(t, err, ErrCollectorUnreachable, "The server should not start without its collector")
+}
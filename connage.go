@@ -0,0 +1,46 @@
+package ponrunner
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/ponrove/configura"
+)
+
+const (
+	SERVER_MAX_CONNECTION_AGE configura.Variable[int64] = "SERVER_MAX_CONNECTION_AGE" // Seconds a keep-alive connection may be reused, 0 disables
+)
+
+// ctxConnStartKey is a context key for storing the time the connection of a request was accepted.
+type ctxConnStartKey struct{}
+
+// connStartContext is an http.Server ConnContext hook, storing the time each connection was accepted in the context of
+// its requests.
+func connStartContext(ctx context.Context, _ net.Conn) context.Context {
+	return context.WithValue(ctx, ctxConnStartKey{}, time.Now())
+}
+
+// maxConnectionAgeHandler responds with `Connection: close` once the connection of a request is older than maxAge,
+// so the server closes it after the response and the client reconnects. Similar to gRPC's MaxConnectionAge, this
+// spreads long-lived keep-alive connections over new instances after a scale-up. It requires the server's ConnContext
+// to be connStartContext.
+func maxConnectionAgeHandler(maxAge time.Duration, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if start, ok := r.Context().Value(ctxConnStartKey{}).(time.Time); ok && time.Since(start) > maxAge {
+			w.Header().Set("Connection", "close")
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// limitConnectionAge configures the server to close keep-alive connections older than SERVER_MAX_CONNECTION_AGE.
+func limitConnectionAge(cfg configura.Config, srv *http.Server) {
+	maxAge := time.Duration(cfg.Int64(SERVER_MAX_CONNECTION_AGE)) * time.Second
+	if maxAge <= 0 {
+		return
+	}
+	srv.ConnContext = connStartContext
+	srv.Handler = maxConnectionAgeHandler(maxAge, srv.Handler)
+}
@@ -0,0 +1,69 @@
+package ponrunner
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"testing"
+	"time"
+
+	"github.com/ponrove/configura"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimitConnectionAge(t *testing.T) {
+	t.Parallel()
+
+	cfg := configura.NewConfigImpl()
+	err := configura.WriteConfiguration(cfg, map[configura.Variable[int64]]int64{
+		SERVER_MAX_CONNECTION_AGE: 1,
+	})
+	require.NoError(t, err)
+
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+	limitConnectionAge(cfg, ts.Config)
+	ts.Start()
+	defer ts.Close()
+
+	client := ts.Client()
+	// get performs a request, and reports whether it reused a connection and the server asked to close it.
+	get := func() (reused bool, closed bool) {
+		req, err := http.NewRequest(http.MethodGet, ts.URL, nil)
+		require.NoError(t, err)
+		req = req.WithContext(httptrace.WithClientTrace(req.Context(), &httptrace.ClientTrace{
+			GotConn: func(info httptrace.GotConnInfo) { reused = info.Reused },
+		}))
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		_, _ = resp.Body.Read(make([]byte, 16))
+		return reused, resp.Close
+	}
+
+	reused, closed := get()
+	assert.False(t, reused)
+	assert.False(t, closed, "A new connection should be kept alive")
+
+	reused, closed = get()
+	assert.True(t, reused, "A young connection should be reused")
+	assert.False(t, closed)
+
+	time.Sleep(1100 * time.Millisecond)
+	reused, closed = get()
+	assert.True(t, reused)
+	assert.True(t, closed, "A connection older than the limit should be closed after the response")
+
+	reused, _ = get()
+	assert.False(t, reused, "The client should reconnect after the old connection was closed")
+}
+
+func TestLimitConnectionAge_Disabled(t *testing.T) {
+	t.Parallel()
+
+	srv := &http.Server{Handler: http.NotFoundHandler()}
+	limitConnectionAge(configura.NewConfigImpl(), srv)
+	assert.Nil(t, srv.ConnContext, "Connections should not be tracked when disabled")
+}
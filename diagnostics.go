@@ -0,0 +1,70 @@
+package ponrunner
+
+import (
+	"context"
+	"log/slog"
+	"runtime"
+
+	"github.com/ponrove/configura"
+)
+
+const (
+	SERVER_SHUTDOWN_DIAGNOSTICS         configura.Variable[bool]  = "SERVER_SHUTDOWN_DIAGNOSTICS"         // Log goroutine count and memory stats around shutdown
+	SERVER_SHUTDOWN_GOROUTINE_THRESHOLD configura.Variable[int64] = "SERVER_SHUTDOWN_GOROUTINE_THRESHOLD" // Dump goroutine stacks if the count exceeds this, 0 disables
+)
+
+// maxGoroutineDumpSize caps the size of the goroutine stack dump, so a leak doesn't produce an unbounded log entry.
+const maxGoroutineDumpSize = 8 << 20
+
+// withShutdownDiagnostics runs the shutdown phases. If SERVER_SHUTDOWN_DIAGNOSTICS is enabled, the goroutine count and
+// memory stats are logged before and after, to help pinpoint goroutine leaks in middleware and bundles. The "end" stats
+// are logged once all the phases have returned, whatever SHUTDOWN_ORDER, so the goroutines of the workers, hooks and
+// telemetry exporters stopped by then aren't counted.
+func withShutdownDiagnostics(ctx context.Context, cfg configura.Config, shutdown func()) {
+	diagnostics := cfg.Bool(SERVER_SHUTDOWN_DIAGNOSTICS)
+	if diagnostics {
+		logRuntimeDiagnostics(ctx, cfg, "start")
+	}
+
+	shutdown()
+
+	if diagnostics {
+		logRuntimeDiagnostics(ctx, cfg, "end")
+	}
+}
+
+// logRuntimeDiagnostics logs the goroutine count and basic memory stats for the given shutdown phase. If the goroutine
+// count exceeds SERVER_SHUTDOWN_GOROUTINE_THRESHOLD, the stacks of all goroutines are logged as well.
+func logRuntimeDiagnostics(ctx context.Context, cfg configura.Config, phase string) {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	goroutines := runtime.NumGoroutine()
+
+	attrs := []slog.Attr{
+		slog.String("phase", phase),
+		slog.Int("goroutines", goroutines),
+		slog.Uint64("heap_alloc_bytes", mem.HeapAlloc),
+		slog.Uint64("heap_objects", mem.HeapObjects),
+		slog.Uint64("sys_bytes", mem.Sys),
+		slog.Uint64("num_gc", uint64(mem.NumGC)),
+	}
+
+	threshold := cfg.Int64(SERVER_SHUTDOWN_GOROUTINE_THRESHOLD)
+	if threshold > 0 && int64(goroutines) > threshold {
+		attrs = append(attrs, slog.String("goroutine_stacks", goroutineStacks()))
+	}
+
+	slog.LogAttrs(ctx, slog.LevelInfo, "Shutdown diagnostics", attrs...)
+}
+
+// goroutineStacks returns the stacks of all goroutines, truncated to maxGoroutineDumpSize.
+func goroutineStacks() string {
+	buf := make([]byte, 64<<10)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) || len(buf) >= maxGoroutineDumpSize {
+			return string(buf[:n])
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
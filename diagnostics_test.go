@@ -0,0 +1,86 @@
+package ponrunner
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"sync"
+	"testing"
+
+	"github.com/ponrove/configura"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// captureDiagnostics runs a shutdown stopping a few goroutines with the given configuration, and returns the diagnostic
+// log entries, along with the "Shutting down" entry the shutdown logs.
+func captureDiagnostics(t *testing.T, cfg configura.Config) []map[string]any {
+	t.Helper()
+	var logBuffer bytes.Buffer
+	originalDefaultLogger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&logBuffer, nil)))
+	t.Cleanup(func() { slog.SetDefault(originalDefaultLogger) })
+
+	// Goroutines of e.g. the workers, stopped by a phase running after the HTTP server is shut down.
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-stop
+		}()
+	}
+
+	withShutdownDiagnostics(context.Background(), cfg, func() {
+		slog.Info("Shutting down")
+		close(stop)
+		wg.Wait()
+	})
+
+	var entries []map[string]any
+	scanner := bufio.NewScanner(&logBuffer)
+	scanner.Buffer(nil, maxGoroutineDumpSize*2)
+	for scanner.Scan() {
+		var entry map[string]any
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &entry))
+		if entry["msg"] == "Shutdown diagnostics" || entry["msg"] == "Shutting down" {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+func TestWithShutdownDiagnostics_Enabled(t *testing.T) {
+	cfg := configura.NewConfigImpl()
+	err := configura.WriteConfiguration(cfg, map[configura.Variable[bool]]bool{
+		SERVER_SHUTDOWN_DIAGNOSTICS: true,
+	})
+	require.NoError(t, err)
+	err = configura.WriteConfiguration(cfg, map[configura.Variable[int64]]int64{
+		SERVER_SHUTDOWN_GOROUTINE_THRESHOLD: 1,
+	})
+	require.NoError(t, err)
+
+	entries := captureDiagnostics(t, cfg)
+
+	require.Len(t, entries, 3, "Diagnostics should be logged at the start and end of shutdown")
+	start, end := entries[0], entries[2]
+	assert.Equal(t, "start", start["phase"])
+	assert.Equal(t, "Shutting down", entries[1]["msg"], "The end should be logged once the shutdown returned")
+	assert.Equal(t, "end", end["phase"])
+	assert.LessOrEqual(t, end["goroutines"], start["goroutines"].(float64)-20, "The stopped goroutines shouldn't be counted at the end")
+	for _, entry := range []map[string]any{start, end} {
+		assert.Greater(t, entry["goroutines"], float64(0))
+		assert.Greater(t, entry["heap_alloc_bytes"], float64(0))
+		assert.Contains(t, entry["goroutine_stacks"], "goroutine ", "Stacks should be dumped when over the threshold")
+	}
+}
+
+func TestWithShutdownDiagnostics_Disabled(t *testing.T) {
+	entries := captureDiagnostics(t, configura.NewConfigImpl())
+	require.Len(t, entries, 1)
+	assert.Equal(t, "Shutting down", entries[0]["msg"], "Diagnostics should not be logged by default")
+}
@@ -0,0 +1,202 @@
+package ponrunner
+
+import (
+	"context"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"github.com/ponrove/configura"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	"go.opentelemetry.io/otel/sdk/trace"
+)
+
+const (
+	OTEL_EXPORT_MAX_QUEUE_SIZE     configura.Variable[int64] = "OTEL_EXPORT_MAX_QUEUE_SIZE"     // Most spans and log records held for export across signals, unlimited by default
+	OTEL_BSP_SCHEDULE_DELAY        configura.Variable[int64] = "OTEL_BSP_SCHEDULE_DELAY"        // Milliseconds between two exports of the spans, defaults to 5000
+	OTEL_BSP_MAX_QUEUE_SIZE        configura.Variable[int64] = "OTEL_BSP_MAX_QUEUE_SIZE"        // Most spans queued for export, further ones are dropped, defaults to 2048
+	OTEL_BSP_MAX_EXPORT_BATCH_SIZE configura.Variable[int64] = "OTEL_BSP_MAX_EXPORT_BATCH_SIZE" // Most spans in a single export, defaults to 512
+)
+
+// exportBudgetWarnRatio is the share of OTEL_EXPORT_MAX_QUEUE_SIZE in use from which a warning is logged.
+const exportBudgetWarnRatio = 0.8
+
+// exportBudget bounds the spans and log records held in memory by the batch processors, from the moment they are
+// queued until their export returns, so a slow or unreachable collector can't make telemetry exhaust the memory of
+// the service. The budget is shared by the signals; metrics are aggregated rather than queued, so they don't use it.
+// A nil budget is unbounded.
+type exportBudget struct {
+	max      int64
+	warnAt   int64
+	held     atomic.Int64
+	dropped  atomic.Int64
+	warned   atomic.Bool
+	dropping atomic.Bool
+}
+
+// newExportBudget returns the budget configured with OTEL_EXPORT_MAX_QUEUE_SIZE, or nil if it isn't set.
+func newExportBudget(cfg configura.Config) *exportBudget {
+	maxSize := cfg.Int64(OTEL_EXPORT_MAX_QUEUE_SIZE)
+	if maxSize <= 0 {
+		return nil
+	}
+	return &exportBudget{max: maxSize, warnAt: max(int64(float64(maxSize)*exportBudgetWarnRatio), 1)}
+}
+
+// acquire reserves room for an item, reporting false if the budget is exhausted and the item must be dropped. A
+// warning is logged once the budget is nearly exhausted, and once it starts dropping, until room is released again.
+func (b *exportBudget) acquire() bool {
+	if b == nil {
+		return true
+	}
+	held := b.held.Add(1)
+	if held > b.max {
+		b.held.Add(-1)
+		dropped := b.dropped.Add(1)
+		if b.dropping.CompareAndSwap(false, true) {
+			slog.Warn("Telemetry export queue is full, dropping spans and log records",
+				slog.Int64("maxQueueSize", b.max),
+				slog.Int64("droppedTotal", dropped))
+		}
+		return false
+	}
+	if held >= b.warnAt && b.warned.CompareAndSwap(false, true) {
+		slog.Warn("Telemetry export queue is nearly full",
+			slog.Int64("queued", held),
+			slog.Int64("maxQueueSize", b.max))
+	}
+	return true
+}
+
+// release returns the room of n exported items to the budget.
+func (b *exportBudget) release(n int) {
+	if b == nil {
+		return
+	}
+	if b.held.Add(-int64(n)) < b.warnAt {
+		b.warned.Store(false)
+		b.dropping.Store(false)
+	}
+}
+
+// spanQueue counts the spans held by the batch span processor, from the moment they are queued until their export
+// returns, so OTEL_BSP_MAX_QUEUE_SIZE can drop them before they take room in the budget. Unlimited if max is 0.
+type spanQueue struct {
+	max  int64
+	held atomic.Int64
+}
+
+// acquire reserves room for a span, reporting false if the queue is full and the span must be dropped.
+func (q *spanQueue) acquire() bool {
+	if held := q.held.Add(1); q.max > 0 && held > q.max {
+		q.held.Add(-1)
+		return false
+	}
+	return true
+}
+
+// release returns the room of n exported spans to the queue.
+func (q *spanQueue) release(n int) {
+	q.held.Add(-int64(n))
+}
+
+// budgetSpanProcessor drops the sampled spans that don't fit in the queue or the budget, before they reach the batch
+// processor.
+type budgetSpanProcessor struct {
+	trace.SpanProcessor
+	queue  *spanQueue
+	budget *exportBudget
+}
+
+func (p *budgetSpanProcessor) OnEnd(s trace.ReadOnlySpan) {
+	// The batch processor ignores unsampled spans, so they don't take room.
+	if !s.SpanContext().IsSampled() || !p.queue.acquire() {
+		return
+	}
+	if !p.budget.acquire() {
+		p.queue.release(1)
+		return
+	}
+	p.SpanProcessor.OnEnd(s)
+}
+
+// budgetSpanExporter returns the room of the spans to the queue and the budget once their export returns.
+type budgetSpanExporter struct {
+	trace.SpanExporter
+	queue  *spanQueue
+	budget *exportBudget
+}
+
+func (e *budgetSpanExporter) ExportSpans(ctx context.Context, spans []trace.ReadOnlySpan) error {
+	defer e.budget.release(len(spans))
+	defer e.queue.release(len(spans))
+	return e.SpanExporter.ExportSpans(ctx, spans)
+}
+
+// batchSpanProcessorOptions returns the options of the batch span processor from the OTEL_BSP_* keys, as named by the
+// specification. The SDK defaults are kept for the keys that aren't set: an export every 5 seconds, of up to 512 of the
+// 2048 spans the queue holds.
+func batchSpanProcessorOptions(cfg configura.Config) []trace.BatchSpanProcessorOption {
+	var opts []trace.BatchSpanProcessorOption
+	if delay := cfg.Int64(OTEL_BSP_SCHEDULE_DELAY); delay > 0 {
+		opts = append(opts, trace.WithBatchTimeout(time.Duration(delay)*time.Millisecond))
+	}
+	if size := cfg.Int64(OTEL_BSP_MAX_QUEUE_SIZE); size > 0 {
+		opts = append(opts, trace.WithMaxQueueSize(int(size)))
+	}
+	if size := cfg.Int64(OTEL_BSP_MAX_EXPORT_BATCH_SIZE); size > 0 {
+		opts = append(opts, trace.WithMaxExportBatchSize(int(size)))
+	}
+	return opts
+}
+
+// newBatchSpanProcessor returns the batch span processor of the exporter, configured with the OTEL_BSP_* keys and
+// holding no more spans than the budget allows.
+func newBatchSpanProcessor(cfg configura.Config, exporter trace.SpanExporter, budget *exportBudget) trace.SpanProcessor {
+	opts := batchSpanProcessorOptions(cfg)
+	if budget == nil {
+		return trace.NewBatchSpanProcessor(exporter, opts...)
+	}
+	// The batch processor's own queue is as large as the budget, so it never drops a span that took room in the budget
+	// and would never be released. A smaller OTEL_BSP_MAX_QUEUE_SIZE is enforced by the span queue instead, before the
+	// budget.
+	queue := &spanQueue{max: max(cfg.Int64(OTEL_BSP_MAX_QUEUE_SIZE), 0)}
+	opts = append(opts, trace.WithMaxQueueSize(int(budget.max)))
+	bsp := trace.NewBatchSpanProcessor(&budgetSpanExporter{SpanExporter: exporter, queue: queue, budget: budget}, opts...)
+	return &budgetSpanProcessor{SpanProcessor: bsp, queue: queue, budget: budget}
+}
+
+// budgetLogProcessor drops the log records that don't fit in the budget, before they reach the batch processor.
+type budgetLogProcessor struct {
+	sdklog.Processor
+	budget *exportBudget
+}
+
+func (p *budgetLogProcessor) OnEmit(ctx context.Context, record *sdklog.Record) error {
+	if !p.budget.acquire() {
+		return nil
+	}
+	return p.Processor.OnEmit(ctx, record)
+}
+
+// budgetLogExporter returns the room of the log records to the budget once their export returns.
+type budgetLogExporter struct {
+	sdklog.Exporter
+	budget *exportBudget
+}
+
+func (e *budgetLogExporter) Export(ctx context.Context, records []sdklog.Record) error {
+	defer e.budget.release(len(records))
+	return e.Exporter.Export(ctx, records)
+}
+
+// newBatchLogProcessor returns the batch log processor of the exporter, holding no more records than the budget
+// allows.
+func newBatchLogProcessor(exporter sdklog.Exporter, budget *exportBudget) sdklog.Processor {
+	if budget == nil {
+		return sdklog.NewBatchProcessor(exporter)
+	}
+	// The batch processor's own queue is as large as the budget, so the budget is what bounds it.
+	bp := sdklog.NewBatchProcessor(&budgetLogExporter{Exporter: exporter, budget: budget}, sdklog.WithMaxQueueSize(int(budget.max)))
+	return &budgetLogProcessor{Processor: bp, budget: budget}
+}
@@ -0,0 +1,260 @@
+package ponrunner
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ponrove/configura"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	otellog "go.opentelemetry.io/otel/log"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// blockingSpanExporter blocks its exports until unblock is closed, like an unreachable collector.
+type blockingSpanExporter struct {
+	unblock  chan struct{}
+	mu       sync.Mutex
+	exported int
+}
+
+func (e *blockingSpanExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	<-e.unblock
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.exported += len(spans)
+	return nil
+}
+
+func (e *blockingSpanExporter) Shutdown(context.Context) error { return nil }
+
+// blockingLogExporter blocks its exports until unblock is closed, like an unreachable collector.
+type blockingLogExporter struct {
+	unblock  chan struct{}
+	mu       sync.Mutex
+	exported int
+}
+
+func (e *blockingLogExporter) Export(ctx context.Context, records []sdklog.Record) error {
+	<-e.unblock
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.exported += len(records)
+	return nil
+}
+
+func (e *blockingLogExporter) Shutdown(context.Context) error   { return nil }
+func (e *blockingLogExporter) ForceFlush(context.Context) error { return nil }
+
+func TestExportBudget(t *testing.T) {
+	var buf bytes.Buffer
+	originalSlogLogger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&buf, nil)))
+	t.Cleanup(func() { slog.SetDefault(originalSlogLogger) })
+
+	cfg := configura.NewConfigImpl()
+	err := configura.WriteConfiguration(cfg, map[configura.Variable[int64]]int64{
+		OTEL_EXPORT_MAX_QUEUE_SIZE: 20,
+	})
+	require.NoError(t, err)
+	budget := newExportBudget(cfg)
+	require.NotNil(t, budget)
+
+	spanExporter := &blockingSpanExporter{unblock: make(chan struct{})}
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(newBatchSpanProcessor(cfg, spanExporter, budget)))
+	logExporter := &blockingLogExporter{unblock: make(chan struct{})}
+	lp := sdklog.NewLoggerProvider(sdklog.WithProcessor(newBatchLogProcessor(logExporter, budget)))
+
+	ctx := context.Background()
+	tracer := tp.Tracer("test")
+	for range 100 {
+		_, span := tracer.Start(ctx, "request")
+		span.End()
+	}
+	logger := lp.Logger("test")
+	for range 10 {
+		var record otellog.Record
+		record.SetBody(otellog.StringValue("request served"))
+		logger.Emit(ctx, record)
+	}
+
+	// The first export blocks holding the 20 spans of the budget, so everything else is dropped.
+	assert.Equal(t, int64(90), budget.dropped.Load(), "Spans and log records over the shared budget should be dropped")
+	assert.Equal(t, int64(20), budget.held.Load())
+	assert.Equal(t, 1, strings.Count(buf.String(), "Telemetry export queue is nearly full"))
+	assert.Equal(t, 1, strings.Count(buf.String(), "Telemetry export queue is full, dropping spans and log records"))
+
+	close(spanExporter.unblock)
+	close(logExporter.unblock)
+	require.NoError(t, tp.Shutdown(ctx))
+	require.NoError(t, lp.Shutdown(ctx))
+	assert.Equal(t, 20, spanExporter.exported)
+	assert.Equal(t, 0, logExporter.exported)
+	assert.Equal(t, int64(0), budget.held.Load(), "Exported spans should return their room to the budget")
+}
+
+func TestNewExportBudget_Unlimited(t *testing.T) {
+	budget := newExportBudget(configura.NewConfigImpl())
+	assert.Nil(t, budget, "The budget should be unbounded by default")
+	assert.True(t, budget.acquire())
+	assert.NotPanics(t, func() { budget.release(1) })
+}
+
+// batchRecordingSpanExporter records the size of each export.
+type batchRecordingSpanExporter struct {
+	mu      sync.Mutex
+	batches []int
+}
+
+func (e *batchRecordingSpanExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.batches = append(e.batches, len(spans))
+	return nil
+}
+
+func (e *batchRecordingSpanExporter) Shutdown(context.Context) error { return nil }
+
+func (e *batchRecordingSpanExporter) Batches() []int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]int(nil), e.batches...)
+}
+
+func TestNewBatchSpanProcessor_ScheduleDelay(t *testing.T) {
+	cfg := configura.NewConfigImpl()
+	err := configura.WriteConfiguration(cfg, map[configura.Variable[int64]]int64{
+		OTEL_BSP_SCHEDULE_DELAY: 50,
+	})
+	require.NoError(t, err)
+
+	exporter := &batchRecordingSpanExporter{}
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(newBatchSpanProcessor(cfg, exporter, nil)))
+	t.Cleanup(func() { _ = tp.Shutdown(context.Background()) })
+
+	_, span := tp.Tracer("test").Start(context.Background(), "request")
+	span.End()
+
+	// The span is exported after the delay, well before the 5 seconds of the default, without a flush.
+	assert.Eventually(t, func() bool { return len(exporter.Batches()) == 1 }, time.Second, 10*time.Millisecond)
+}
+
+func TestNewBatchSpanProcessor_MaxExportBatchSize(t *testing.T) {
+	cfg := configura.NewConfigImpl()
+	err := configura.WriteConfiguration(cfg, map[configura.Variable[int64]]int64{
+		OTEL_BSP_MAX_EXPORT_BATCH_SIZE: 2,
+	})
+	require.NoError(t, err)
+
+	exporter := &batchRecordingSpanExporter{}
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(newBatchSpanProcessor(cfg, exporter, nil)))
+
+	ctx := context.Background()
+	for range 5 {
+		_, span := tp.Tracer("test").Start(ctx, "request")
+		span.End()
+	}
+	require.NoError(t, tp.Shutdown(ctx))
+
+	total := 0
+	for _, size := range exporter.Batches() {
+		assert.LessOrEqual(t, size, 2, "No export should hold more spans than the batch size")
+		total += size
+	}
+	assert.Equal(t, 5, total)
+}
+
+func TestNewBatchSpanProcessor_MaxQueueSize(t *testing.T) {
+	tests := []struct {
+		name      string
+		queueSize int64
+		budget    int64
+		expected  int
+	}{
+		{name: "Queue size", queueSize: 3, expected: 6},
+		{name: "Smaller than the budget", queueSize: 3, budget: 20, expected: 3},
+		{name: "Larger than the budget", queueSize: 50, budget: 4, expected: 4},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			originalSlogLogger := slog.Default()
+			slog.SetDefault(slog.New(slog.NewJSONHandler(io.Discard, nil)))
+			t.Cleanup(func() { slog.SetDefault(originalSlogLogger) })
+
+			cfg := configura.NewConfigImpl()
+			err := configura.WriteConfiguration(cfg, map[configura.Variable[int64]]int64{
+				OTEL_BSP_MAX_QUEUE_SIZE:    tc.queueSize,
+				OTEL_EXPORT_MAX_QUEUE_SIZE: tc.budget,
+			})
+			require.NoError(t, err)
+
+			exporter := &blockingSpanExporter{unblock: make(chan struct{})}
+			tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(newBatchSpanProcessor(cfg, exporter, newExportBudget(cfg))))
+
+			ctx := context.Background()
+			for range 100 {
+				_, span := tp.Tracer("test").Start(ctx, "request")
+				span.End()
+			}
+			close(exporter.unblock)
+			require.NoError(t, tp.Shutdown(ctx))
+
+			// The blocked export holds a batch, at most as large as the queue, and the queue fills up behind it, so the
+			// other spans are dropped. With a budget, the queue size bounds the spans of both together, as the budget
+			// does when it is smaller.
+			assert.LessOrEqual(t, exporter.exported, tc.expected)
+			assert.Positive(t, exporter.exported)
+		})
+	}
+}
+
+func TestNewBatchSpanProcessor_MaxQueueSizeReleasesBudget(t *testing.T) {
+	originalSlogLogger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(io.Discard, nil)))
+	t.Cleanup(func() { slog.SetDefault(originalSlogLogger) })
+
+	cfg := configura.NewConfigImpl()
+	err := configura.WriteConfiguration(cfg, map[configura.Variable[int64]]int64{
+		OTEL_BSP_MAX_QUEUE_SIZE:    3,
+		OTEL_EXPORT_MAX_QUEUE_SIZE: 20,
+	})
+	require.NoError(t, err)
+
+	budget := newExportBudget(cfg)
+	exporter := &blockingSpanExporter{unblock: make(chan struct{})}
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(newBatchSpanProcessor(cfg, exporter, budget)))
+	t.Cleanup(func() { _ = tp.Shutdown(context.Background()) })
+
+	ctx := context.Background()
+	for range 100 {
+		_, span := tp.Tracer("test").Start(ctx, "request")
+		span.End()
+	}
+	assert.LessOrEqual(t, budget.held.Load(), int64(3), "Spans dropped by the queue should not hold room in the budget")
+
+	close(exporter.unblock)
+	require.NoError(t, tp.ForceFlush(ctx))
+	assert.Zero(t, budget.held.Load(), "Every span should return its room once exported")
+	exporter.mu.Lock()
+	exported := exporter.exported
+	exporter.mu.Unlock()
+
+	// The budget isn't leaked, so spans are still exported once the collector is back.
+	for range 20 {
+		_, span := tp.Tracer("test").Start(ctx, "request")
+		span.End()
+		require.NoError(t, tp.ForceFlush(ctx))
+	}
+	exporter.mu.Lock()
+	defer exporter.mu.Unlock()
+	assert.Equal(t, exported+20, exporter.exported)
+	assert.Zero(t, budget.held.Load())
+}
@@ -0,0 +1,17 @@
+package ponrunner
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// RouteGroup mounts a router at pattern, e.g. /admin, for bundles to register routes that need middleware of their
+// own, e.g. middleware.Authenticate or middleware.RateLimitScope, on top of the server's middleware. The middleware
+// only runs for the routes registered on the returned router, after the server's middleware. Routes sharing their
+// path prefix with others that don't need the middleware can use chi's router.Group instead.
+func RouteGroup(router chi.Router, pattern string, middlewares ...func(http.Handler) http.Handler) chi.Router {
+	return router.Route(pattern, func(r chi.Router) {
+		r.Use(middlewares...)
+	})
+}
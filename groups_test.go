@@ -0,0 +1,71 @@
+package ponrunner
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/go-chi/chi/v5"
+	"github.com/ponrove/configura"
+	"github.com/ponrove/ponrunner/middleware"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRouteGroup(t *testing.T) {
+	cfg := configura.NewConfigImpl()
+	err := configura.WriteConfiguration(cfg, map[configura.Variable[string]]string{
+		SERVER_HOST: "127.0.0.1",
+	})
+	require.NoError(t, err)
+	err = configura.WriteConfiguration(cfg, map[configura.Variable[int64]]int64{
+		SERVER_PORT: 0,
+	})
+	require.NoError(t, err)
+
+	ok := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Principal", middleware.GetPrincipalFromContext(r.Context()))
+	}
+	server, err := StartAsync(context.Background(), configura.Merge(newDefaultCfg(), cfg), chi.NewRouter(), func(c configura.Config, r chi.Router, a huma.API) error {
+		admin := RouteGroup(r, "/admin", middleware.Authenticate(c, middleware.BearerTokens(map[string]string{"s3cr3t": "ops"})))
+		admin.Get("/users", ok)
+		public := RouteGroup(r, "/public", middleware.RateLimitScope(c, nil, "public", 1, time.Minute))
+		public.Get("/items", ok)
+		r.Get("/other", ok)
+		return nil
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = server.Shutdown(ctx)
+		_ = server.Wait()
+	})
+
+	get := func(path, token string) *http.Response {
+		req, err := http.NewRequest(http.MethodGet, "http://"+server.Addr().String()+path, nil)
+		require.NoError(t, err)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp
+	}
+
+	// The authentication only applies to the admin group.
+	assert.Equal(t, http.StatusUnauthorized, get("/admin/users", "").StatusCode)
+	authenticated := get("/admin/users", "s3cr3t")
+	assert.Equal(t, http.StatusOK, authenticated.StatusCode)
+	assert.Equal(t, "ops", authenticated.Header.Get("X-Principal"))
+	assert.Equal(t, http.StatusOK, get("/other", "").StatusCode)
+
+	// The rate limit only applies to the public group.
+	assert.Equal(t, http.StatusOK, get("/public/items", "").StatusCode)
+	assert.Equal(t, http.StatusTooManyRequests, get("/public/items", "").StatusCode)
+	assert.Equal(t, http.StatusOK, get("/other", "").StatusCode)
+	assert.Equal(t, http.StatusOK, get("/admin/users", "s3cr3t").StatusCode)
+}
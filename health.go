@@ -0,0 +1,166 @@
+package ponrunner
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/ponrove/configura"
+	"github.com/ponrove/ponrunner/middleware"
+)
+
+const (
+	SERVER_LIVENESS_PATH  configura.Variable[string] = "SERVER_LIVENESS_PATH"  // Path of the liveness endpoint, defaults to /livez
+	SERVER_READINESS_PATH configura.Variable[string] = "SERVER_READINESS_PATH" // Path of the readiness endpoint, defaults to /readyz
+	SERVER_WARMUP_PERIOD  configura.Variable[int64]  = "SERVER_WARMUP_PERIOD"  // Seconds after start during which the server is not ready
+	SERVER_DRAIN_PERIOD   configura.Variable[int64]  = "SERVER_DRAIN_PERIOD"   // Seconds between the shutdown signal and the shutdown, during which the server is not ready
+)
+
+// lifecycle tracks the readiness of a running server. A server is not ready until it is warm, which happens when the
+// warmup period elapses or MarkWarm is called, whichever comes first, and no longer ready once it starts draining.
+type lifecycle struct {
+	warm     atomic.Bool
+	draining atomic.Bool
+	timer    *time.Timer
+}
+
+// currentLifecycle is the lifecycle of the server started last, which MarkWarm operates on.
+var currentLifecycle atomic.Pointer[lifecycle]
+
+// newLifecycle returns a lifecycle that becomes warm after warmup. A non-positive warmup makes it warm immediately.
+func newLifecycle(warmup time.Duration) *lifecycle {
+	l := &lifecycle{}
+	if warmup <= 0 {
+		l.warm.Store(true)
+		return l
+	}
+	l.timer = time.AfterFunc(warmup, func() {
+		if l.warm.CompareAndSwap(false, true) {
+			slog.Info("Warmup period elapsed, server is ready", slog.Duration("warmup", warmup))
+		}
+	})
+	return l
+}
+
+// markWarm marks the lifecycle as warm, ending the warmup period early.
+func (l *lifecycle) markWarm() {
+	if l.warm.CompareAndSwap(false, true) {
+		slog.Info("Server marked as warm, server is ready")
+	}
+	l.stop()
+}
+
+// stop releases the warmup timer, if any.
+func (l *lifecycle) stop() {
+	if l.timer != nil {
+		l.timer.Stop()
+	}
+}
+
+// drain marks the server as draining, no longer ready for traffic.
+func (l *lifecycle) drain() {
+	if l.draining.CompareAndSwap(false, true) {
+		slog.Info("Server is draining, not ready anymore")
+	}
+}
+
+// ready reports whether the server should receive traffic.
+func (l *lifecycle) ready() bool {
+	return l.warm.Load() && !l.draining.Load()
+}
+
+// MarkWarm ends the warmup period (SERVER_WARMUP_PERIOD) of the running server, so /readyz starts reporting ready.
+// Bundles that prefill caches or similar can call it once they're done, rather than waiting for the period to elapse.
+// It's a no-op if no server is running.
+func MarkWarm() {
+	if l := currentLifecycle.Load(); l != nil {
+		l.markWarm()
+	}
+}
+
+// livenessPath returns the path of the liveness endpoint.
+func livenessPath(cfg configura.Config) string {
+	return configura.Fallback(cfg.String(SERVER_LIVENESS_PATH), "/livez")
+}
+
+// readinessPath returns the path of the readiness endpoint.
+func readinessPath(cfg configura.Config) string {
+	return configura.Fallback(cfg.String(SERVER_READINESS_PATH), "/readyz")
+}
+
+// internalPaths returns the paths of the internal endpoints of the server: the health checks, and the metrics and version
+// endpoints if they are enabled. They are exempt from the middleware gating the API (RequireHTTPS, Maintenance,
+// RequireAPIVersion and Accept), unless their exempt paths are configured.
+func internalPaths(cfg configura.Config) []string {
+	paths := []string{livenessPath(cfg), readinessPath(cfg)}
+	if strings.EqualFold(cfg.String(OTEL_METRICS_EXPORTER), metricsExporterPrometheus) {
+		paths = append(paths, configura.Fallback(cfg.String(OTEL_PROMETHEUS_PATH), "/metrics"))
+	}
+	if path := cfg.String(SERVER_VERSION_PATH); path != "" {
+		paths = append(paths, path)
+	}
+	return paths
+}
+
+// registerHealthEndpoints registers the liveness and readiness endpoints on the router. Liveness reports the process
+// is up and serving, independent of readiness, so orchestrators don't restart a server that is still warming up or
+// draining.
+func registerHealthEndpoints(cfg configura.Config, router chi.Router, l *lifecycle) {
+	router.Get(livenessPath(cfg), func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		_, _ = w.Write([]byte("ok"))
+	})
+	router.Get(readinessPath(cfg), func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		if l.draining.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = w.Write([]byte("draining"))
+			return
+		}
+		if !l.ready() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = w.Write([]byte("warming up"))
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	})
+}
+
+// rejectWhileDraining is a middleware rejecting requests with a 503 Service Unavailable once the server is draining, so
+// clients retry on another instance. The internalPaths are still served, so orchestrators see the server alive but not
+// ready, and the last metrics are scraped.
+func rejectWhileDraining(cfg configura.Config, l *lifecycle) func(http.Handler) http.Handler {
+	exempt := internalPaths(cfg)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if l.draining.Load() && !slices.Contains(exempt, r.URL.Path) {
+				middleware.SetRetryAfter(cfg, w, time.Second)
+				middleware.Reject(cfg, w, r, http.StatusServiceUnavailable, "The server is shutting down")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// drainPeriod returns how long the server drains before it's shut down, SERVER_DRAIN_PERIOD.
+func drainPeriod(cfg configura.Config) time.Duration {
+	return max(time.Duration(cfg.Int64(SERVER_DRAIN_PERIOD))*time.Second, 0)
+}
+
+// drainServer marks the server as draining, and waits for SERVER_DRAIN_PERIOD before it's shut down, giving load
+// balancers time to notice it's not ready and stop sending it traffic.
+func drainServer(ctx context.Context, cfg configura.Config, l *lifecycle) {
+	l.drain()
+	period := drainPeriod(cfg)
+	if period <= 0 {
+		return
+	}
+	slog.InfoContext(ctx, "Draining before shutdown", slog.Duration("period", period))
+	time.Sleep(period)
+}
@@ -0,0 +1,249 @@
+package ponrunner
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/go-chi/chi/v5"
+	"github.com/ponrove/configura"
+	"github.com/ponrove/ponrunner/middleware"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func healthStatus(t *testing.T, r http.Handler, path string) int {
+	t.Helper()
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+	return rr.Code
+}
+
+func TestHealthEndpoints_WarmupElapses(t *testing.T) {
+	t.Parallel()
+
+	lc := newLifecycle(100 * time.Millisecond)
+	defer lc.stop()
+	r := chi.NewRouter()
+	registerHealthEndpoints(configura.NewConfigImpl(), r, lc)
+
+	assert.Equal(t, http.StatusServiceUnavailable, healthStatus(t, r, "/readyz"), "Should not be ready during warmup")
+	assert.Equal(t, http.StatusOK, healthStatus(t, r, "/livez"), "Liveness is independent of warmup")
+
+	assert.Eventually(t, func() bool {
+		return healthStatus(t, r, "/readyz") == http.StatusOK
+	}, 2*time.Second, 10*time.Millisecond, "Should become ready once the warmup elapses")
+}
+
+func TestHealthEndpoints_NoWarmup(t *testing.T) {
+	t.Parallel()
+
+	cfg := configura.NewConfigImpl()
+	err := configura.WriteConfiguration(cfg, map[configura.Variable[string]]string{
+		SERVER_LIVENESS_PATH:  "/health/live",
+		SERVER_READINESS_PATH: "/health/ready",
+	})
+	require.NoError(t, err)
+
+	r := chi.NewRouter()
+	registerHealthEndpoints(cfg, r, newLifecycle(0))
+
+	assert.Equal(t, http.StatusOK, healthStatus(t, r, "/health/ready"))
+	assert.Equal(t, http.StatusOK, healthStatus(t, r, "/health/live"))
+	assert.Equal(t, http.StatusNotFound, healthStatus(t, r, "/readyz"), "Default path should not be used when configured")
+}
+
+func TestStart_MarkWarm(t *testing.T) {
+	// Not parallel, MarkWarm operates on the server started last.
+	freePort, err := getFreePort()
+	require.NoError(t, err, "Failed to get free port")
+
+	emptyCfg := configura.NewConfigImpl()
+	err = configura.WriteConfiguration(emptyCfg, map[configura.Variable[int64]]int64{
+		SERVER_PORT:          int64(freePort),
+		SERVER_WARMUP_PERIOD: 3600,
+	})
+	require.NoError(t, err, "Failed to write configuration")
+	finalCfg := configura.Merge(newDefaultCfg(), emptyCfg)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	startErrChan := make(chan error, 1)
+	go func() {
+		startErrChan <- Start(ctx, finalCfg, chi.NewRouter(), func(cfg configura.Config, r chi.Router, a huma.API) error {
+			return nil
+		})
+	}()
+
+	// A client of its own, whose idle connections are closed before the shutdown, as it waits up to 5 seconds for the
+	// connections that never sent a request, like the spare ones http.DefaultTransport may dial.
+	client := &http.Client{Transport: &http.Transport{}}
+	baseURL := fmt.Sprintf("http://localhost:%d", freePort)
+	get := func(path string) int {
+		resp, err := client.Get(baseURL + path)
+		if err != nil {
+			return 0
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	require.Eventually(t, func() bool { return get("/livez") == http.StatusOK }, 2*time.Second, 50*time.Millisecond, "server never started")
+	assert.Equal(t, http.StatusServiceUnavailable, get("/readyz"), "Should not be ready during warmup")
+
+	MarkWarm()
+	assert.Equal(t, http.StatusOK, get("/readyz"), "Should be ready after MarkWarm")
+
+	client.CloseIdleConnections()
+	cancel()
+	select {
+	case err := <-startErrChan:
+		assert.NoError(t, err, "Start should exit gracefully without error")
+	case <-time.After(3 * time.Second):
+		t.Fatal("Start did not exit after context cancellation")
+	}
+}
+
+func TestStart_DrainKeepsHealthEndpoints(t *testing.T) {
+	t.Parallel()
+
+	freePort, err := getFreePort()
+	require.NoError(t, err, "Failed to get free port")
+
+	emptyCfg := configura.NewConfigImpl()
+	err = configura.WriteConfiguration(emptyCfg, map[configura.Variable[int64]]int64{
+		SERVER_PORT:         int64(freePort),
+		SERVER_DRAIN_PERIOD: 1,
+	})
+	require.NoError(t, err, "Failed to write configuration")
+	err = configura.WriteConfiguration(emptyCfg, map[configura.Variable[string]]string{
+		SERVER_VERSION_PATH: "/version",
+	})
+	require.NoError(t, err, "Failed to write configuration")
+	finalCfg := configura.Merge(newDefaultCfg(), emptyCfg)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	startErrChan := make(chan error, 1)
+	go func() {
+		startErrChan <- Start(ctx, finalCfg, chi.NewRouter(), func(cfg configura.Config, r chi.Router, a huma.API) error {
+			r.Get("/api/x", func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte("x"))
+			})
+			return nil
+		})
+	}()
+
+	// A client of its own without keep-alives, as the shutdown waits up to 5 seconds for the connections that never
+	// sent a request, like the spare ones http.DefaultTransport may dial, longer than the test waits for Start to exit.
+	client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+	baseURL := fmt.Sprintf("http://localhost:%d", freePort)
+	get := func(path string) int {
+		resp, err := client.Get(baseURL + path)
+		if err != nil {
+			return 0
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	require.Eventually(t, func() bool { return get("/livez") == http.StatusOK }, 2*time.Second, 50*time.Millisecond, "server never started")
+	assert.Equal(t, http.StatusOK, get("/api/x"))
+
+	cancel()
+	require.Eventually(t, func() bool { return get("/readyz") == http.StatusServiceUnavailable }, time.Second, 10*time.Millisecond,
+		"Should not be ready while draining")
+	assert.Equal(t, http.StatusOK, get("/livez"), "Liveness should be served while draining")
+	assert.Equal(t, http.StatusOK, get("/version"), "The internal endpoints should be served while draining")
+	assert.Equal(t, http.StatusServiceUnavailable, get("/api/x"), "Application routes should be rejected while draining")
+
+	select {
+	case err := <-startErrChan:
+		assert.NoError(t, err, "Start should exit gracefully without error")
+	case <-time.After(4 * time.Second):
+		t.Fatal("Start did not exit after the drain period")
+	}
+}
+
+func TestInternalPaths(t *testing.T) {
+	tests := []struct {
+		name     string
+		values   map[configura.Variable[string]]string
+		expected []string
+	}{
+		{name: "Default health checks", expected: []string{"/livez", "/readyz"}},
+		{
+			name:     "Custom health checks",
+			values:   map[configura.Variable[string]]string{SERVER_LIVENESS_PATH: "/healthz", SERVER_READINESS_PATH: "/ready"},
+			expected: []string{"/healthz", "/ready"},
+		},
+		{
+			name:     "Prometheus and version endpoints",
+			values:   map[configura.Variable[string]]string{OTEL_METRICS_EXPORTER: "Prometheus", SERVER_VERSION_PATH: "/version"},
+			expected: []string{"/livez", "/readyz", "/metrics", "/version"},
+		},
+		{
+			name:     "Custom Prometheus path",
+			values:   map[configura.Variable[string]]string{OTEL_METRICS_EXPORTER: "prometheus", OTEL_PROMETHEUS_PATH: "/internal/metrics"},
+			expected: []string{"/livez", "/readyz", "/internal/metrics"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := configura.NewConfigImpl()
+			require.NoError(t, configura.WriteConfiguration(cfg, tc.values))
+			assert.Equal(t, tc.expected, internalPaths(cfg))
+		})
+	}
+}
+
+func TestStart_InternalPathsExempt(t *testing.T) {
+	// Not parallel, maintenance mode is global.
+	t.Cleanup(func() { middleware.SetMaintenance(false) })
+
+	cfg := configura.NewConfigImpl()
+	err := configura.WriteConfiguration(cfg, map[configura.Variable[string]]string{
+		SERVER_HOST:                      "127.0.0.1",
+		SERVER_LIVENESS_PATH:             "/healthz",
+		SERVER_READINESS_PATH:            "/ready",
+		SERVER_VERSION_PATH:              "/version",
+		middleware.REQUIRE_HTTPS_MODE:    "reject",
+		middleware.API_VERSION_SUPPORTED: "2024-01-01",
+	})
+	require.NoError(t, err)
+	err = configura.WriteConfiguration(cfg, map[configura.Variable[bool]]bool{
+		middleware.MAINTENANCE_ENABLED: true,
+	})
+	require.NoError(t, err)
+	err = configura.WriteConfiguration(cfg, map[configura.Variable[int64]]int64{
+		SERVER_PORT: 0,
+	})
+	require.NoError(t, err)
+
+	server, err := StartAsync(context.Background(), configura.Merge(newDefaultCfg(), cfg), chi.NewRouter(), func(c configura.Config, r chi.Router, a huma.API) error {
+		return nil
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = server.Shutdown(ctx)
+		_ = server.Wait()
+	})
+
+	get := func(path string) int {
+		resp, err := http.Get("http://" + server.Addr().String() + path)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	// The configured health checks and version endpoint are served in maintenance, over plain HTTP and without a version.
+	assert.Equal(t, http.StatusOK, get("/healthz"))
+	assert.Equal(t, http.StatusOK, get("/ready"))
+	assert.Equal(t, http.StatusOK, get("/version"))
+	assert.Equal(t, http.StatusServiceUnavailable, get("/livez"), "The default health check isn't a health check anymore")
+}
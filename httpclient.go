@@ -0,0 +1,55 @@
+package ponrunner
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ponrove/configura"
+	"github.com/ponrove/ponrunner/middleware"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+const (
+	HTTP_CLIENT_TIMEOUT configura.Variable[int64] = "HTTP_CLIENT_TIMEOUT" // Seconds an outbound request made with NewHTTPClient may take, 0 is unlimited
+)
+
+// serverTimingTransport records the Server-Timing headers of the responses in the context of their requests, for
+// the upstream_timing field of the access log.
+type serverTimingTransport struct {
+	next http.RoundTripper
+}
+
+// RoundTrip sends the request, recording the Server-Timing headers of the response.
+func (t *serverTimingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	if err == nil {
+		middleware.RecordUpstreamServerTiming(req.Context(), req.URL.Host, resp.Header.Values("Server-Timing"))
+	}
+	return resp, err
+}
+
+// httpClientTransport returns the transport shared by the clients of NewHTTPClient, so they share their connection
+// pool, and its idle connections can be closed on shutdown however many clients were created.
+var httpClientTransport = sync.OnceValue(func() *http.Transport {
+	return http.DefaultTransport.(*http.Transport).Clone()
+})
+
+// closeHTTPClientConnections is the shutdown hook closing the idle connections of the clients of NewHTTPClient.
+func closeHTTPClientConnections(context.Context) error {
+	httpClientTransport().CloseIdleConnections()
+	return nil
+}
+
+// NewHTTPClient returns an http.Client for calls to other services, instrumented with OpenTelemetry so the calls are
+// traced as children of the request span and carry the trace context downstream. Requests time out after
+// HTTP_CLIENT_TIMEOUT. The clients share a connection pool, whose idle connections are closed when the server shuts
+// down, after the shutdown hooks, so clients can be created at any time, e.g. per request. The Server-Timing headers of
+// the responses are logged in the upstream_timing field of the access log if REQUEST_LOG_UPSTREAM_TIMING is set.
+func NewHTTPClient(cfg configura.Config, opts ...otelhttp.Option) *http.Client {
+	return &http.Client{
+		Transport: otelhttp.NewTransport(&serverTimingTransport{next: httpClientTransport()}, opts...),
+		Timeout:   time.Duration(cfg.Int64(HTTP_CLIENT_TIMEOUT)) * time.Second,
+	}
+}
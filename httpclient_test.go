@@ -0,0 +1,203 @@
+package ponrunner
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/go-chi/chi/v5"
+	chim "github.com/go-chi/chi/v5/middleware"
+	"github.com/ponrove/configura"
+	"github.com/ponrove/ponrunner/middleware"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/baggage"
+)
+
+func TestStart_ClosesHTTPClientIdleConnections(t *testing.T) {
+	// Not parallel, shutdown hooks are registered globally.
+	var mu sync.Mutex
+	states := make(map[net.Conn]http.ConnState)
+	upstream := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+	upstream.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		mu.Lock()
+		defer mu.Unlock()
+		states[conn] = state
+	}
+	upstream.Start()
+	defer upstream.Close()
+	connStates := func() []http.ConnState {
+		mu.Lock()
+		defer mu.Unlock()
+		var s []http.ConnState
+		for _, state := range states {
+			s = append(s, state)
+		}
+		return s
+	}
+
+	freePort, err := getFreePort()
+	require.NoError(t, err, "Failed to get free port")
+	emptyCfg := configura.NewConfigImpl()
+	err = configura.WriteConfiguration(emptyCfg, map[configura.Variable[int64]]int64{
+		SERVER_PORT: int64(freePort),
+	})
+	require.NoError(t, err, "Failed to write configuration")
+	finalCfg := configura.Merge(newDefaultCfg(), emptyCfg)
+
+	clientChan := make(chan *http.Client, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	startErrChan := make(chan error, 1)
+	go func() {
+		startErrChan <- Start(ctx, finalCfg, chi.NewRouter(), func(cfg configura.Config, r chi.Router, a huma.API) error {
+			clientChan <- NewHTTPClient(cfg)
+			return nil
+		})
+	}()
+
+	client := <-clientChan
+	resp, err := client.Get(upstream.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Eventually(t, func() bool {
+		s := connStates()
+		return len(s) == 1 && s[0] == http.StateIdle
+	}, time.Second, 10*time.Millisecond, "The connection should be kept alive")
+
+	cancel()
+	select {
+	case err := <-startErrChan:
+		assert.NoError(t, err, "Start should exit gracefully without error")
+	case <-time.After(3 * time.Second):
+		t.Fatal("Start did not exit after context cancellation")
+	}
+
+	assert.Eventually(t, func() bool {
+		s := connStates()
+		return len(s) == 1 && s[0] == http.StateClosed
+	}, time.Second, 10*time.Millisecond, "The idle connection should be closed on shutdown")
+}
+
+func TestNewHTTPClient_ForwardsRequestIDBaggage(t *testing.T) {
+	// Not parallel, the propagator and the shutdown hooks are global.
+	originalPropagator := otel.GetTextMapPropagator()
+	initializePropagator(context.Background())
+	t.Cleanup(func() {
+		otel.SetTextMapPropagator(originalPropagator)
+		takeShutdownHooks()
+	})
+
+	received := make(chan string, 1)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r.Header.Get("Baggage")
+	}))
+	defer upstream.Close()
+
+	cfg := configura.NewConfigImpl()
+	require.NoError(t, configura.WriteConfiguration(cfg, map[configura.Variable[bool]]bool{
+		middleware.OTEL_BAGGAGE_REQUEST_ID: true,
+	}))
+	client := NewHTTPClient(cfg)
+
+	var requestID string
+	handler := chim.RequestID(middleware.RequestIDBaggage(cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID = chim.GetReqID(r.Context())
+		req, err := http.NewRequestWithContext(r.Context(), http.MethodGet, upstream.URL, nil)
+		require.NoError(t, err)
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+	})))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	require.NotEmpty(t, requestID)
+	bag, err := baggage.Parse(<-received)
+	require.NoError(t, err)
+	assert.Equal(t, requestID, bag.Member("request_id").Value(), "The request ID should be forwarded in the baggage")
+}
+
+func TestNewHTTPClient_LogsUpstreamServerTiming(t *testing.T) {
+	// Not parallel, the default logger and the shutdown hooks are global.
+	var logBuffer strings.Builder
+	originalDefaultLogger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&logBuffer, nil)))
+	t.Cleanup(func() {
+		slog.SetDefault(originalDefaultLogger)
+		takeShutdownHooks()
+	})
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Server-Timing", `db;desc="Query users";dur=12.5, cache;desc="miss"`)
+		w.Header().Add("Server-Timing", "total;dur=20")
+	}))
+	defer upstream.Close()
+
+	cfg := configura.NewConfigImpl()
+	require.NoError(t, configura.WriteConfiguration(cfg, map[configura.Variable[bool]]bool{
+		middleware.REQUEST_LOG_UPSTREAM_TIMING: true,
+	}))
+	client := NewHTTPClient(cfg)
+
+	handler := middleware.LogRequest(cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for range 2 {
+			req, err := http.NewRequestWithContext(r.Context(), http.MethodGet, upstream.URL, nil)
+			require.NoError(t, err)
+			resp, err := client.Do(req)
+			require.NoError(t, err)
+			resp.Body.Close()
+		}
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	var logged map[string]any
+	require.NoError(t, json.Unmarshal([]byte(logBuffer.String()), &logged))
+	upstreamHost := strings.TrimPrefix(upstream.URL, "http://")
+	assert.Equal(t, map[string]any{
+		upstreamHost: map[string]any{
+			"db":    float64(25 * time.Millisecond),
+			"total": float64(40 * time.Millisecond),
+		},
+	}, logged["upstream_timing"], "The timings should be summed over the calls to the upstream")
+}
+
+func TestNewHTTPClient_SharedTransport(t *testing.T) {
+	// Not parallel, shutdown hooks are registered globally.
+	var closed atomic.Int32
+	upstream := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+	upstream.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateClosed {
+			closed.Add(1)
+		}
+	}
+	upstream.Start()
+	defer upstream.Close()
+
+	takeShutdownHooks()
+	cfg := configura.NewConfigImpl()
+	for range 3 {
+		resp, err := NewHTTPClient(cfg).Get(upstream.URL)
+		require.NoError(t, err)
+		_, _ = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+	assert.Empty(t, takeShutdownHooks(), "Clients created per request should not register a shutdown hook each")
+
+	// The clients share their idle connection, closed by the shutdown of the server, whenever they were created.
+	require.NoError(t, closeHTTPClientConnections(context.Background()))
+	assert.Eventually(t, func() bool { return closed.Load() == 1 }, time.Second, 10*time.Millisecond)
+}
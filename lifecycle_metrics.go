@@ -0,0 +1,70 @@
+package ponrunner
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// lifecycleInstrumentationName is the instrumentation scope of the server lifecycle metrics.
+const lifecycleInstrumentationName = "github.com/ponrove/ponrunner"
+
+// lifecycleMetrics records the start and shutdown of the server, for deploy dashboards. With the Prometheus naming
+// conventions, they are exported as server_start_timestamp, server_uptime_seconds and server_shutdown_total.
+type lifecycleMetrics struct {
+	shutdowns    metric.Int64Counter
+	registration metric.Registration
+}
+
+// newLifecycleMetrics registers the lifecycle metrics of a server started at start with the meter provider. The start
+// timestamp and uptime are observable gauges, reported on each collection until unregister is called.
+func newLifecycleMetrics(mp metric.MeterProvider, start time.Time) *lifecycleMetrics {
+	meter := mp.Meter(lifecycleInstrumentationName)
+	lm := &lifecycleMetrics{}
+
+	startTimestamp, err := meter.Float64ObservableGauge("server.start_timestamp",
+		metric.WithDescription("Unix time in seconds the server started at."),
+	)
+	if err != nil {
+		otel.Handle(err)
+	}
+	uptime, err := meter.Float64ObservableGauge("server.uptime",
+		metric.WithDescription("Seconds since the server started."),
+		metric.WithUnit("s"),
+	)
+	if err != nil {
+		otel.Handle(err)
+	}
+	lm.shutdowns, err = meter.Int64Counter("server.shutdown",
+		metric.WithDescription("Number of server shutdowns initiated."),
+	)
+	if err != nil {
+		otel.Handle(err)
+	}
+
+	lm.registration, err = meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		o.ObserveFloat64(startTimestamp, float64(start.UnixNano())/float64(time.Second))
+		o.ObserveFloat64(uptime, time.Since(start).Seconds())
+		return nil
+	}, startTimestamp, uptime)
+	if err != nil {
+		otel.Handle(err)
+	}
+	return lm
+}
+
+// shutdown records that the server initiated its shutdown.
+func (lm *lifecycleMetrics) shutdown(ctx context.Context) {
+	lm.shutdowns.Add(ctx, 1)
+}
+
+// unregister stops reporting the start timestamp and uptime.
+func (lm *lifecycleMetrics) unregister() {
+	if lm.registration != nil {
+		if err := lm.registration.Unregister(); err != nil {
+			otel.Handle(err)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package ponrunner
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// collectLifecycleMetrics collects the lifecycle metrics as a map of metric name to the value of its single data point.
+func collectLifecycleMetrics(t *testing.T, reader *sdkmetric.ManualReader) map[string]float64 {
+	t.Helper()
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+
+	values := make(map[string]float64)
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			switch data := m.Data.(type) {
+			case metricdata.Gauge[float64]:
+				require.Len(t, data.DataPoints, 1)
+				values[m.Name] = data.DataPoints[0].Value
+			case metricdata.Sum[int64]:
+				require.Len(t, data.DataPoints, 1)
+				values[m.Name] = float64(data.DataPoints[0].Value)
+			}
+		}
+	}
+	return values
+}
+
+func TestLifecycleMetrics(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	defer func() { _ = mp.Shutdown(context.Background()) }()
+
+	start := time.Now().Add(-time.Minute)
+	lm := newLifecycleMetrics(mp, start)
+
+	first := collectLifecycleMetrics(t, reader)
+	assert.InDelta(t, float64(start.Unix()), first["server.start_timestamp"], 1)
+	assert.GreaterOrEqual(t, first["server.uptime"], 60.0)
+	assert.NotContains(t, first, "server.shutdown", "No shutdown should be recorded while running")
+
+	time.Sleep(10 * time.Millisecond)
+	lm.shutdown(context.Background())
+	second := collectLifecycleMetrics(t, reader)
+	assert.Greater(t, second["server.uptime"], first["server.uptime"], "Uptime should increase")
+	assert.Equal(t, first["server.start_timestamp"], second["server.start_timestamp"])
+	assert.Equal(t, 1.0, second["server.shutdown"])
+
+	lm.unregister()
+	third := collectLifecycleMetrics(t, reader)
+	assert.NotContains(t, third, "server.uptime", "Uptime should not be reported once unregistered")
+}
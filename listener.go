@@ -0,0 +1,90 @@
+package ponrunner
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"syscall"
+	"time"
+
+	"github.com/ponrove/configura"
+)
+
+const (
+	SERVER_ACCEPT_BACKOFF_MAX  configura.Variable[int64] = "SERVER_ACCEPT_BACKOFF_MAX"  // Most milliseconds to wait between retries of a failed accept, defaults to 1000
+	SERVER_BIND_RETRY_ATTEMPTS configura.Variable[int64] = "SERVER_BIND_RETRY_ATTEMPTS" // Retries of binding the listener while the address is in use, none by default
+	SERVER_BIND_RETRY_DELAY    configura.Variable[int64] = "SERVER_BIND_RETRY_DELAY"    // Milliseconds to wait between retries of binding the listener, defaults to 500
+)
+
+// Bounds of the delay between retries of a failed accept, as in http.Server.
+const (
+	acceptBackoffMin     = 5 * time.Millisecond
+	defaultAcceptBackoff = time.Second
+)
+
+// acceptBackoffListener retries the temporary errors of Accept, e.g. EMFILE when the process runs out of file
+// descriptors, waiting twice as long after each consecutive failure up to max. http.Server retries them the same way,
+// but silently; here each failure is logged, so descriptor exhaustion shows up in the logs.
+type acceptBackoffListener struct {
+	net.Listener
+	max time.Duration
+}
+
+// Accept waits for the next connection, retrying temporary errors. Other errors, e.g. the listener being closed, are
+// returned as is.
+func (l *acceptBackoffListener) Accept() (net.Conn, error) {
+	var delay time.Duration
+	for {
+		conn, err := l.Listener.Accept()
+		if err == nil {
+			return conn, nil
+		}
+		var tempErr interface{ Temporary() bool }
+		if !errors.As(err, &tempErr) || !tempErr.Temporary() {
+			return nil, err
+		}
+
+		delay = min(max(delay*2, acceptBackoffMin), l.max)
+		slog.Warn("Failed to accept connection, retrying",
+			slog.Any("error", err),
+			slog.Duration("retryIn", delay))
+		time.Sleep(delay)
+	}
+}
+
+// newAcceptBackoffListener wraps the listener to log and retry temporary accept errors, backing off up to
+// SERVER_ACCEPT_BACKOFF_MAX.
+func newAcceptBackoffListener(cfg configura.Config, ln net.Listener) net.Listener {
+	maxDelay := time.Duration(cfg.Int64(SERVER_ACCEPT_BACKOFF_MAX)) * time.Millisecond
+	if maxDelay <= 0 {
+		maxDelay = defaultAcceptBackoff
+	}
+	return &acceptBackoffListener{Listener: ln, max: max(maxDelay, acceptBackoffMin)}
+}
+
+// listen binds the listener of the server on address. While the address is in use, e.g. by the previous process during
+// a rolling deploy, binding is retried SERVER_BIND_RETRY_ATTEMPTS times, SERVER_BIND_RETRY_DELAY milliseconds apart,
+// each attempt being logged. Other errors, and ctx being canceled, end the retries early.
+func listen(ctx context.Context, cfg configura.Config, address string) (net.Listener, error) {
+	lc := newListenConfig(cfg)
+	attempts := cfg.Int64(SERVER_BIND_RETRY_ATTEMPTS)
+	delay := time.Duration(configura.Fallback(cfg.Int64(SERVER_BIND_RETRY_DELAY), 500)) * time.Millisecond
+	for attempt := int64(1); ; attempt++ {
+		ln, err := lc.Listen(ctx, "tcp", address)
+		if err == nil || attempt > attempts || !errors.Is(err, syscall.EADDRINUSE) {
+			return ln, err
+		}
+
+		slog.WarnContext(ctx, "Address in use, retrying to bind",
+			slog.String("address", address),
+			slog.Int64("attempt", attempt),
+			slog.Int64("attempts", attempts),
+			slog.Duration("retryIn", delay))
+		select {
+		case <-ctx.Done():
+			return nil, err
+		case <-time.After(delay):
+		}
+	}
+}
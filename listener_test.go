@@ -0,0 +1,159 @@
+package ponrunner
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/go-chi/chi/v5"
+	"github.com/ponrove/configura"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// flakyListener returns its errors from Accept, in order, before accepting conn.
+type flakyListener struct {
+	net.Listener
+	errs []error
+	conn net.Conn
+}
+
+func (l *flakyListener) Accept() (net.Conn, error) {
+	if len(l.errs) > 0 {
+		err := l.errs[0]
+		l.errs = l.errs[1:]
+		return nil, err
+	}
+	if l.conn == nil {
+		return nil, net.ErrClosed
+	}
+	conn := l.conn
+	l.conn = nil
+	return conn, nil
+}
+
+func TestAcceptBackoffListener(t *testing.T) {
+	var buf bytes.Buffer
+	originalSlogLogger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&buf, nil)))
+	t.Cleanup(func() { slog.SetDefault(originalSlogLogger) })
+
+	cfg := configura.NewConfigImpl()
+	err := configura.WriteConfiguration(cfg, map[configura.Variable[int64]]int64{
+		SERVER_ACCEPT_BACKOFF_MAX: 20,
+	})
+	require.NoError(t, err)
+
+	server, client := net.Pipe()
+	defer client.Close()
+	emfile := &net.OpError{Op: "accept", Net: "tcp", Err: syscall.EMFILE}
+	ln := newAcceptBackoffListener(cfg, &flakyListener{errs: []error{emfile, emfile, emfile}, conn: server})
+
+	start := time.Now()
+	conn, err := ln.Accept()
+	require.NoError(t, err, "Temporary errors should be retried")
+	assert.Equal(t, server, conn)
+	// The delays double from 5ms, capped at 20ms: 5ms, 10ms, then 20ms.
+	assert.GreaterOrEqual(t, time.Since(start), 35*time.Millisecond)
+	assert.Equal(t, 3, strings.Count(buf.String(), "Failed to accept connection, retrying"), "Each temporary error should be logged")
+	assert.Contains(t, buf.String(), "too many open files")
+
+	_, err = ln.Accept()
+	assert.ErrorIs(t, err, net.ErrClosed, "Other errors should be returned")
+}
+
+// busyAddress returns the address of a port held by a listener, closed after delay.
+func busyAddress(t *testing.T, delay time.Duration) string {
+	t.Helper()
+	busy, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	timer := time.AfterFunc(delay, func() { busy.Close() })
+	t.Cleanup(func() {
+		timer.Stop()
+		busy.Close()
+	})
+	return busy.Addr().String()
+}
+
+func TestListen_RetriesWhileAddressInUse(t *testing.T) {
+	var buf bytes.Buffer
+	originalSlogLogger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&buf, nil)))
+	t.Cleanup(func() { slog.SetDefault(originalSlogLogger) })
+
+	cfg := configura.NewConfigImpl()
+	err := configura.WriteConfiguration(cfg, map[configura.Variable[int64]]int64{
+		SERVER_BIND_RETRY_ATTEMPTS: 20,
+		SERVER_BIND_RETRY_DELAY:    50,
+	})
+	require.NoError(t, err)
+
+	address := busyAddress(t, 200*time.Millisecond)
+	ln, err := listen(context.Background(), cfg, address)
+	require.NoError(t, err, "The listener should bind once the port is freed")
+	defer ln.Close()
+	assert.Equal(t, address, ln.Addr().String())
+	assert.Contains(t, buf.String(), "Address in use, retrying to bind")
+}
+
+func TestListen_NoRetryByDefault(t *testing.T) {
+	address := busyAddress(t, time.Minute)
+	_, err := listen(context.Background(), configura.NewConfigImpl(), address)
+	assert.ErrorIs(t, err, syscall.EADDRINUSE)
+}
+
+func TestListen_GivesUpAfterAttempts(t *testing.T) {
+	cfg := configura.NewConfigImpl()
+	err := configura.WriteConfiguration(cfg, map[configura.Variable[int64]]int64{
+		SERVER_BIND_RETRY_ATTEMPTS: 2,
+		SERVER_BIND_RETRY_DELAY:    10,
+	})
+	require.NoError(t, err)
+
+	address := busyAddress(t, time.Minute)
+	_, err = listen(context.Background(), cfg, address)
+	assert.ErrorIs(t, err, syscall.EADDRINUSE)
+}
+
+func TestStart_BindRetry(t *testing.T) {
+	address := busyAddress(t, 300*time.Millisecond)
+	host, port, err := net.SplitHostPort(address)
+	require.NoError(t, err)
+
+	cfg := configura.NewConfigImpl()
+	err = configura.WriteConfiguration(cfg, map[configura.Variable[string]]string{
+		SERVER_HOST: host,
+	})
+	require.NoError(t, err)
+	portNumber, err := net.LookupPort("tcp", port)
+	require.NoError(t, err)
+	err = configura.WriteConfiguration(cfg, map[configura.Variable[int64]]int64{
+		SERVER_PORT:                int64(portNumber),
+		SERVER_BIND_RETRY_ATTEMPTS: 20,
+		SERVER_BIND_RETRY_DELAY:    50,
+	})
+	require.NoError(t, err)
+
+	server, err := StartAsync(context.Background(), configura.Merge(newDefaultCfg(), cfg), chi.NewRouter(), func(c configura.Config, r chi.Router, a huma.API) error {
+		return nil
+	})
+	require.NoError(t, err, "The server should bind once the port is freed")
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = server.Shutdown(ctx)
+		_ = server.Wait()
+	})
+
+	resp, err := http.Get("http://" + address + "/livez")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
@@ -0,0 +1,189 @@
+package ponrunner
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"time"
+
+	"github.com/ponrove/configura"
+)
+
+const (
+	SERVER_LOG_STDERR_LEVEL configura.Variable[string] = "SERVER_LOG_STDERR_LEVEL" // Logs at or above this level go to stderr, empty logs everything to stdout
+	SERVER_LOG_TIMEZONE     configura.Variable[string] = "SERVER_LOG_TIMEZONE"     // Location of log timestamps, e.g. UTC, Local or an IANA name, defaults to slog's (local)
+)
+
+// parseLogLevel maps a configured log level to its slog.Level. slog has no trace level, so trace maps to debug.
+func parseLogLevel(level string) (slog.Level, bool) {
+	switch level {
+	case "trace", "debug":
+		return slog.LevelDebug, true
+	case "info":
+		return slog.LevelInfo, true
+	case "warn":
+		return slog.LevelWarn, true
+	case "error":
+		return slog.LevelError, true
+	default:
+		return slog.LevelInfo, false
+	}
+}
+
+// splitHandler sends the records at or above threshold to the high handler, and the others to the low handler, e.g.
+// errors to stderr and the rest to stdout, for environments that route the two streams separately.
+type splitHandler struct {
+	low       slog.Handler
+	high      slog.Handler
+	threshold slog.Level
+}
+
+// Ensure the splitHandler implements the slog.Handler interface at compile time.
+var _ slog.Handler = splitHandler{}
+
+// Enabled reports whether the handler the level is sent to handles it.
+func (h splitHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	if level >= h.threshold {
+		return h.high.Enabled(ctx, level)
+	}
+	return h.low.Enabled(ctx, level)
+}
+
+// Handle sends the record to the handler of its level.
+func (h splitHandler) Handle(ctx context.Context, r slog.Record) error {
+	if r.Level >= h.threshold {
+		return h.high.Handle(ctx, r)
+	}
+	return h.low.Handle(ctx, r)
+}
+
+// WithAttrs returns a splitHandler with the attributes added to both handlers.
+func (h splitHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return splitHandler{low: h.low.WithAttrs(attrs), high: h.high.WithAttrs(attrs), threshold: h.threshold}
+}
+
+// WithGroup returns a splitHandler with the group added to both handlers.
+func (h splitHandler) WithGroup(name string) slog.Handler {
+	return splitHandler{low: h.low.WithGroup(name), high: h.high.WithGroup(name), threshold: h.threshold}
+}
+
+// logTimezone returns a ReplaceAttr function rendering the time of records in the location named by
+// SERVER_LOG_TIMEZONE, or nil to keep slog's default if it's unset or unknown.
+func logTimezone(ctx context.Context, cfg configura.Config) func(groups []string, a slog.Attr) slog.Attr {
+	name := cfg.String(SERVER_LOG_TIMEZONE)
+	if name == "" {
+		return nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		slog.WarnContext(ctx, "Unknown log timezone configured, keeping the default", slog.String("configuredTimezone", name), slog.Any("error", err))
+		return nil
+	}
+	return func(groups []string, a slog.Attr) slog.Attr {
+		if len(groups) == 0 && a.Key == slog.TimeKey && a.Value.Kind() == slog.KindTime {
+			a.Value = slog.TimeValue(a.Value.Time().In(loc))
+		}
+		return a
+	}
+}
+
+// newLogHandler returns the handler of the default logger, in SERVER_LOG_FORMAT (text by default) at SERVER_LOG_LEVEL
+// (info by default), with timestamps in SERVER_LOG_TIMEZONE. Everything is written to stdout, unless
+// SERVER_LOG_STDERR_LEVEL is set, in which case the records at or above that level are written to stderr instead.
+func newLogHandler(ctx context.Context, cfg configura.Config, stdout, stderr io.Writer) slog.Handler {
+	logLevelStr := configura.Fallback(cfg.String(SERVER_LOG_LEVEL), "info")
+	logLevel, ok := parseLogLevel(logLevelStr)
+	if !ok {
+		slog.WarnContext(ctx, "Unsupported or unmappable log level configured, defaulting to INFO", slog.String("configuredLevel", logLevelStr))
+	}
+
+	handlerOpts := &slog.HandlerOptions{Level: logLevel, ReplaceAttr: logTimezone(ctx, cfg)}
+	newHandler := func(w io.Writer) slog.Handler {
+		if configura.Fallback(cfg.String(SERVER_LOG_FORMAT), "text") == "json" {
+			return slog.NewJSONHandler(w, handlerOpts)
+		}
+		return slog.NewTextHandler(w, handlerOpts)
+	}
+
+	stderrLevelStr := cfg.String(SERVER_LOG_STDERR_LEVEL)
+	if stderrLevelStr == "" {
+		return newHandler(stdout)
+	}
+	stderrLevel, ok := parseLogLevel(stderrLevelStr)
+	if !ok {
+		slog.WarnContext(ctx, "Unsupported stderr log level configured, logging everything to stdout", slog.String("configuredLevel", stderrLevelStr))
+		return newHandler(stdout)
+	}
+	return splitHandler{low: newHandler(stdout), high: newHandler(stderr), threshold: stderrLevel}
+}
+
+// levelHandler drops the records below level before they reach the wrapped handler, e.g. to export fewer logs over
+// OTLP than are written to stdout.
+type levelHandler struct {
+	slog.Handler
+	level slog.Level
+}
+
+// Ensure the levelHandler implements the slog.Handler interface at compile time.
+var _ slog.Handler = levelHandler{}
+
+// Enabled reports whether the level is at or above the minimum level, and handled by the wrapped handler.
+func (h levelHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= h.level && h.Handler.Enabled(ctx, level)
+}
+
+// WithAttrs returns a levelHandler with the attributes added to the wrapped handler.
+func (h levelHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return levelHandler{Handler: h.Handler.WithAttrs(attrs), level: h.level}
+}
+
+// WithGroup returns a levelHandler with the group added to the wrapped handler.
+func (h levelHandler) WithGroup(name string) slog.Handler {
+	return levelHandler{Handler: h.Handler.WithGroup(name), level: h.level}
+}
+
+// multiHandler sends each record to all of its handlers enabled for its level, e.g. to stdout and to OTLP.
+type multiHandler []slog.Handler
+
+// Ensure the multiHandler implements the slog.Handler interface at compile time.
+var _ slog.Handler = multiHandler{}
+
+// Enabled reports whether any of the handlers handles the level.
+func (h multiHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, handler := range h {
+		if handler.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+// Handle sends the record to the handlers enabled for its level.
+func (h multiHandler) Handle(ctx context.Context, r slog.Record) error {
+	var errs []error
+	for _, handler := range h {
+		if handler.Enabled(ctx, r.Level) {
+			errs = append(errs, handler.Handle(ctx, r.Clone()))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// WithAttrs returns a multiHandler with the attributes added to all handlers.
+func (h multiHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	handlers := make(multiHandler, len(h))
+	for i, handler := range h {
+		handlers[i] = handler.WithAttrs(attrs)
+	}
+	return handlers
+}
+
+// WithGroup returns a multiHandler with the group added to all handlers.
+func (h multiHandler) WithGroup(name string) slog.Handler {
+	handlers := make(multiHandler, len(h))
+	for i, handler := range h {
+		handlers[i] = handler.WithGroup(name)
+	}
+	return handlers
+}
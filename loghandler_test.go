@@ -0,0 +1,101 @@
+package ponrunner
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ponrove/configura"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewLogHandler_SplitStreams(t *testing.T) {
+	cfg := configura.NewConfigImpl()
+	err := configura.WriteConfiguration(cfg, map[configura.Variable[string]]string{
+		SERVER_LOG_STDERR_LEVEL: "warn",
+	})
+	require.NoError(t, err)
+
+	var stdout, stderr bytes.Buffer
+	logger := slog.New(newLogHandler(context.Background(), cfg, &stdout, &stderr)).With(slog.String("service", "test"))
+
+	logger.Info("request served")
+	logger.Error("database unreachable")
+
+	assert.Contains(t, stdout.String(), "request served")
+	assert.NotContains(t, stdout.String(), "database unreachable")
+	assert.Contains(t, stderr.String(), "database unreachable")
+	assert.NotContains(t, stderr.String(), "request served")
+	assert.Contains(t, stderr.String(), "service=test", "Attributes should be added to both streams")
+}
+
+func TestNewLogHandler_SingleStream(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	logger := slog.New(newLogHandler(context.Background(), configura.NewConfigImpl(), &stdout, &stderr))
+
+	logger.Info("request served")
+	logger.Error("database unreachable")
+
+	assert.Contains(t, stdout.String(), "request served")
+	assert.Contains(t, stdout.String(), "database unreachable")
+	assert.Empty(t, stderr.String(), "Everything should go to stdout by default")
+}
+
+func TestNewLogHandler_LevelFiltering(t *testing.T) {
+	cfg := configura.NewConfigImpl()
+	err := configura.WriteConfiguration(cfg, map[configura.Variable[string]]string{
+		SERVER_LOG_LEVEL:        "warn",
+		SERVER_LOG_STDERR_LEVEL: "error",
+	})
+	require.NoError(t, err)
+
+	var stdout, stderr bytes.Buffer
+	logger := slog.New(newLogHandler(context.Background(), cfg, &stdout, &stderr))
+
+	logger.Info("request served")
+	logger.Warn("slow query")
+
+	assert.NotContains(t, stdout.String(), "request served", "The log level should still apply")
+	assert.Contains(t, stdout.String(), "slow query")
+	assert.Empty(t, stderr.String())
+}
+
+func TestNewLogHandler_Timezone(t *testing.T) {
+	tests := []struct {
+		name     string
+		timezone string
+		offset   string
+	}{
+		{name: "UTC", timezone: "UTC", offset: "Z"},
+		{name: "IANA name", timezone: "Asia/Tokyo", offset: "+09:00"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := time.LoadLocation(tc.timezone); err != nil {
+				t.Skipf("Timezone database unavailable: %v", err)
+			}
+			cfg := configura.NewConfigImpl()
+			err := configura.WriteConfiguration(cfg, map[configura.Variable[string]]string{
+				SERVER_LOG_FORMAT:   "json",
+				SERVER_LOG_TIMEZONE: tc.timezone,
+			})
+			require.NoError(t, err)
+
+			var stdout bytes.Buffer
+			slog.New(newLogHandler(context.Background(), cfg, &stdout, io.Discard)).Info("request served")
+
+			var record struct {
+				Time string `json:"time"`
+			}
+			require.NoError(t, json.Unmarshal(stdout.Bytes(), &record))
+			assert.True(t, strings.HasSuffix(record.Time, tc.offset), "Timestamp %q should be in %s", record.Time, tc.timezone)
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package ponrunner
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/ponrove/configura"
+)
+
+const (
+	SERVER_MAX_LIFETIME configura.Variable[int64] = "SERVER_MAX_LIFETIME" // Seconds the server runs before shutting down gracefully to be restarted, 0 disables
+)
+
+// errMaxLifetime is the cause of the server context's cancellation once SERVER_MAX_LIFETIME elapsed.
+var errMaxLifetime = errors.New("server reached its maximum lifetime")
+
+// withMaxLifetime returns a copy of ctx canceled with errMaxLifetime once SERVER_MAX_LIFETIME elapsed, so the server
+// shuts down gracefully as if a signal arrived and the orchestrator restarts it. Recycling the process periodically
+// mitigates slow leaks. ctx is returned as is if SERVER_MAX_LIFETIME isn't set.
+func withMaxLifetime(ctx context.Context, cfg configura.Config) (context.Context, context.CancelFunc) {
+	maxLifetime := time.Duration(cfg.Int64(SERVER_MAX_LIFETIME)) * time.Second
+	if maxLifetime <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeoutCause(ctx, maxLifetime, errMaxLifetime)
+}
+
+// shutdownReason returns why the server context was canceled, for the logs.
+func shutdownReason(serverCtx context.Context) string {
+	if errors.Is(context.Cause(serverCtx), errMaxLifetime) {
+		return "max-lifetime"
+	}
+	return "signal"
+}
@@ -0,0 +1,69 @@
+package ponrunner
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/go-chi/chi/v5"
+	"github.com/ponrove/configura"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStart_MaxLifetime(t *testing.T) {
+	t.Parallel()
+
+	freePort, err := getFreePort()
+	require.NoError(t, err, "Failed to get free port")
+	lifetimeCfg := configura.NewConfigImpl()
+	err = configura.WriteConfiguration(lifetimeCfg, map[configura.Variable[int64]]int64{
+		SERVER_PORT:         int64(freePort),
+		SERVER_MAX_LIFETIME: 1,
+	})
+	require.NoError(t, err)
+	finalCfg := configura.Merge(newDefaultCfg(), lifetimeCfg)
+
+	start := time.Now()
+	startErrChan := make(chan error, 1)
+	go func() {
+		startErrChan <- Start(context.Background(), finalCfg, chi.NewRouter(), func(c configura.Config, r chi.Router, a huma.API) error { return nil })
+	}()
+
+	select {
+	case err := <-startErrChan:
+		assert.NoError(t, err, "Start should shut down gracefully once the lifetime elapsed")
+		assert.GreaterOrEqual(t, time.Since(start), time.Second, "Start should run for its lifetime")
+	case <-time.After(5 * time.Second):
+		t.Fatal("Start did not exit after its maximum lifetime")
+	}
+}
+
+func TestShutdownReason(t *testing.T) {
+	t.Parallel()
+
+	cfg := configura.NewConfigImpl()
+	err := configura.WriteConfiguration(cfg, map[configura.Variable[int64]]int64{
+		SERVER_MAX_LIFETIME: 1,
+	})
+	require.NoError(t, err)
+
+	ctx, cancel := withMaxLifetime(context.Background(), cfg)
+	defer cancel()
+	<-ctx.Done()
+	assert.Equal(t, "max-lifetime", shutdownReason(ctx))
+
+	signalCtx, stop := context.WithCancel(context.Background())
+	stop()
+	assert.Equal(t, "signal", shutdownReason(signalCtx))
+}
+
+func TestWithMaxLifetime_DisabledByDefault(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := withMaxLifetime(context.Background(), configura.NewConfigImpl())
+	defer cancel()
+	_, ok := ctx.Deadline()
+	assert.False(t, ok, "The context should have no deadline without SERVER_MAX_LIFETIME")
+}
@@ -0,0 +1,112 @@
+package middleware
+
+import (
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/ponrove/configura"
+	"github.com/ponrove/ponrunner/utils"
+)
+
+const (
+	ACCEPT_SUPPORTED_TYPES configura.Variable[string] = "ACCEPT_SUPPORTED_TYPES" // Comma separated media types the API responds with, empty disables the check
+	ACCEPT_EXEMPT_PATHS    configura.Variable[string] = "ACCEPT_EXEMPT_PATHS"    // Comma separated paths served regardless of Accept, defaults to the health and docs endpoints
+)
+
+// acceptRange is a media range of an Accept header, with its quality.
+type acceptRange struct {
+	mediaType string
+	quality   float64
+}
+
+// parseAccept returns the valid media ranges of an Accept header, by decreasing quality. Ranges that can't be parsed
+// are skipped, as are the ones with a quality of 0, which the client doesn't accept.
+func parseAccept(header string) []acceptRange {
+	var ranges []acceptRange
+	for _, part := range strings.Split(header, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil || !strings.Contains(mediaType, "/") {
+			continue
+		}
+		quality := 1.0
+		if q, ok := params["q"]; ok {
+			quality, err = strconv.ParseFloat(q, 64)
+			if err != nil || quality < 0 || quality > 1 {
+				continue
+			}
+		}
+		if quality > 0 {
+			ranges = append(ranges, acceptRange{mediaType: mediaType, quality: quality})
+		}
+	}
+	slices.SortStableFunc(ranges, func(a, b acceptRange) int {
+		switch {
+		case a.quality > b.quality:
+			return -1
+		case a.quality < b.quality:
+			return 1
+		}
+		return 0
+	})
+	return ranges
+}
+
+// mediaRangeMatches reports whether the media type is in the media range, e.g. application/json in application/*.
+func mediaRangeMatches(mediaRange, mediaType string) bool {
+	if mediaRange == "*/*" || mediaRange == mediaType {
+		return true
+	}
+	prefix, ok := strings.CutSuffix(mediaRange, "/*")
+	return ok && strings.HasPrefix(mediaType, prefix+"/")
+}
+
+// negotiateMediaType returns the first supported media type in the preferred media range of the Accept header.
+func negotiateMediaType(header string, supported []string) (string, bool) {
+	for _, r := range parseAccept(header) {
+		for _, mediaType := range supported {
+			if mediaRangeMatches(r.mediaType, mediaType) {
+				return mediaType, true
+			}
+		}
+	}
+	return "", false
+}
+
+// Accept is a content negotiation middleware for the media types listed in ACCEPT_SUPPORTED_TYPES (e.g.
+// application/json,application/cbor). Requests whose Accept header matches none of them are rejected early with 406
+// Not Acceptable, listing the supported types. Otherwise the header is normalized to the negotiated type, so malformed
+// or overly broad headers (e.g. */*) reach the API as a single supported type. Requests without an Accept header accept
+// any type and are served as usual, as are the paths in ACCEPT_EXEMPT_PATHS (by default the internalPaths of the
+// server and the API docs, as for RequireAPIVersion), so e.g. Prometheus keeps negotiating the OpenMetrics format. The
+// middleware is disabled unless ACCEPT_SUPPORTED_TYPES is set.
+func Accept(cfg configura.Config, internalPaths ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		var supported []string
+		for _, mediaType := range utils.SplitCommaSeparated(cfg.String(ACCEPT_SUPPORTED_TYPES)) {
+			supported = append(supported, strings.ToLower(mediaType))
+		}
+		if len(supported) == 0 {
+			return next
+		}
+		exempt := exemptPaths(cfg.String(ACCEPT_EXEMPT_PATHS), internalPaths, apiDocsPaths...)
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			header := r.Header.Get("Accept")
+			if header == "" || slices.Contains(exempt, r.URL.Path) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			mediaType, ok := negotiateMediaType(header, supported)
+			if !ok {
+				Reject(cfg, w, r, http.StatusNotAcceptable, "Supported media types: "+strings.Join(supported, ", "))
+				return
+			}
+			r.Header.Set("Accept", mediaType)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
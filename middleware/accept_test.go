@@ -0,0 +1,120 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ponrove/configura"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccept(t *testing.T) {
+	tests := []struct {
+		name           string
+		path           string
+		accept         string
+		expectedStatus int
+		expectedAccept string
+	}{
+		{
+			name:           "Matching type",
+			path:           "/users",
+			accept:         "application/cbor",
+			expectedStatus: http.StatusOK,
+			expectedAccept: "application/cbor",
+		},
+		{
+			name:           "Preferred matching type",
+			path:           "/users",
+			accept:         "text/html, application/json;q=0.5, application/cbor;q=0.9",
+			expectedStatus: http.StatusOK,
+			expectedAccept: "application/cbor",
+		},
+		{
+			name:           "Wildcard",
+			path:           "/users",
+			accept:         "*/*",
+			expectedStatus: http.StatusOK,
+			expectedAccept: "application/json",
+		},
+		{
+			name:           "Type wildcard",
+			path:           "/users",
+			accept:         "application/*",
+			expectedStatus: http.StatusOK,
+			expectedAccept: "application/json",
+		},
+		{
+			name:           "Malformed ranges are skipped",
+			path:           "/users",
+			accept:         "json, application/json",
+			expectedStatus: http.StatusOK,
+			expectedAccept: "application/json",
+		},
+		{
+			name:           "Missing header",
+			path:           "/users",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "No matching type",
+			path:           "/users",
+			accept:         "text/html, application/xml",
+			expectedStatus: http.StatusNotAcceptable,
+		},
+		{
+			name:           "Refused type",
+			path:           "/users",
+			accept:         "application/json;q=0",
+			expectedStatus: http.StatusNotAcceptable,
+		},
+		{
+			name:           "Exempt docs",
+			path:           "/docs",
+			accept:         "text/html",
+			expectedStatus: http.StatusOK,
+			expectedAccept: "text/html",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := configura.NewConfigImpl()
+			err := configura.WriteConfiguration(cfg, map[configura.Variable[string]]string{
+				ACCEPT_SUPPORTED_TYPES: "application/json, application/cbor",
+			})
+			require.NoError(t, err)
+
+			var accept string
+			handler := Accept(cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				accept = r.Header.Get("Accept")
+			}))
+			req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			if tc.accept != "" {
+				req.Header.Set("Accept", tc.accept)
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			assert.Equal(t, tc.expectedStatus, rr.Code)
+			assert.Equal(t, tc.expectedAccept, accept)
+			if tc.expectedStatus == http.StatusNotAcceptable {
+				var problem rejectionProblem
+				require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &problem))
+				assert.Equal(t, "Supported media types: application/json, application/cbor", problem.Detail)
+			}
+		})
+	}
+}
+
+func TestAccept_Disabled(t *testing.T) {
+	handler := Accept(configura.NewConfigImpl())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	req := httptest.NewRequest(http.MethodGet, "/users", nil)
+	req.Header.Set("Accept", "text/html")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+}
@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/ponrove/configura"
+	"github.com/ponrove/ponrunner/utils"
+)
+
+const (
+	API_VERSION_HEADER       configura.Variable[string] = "API_VERSION_HEADER"       // Header carrying the API version, defaults to Api-Version
+	API_VERSION_SUPPORTED    configura.Variable[string] = "API_VERSION_SUPPORTED"    // Comma separated supported API versions, empty disables the check
+	API_VERSION_DEFAULT      configura.Variable[string] = "API_VERSION_DEFAULT"      // Version assumed when the header is missing, empty rejects the request
+	API_VERSION_EXEMPT_PATHS configura.Variable[string] = "API_VERSION_EXEMPT_PATHS" // Comma separated paths served without a version, defaults to the internal and docs endpoints
+)
+
+// ctxAPIVersionKey is a context key for storing the API version of the request.
+type ctxAPIVersionKey struct{}
+
+// RequireAPIVersion is a middleware that requires requests to state a supported API version in the API_VERSION_HEADER
+// header (Api-Version by default), so versions are gated before the request reaches the API. Requests with a version
+// not listed in API_VERSION_SUPPORTED are rejected with 400 Bad Request. So are requests without a version, unless
+// API_VERSION_DEFAULT is set, in which case they are served as that version. The version is stored in the request
+// context. The paths in API_VERSION_EXEMPT_PATHS are always served, by default the internalPaths of the server (the
+// health checks, metrics and version endpoints, /livez and /readyz if none is given) and the API docs. The middleware is
+// disabled unless API_VERSION_SUPPORTED is set.
+func RequireAPIVersion(cfg configura.Config, internalPaths ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		supported := utils.SplitCommaSeparated(cfg.String(API_VERSION_SUPPORTED))
+		if len(supported) == 0 {
+			return next
+		}
+		header := configura.Fallback(cfg.String(API_VERSION_HEADER), "Api-Version")
+		defaultVersion := cfg.String(API_VERSION_DEFAULT)
+		exempt := exemptPaths(cfg.String(API_VERSION_EXEMPT_PATHS), internalPaths, apiDocsPaths...)
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if slices.Contains(exempt, r.URL.Path) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			version := strings.TrimSpace(r.Header.Get(header))
+			if version == "" {
+				version = defaultVersion
+			}
+			if version == "" {
+				Reject(cfg, w, r, http.StatusBadRequest, "missing "+header+" header, supported versions: "+strings.Join(supported, ", "))
+				return
+			}
+			if !slices.Contains(supported, version) {
+				Reject(cfg, w, r, http.StatusBadRequest, "unsupported "+header+" "+version+", supported versions: "+strings.Join(supported, ", "))
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), ctxAPIVersionKey{}, version)))
+		})
+	}
+}
+
+// GetAPIVersionFromContext retrieves the API version of the request from the context, as validated by the
+// RequireAPIVersion middleware.
+func GetAPIVersionFromContext(ctx context.Context) string {
+	if version, ok := ctx.Value(ctxAPIVersionKey{}).(string); ok {
+		return version
+	}
+	return ""
+}
@@ -0,0 +1,106 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ponrove/configura"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequireAPIVersion(t *testing.T) {
+	tests := []struct {
+		name            string
+		defaultVersion  string
+		path            string
+		version         string
+		expectedStatus  int
+		expectedVersion string
+	}{
+		{
+			name:            "Supported version",
+			path:            "/users",
+			version:         "2024-06-01",
+			expectedStatus:  http.StatusOK,
+			expectedVersion: "2024-06-01",
+		},
+		{
+			name:           "Unsupported version",
+			path:           "/users",
+			version:        "2023-01-01",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Missing version",
+			path:           "/users",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:            "Missing version routed to the default",
+			defaultVersion:  "2024-01-01",
+			path:            "/users",
+			expectedStatus:  http.StatusOK,
+			expectedVersion: "2024-01-01",
+		},
+		{
+			name:           "Exempt health check",
+			path:           "/livez",
+			expectedStatus: http.StatusOK,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := configura.NewConfigImpl()
+			err := configura.WriteConfiguration(cfg, map[configura.Variable[string]]string{
+				API_VERSION_SUPPORTED: "2024-01-01, 2024-06-01",
+				API_VERSION_DEFAULT:   tc.defaultVersion,
+			})
+			require.NoError(t, err)
+
+			var version string
+			handler := RequireAPIVersion(cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				version = GetAPIVersionFromContext(r.Context())
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			if tc.version != "" {
+				req.Header.Set("Api-Version", tc.version)
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			assert.Equal(t, tc.expectedStatus, rr.Code)
+			assert.Equal(t, tc.expectedVersion, version)
+			if tc.expectedStatus == http.StatusBadRequest {
+				var problem map[string]any
+				require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &problem))
+				assert.Contains(t, problem["detail"], "supported versions: 2024-01-01, 2024-06-01")
+			}
+		})
+	}
+}
+
+func TestRequireAPIVersion_CustomHeader(t *testing.T) {
+	cfg := configura.NewConfigImpl()
+	err := configura.WriteConfiguration(cfg, map[configura.Variable[string]]string{
+		API_VERSION_SUPPORTED: "v2",
+		API_VERSION_HEADER:    "X-Api-Version",
+	})
+	require.NoError(t, err)
+
+	handler := RequireAPIVersion(cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/users", nil)
+	req.Header.Set("X-Api-Version", "v2")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+}
@@ -0,0 +1,85 @@
+package middleware
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/ponrove/configura"
+	slogctx "github.com/veqryn/slog-context"
+)
+
+// ErrUnauthenticated is returned by an Authenticator when the request carries no valid credentials.
+var ErrUnauthenticated = errors.New("unauthenticated")
+
+// Authenticator identifies the client of a request, e.g. by validating its bearer token with an identity provider. It
+// returns the principal the client authenticated as, e.g. a user or service ID, or ErrUnauthenticated if the request
+// carries no valid credentials.
+type Authenticator interface {
+	Authenticate(r *http.Request) (string, error)
+}
+
+// AuthenticatorFunc is a function implementing Authenticator.
+type AuthenticatorFunc func(r *http.Request) (string, error)
+
+// Authenticate calls the function.
+func (f AuthenticatorFunc) Authenticate(r *http.Request) (string, error) {
+	return f(r)
+}
+
+// BearerTokens returns an Authenticator accepting the bearer tokens of the Authorization header that are keys of
+// tokens, authenticating the client as the principal the token maps to, e.g. the name of a service calling an internal
+// API. Tokens are compared in constant time.
+func BearerTokens(tokens map[string]string) Authenticator {
+	return AuthenticatorFunc(func(r *http.Request) (string, error) {
+		bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || bearer == "" {
+			return "", ErrUnauthenticated
+		}
+		for token, principal := range tokens {
+			if subtle.ConstantTimeCompare([]byte(bearer), []byte(token)) == 1 {
+				return principal, nil
+			}
+		}
+		return "", ErrUnauthenticated
+	})
+}
+
+// ctxPrincipalKey is a context key for storing the principal of the client.
+type ctxPrincipalKey struct{}
+
+// Authenticate is a middleware that authenticates each request with the authenticator, and stores the principal in
+// the request context, where handlers can read it with GetPrincipalFromContext. Requests without valid credentials are
+// rejected with 401 Unauthorized, and requests the authenticator fails to check, e.g. because the identity provider is
+// unreachable, with 503 Service Unavailable. It's meant for groups of routes rather than the whole server, see
+// ponrunner.RouteGroup.
+func Authenticate(cfg configura.Config, authenticator Authenticator) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal, err := authenticator.Authenticate(r)
+			if errors.Is(err, ErrUnauthenticated) {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				Reject(cfg, w, r, http.StatusUnauthorized, "valid credentials are required")
+				return
+			}
+			if err != nil {
+				slogctx.FromCtx(r.Context()).Warn("Failed to authenticate the request", slog.Any("error", err))
+				Reject(cfg, w, r, http.StatusServiceUnavailable, "authentication is unavailable")
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), ctxPrincipalKey{}, principal)))
+		})
+	}
+}
+
+// GetPrincipalFromContext retrieves the principal of the client from the context, as authenticated by the
+// Authenticate middleware.
+func GetPrincipalFromContext(ctx context.Context) string {
+	if principal, ok := ctx.Value(ctxPrincipalKey{}).(string); ok {
+		return principal
+	}
+	return ""
+}
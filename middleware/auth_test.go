@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ponrove/configura"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthenticate(t *testing.T) {
+	tokens := BearerTokens(map[string]string{"s3cr3t": "billing-service"})
+	unavailable := AuthenticatorFunc(func(r *http.Request) (string, error) {
+		return "", errors.New("identity provider unreachable")
+	})
+
+	tests := []struct {
+		name              string
+		authenticator     Authenticator
+		authorization     string
+		expectedStatus    int
+		expectedPrincipal string
+	}{
+		{name: "Valid token", authenticator: tokens, authorization: "Bearer s3cr3t", expectedStatus: http.StatusOK, expectedPrincipal: "billing-service"},
+		{name: "Unknown token", authenticator: tokens, authorization: "Bearer guess", expectedStatus: http.StatusUnauthorized},
+		{name: "Other scheme", authenticator: tokens, authorization: "Basic czNjcjN0", expectedStatus: http.StatusUnauthorized},
+		{name: "No credentials", authenticator: tokens, expectedStatus: http.StatusUnauthorized},
+		{name: "Authenticator failure", authenticator: unavailable, authorization: "Bearer s3cr3t", expectedStatus: http.StatusServiceUnavailable},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var principal string
+			handler := Authenticate(configura.NewConfigImpl(), tc.authenticator)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				principal = GetPrincipalFromContext(r.Context())
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/admin", nil)
+			if tc.authorization != "" {
+				req.Header.Set("Authorization", tc.authorization)
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			assert.Equal(t, tc.expectedStatus, rr.Code)
+			assert.Equal(t, tc.expectedPrincipal, principal)
+			if tc.expectedStatus == http.StatusUnauthorized {
+				assert.Equal(t, "Bearer", rr.Header().Get("WWW-Authenticate"))
+			}
+		})
+	}
+}
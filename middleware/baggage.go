@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/ponrove/configura"
+	"go.opentelemetry.io/otel/baggage"
+)
+
+const (
+	OTEL_BAGGAGE_REQUEST_ID     configura.Variable[bool]   = "OTEL_BAGGAGE_REQUEST_ID"     // Add the request ID to the OpenTelemetry baggage, off by default
+	OTEL_BAGGAGE_REQUEST_ID_KEY configura.Variable[string] = "OTEL_BAGGAGE_REQUEST_ID_KEY" // Baggage key of the request ID, defaults to request_id
+)
+
+// RequestIDBaggage is a middleware that adds the request ID set by chi's RequestID middleware to the OpenTelemetry
+// baggage of the request context, under OTEL_BAGGAGE_REQUEST_ID_KEY (request_id by default). Outbound calls made with
+// the context through an instrumented client (e.g. ponrunner.NewHTTPClient) then carry it to downstream services in
+// the baggage header, next to traceparent, correlating their logs with this request. It must be installed after the
+// RequestID middleware. The middleware is disabled unless OTEL_BAGGAGE_REQUEST_ID is set.
+func RequestIDBaggage(cfg configura.Config) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if !cfg.Bool(OTEL_BAGGAGE_REQUEST_ID) {
+			return next
+		}
+		key := configura.Fallback(cfg.String(OTEL_BAGGAGE_REQUEST_ID_KEY), "request_id")
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requestID := middleware.GetReqID(r.Context())
+			if requestID == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			member, err := baggage.NewMemberRaw(key, requestID)
+			if err == nil {
+				var bag baggage.Baggage
+				bag, err = baggage.FromContext(r.Context()).SetMember(member)
+				if err == nil {
+					r = r.WithContext(baggage.ContextWithBaggage(r.Context(), bag))
+				}
+			}
+			if err != nil {
+				slog.DebugContext(r.Context(), "Failed to add the request ID to the baggage", slog.Any("error", err))
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
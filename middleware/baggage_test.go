@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/ponrove/configura"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/baggage"
+)
+
+func TestRequestIDBaggage(t *testing.T) {
+	tests := []struct {
+		name     string
+		enabled  bool
+		key      string
+		expected map[string]string
+	}{
+		{name: "Disabled", expected: map[string]string{}},
+		{name: "Default key", enabled: true, expected: map[string]string{"request_id": "abc/123"}},
+		{name: "Configured key", enabled: true, key: "correlation_id", expected: map[string]string{"correlation_id": "abc/123"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := configura.NewConfigImpl()
+			require.NoError(t, configura.WriteConfiguration(cfg, map[configura.Variable[bool]]bool{
+				OTEL_BAGGAGE_REQUEST_ID: tc.enabled,
+			}))
+			require.NoError(t, configura.WriteConfiguration(cfg, map[configura.Variable[string]]string{
+				OTEL_BAGGAGE_REQUEST_ID_KEY: tc.key,
+			}))
+
+			members := map[string]string{}
+			handler := RequestIDBaggage(cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				for _, member := range baggage.FromContext(r.Context()).Members() {
+					members[member.Key()] = member.Value()
+				}
+			}))
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req = req.WithContext(context.WithValue(req.Context(), middleware.RequestIDKey, "abc/123"))
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			assert.Equal(t, tc.expected, members)
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/ponrove/configura"
+)
+
+const (
+	API_DEFAULT_CACHE_CONTROL configura.Variable[string] = "API_DEFAULT_CACHE_CONTROL" // Default Cache-Control of responses, defaults to no-store
+)
+
+// cacheControlResponseWriter sets a default Cache-Control header on the response, if the handler didn't set one by the
+// time the response headers are written.
+type cacheControlResponseWriter struct {
+	http.ResponseWriter
+	value       string
+	wroteHeader bool
+}
+
+// Ensure the cacheControlResponseWriter implements the http.ResponseWriter interface at compile time.
+var _ http.ResponseWriter = &cacheControlResponseWriter{}
+
+// Interceptor that sets the default Cache-Control header before the headers are written.
+func (cw *cacheControlResponseWriter) WriteHeader(code int) {
+	if !cw.wroteHeader {
+		cw.wroteHeader = true
+		if cw.Header().Get("Cache-Control") == "" {
+			cw.Header().Set("Cache-Control", cw.value)
+		}
+	}
+	cw.ResponseWriter.WriteHeader(code)
+}
+
+// Interceptor that sets the default Cache-Control header before an implicit 200 OK is written.
+func (cw *cacheControlResponseWriter) Write(b []byte) (int, error) {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+	return cw.ResponseWriter.Write(b)
+}
+
+// Flush sets the default Cache-Control header if the handler flushes the headers before writing the body, and flushes
+// the response to the client.
+func (cw *cacheControlResponseWriter) Flush() {
+	_ = cw.FlushError()
+}
+
+// FlushError is Flush returning the error of the underlying writer, for http.ResponseController.
+func (cw *cacheControlResponseWriter) FlushError() error {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+	return http.NewResponseController(cw.ResponseWriter).Flush()
+}
+
+// Unwrap returns the wrapped http.ResponseWriter, for http.ResponseController.
+func (cw *cacheControlResponseWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// CacheControl is a middleware that sets API_DEFAULT_CACHE_CONTROL (no-store by default) as the Cache-Control header
+// of responses, unless the handler set its own. Dynamic API responses shouldn't be cached by intermediaries, but Go sets
+// no default. Set it to "none" to disable the middleware.
+func CacheControl(cfg configura.Config) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		value := configura.Fallback(cfg.String(API_DEFAULT_CACHE_CONTROL), "no-store")
+		if value == "none" {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(&cacheControlResponseWriter{ResponseWriter: w, value: value}, r)
+		})
+	}
+}
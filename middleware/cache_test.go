@@ -0,0 +1,81 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ponrove/configura"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCacheControl(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		handler  http.HandlerFunc
+		expected string
+	}{
+		{
+			name: "Default on implicit 200",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte("ok"))
+			},
+			expected: "no-store",
+		},
+		{
+			name: "Default on explicit status",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNotFound)
+			},
+			expected: "no-store",
+		},
+		{
+			name: "Default on flush without write",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				_ = http.NewResponseController(w).Flush()
+			},
+			expected: "no-store",
+		},
+		{
+			name:  "Configured default",
+			value: "private, max-age=60",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte("ok"))
+			},
+			expected: "private, max-age=60",
+		},
+		{
+			name: "Handler value is not overridden",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Cache-Control", "public, max-age=3600")
+				_, _ = w.Write([]byte("ok"))
+			},
+			expected: "public, max-age=3600",
+		},
+		{
+			name:  "Disabled",
+			value: "none",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte("ok"))
+			},
+			expected: "",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := configura.NewConfigImpl()
+			err := configura.WriteConfiguration(cfg, map[configura.Variable[string]]string{
+				API_DEFAULT_CACHE_CONTROL: tc.value,
+			})
+			require.NoError(t, err)
+
+			rr := httptest.NewRecorder()
+			CacheControl(cfg)(tc.handler).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			assert.Equal(t, tc.expected, rr.Header().Get("Cache-Control"))
+		})
+	}
+}
@@ -0,0 +1,161 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"strings"
+
+	"github.com/ponrove/configura"
+)
+
+const (
+	CACHE_ETAG_ENABLED        configura.Variable[bool]  = "CACHE_ETAG_ENABLED"        // Compute ETags of GET responses, off by default
+	CACHE_ETAG_MAX_BODY_BYTES configura.Variable[int64] = "CACHE_ETAG_MAX_BODY_BYTES" // Largest response body buffered to compute its ETag, defaults to 1MB
+)
+
+// defaultETagMaxBodyBytes is the largest response body buffered to compute its ETag, if CACHE_ETAG_MAX_BODY_BYTES is
+// not set.
+const defaultETagMaxBodyBytes int64 = 1 << 20
+
+// etagResponseWriter buffers a 200 OK response up to limit bytes, so its ETag can be computed once the handler returns.
+// Responses with another status, or a body larger than limit, are streamed to the client without an ETag.
+type etagResponseWriter struct {
+	http.ResponseWriter
+	limit       int64
+	status      int
+	wroteHeader bool
+	streaming   bool
+	buf         bytes.Buffer
+}
+
+// Ensure the etagResponseWriter implements the http.ResponseWriter interface at compile time.
+var _ http.ResponseWriter = &etagResponseWriter{}
+
+// Interceptor that holds back the status code of 200 OK responses until the body is complete.
+func (ew *etagResponseWriter) WriteHeader(code int) {
+	if ew.wroteHeader {
+		return
+	}
+	ew.wroteHeader = true
+	ew.status = code
+	if code != http.StatusOK {
+		ew.streaming = true
+		ew.ResponseWriter.WriteHeader(code)
+	}
+}
+
+// Interceptor that buffers the body, and switches to streaming once it exceeds the limit.
+func (ew *etagResponseWriter) Write(b []byte) (int, error) {
+	if !ew.wroteHeader {
+		ew.WriteHeader(http.StatusOK)
+	}
+	if ew.streaming {
+		return ew.ResponseWriter.Write(b)
+	}
+	if int64(ew.buf.Len()+len(b)) > ew.limit {
+		if err := ew.stream(); err != nil {
+			return 0, err
+		}
+		return ew.ResponseWriter.Write(b)
+	}
+	return ew.buf.Write(b)
+}
+
+// stream gives up on the ETag of the response, and writes the held back status code and the buffered body through.
+func (ew *etagResponseWriter) stream() error {
+	ew.streaming = true
+	ew.ResponseWriter.WriteHeader(ew.status)
+	_, err := ew.ResponseWriter.Write(ew.buf.Bytes())
+	ew.buf.Reset()
+	return err
+}
+
+// Flush streams the response without an ETag, as the client is to get the body before it is complete, and flushes it to
+// the client.
+func (ew *etagResponseWriter) Flush() {
+	_ = ew.FlushError()
+}
+
+// FlushError is Flush returning the error of the underlying writer, for http.ResponseController.
+func (ew *etagResponseWriter) FlushError() error {
+	if !ew.wroteHeader {
+		ew.WriteHeader(http.StatusOK)
+	}
+	if !ew.streaming {
+		if err := ew.stream(); err != nil {
+			return err
+		}
+	}
+	return http.NewResponseController(ew.ResponseWriter).Flush()
+}
+
+// Unwrap returns the wrapped http.ResponseWriter, for http.ResponseController.
+func (ew *etagResponseWriter) Unwrap() http.ResponseWriter {
+	return ew.ResponseWriter
+}
+
+// finish writes the buffered response with its ETag, or a 304 Not Modified if the client already has it.
+func (ew *etagResponseWriter) finish(r *http.Request) {
+	if !ew.wroteHeader || ew.streaming {
+		return
+	}
+
+	etag := ew.Header().Get("ETag")
+	if etag == "" {
+		sum := sha256.Sum256(ew.buf.Bytes())
+		etag = `"` + base64.RawURLEncoding.EncodeToString(sum[:16]) + `"`
+		ew.Header().Set("ETag", etag)
+	}
+
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		ew.Header().Del("Content-Length")
+		ew.ResponseWriter.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	ew.ResponseWriter.WriteHeader(ew.status)
+	_, _ = ew.ResponseWriter.Write(ew.buf.Bytes())
+}
+
+// etagMatches reports whether an If-None-Match header matches the ETag, using the weak comparison required for
+// If-None-Match.
+func etagMatches(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// ETag is a middleware that sets an ETag header on 200 OK responses to GET requests, computed from the response body
+// unless the handler set its own, and responds with 304 Not Modified when it matches the request's If-None-Match
+// header. The body has to be buffered to compute its hash, so responses larger than CACHE_ETAG_MAX_BODY_BYTES (1MB by
+// default) skip the ETag and are streamed normally, as are flushed responses. The middleware is disabled unless
+// CACHE_ETAG_ENABLED is set.
+func ETag(cfg configura.Config) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if !cfg.Bool(CACHE_ETAG_ENABLED) {
+			return next
+		}
+		limit := configura.Fallback(cfg.Int64(CACHE_ETAG_MAX_BODY_BYTES), defaultETagMaxBodyBytes)
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ew := &etagResponseWriter{ResponseWriter: w, limit: limit}
+			next.ServeHTTP(ew, r)
+			ew.finish(r)
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ponrove/configura"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newETagHandler(t *testing.T, maxBodyBytes int64, body string) http.Handler {
+	t.Helper()
+	cfg := configura.NewConfigImpl()
+	require.NoError(t, configura.WriteConfiguration(cfg, map[configura.Variable[bool]]bool{
+		CACHE_ETAG_ENABLED: true,
+	}))
+	require.NoError(t, configura.WriteConfiguration(cfg, map[configura.Variable[int64]]int64{
+		CACHE_ETAG_MAX_BODY_BYTES: maxBodyBytes,
+	}))
+
+	return ETag(cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Written in chunks, to cross the limit midway through the body.
+		for chunk := range strings.SplitSeq(body, " ") {
+			_, _ = w.Write([]byte(chunk + " "))
+		}
+	}))
+}
+
+func TestETag_SmallResponse(t *testing.T) {
+	handler := newETagHandler(t, 1024, "a small response")
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	etag := rr.Header().Get("ETag")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.NotEmpty(t, etag, "ETag should be computed for a response under the limit")
+	assert.Equal(t, "a small response ", rr.Body.String())
+
+	rr = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("If-None-Match", etag)
+	handler.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusNotModified, rr.Code)
+	assert.Empty(t, rr.Body.String())
+}
+
+func TestETag_LargeResponse(t *testing.T) {
+	body := strings.Repeat("large ", 100)
+	handler := newETagHandler(t, 64, body)
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Empty(t, rr.Header().Get("ETag"), "ETag should be skipped for a response over the limit")
+	assert.Equal(t, body, strings.TrimSuffix(rr.Body.String(), " "), "The full body should be streamed")
+}
+
+func TestETag_Flush(t *testing.T) {
+	cfg := configura.NewConfigImpl()
+	require.NoError(t, configura.WriteConfiguration(cfg, map[configura.Variable[bool]]bool{
+		CACHE_ETAG_ENABLED: true,
+	}))
+	handler := ETag(cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("data: 1\n"))
+		require.NoError(t, http.NewResponseController(w).Flush())
+		_, _ = w.Write([]byte("data: 2\n"))
+	}))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.True(t, rr.Flushed, "The flush should reach the underlying ResponseWriter")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Empty(t, rr.Header().Get("ETag"), "ETag should be skipped for a flushed response")
+	assert.Equal(t, "data: 1\ndata: 2\n", rr.Body.String())
+}
+
+func TestETag_Disabled(t *testing.T) {
+	handler := ETag(configura.NewConfigImpl())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.Equal(t, "ok", rr.Body.String())
+	assert.Empty(t, rr.Header().Get("ETag"))
+}
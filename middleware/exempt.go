@@ -0,0 +1,25 @@
+package middleware
+
+import (
+	"github.com/ponrove/ponrunner/utils"
+)
+
+// defaultInternalPaths are the internal endpoints of the server when the middleware isn't given any, the default
+// health check endpoints.
+var defaultInternalPaths = []string{"/livez", "/readyz"}
+
+// apiDocsPaths are the paths of the API docs served by huma.
+var apiDocsPaths = []string{"/docs", "/openapi.json", "/openapi.yaml", "/openapi-3.0.json", "/openapi-3.0.yaml"}
+
+// exemptPaths returns the comma separated paths of value, or if it is not set, the internal endpoints of the server (the
+// health checks, metrics and version endpoints Start passes in, the default health check endpoints otherwise) followed
+// by extra.
+func exemptPaths(value string, internalPaths []string, extra ...string) []string {
+	if value != "" {
+		return utils.SplitCommaSeparated(value)
+	}
+	if len(internalPaths) == 0 {
+		internalPaths = defaultInternalPaths
+	}
+	return append(append([]string{}, internalPaths...), extra...)
+}
@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExemptPaths(t *testing.T) {
+	tests := []struct {
+		name          string
+		value         string
+		internalPaths []string
+		extra         []string
+		expected      []string
+	}{
+		{name: "Default health checks", expected: []string{"/livez", "/readyz"}},
+		{name: "Default health checks and extra paths", extra: []string{"/docs"}, expected: []string{"/livez", "/readyz", "/docs"}},
+		{name: "Internal paths", internalPaths: []string{"/healthz", "/ready", "/metrics"}, extra: []string{"/docs"}, expected: []string{"/healthz", "/ready", "/metrics", "/docs"}},
+		{name: "Configured paths", value: "/status, /ping", internalPaths: []string{"/healthz"}, extra: []string{"/docs"}, expected: []string{"/status", "/ping"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, exemptPaths(tc.value, tc.internalPaths, tc.extra...))
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/ponrove/configura"
+	"github.com/ponrove/ponrunner/utils"
+)
+
+const (
+	REQUEST_FINGERPRINT_HEADER configura.Variable[string] = "REQUEST_FINGERPRINT_HEADER" // Header the edge forwards the client's TLS fingerprint (e.g. JA3) in, defaults to X-Fingerprint
+)
+
+// ctxFingerprintKey is a context key for storing the TLS fingerprint of the client.
+type ctxFingerprintKey struct{}
+
+// Fingerprint is a middleware that stores the TLS fingerprint of the client, e.g. a JA3 hash computed by the edge proxy
+// terminating TLS, in the request context, where LogRequest picks it up as the fingerprint field of the access log and
+// handlers can read it with GetFingerprintFromContext, e.g. for abuse checks. The fingerprint is read from the
+// REQUEST_FINGERPRINT_HEADER header, only when the request comes from one of the proxies listed in
+// HTTP_TRUSTED_PROXIES, as clients could otherwise claim any fingerprint.
+func Fingerprint(cfg configura.Config) func(http.Handler) http.Handler {
+	header := configura.Fallback(cfg.String(REQUEST_FINGERPRINT_HEADER), "X-Fingerprint")
+	trusted := utils.ParseTrustedProxies(cfg.String(HTTP_TRUSTED_PROXIES))
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fingerprint := strings.TrimSpace(r.Header.Get(header))
+			if fingerprint == "" || !utils.IsTrustedProxy(r.RemoteAddr, trusted) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), ctxFingerprintKey{}, fingerprint)))
+		})
+	}
+}
+
+// GetFingerprintFromContext retrieves the TLS fingerprint of the client from the context, as forwarded by a trusted
+// proxy to the Fingerprint middleware.
+func GetFingerprintFromContext(ctx context.Context) string {
+	if fingerprint, ok := ctx.Value(ctxFingerprintKey{}).(string); ok {
+		return fingerprint
+	}
+	return ""
+}
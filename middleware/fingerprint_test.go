@@ -0,0 +1,95 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ponrove/configura"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFingerprint(t *testing.T) {
+	const ja3 = "771,4865-4866-4867-49195,0-23-65281-10-11,29-23-24,0"
+
+	tests := []struct {
+		name                string
+		header              string
+		trustedProxies      string
+		remoteAddr          string
+		headers             map[string]string
+		expectedFingerprint string
+	}{
+		{
+			name:                "Configured header from trusted proxy",
+			header:              "X-JA3-Fingerprint",
+			trustedProxies:      "10.0.0.0/8",
+			remoteAddr:          "10.0.0.1:1234",
+			headers:             map[string]string{"X-JA3-Fingerprint": ja3},
+			expectedFingerprint: ja3,
+		},
+		{
+			name:                "Default header from trusted proxy",
+			trustedProxies:      "10.0.0.0/8",
+			remoteAddr:          "10.0.0.1:1234",
+			headers:             map[string]string{"X-Fingerprint": ja3},
+			expectedFingerprint: ja3,
+		},
+		{
+			name:           "Default header ignored once another is configured",
+			header:         "X-JA3-Fingerprint",
+			trustedProxies: "10.0.0.0/8",
+			remoteAddr:     "10.0.0.1:1234",
+			headers:        map[string]string{"X-Fingerprint": ja3},
+		},
+		{
+			name:           "Untrusted peer is ignored",
+			header:         "X-JA3-Fingerprint",
+			trustedProxies: "10.0.0.0/8",
+			remoteAddr:     "203.0.113.10:1234",
+			headers:        map[string]string{"X-JA3-Fingerprint": "forged"},
+		},
+		{
+			name:       "Ignored without trusted proxies",
+			remoteAddr: "10.0.0.1:1234",
+			headers:    map[string]string{"X-Fingerprint": ja3},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var logBuffer bytes.Buffer
+			originalDefaultLogger := slog.Default()
+			slog.SetDefault(slog.New(slog.NewJSONHandler(&logBuffer, nil)))
+			t.Cleanup(func() { slog.SetDefault(originalDefaultLogger) })
+
+			cfg := configura.NewConfigImpl()
+			err := configura.WriteConfiguration(cfg, map[configura.Variable[string]]string{
+				REQUEST_FINGERPRINT_HEADER: tc.header,
+				HTTP_TRUSTED_PROXIES:       tc.trustedProxies,
+			})
+			require.NoError(t, err)
+
+			var actualFingerprint string
+			handler := Fingerprint(cfg)(LogRequest(cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				actualFingerprint = GetFingerprintFromContext(r.Context())
+			})))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tc.remoteAddr
+			for k, v := range tc.headers {
+				req.Header.Set(k, v)
+			}
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			assert.Equal(t, tc.expectedFingerprint, actualFingerprint)
+			var logged map[string]any
+			require.NoError(t, json.Unmarshal(logBuffer.Bytes(), &logged))
+			assert.Equal(t, tc.expectedFingerprint, logged["fingerprint"])
+		})
+	}
+}
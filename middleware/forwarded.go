@@ -0,0 +1,69 @@
+package middleware
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/ponrove/configura"
+	"github.com/ponrove/ponrunner/utils"
+)
+
+const (
+	HTTP_TRUSTED_PROXIES configura.Variable[string] = "HTTP_TRUSTED_PROXIES" // Comma separated CIDR ranges or IPs of trusted proxies
+)
+
+// ctxExternalHostKey is a context key for storing the external host of the request.
+type ctxExternalHostKey struct{}
+
+// ExternalHost is a middleware that resolves the host (and port) the client used to reach the service, and stores it in
+// the request context. The X-Forwarded-Host and X-Forwarded-Port headers are only honored when the request comes from
+// one of the proxies listed in HTTP_TRUSTED_PROXIES, otherwise the Host of the request is used.
+func ExternalHost(cfg configura.Config) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			host := r.Host
+			if utils.IsTrustedProxy(r.RemoteAddr, utils.ParseTrustedProxies(cfg.String(HTTP_TRUSTED_PROXIES))) {
+				host = forwardedHost(r)
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), ctxExternalHostKey{}, host)))
+		})
+	}
+}
+
+// forwardedHost builds the external host from the X-Forwarded-Host and X-Forwarded-Port headers, falling back to the
+// Host of the request for any part that is missing.
+func forwardedHost(r *http.Request) string {
+	host := r.Host
+	// When the request passed through multiple proxies, the first value is the one the client used.
+	if forwarded := firstHeaderValue(r.Header.Get("X-Forwarded-Host")); forwarded != "" {
+		host = forwarded
+	}
+
+	port := firstHeaderValue(r.Header.Get("X-Forwarded-Port"))
+	if port == "" {
+		return host
+	}
+
+	// Replace any port already part of the host with the forwarded port.
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	return net.JoinHostPort(host, port)
+}
+
+// firstHeaderValue returns the first entry of a comma separated header value.
+func firstHeaderValue(value string) string {
+	first, _, _ := strings.Cut(value, ",")
+	return strings.TrimSpace(first)
+}
+
+// GetExternalHostFromContext retrieves the external host from the context.
+func GetExternalHostFromContext(ctx context.Context) string {
+	if host, ok := ctx.Value(ctxExternalHostKey{}).(string); ok {
+		return host
+	}
+	return ""
+}
@@ -0,0 +1,106 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ponrove/configura"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExternalHost(t *testing.T) {
+	tests := []struct {
+		name           string
+		trustedProxies string
+		remoteAddr     string
+		host           string
+		headers        map[string]string
+		expectedHost   string
+	}{
+		{
+			name:         "No forwarded headers",
+			remoteAddr:   "10.0.0.1:1234",
+			host:         "internal:8080",
+			expectedHost: "internal:8080",
+		},
+		{
+			name:           "Forwarded host from trusted proxy",
+			trustedProxies: "10.0.0.0/8",
+			remoteAddr:     "10.0.0.1:1234",
+			host:           "internal:8080",
+			headers:        map[string]string{"X-Forwarded-Host": "api.example.com"},
+			expectedHost:   "api.example.com",
+		},
+		{
+			name:           "Forwarded host and port from trusted proxy",
+			trustedProxies: "10.0.0.1",
+			remoteAddr:     "10.0.0.1:1234",
+			host:           "internal:8080",
+			headers:        map[string]string{"X-Forwarded-Host": "api.example.com:80", "X-Forwarded-Port": "8443"},
+			expectedHost:   "api.example.com:8443",
+		},
+		{
+			name:           "Forwarded port only from trusted proxy",
+			trustedProxies: "10.0.0.0/8",
+			remoteAddr:     "10.0.0.1:1234",
+			host:           "internal:8080",
+			headers:        map[string]string{"X-Forwarded-Port": "443"},
+			expectedHost:   "internal:443",
+		},
+		{
+			name:           "Multiple forwarded hosts, first one wins",
+			trustedProxies: "10.0.0.0/8",
+			remoteAddr:     "10.0.0.1:1234",
+			host:           "internal:8080",
+			headers:        map[string]string{"X-Forwarded-Host": "api.example.com, proxy.internal"},
+			expectedHost:   "api.example.com",
+		},
+		{
+			name:           "Forwarded headers from untrusted peer are ignored",
+			trustedProxies: "10.0.0.0/8",
+			remoteAddr:     "203.0.113.10:1234",
+			host:           "internal:8080",
+			headers:        map[string]string{"X-Forwarded-Host": "evil.example.com", "X-Forwarded-Port": "1337"},
+			expectedHost:   "internal:8080",
+		},
+		{
+			name:         "Forwarded headers ignored without trusted proxies",
+			remoteAddr:   "10.0.0.1:1234",
+			host:         "internal:8080",
+			headers:      map[string]string{"X-Forwarded-Host": "api.example.com"},
+			expectedHost: "internal:8080",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := configura.NewConfigImpl()
+			err := configura.WriteConfiguration(cfg, map[configura.Variable[string]]string{
+				HTTP_TRUSTED_PROXIES: tc.trustedProxies,
+			})
+			require.NoError(t, err)
+
+			var actualHost string
+			handler := ExternalHost(cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				actualHost = GetExternalHostFromContext(r.Context())
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tc.remoteAddr
+			req.Host = tc.host
+			for k, v := range tc.headers {
+				req.Header.Set(k, v)
+			}
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			assert.Equal(t, tc.expectedHost, actualHost)
+		})
+	}
+}
+
+func TestGetExternalHostFromContext_NotFound(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	assert.Empty(t, GetExternalHostFromContext(req.Context()))
+}
@@ -0,0 +1,119 @@
+package middleware
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"net/http"
+	"sync"
+
+	"github.com/ponrove/configura"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	GEOIP_CACHE_SIZE     configura.Variable[int64] = "GEOIP_CACHE_SIZE"     // IP addresses whose country is cached, defaults to 10000
+	GEOIP_SPAN_ATTRIBUTE configura.Variable[bool]  = "GEOIP_SPAN_ATTRIBUTE" // Set the country as an attribute of the request span, off by default
+)
+
+// geoIPCountryAttribute is the span attribute of the client's country, as named by the OpenTelemetry semantic
+// conventions.
+const geoIPCountryAttribute = attribute.Key("client.geo.country_iso_code")
+
+// GeoIPResolver maps the IP address of a client to the ISO 3166-1 alpha-2 code of its country, e.g. by looking it up in
+// a MaxMind database. An empty code means the country is unknown.
+type GeoIPResolver interface {
+	Country(ctx context.Context, ip net.IP) (string, error)
+}
+
+// GeoIPResolverFunc is a function implementing GeoIPResolver.
+type GeoIPResolverFunc func(ctx context.Context, ip net.IP) (string, error)
+
+// Country calls the function.
+func (f GeoIPResolverFunc) Country(ctx context.Context, ip net.IP) (string, error) {
+	return f(ctx, ip)
+}
+
+// ctxCountryKey is a context key for storing the country of the client.
+type ctxCountryKey struct{}
+
+// geoIPCache caches the countries resolved for IP addresses. It is emptied once it holds size entries, which bounds
+// its memory without tracking the use of each entry.
+type geoIPCache struct {
+	mu        sync.RWMutex
+	size      int
+	countries map[string]string
+}
+
+// get returns the cached country of the IP address.
+func (c *geoIPCache) get(ip string) (string, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	country, ok := c.countries[ip]
+	return country, ok
+}
+
+// set caches the country of the IP address.
+func (c *geoIPCache) set(ip, country string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.countries) >= c.size {
+		c.countries = make(map[string]string, c.size)
+	}
+	c.countries[ip] = country
+}
+
+// GeoIP is a middleware that resolves the country of the client from the IP address found by the IPAddress middleware,
+// and stores it in the request context, where LogRequest picks it up as the country field of the access log. With
+// GEOIP_SPAN_ATTRIBUTE set, it's also set as an attribute of the request span. Lookups are cached for the
+// GEOIP_CACHE_SIZE most recent addresses. The middleware is disabled if the resolver is nil.
+func GeoIP(cfg configura.Config, resolver GeoIPResolver) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if resolver == nil {
+			return next
+		}
+		spanAttribute := cfg.Bool(GEOIP_SPAN_ATTRIBUTE)
+		size := int(configura.Fallback(cfg.Int64(GEOIP_CACHE_SIZE), 10000))
+		cache := &geoIPCache{size: size, countries: make(map[string]string, size)}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			address := GetIPAddressFromContext(r.Context())
+			if address == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			country, ok := cache.get(address)
+			if !ok {
+				var err error
+				country, err = resolver.Country(r.Context(), net.ParseIP(address))
+				if err != nil {
+					// Errors aren't cached, the lookup is retried on the next request of the client.
+					slog.DebugContext(r.Context(), "Failed to resolve the country of the client", slog.Any("error", err))
+					next.ServeHTTP(w, r)
+					return
+				}
+				cache.set(address, country)
+			}
+
+			if country == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if spanAttribute {
+				trace.SpanFromContext(r.Context()).SetAttributes(geoIPCountryAttribute.String(country))
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), ctxCountryKey{}, country)))
+		})
+	}
+}
+
+// GetCountryFromContext retrieves the country code of the client from the context, as resolved by the GeoIP
+// middleware.
+func GetCountryFromContext(ctx context.Context) string {
+	if country, ok := ctx.Value(ctxCountryKey{}).(string); ok {
+		return country
+	}
+	return ""
+}
@@ -0,0 +1,104 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ponrove/configura"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// stubCountries is a GeoIPResolver stub, counting its lookups.
+type stubCountries struct {
+	countries map[string]string
+	lookups   int
+}
+
+// Country returns the country of the IP address in the stub's map.
+func (s *stubCountries) Country(_ context.Context, ip net.IP) (string, error) {
+	s.lookups++
+	country, ok := s.countries[ip.String()]
+	if !ok {
+		return "", errors.New("address not found")
+	}
+	return country, nil
+}
+
+func TestGeoIP_LoggedCountry(t *testing.T) {
+	var logBuffer bytes.Buffer
+	originalDefaultLogger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&logBuffer, nil)))
+	t.Cleanup(func() { slog.SetDefault(originalDefaultLogger) })
+
+	cfg := configura.NewConfigImpl()
+	resolver := &stubCountries{countries: map[string]string{"8.8.8.8": "US"}}
+	handler := IPAddress(cfg)(GeoIP(cfg, resolver)(LogRequest(cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))))
+
+	for range 3 {
+		logBuffer.Reset()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = "8.8.8.8:1234"
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+
+		var logged map[string]any
+		require.NoError(t, json.Unmarshal(logBuffer.Bytes(), &logged))
+		assert.Equal(t, "US", logged["country"])
+	}
+	assert.Equal(t, 1, resolver.lookups, "The country should be cached after the first lookup")
+}
+
+func TestGeoIP_UnknownCountry(t *testing.T) {
+	var logBuffer bytes.Buffer
+	originalDefaultLogger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&logBuffer, nil)))
+	t.Cleanup(func() { slog.SetDefault(originalDefaultLogger) })
+
+	cfg := configura.NewConfigImpl()
+	handler := IPAddress(cfg)(GeoIP(cfg, &stubCountries{})(LogRequest(cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "1.1.1.1:1234"
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	var logged map[string]any
+	require.NoError(t, json.Unmarshal(logBuffer.Bytes(), &logged))
+	assert.NotContains(t, logged, "country")
+}
+
+func TestGeoIP_SpanAttribute(t *testing.T) {
+	cfg := configura.NewConfigImpl()
+	require.NoError(t, configura.WriteConfiguration(cfg, map[configura.Variable[bool]]bool{
+		GEOIP_SPAN_ATTRIBUTE: true,
+	}))
+
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	resolver := GeoIPResolverFunc(func(context.Context, net.IP) (string, error) { return "SE", nil })
+	handler := IPAddress(cfg)(GeoIP(cfg, resolver)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "SE", GetCountryFromContext(r.Context()))
+	})))
+
+	ctx, span := tp.Tracer("test").Start(context.Background(), "request")
+	req := httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx)
+	req.RemoteAddr = "8.8.4.4:1234"
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	span.End()
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	assert.Contains(t, spans[0].Attributes(), geoIPCountryAttribute.String("SE"))
+}
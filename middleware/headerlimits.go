@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/ponrove/configura"
+	slogctx "github.com/veqryn/slog-context"
+)
+
+const (
+	SERVER_MAX_HEADER_VALUE_BYTES configura.Variable[int64] = "SERVER_MAX_HEADER_VALUE_BYTES" // Largest value of a single request header, unlimited by default
+)
+
+// oversizedHeader returns the name of the first header with a value longer than limit bytes, or "" if there is none.
+func oversizedHeader(header http.Header, limit int64) string {
+	for name, values := range header {
+		for _, value := range values {
+			if int64(len(value)) > limit {
+				return name
+			}
+		}
+	}
+	return ""
+}
+
+// HeaderLimits is a middleware that rejects requests with a header value longer than SERVER_MAX_HEADER_VALUE_BYTES
+// with 431 Request Header Fields Too Large, so that a single huge value, such as a giant cookie, can't reach the
+// handlers even when the headers fit in the server's total header budget. The name of the offending header is logged,
+// never its value, which may be a credential. The middleware is disabled unless SERVER_MAX_HEADER_VALUE_BYTES is set.
+func HeaderLimits(cfg configura.Config) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		limit := cfg.Int64(SERVER_MAX_HEADER_VALUE_BYTES)
+		if limit <= 0 {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if name := oversizedHeader(r.Header, limit); name != "" {
+				slogctx.FromCtx(r.Context()).Warn("Request header value too large",
+					slog.String("header", name),
+					slog.Int64("limit", limit))
+				Reject(cfg, w, r, http.StatusRequestHeaderFieldsTooLarge,
+					fmt.Sprintf("Value of header %s is too large, at most %d bytes are allowed", name, limit))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ponrove/configura"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHeaderLimits(t *testing.T) {
+	var logBuffer bytes.Buffer
+	originalDefaultLogger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&logBuffer, nil)))
+	t.Cleanup(func() { slog.SetDefault(originalDefaultLogger) })
+
+	cfg := configura.NewConfigImpl()
+	err := configura.WriteConfiguration(cfg, map[configura.Variable[int64]]int64{
+		SERVER_MAX_HEADER_VALUE_BYTES: 16,
+	})
+	require.NoError(t, err)
+
+	handler := HeaderLimits(cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	t.Run("Allowed", func(t *testing.T) {
+		logBuffer.Reset()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Cookie", "session=abc")
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Empty(t, logBuffer.String())
+	})
+
+	t.Run("Oversized value", func(t *testing.T) {
+		logBuffer.Reset()
+		value := "session=" + strings.Repeat("x", 64)
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Cookie", value)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusRequestHeaderFieldsTooLarge, rr.Code)
+		assert.NotContains(t, rr.Body.String(), value)
+
+		var logged map[string]any
+		require.NoError(t, json.Unmarshal(logBuffer.Bytes(), &logged))
+		assert.Equal(t, "Request header value too large", logged["msg"])
+		assert.Equal(t, "Cookie", logged["header"])
+		assert.NotContains(t, logBuffer.String(), value, "The header value must not be logged")
+	})
+}
+
+func TestHeaderLimits_UnlimitedByDefault(t *testing.T) {
+	handler := HeaderLimits(configura.NewConfigImpl())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Cookie", strings.Repeat("x", 64<<10))
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+}
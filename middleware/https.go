@@ -0,0 +1,73 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/ponrove/configura"
+	"github.com/ponrove/ponrunner/utils"
+)
+
+const (
+	REQUIRE_HTTPS_MODE         configura.Variable[string] = "REQUIRE_HTTPS_MODE"         // "redirect", "reject" or empty to allow plain HTTP (default)
+	REQUIRE_HTTPS_EXEMPT_PATHS configura.Variable[string] = "REQUIRE_HTTPS_EXEMPT_PATHS" // Comma separated paths served over plain HTTP, defaults to the internal endpoints
+)
+
+// isHTTPS reports whether the client reached the service over HTTPS, either directly or, for requests from a trusted
+// proxy, according to the X-Forwarded-Proto header.
+func isHTTPS(r *http.Request, trusted []*net.IPNet) bool {
+	if r.TLS != nil {
+		return true
+	}
+	if !utils.IsTrustedProxy(r.RemoteAddr, trusted) {
+		return false
+	}
+	return strings.EqualFold(firstHeaderValue(r.Header.Get("X-Forwarded-Proto")), "https")
+}
+
+// httpsURL returns the HTTPS URL of the request, on the default port of the external host.
+func httpsURL(r *http.Request) string {
+	host := GetExternalHostFromContext(r.Context())
+	if host == "" {
+		host = r.Host
+	}
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+		if strings.Contains(host, ":") {
+			host = "[" + host + "]"
+		}
+	}
+	return "https://" + host + r.URL.RequestURI()
+}
+
+// RequireHTTPS is a middleware for HTTPS-only services. Depending on REQUIRE_HTTPS_MODE, requests made over plain HTTP
+// are redirected to their HTTPS URL with a 308 Permanent Redirect ("redirect"), or rejected with a 403 Forbidden
+// ("reject"). Behind a proxy terminating TLS, the X-Forwarded-Proto header is honored from the proxies listed in
+// HTTP_TRUSTED_PROXIES. The paths in REQUIRE_HTTPS_EXEMPT_PATHS are always served, by default the internalPaths of the
+// server (the health checks, metrics and version endpoints, /livez and /readyz if none is given), so health checks and
+// scrapes over plain HTTP keep working. The middleware is disabled unless REQUIRE_HTTPS_MODE is set.
+func RequireHTTPS(cfg configura.Config, internalPaths ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		mode := strings.ToLower(cfg.String(REQUIRE_HTTPS_MODE))
+		if mode != "redirect" && mode != "reject" {
+			return next
+		}
+		trusted := utils.ParseTrustedProxies(cfg.String(HTTP_TRUSTED_PROXIES))
+		exempt := exemptPaths(cfg.String(REQUIRE_HTTPS_EXEMPT_PATHS), internalPaths)
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if isHTTPS(r, trusted) || slices.Contains(exempt, r.URL.Path) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if mode == "redirect" {
+				http.Redirect(w, r, httpsURL(r), http.StatusPermanentRedirect)
+				return
+			}
+			Reject(cfg, w, r, http.StatusForbidden, "HTTPS is required")
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ponrove/configura"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequireHTTPS(t *testing.T) {
+	tests := []struct {
+		name             string
+		mode             string
+		target           string
+		headers          map[string]string
+		expectedStatus   int
+		expectedLocation string
+	}{
+		{
+			name:           "Disabled",
+			target:         "http://api.example.com/users",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:             "Redirect mode",
+			mode:             "redirect",
+			target:           "http://api.example.com:8080/users?page=2",
+			expectedStatus:   http.StatusPermanentRedirect,
+			expectedLocation: "https://api.example.com/users?page=2",
+		},
+		{
+			name:           "Reject mode",
+			mode:           "reject",
+			target:         "http://api.example.com/users",
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "Forwarded HTTPS from trusted proxy",
+			mode:           "reject",
+			target:         "http://api.example.com/users",
+			headers:        map[string]string{"X-Forwarded-Proto": "https"},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Health check exempt",
+			mode:           "reject",
+			target:         "http://10.0.0.5/readyz",
+			expectedStatus: http.StatusOK,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := configura.NewConfigImpl()
+			err := configura.WriteConfiguration(cfg, map[configura.Variable[string]]string{
+				REQUIRE_HTTPS_MODE:   tc.mode,
+				HTTP_TRUSTED_PROXIES: "10.0.0.0/8",
+			})
+			require.NoError(t, err)
+
+			handler := RequireHTTPS(cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, tc.target, nil)
+			req.RemoteAddr = "10.0.0.1:1234"
+			for k, v := range tc.headers {
+				req.Header.Set(k, v)
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			assert.Equal(t, tc.expectedStatus, rr.Code)
+			assert.Equal(t, tc.expectedLocation, rr.Header().Get("Location"))
+		})
+	}
+}
+
+func TestRequireHTTPS_UntrustedForwardedProto(t *testing.T) {
+	cfg := configura.NewConfigImpl()
+	err := configura.WriteConfiguration(cfg, map[configura.Variable[string]]string{
+		REQUIRE_HTTPS_MODE:   "reject",
+		HTTP_TRUSTED_PROXIES: "10.0.0.0/8",
+	})
+	require.NoError(t, err)
+
+	handler := RequireHTTPS(cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "http://api.example.com/users", nil)
+	req.RemoteAddr = "203.0.113.9:1234"
+	req.Header.Set("X-Forwarded-Proto", "https")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusForbidden, rr.Code, "X-Forwarded-Proto should be ignored from untrusted peers")
+}
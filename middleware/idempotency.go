@@ -0,0 +1,291 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ponrove/configura"
+	"github.com/ponrove/ponrunner/utils"
+	slogctx "github.com/veqryn/slog-context"
+)
+
+const (
+	IDEMPOTENCY_PATHS      configura.Variable[string] = "IDEMPOTENCY_PATHS"      // Comma separated paths honoring Idempotency-Key, a trailing * matches a prefix, empty disables
+	IDEMPOTENCY_TTL        configura.Variable[int64]  = "IDEMPOTENCY_TTL"        // Seconds a response is replayed for its key, defaults to 86400
+	IDEMPOTENCY_KEY_HEADER configura.Variable[string] = "IDEMPOTENCY_KEY_HEADER" // Header of the idempotency key, defaults to Idempotency-Key
+	IDEMPOTENCY_LOCK_TTL   configura.Variable[int64]  = "IDEMPOTENCY_LOCK_TTL"   // Seconds a key stays reserved by a request in flight, defaults to 60
+)
+
+// defaultIdempotencyTTL is how long a response is replayed for its key, if IDEMPOTENCY_TTL is not set.
+const defaultIdempotencyTTL = 24 * time.Hour
+
+// defaultIdempotencyLockTTL is how long a key stays reserved by a request in flight, if IDEMPOTENCY_LOCK_TTL is not set.
+const defaultIdempotencyLockTTL = time.Minute
+
+// idempotencyEntry is the response to the first request with an idempotency key, as kept in the Store, along with the
+// hash of that request's body, so the key can't be reused for another request.
+type idempotencyEntry struct {
+	RequestHash string      `json:"requestHash"`
+	Status      int         `json:"status"`
+	Header      http.Header `json:"header"`
+	Body        []byte      `json:"body"`
+}
+
+// unreplayedHeaders are the headers of a response that aren't kept to be replayed: the hop-by-hop headers, which only
+// applied to the connection of the first request, and the credentials issued to its client.
+var unreplayedHeaders = []string{
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Connection",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+	"Set-Cookie",
+	"Authentication-Info",
+	"Proxy-Authenticate",
+	"Proxy-Authentication-Info",
+}
+
+// idempotencyScope returns the caller a key belongs to, so a client can't replay the responses of another client by
+// sending its key: the principal of an authenticated request, a hash of its Authorization header, or else the address
+// of the client.
+func idempotencyScope(r *http.Request, trusted []*net.IPNet) string {
+	if principal := GetPrincipalFromContext(r.Context()); principal != "" {
+		return "principal:" + principal
+	}
+	if authorization := r.Header.Get("Authorization"); authorization != "" {
+		sum := sha256.Sum256([]byte(authorization))
+		return "authorization:" + hex.EncodeToString(sum[:])
+	}
+	return "ip:" + clientAddress(r, trusted)
+}
+
+// hashBody returns the hash of the body of the request, which is read in memory and restored for the handler.
+func hashBody(r *http.Request) (string, error) {
+	if r.Body == nil || r.Body == http.NoBody {
+		sum := sha256.Sum256(nil)
+		return hex.EncodeToString(sum[:]), nil
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return "", err
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// idempotencyStore keeps the responses of the idempotency keys in a Store, until they expire. A key is reserved by the
+// request in flight with a lock, so a duplicate can be told apart from a retry of a finished request. The lock expires
+// after lockTTL, so the key isn't reserved for long by a replica that crashed mid-request.
+type idempotencyStore struct {
+	store   Store
+	ttl     time.Duration
+	lockTTL time.Duration
+}
+
+// get returns the response kept for the key, if there is one.
+func (s *idempotencyStore) get(ctx context.Context, key string) (*idempotencyEntry, error) {
+	value, ok, err := s.store.Get(ctx, "idempotency:"+key)
+	if err != nil || !ok {
+		return nil, err
+	}
+	var entry idempotencyEntry
+	if err := json.Unmarshal(value, &entry); err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// begin returns the response kept for the key if there is one, or reserves the key for a request in flight. It
+// reports whether a request with the key is already in flight.
+func (s *idempotencyStore) begin(ctx context.Context, key string) (entry *idempotencyEntry, inFlight bool, err error) {
+	if entry, err = s.get(ctx, key); entry != nil || err != nil {
+		return entry, false, err
+	}
+	n, err := s.store.Incr(ctx, "idempotency-lock:"+key, s.lockTTL)
+	if err != nil {
+		return nil, false, err
+	}
+	if n > 1 {
+		return nil, true, nil
+	}
+	// The response may have been kept between the lookup and the lock, by a request that just finished.
+	if entry, err = s.get(ctx, key); entry != nil || err != nil {
+		_ = s.store.Delete(ctx, "idempotency-lock:"+key)
+	}
+	return entry, false, err
+}
+
+// finish keeps the response of the key, if there is a response worth replaying, and releases the key.
+func (s *idempotencyStore) finish(ctx context.Context, key string, entry *idempotencyEntry) error {
+	defer func() { _ = s.store.Delete(ctx, "idempotency-lock:"+key) }()
+	if entry == nil {
+		return nil
+	}
+	value, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	return s.store.Set(ctx, "idempotency:"+key, value, s.ttl)
+}
+
+// idempotencyResponseWriter writes the response through to the client, keeping a copy to replay.
+type idempotencyResponseWriter struct {
+	http.ResponseWriter
+	status int
+	header http.Header
+	body   bytes.Buffer
+}
+
+// Ensure the idempotencyResponseWriter implements the http.ResponseWriter interface at compile time.
+var _ http.ResponseWriter = &idempotencyResponseWriter{}
+
+// Interceptor that keeps the status code and the headers of the response.
+func (iw *idempotencyResponseWriter) WriteHeader(code int) {
+	if iw.status == 0 {
+		iw.status = code
+		iw.header = iw.Header().Clone()
+	}
+	iw.ResponseWriter.WriteHeader(code)
+}
+
+// Interceptor that keeps a copy of the body.
+func (iw *idempotencyResponseWriter) Write(b []byte) (int, error) {
+	if iw.status == 0 {
+		iw.WriteHeader(http.StatusOK)
+	}
+	iw.body.Write(b)
+	return iw.ResponseWriter.Write(b)
+}
+
+// Unwrap returns the wrapped http.ResponseWriter, for http.ResponseController.
+func (iw *idempotencyResponseWriter) Unwrap() http.ResponseWriter {
+	return iw.ResponseWriter
+}
+
+// idempotentPath reports whether the path is listed in paths, where a trailing * matches any path with that prefix.
+func idempotentPath(paths []string, path string) bool {
+	for _, p := range paths {
+		if prefix, ok := strings.CutSuffix(p, "*"); ok {
+			if strings.HasPrefix(path, prefix) {
+				return true
+			}
+		} else if p == path {
+			return true
+		}
+	}
+	return false
+}
+
+// Idempotency is a middleware making retries of unsafe requests (e.g. a payment) safe, for the paths in
+// IDEMPOTENCY_PATHS. The response to the first request with an Idempotency-Key header is kept in memory (or in the
+// Store of IdempotencyWithStore) for IDEMPOTENCY_TTL (a day by default), and replayed with an Idempotent-Replayed
+// header for later requests of the same caller with the same key, method and path, without calling the handler again.
+// The caller is the authenticated principal, the Authorization header, or else the client address. A later request
+// with another body is rejected with a 422 Unprocessable Entity, and a duplicate arriving while the first request is
+// still in flight, for up to IDEMPOTENCY_LOCK_TTL (a minute by default), with a 409 Conflict. Server errors aren't
+// kept, so the request can be retried, and neither are cookies and hop-by-hop headers. Requests without the header,
+// or with a safe method, are served as usual. The middleware is disabled unless IDEMPOTENCY_PATHS is set.
+func Idempotency(cfg configura.Config) func(http.Handler) http.Handler {
+	return IdempotencyWithStore(cfg, nil)
+}
+
+// IdempotencyWithStore is Idempotency, keeping the responses in store rather than in memory, e.g. to deduplicate
+// requests across the replicas of a service. A nil store keeps them in memory. If the store fails, a warning is logged
+// and the request is served without deduplication.
+func IdempotencyWithStore(cfg configura.Config, store Store) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		paths := utils.SplitCommaSeparated(cfg.String(IDEMPOTENCY_PATHS))
+		if len(paths) == 0 {
+			return next
+		}
+		header := configura.Fallback(cfg.String(IDEMPOTENCY_KEY_HEADER), "Idempotency-Key")
+		ttl := time.Duration(cfg.Int64(IDEMPOTENCY_TTL)) * time.Second
+		if ttl <= 0 {
+			ttl = defaultIdempotencyTTL
+		}
+		lockTTL := time.Duration(cfg.Int64(IDEMPOTENCY_LOCK_TTL)) * time.Second
+		if lockTTL <= 0 {
+			lockTTL = defaultIdempotencyLockTTL
+		}
+		if store == nil {
+			store = NewMemoryStore()
+		}
+		responses := &idempotencyStore{store: store, ttl: ttl, lockTTL: lockTTL}
+		trusted := utils.ParseTrustedProxies(cfg.String(HTTP_TRUSTED_PROXIES))
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(header)
+			switch r.Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+				key = ""
+			}
+			if key == "" || !idempotentPath(paths, r.URL.Path) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			requestHash, err := hashBody(r)
+			if err != nil {
+				Reject(cfg, w, r, http.StatusBadRequest, "failed to read the request body")
+				return
+			}
+			storeKey := idempotencyScope(r, trusted) + " " + r.Method + " " + r.URL.Path + " " + key
+			entry, inFlight, err := responses.begin(r.Context(), storeKey)
+			if err != nil {
+				slogctx.FromCtx(r.Context()).Warn("Idempotency store failed, serving the request without deduplication",
+					slog.Any("error", err))
+				next.ServeHTTP(w, r)
+				return
+			}
+			if inFlight {
+				Reject(cfg, w, r, http.StatusConflict, "A request with the same "+header+" is in progress")
+				return
+			}
+			if entry != nil {
+				if entry.RequestHash != requestHash {
+					Reject(cfg, w, r, http.StatusUnprocessableEntity, "The "+header+" was already used for another request")
+					return
+				}
+				for k, v := range entry.Header {
+					w.Header()[k] = v
+				}
+				w.Header().Set("Idempotent-Replayed", "true")
+				w.WriteHeader(entry.Status)
+				_, _ = w.Write(entry.Body)
+				return
+			}
+
+			iw := &idempotencyResponseWriter{ResponseWriter: w}
+			// The key is released if the handler panics or fails, so the request can be retried.
+			defer func() {
+				if err := responses.finish(context.WithoutCancel(r.Context()), storeKey, entry); err != nil {
+					slogctx.FromCtx(r.Context()).Warn("Idempotency store failed to keep the response", slog.Any("error", err))
+				}
+			}()
+			next.ServeHTTP(iw, r)
+
+			if iw.status == 0 {
+				iw.status = http.StatusOK
+				iw.header = w.Header().Clone()
+			}
+			if iw.status < http.StatusInternalServerError {
+				for _, h := range unreplayedHeaders {
+					iw.header.Del(h)
+				}
+				entry = &idempotencyEntry{RequestHash: requestHash, Status: iw.status, Header: iw.header, Body: iw.body.Bytes()}
+			}
+		})
+	}
+}
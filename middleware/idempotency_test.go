@@ -0,0 +1,260 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ponrove/configura"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newIdempotencyConfig(t *testing.T) configura.Config {
+	t.Helper()
+	cfg := configura.NewConfigImpl()
+	err := configura.WriteConfiguration(cfg, map[configura.Variable[string]]string{
+		IDEMPOTENCY_PATHS: "/payments,/orders/*",
+	})
+	require.NoError(t, err)
+	return cfg
+}
+
+func TestIdempotency_Replay(t *testing.T) {
+	var calls atomic.Int32
+	handler := Idempotency(newIdempotencyConfig(t))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := calls.Add(1)
+		w.Header().Set("Location", "/payments/"+strconv.Itoa(int(n)))
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte("payment " + strconv.Itoa(int(n))))
+	}))
+
+	post := func(path, key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, nil)
+		if key != "" {
+			req.Header.Set("Idempotency-Key", key)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	first := post("/payments", "abc")
+	assert.Equal(t, http.StatusCreated, first.Code)
+	assert.Equal(t, "payment 1", first.Body.String())
+	assert.Empty(t, first.Header().Get("Idempotent-Replayed"))
+
+	replayed := post("/payments", "abc")
+	assert.Equal(t, http.StatusCreated, replayed.Code, "The response should be replayed")
+	assert.Equal(t, "payment 1", replayed.Body.String())
+	assert.Equal(t, "/payments/1", replayed.Header().Get("Location"))
+	assert.Equal(t, "true", replayed.Header().Get("Idempotent-Replayed"))
+	assert.Equal(t, int32(1), calls.Load(), "The handler should not be called for a replay")
+
+	assert.Equal(t, "payment 2", post("/payments", "def").Body.String(), "Another key should reach the handler")
+	assert.Equal(t, "payment 3", post("/payments", "").Body.String(), "A request without a key should reach the handler")
+	assert.Equal(t, "payment 4", post("/orders/1", "abc").Body.String(), "The key should be scoped to the path")
+	assert.Equal(t, "payment 4", post("/orders/1", "abc").Body.String(), "A prefix should match")
+	assert.Equal(t, "payment 5", post("/refunds", "abc").Body.String(), "Other paths should not be deduplicated")
+	assert.Equal(t, "payment 6", post("/refunds", "abc").Body.String())
+}
+
+func TestIdempotency_ServerErrorNotKept(t *testing.T) {
+	var calls atomic.Int32
+	handler := Idempotency(newIdempotencyConfig(t))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	for _, expected := range []int{http.StatusServiceUnavailable, http.StatusCreated, http.StatusCreated} {
+		req := httptest.NewRequest(http.MethodPost, "/payments", nil)
+		req.Header.Set("Idempotency-Key", "abc")
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		assert.Equal(t, expected, rr.Code)
+	}
+	assert.Equal(t, int32(2), calls.Load(), "The request should be retried after a server error, then replayed")
+}
+
+func TestIdempotency_ConcurrentDuplicate(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	handler := Idempotency(newIdempotencyConfig(t))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	newRequest := func() *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/payments", nil)
+		req.Header.Set("Idempotency-Key", "abc")
+		return req
+	}
+
+	first := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		handler.ServeHTTP(first, newRequest())
+	}()
+	<-started
+
+	duplicate := httptest.NewRecorder()
+	handler.ServeHTTP(duplicate, newRequest())
+	assert.Equal(t, http.StatusConflict, duplicate.Code, "A duplicate in flight should be rejected")
+	assert.Equal(t, "application/problem+json", duplicate.Header().Get("Content-Type"))
+
+	close(release)
+	<-done
+	assert.Equal(t, http.StatusCreated, first.Code)
+}
+
+func TestIdempotencyWithStore(t *testing.T) {
+	store := newRecordingStore()
+	handler := IdempotencyWithStore(newIdempotencyConfig(t), store)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte("payment"))
+	}))
+
+	post := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/payments", nil)
+		req.Header.Set("Idempotency-Key", "abc")
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	assert.Equal(t, http.StatusCreated, post().Code)
+	assert.Equal(t, []string{
+		"Get idempotency:ip:192.0.2.1 POST /payments abc",
+		"Incr idempotency-lock:ip:192.0.2.1 POST /payments abc 1m0s",
+		"Get idempotency:ip:192.0.2.1 POST /payments abc",
+		"Set idempotency:ip:192.0.2.1 POST /payments abc 24h0m0s",
+		"Delete idempotency-lock:ip:192.0.2.1 POST /payments abc",
+	}, store.Calls(), "The first request should reserve the key, then keep its response and release the key")
+
+	replayed := post()
+	assert.Equal(t, http.StatusCreated, replayed.Code)
+	assert.Equal(t, "payment", replayed.Body.String())
+	assert.Equal(t, "true", replayed.Header().Get("Idempotent-Replayed"))
+	assert.Equal(t, "Get idempotency:ip:192.0.2.1 POST /payments abc", store.Calls()[5], "The replay should be read from the store")
+	assert.Len(t, store.Calls(), 6)
+}
+
+func TestIdempotencyWithStore_StoreFailure(t *testing.T) {
+	store := newRecordingStore()
+	store.err = errStoreUnavailable
+	var calls atomic.Int32
+	handler := IdempotencyWithStore(newIdempotencyConfig(t), store)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	for range 2 {
+		req := httptest.NewRequest(http.MethodPost, "/payments", nil)
+		req.Header.Set("Idempotency-Key", "abc")
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusCreated, rr.Code)
+	}
+	assert.Equal(t, int32(2), calls.Load(), "Requests should be served without deduplication when the store fails")
+}
+
+func TestIdempotencyWithStore_LockTTL(t *testing.T) {
+	cfg := newIdempotencyConfig(t)
+	err := configura.WriteConfiguration(cfg.(*configura.ConfigImpl), map[configura.Variable[int64]]int64{
+		IDEMPOTENCY_TTL:      3600,
+		IDEMPOTENCY_LOCK_TTL: 30,
+	})
+	require.NoError(t, err)
+	store := newRecordingStore()
+	handler := IdempotencyWithStore(cfg, store)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/payments", nil)
+	req.Header.Set("Idempotency-Key", "abc")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	// A replica crashing mid-request leaves the lock behind, it must expire long before the response would.
+	assert.Contains(t, store.Calls(), "Incr idempotency-lock:ip:192.0.2.1 POST /payments abc 30s")
+	assert.Contains(t, store.Calls(), "Set idempotency:ip:192.0.2.1 POST /payments abc 1h0m0s")
+}
+
+func TestIdempotency_Scope(t *testing.T) {
+	var calls atomic.Int32
+	handler := Idempotency(newIdempotencyConfig(t))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := calls.Add(1)
+		_, _ = w.Write([]byte("payment " + strconv.Itoa(int(n))))
+	}))
+
+	post := func(remoteAddr, authorization string) string {
+		req := httptest.NewRequest(http.MethodPost, "/payments", nil)
+		req.RemoteAddr = remoteAddr
+		req.Header.Set("Idempotency-Key", "abc")
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr.Body.String()
+	}
+
+	assert.Equal(t, "payment 1", post("192.0.2.1:1234", ""))
+	assert.Equal(t, "payment 1", post("192.0.2.1:1234", ""), "The client should get its own response replayed")
+	assert.Equal(t, "payment 2", post("192.0.2.2:1234", ""), "Another client should not get the response of the key")
+	assert.Equal(t, "payment 3", post("192.0.2.1:1234", "Bearer alice"))
+	assert.Equal(t, "payment 3", post("192.0.2.2:1234", "Bearer alice"), "The key should follow the credentials")
+	assert.Equal(t, "payment 4", post("192.0.2.1:1234", "Bearer bob"), "Other credentials should not get the response")
+}
+
+func TestIdempotency_OtherRequest(t *testing.T) {
+	var calls atomic.Int32
+	handler := Idempotency(newIdempotencyConfig(t))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	post := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/payments", strings.NewReader(body))
+		req.Header.Set("Idempotency-Key", "abc")
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	assert.Equal(t, http.StatusCreated, post(`{"amount":10}`).Code)
+	assert.Equal(t, http.StatusCreated, post(`{"amount":10}`).Code)
+	other := post(`{"amount":1000}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, other.Code, "The key should not be reused for another request")
+	assert.Equal(t, "application/problem+json", other.Header().Get("Content-Type"))
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+func TestIdempotency_UnreplayedHeaders(t *testing.T) {
+	handler := Idempotency(newIdempotencyConfig(t))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "secret"})
+		w.Header().Set("Location", "/payments/1")
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	post := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/payments", nil)
+		req.Header.Set("Idempotency-Key", "abc")
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	assert.NotEmpty(t, post().Header().Get("Set-Cookie"))
+	replayed := post()
+	assert.Equal(t, "true", replayed.Header().Get("Idempotent-Replayed"))
+	assert.Equal(t, "/payments/1", replayed.Header().Get("Location"))
+	assert.Empty(t, replayed.Header().Get("Set-Cookie"), "Cookies should not be replayed")
+}
@@ -0,0 +1,87 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/ponrove/configura"
+)
+
+const (
+	SERVER_MAX_JSON_DEPTH configura.Variable[int64] = "SERVER_MAX_JSON_DEPTH" // Deepest nesting of objects and arrays in a JSON request body, unlimited by default
+)
+
+// isJSONMediaType reports whether the Content-Type is JSON, application/json or a +json type like
+// application/merge-patch+json.
+func isJSONMediaType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// jsonDepthExceeds reports whether the JSON read from r nests objects and arrays deeper than limit. The tokens are
+// scanned without decoding the values, and the scan stops at the limit. Invalid JSON isn't reported, it's left to the
+// handler decoding it.
+func jsonDepthExceeds(r io.Reader, limit int64) bool {
+	dec := json.NewDecoder(r)
+	var depth int64
+	for {
+		token, err := dec.Token()
+		if err != nil {
+			return false
+		}
+		delim, ok := token.(json.Delim)
+		if !ok {
+			continue
+		}
+		switch delim {
+		case '{', '[':
+			depth++
+			if depth > limit {
+				return true
+			}
+		default:
+			depth--
+		}
+	}
+}
+
+// MaxJSONDepth is a middleware that rejects JSON request bodies nesting objects and arrays deeper than
+// SERVER_MAX_JSON_DEPTH with 400 Bad Request, before huma decodes them, so deeply nested payloads can't exhaust the
+// stack of the decoder. The body is scanned as it's read and kept in memory, then restored for the handler. Requests
+// whose Content-Type isn't JSON are passed through. The middleware is disabled unless SERVER_MAX_JSON_DEPTH is set.
+func MaxJSONDepth(cfg configura.Config) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		limit := cfg.Int64(SERVER_MAX_JSON_DEPTH)
+		if limit <= 0 {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Body == nil || r.Body == http.NoBody || !isJSONMediaType(r.Header.Get("Content-Type")) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			var scanned bytes.Buffer
+			if jsonDepthExceeds(io.TeeReader(r.Body, &scanned), limit) {
+				Reject(cfg, w, r, http.StatusBadRequest, fmt.Sprintf("JSON body nested too deeply, at most %d levels are allowed", limit))
+				return
+			}
+
+			// The handler reads the scanned bytes, then whatever the scan stopped before, e.g. after invalid JSON.
+			r.Body = struct {
+				io.Reader
+				io.Closer
+			}{io.MultiReader(&scanned, r.Body), r.Body}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
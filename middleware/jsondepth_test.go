@@ -0,0 +1,63 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ponrove/configura"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaxJSONDepth(t *testing.T) {
+	nested := func(depth int) string {
+		return strings.Repeat(`{"a":`, depth) + "1" + strings.Repeat("}", depth)
+	}
+
+	tests := []struct {
+		name           string
+		limit          int64
+		contentType    string
+		body           string
+		expectedStatus int
+	}{
+		{name: "Normal payload", limit: 3, contentType: "application/json", body: `{"a":[1,{"b":2}],"c":"{[["}`, expectedStatus: http.StatusOK},
+		{name: "At the limit", limit: 3, contentType: "application/json", body: nested(3), expectedStatus: http.StatusOK},
+		{name: "Over the limit", limit: 3, contentType: "application/json", body: nested(4), expectedStatus: http.StatusBadRequest},
+		{name: "Nested arrays", limit: 3, contentType: "application/json", body: "[[[[]]]]", expectedStatus: http.StatusBadRequest},
+		{name: "Deep payload rejected early", limit: 32, contentType: "application/json", body: strings.Repeat("[", 100000), expectedStatus: http.StatusBadRequest},
+		{name: "JSON suffix", limit: 3, contentType: "application/merge-patch+json; charset=utf-8", body: nested(4), expectedStatus: http.StatusBadRequest},
+		{name: "Invalid JSON is left to the handler", limit: 3, contentType: "application/json", body: `{"a":`, expectedStatus: http.StatusOK},
+		{name: "Not JSON", limit: 3, contentType: "text/plain", body: nested(4), expectedStatus: http.StatusOK},
+		{name: "Unlimited by default", contentType: "application/json", body: nested(4), expectedStatus: http.StatusOK},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := configura.NewConfigImpl()
+			err := configura.WriteConfiguration(cfg, map[configura.Variable[int64]]int64{
+				SERVER_MAX_JSON_DEPTH: tc.limit,
+			})
+			require.NoError(t, err)
+
+			var received string
+			handler := MaxJSONDepth(cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, err := io.ReadAll(r.Body)
+				require.NoError(t, err)
+				received = string(body)
+			}))
+			req := httptest.NewRequest(http.MethodPost, "/items", strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", tc.contentType)
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			assert.Equal(t, tc.expectedStatus, rr.Code)
+			if tc.expectedStatus == http.StatusOK {
+				assert.Equal(t, tc.body, received, "The handler should read the whole body")
+			}
+		})
+	}
+}
@@ -1,22 +1,32 @@
 package middleware
 
 import (
+	"bufio"
+	"context"
 	"fmt"
 	"log/slog"
+	"net"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/ponrove/configura"
+	"github.com/ponrove/ponrunner/utils"
 	slogctx "github.com/veqryn/slog-context"
 )
 
-// Custom response writer to capture the status code and response size, for logging.
+// Custom response writer to capture the status code, response size and write error, for logging. Whether the response
+// was streamed (flushed before the handler returned) or the connection hijacked is captured as well.
 type captureResponseWriter struct {
 	http.ResponseWriter
 	statusCode int
 	size       int
+	writeErr   error
+	streamed   bool
+	hijacked   bool
 }
 
 // Ensure the captureResponseWriter implements the http.ResponseWriter interface at compile time.
@@ -28,41 +38,152 @@ func (crw *captureResponseWriter) WriteHeader(code int) {
 	crw.ResponseWriter.WriteHeader(code)
 }
 
-// Interceptor that writes the size of the response body to the captureResponseWriter.
+// Interceptor that writes the size of the response body to the captureResponseWriter. The first write error, e.g. a
+// broken pipe when the client disconnected mid-response, is captured as well.
 func (crw *captureResponseWriter) Write(b []byte) (int, error) {
 	if crw.statusCode == 0 {
 		crw.statusCode = http.StatusOK
 	}
 	size, err := crw.ResponseWriter.Write(b)
 	crw.size += size
+	if err != nil && crw.writeErr == nil {
+		crw.writeErr = err
+	}
 	return size, err
 }
 
+// Flush sends the buffered response to the client, so streaming handlers (SSE, chunked downloads, ...) can flush
+// through the writer. The size keeps counting the streamed bytes until the handler returns.
+func (crw *captureResponseWriter) Flush() {
+	_ = crw.FlushError()
+}
+
+// FlushError is Flush returning the error of the underlying writer, for http.ResponseController. The response is only
+// captured as streamed if the flush went through, a writer of the chain that can't flush is logged at debug level.
+func (crw *captureResponseWriter) FlushError() error {
+	if err := http.NewResponseController(crw.ResponseWriter).Flush(); err != nil {
+		slog.Debug("Failed to flush the response", slog.Any("error", err))
+		return err
+	}
+	if crw.statusCode == 0 {
+		crw.statusCode = http.StatusOK
+	}
+	crw.streamed = true
+	return nil
+}
+
+// Hijack lets the handler take over the connection, e.g. for WebSockets. The bytes written to a hijacked connection
+// aren't counted in the size.
+func (crw *captureResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := http.NewResponseController(crw.ResponseWriter).Hijack()
+	if err == nil {
+		crw.hijacked = true
+	}
+	return conn, rw, err
+}
+
+// Unwrap returns the wrapped response writer, for http.ResponseController.
+func (crw *captureResponseWriter) Unwrap() http.ResponseWriter {
+	return crw.ResponseWriter
+}
+
+// WriteError returns the first error writing the response body failed with, or nil.
+func (crw *captureResponseWriter) WriteError() error {
+	return crw.writeErr
+}
+
+// ctxRequestStartKey is a context key for storing the time the request was received.
+type ctxRequestStartKey struct{}
+
+// GetRequestStartFromContext retrieves the time the request was received, as captured by LogRequest.
+func GetRequestStartFromContext(ctx context.Context) (time.Time, bool) {
+	start, ok := ctx.Value(ctxRequestStartKey{}).(time.Time)
+	return start, ok
+}
+
 const (
-	REQUEST_LOG_FIELD_DURATION       configura.Variable[string] = "REQUEST_LOG_FIELD_DURATION"
-	REQUEST_LOG_FIELD_REQUEST_METHOD configura.Variable[string] = "REQUEST_LOG_FIELD_REQUEST_METHOD"
-	REQUEST_LOG_FIELD_REQUEST_URL    configura.Variable[string] = "REQUEST_LOG_FIELD_REQUEST_URL"
-	REQUEST_LOG_FIELD_USER_AGENT     configura.Variable[string] = "REQUEST_LOG_FIELD_USER_AGENT"
-	REQUEST_LOG_FIELD_REQUEST_SIZE   configura.Variable[string] = "REQUEST_LOG_FIELD_REQUEST_SIZE"
-	REQUEST_LOG_FIELD_REMOTE_IP      configura.Variable[string] = "REQUEST_LOG_FIELD_REMOTE_IP"
-	REQUEST_LOG_FIELD_REFERER        configura.Variable[string] = "REQUEST_LOG_FIELD_REFERER"
-	REQUEST_LOG_FIELD_PROTOCOL       configura.Variable[string] = "REQUEST_LOG_FIELD_PROTOCOL"
-	REQUEST_LOG_FIELD_REQUEST_ID     configura.Variable[string] = "REQUEST_LOG_FIELD_REQUEST_ID"
-	REQUEST_LOG_FIELD_REAL_IP        configura.Variable[string] = "REQUEST_LOG_FIELD_REAL_IP"
-	REQUEST_LOG_FIELD_STATUS_CODE    configura.Variable[string] = "REQUEST_LOG_FIELD_STATUS_CODE"
-	REQUEST_LOG_FIELD_RESPONSE_SIZE  configura.Variable[string] = "REQUEST_LOG_FIELD_RESPONSE_SIZE"
-	REQUEST_LOG_FIELD_HOST           configura.Variable[string] = "REQUEST_LOG_FIELD_HOST"
-	REQUEST_LOG_FIELD_FINGERPRINT    configura.Variable[string] = "REQUEST_LOG_FIELD_FINGERPRINT"
+	REQUEST_LOG_FIELD_DURATION        configura.Variable[string] = "REQUEST_LOG_FIELD_DURATION"
+	REQUEST_LOG_FIELD_REQUEST_METHOD  configura.Variable[string] = "REQUEST_LOG_FIELD_REQUEST_METHOD"
+	REQUEST_LOG_FIELD_REQUEST_URL     configura.Variable[string] = "REQUEST_LOG_FIELD_REQUEST_URL"
+	REQUEST_LOG_FIELD_USER_AGENT      configura.Variable[string] = "REQUEST_LOG_FIELD_USER_AGENT"
+	REQUEST_LOG_FIELD_REQUEST_SIZE    configura.Variable[string] = "REQUEST_LOG_FIELD_REQUEST_SIZE"
+	REQUEST_LOG_FIELD_REMOTE_IP       configura.Variable[string] = "REQUEST_LOG_FIELD_REMOTE_IP"
+	REQUEST_LOG_FIELD_REFERER         configura.Variable[string] = "REQUEST_LOG_FIELD_REFERER"
+	REQUEST_LOG_FIELD_PROTOCOL        configura.Variable[string] = "REQUEST_LOG_FIELD_PROTOCOL"
+	REQUEST_LOG_FIELD_REQUEST_ID      configura.Variable[string] = "REQUEST_LOG_FIELD_REQUEST_ID"
+	REQUEST_LOG_FIELD_REAL_IP         configura.Variable[string] = "REQUEST_LOG_FIELD_REAL_IP"
+	REQUEST_LOG_FIELD_STATUS_CODE     configura.Variable[string] = "REQUEST_LOG_FIELD_STATUS_CODE"
+	REQUEST_LOG_FIELD_RESPONSE_SIZE   configura.Variable[string] = "REQUEST_LOG_FIELD_RESPONSE_SIZE"
+	REQUEST_LOG_FIELD_HOST            configura.Variable[string] = "REQUEST_LOG_FIELD_HOST"
+	REQUEST_LOG_FIELD_FINGERPRINT     configura.Variable[string] = "REQUEST_LOG_FIELD_FINGERPRINT"
+	REQUEST_LOG_FIELD_EDGE_LATENCY    configura.Variable[string] = "REQUEST_LOG_FIELD_EDGE_LATENCY"
+	REQUEST_LOG_FIELD_WRITE_ERROR     configura.Variable[string] = "REQUEST_LOG_FIELD_WRITE_ERROR"
+	REQUEST_LOG_FIELD_STREAMED        configura.Variable[string] = "REQUEST_LOG_FIELD_STREAMED"
+	REQUEST_LOG_FIELD_HIJACKED        configura.Variable[string] = "REQUEST_LOG_FIELD_HIJACKED"
+	REQUEST_LOG_FIELD_COUNTRY         configura.Variable[string] = "REQUEST_LOG_FIELD_COUNTRY"
+	REQUEST_LOG_FIELD_TLS_SERVER_NAME configura.Variable[string] = "REQUEST_LOG_FIELD_TLS_SERVER_NAME"
+	REQUEST_LOG_FIELD_ROUTE_PARAMS    configura.Variable[string] = "REQUEST_LOG_FIELD_ROUTE_PARAMS"
+	REQUEST_LOG_FIELD_UPSTREAM_TIMING configura.Variable[string] = "REQUEST_LOG_FIELD_UPSTREAM_TIMING"
+
+	REQUEST_LOG_QUERY_PARAMS configura.Variable[string] = "REQUEST_LOG_QUERY_PARAMS" // Comma separated query parameters logged as query_<name> fields
+	REQUEST_LOG_COOKIE_NAMES configura.Variable[string] = "REQUEST_LOG_COOKIE_NAMES" // Comma separated cookies logged as cookie_<name> fields, no cookie is logged by default
+	REQUEST_LOG_ROUTE_PARAMS configura.Variable[bool]   = "REQUEST_LOG_ROUTE_PARAMS" // Log the URL parameters of the matched chi route in a route_params group
 )
 
+// parseRequestStart parses the X-Request-Start header set by edge proxies. Both the `t=` prefixed form (as set by
+// nginx) and a bare timestamp are supported, in seconds with a fractional part, or integer seconds, milliseconds or
+// microseconds since the epoch.
+func parseRequestStart(value string) (time.Time, bool) {
+	value = strings.TrimPrefix(strings.TrimSpace(value), "t=")
+	if value == "" {
+		return time.Time{}, false
+	}
+
+	if strings.Contains(value, ".") {
+		seconds, err := strconv.ParseFloat(value, 64)
+		if err != nil || seconds <= 0 {
+			return time.Time{}, false
+		}
+		return time.UnixMicro(int64(seconds * 1e6)), true
+	}
+
+	ts, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || ts <= 0 {
+		return time.Time{}, false
+	}
+	switch {
+	case ts > 1e15:
+		return time.UnixMicro(ts), true
+	case ts > 1e12:
+		return time.UnixMilli(ts), true
+	default:
+		return time.Unix(ts, 0), true
+	}
+}
+
 // LogRequest is a middleware that logs the request details on each request.
 func LogRequest(cfg configura.Config) func(http.Handler) http.Handler {
+	queryParams := utils.SplitCommaSeparated(cfg.String(REQUEST_LOG_QUERY_PARAMS))
+	cookieNames := utils.SplitCommaSeparated(cfg.String(REQUEST_LOG_COOKIE_NAMES))
+	logRouteParams := cfg.Bool(REQUEST_LOG_ROUTE_PARAMS)
+	logUpstreamTiming := cfg.Bool(REQUEST_LOG_UPSTREAM_TIMING)
+	redact := newRedactor(cfg)
+
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			start := time.Now()
 
-			// Add the logger to the request context, to pass it downstream.
-			r = r.WithContext(slogctx.NewCtx(r.Context(), slog.Default()))
+			// Add the logger and the start time to the request context, to pass it downstream.
+			ctx := slogctx.NewCtx(r.Context(), slog.Default())
+			ctx = context.WithValue(ctx, ctxRequestStartKey{}, start)
+			// The upstream timings are collected from the responses of the calls the handler makes, see
+			// RecordUpstreamServerTiming.
+			var upstream *upstreamTimings
+			if logUpstreamTiming {
+				upstream = &upstreamTimings{metrics: make(map[string][]serverTimingEntry)}
+				ctx = context.WithValue(ctx, ctxUpstreamTimingKey{}, upstream)
+			}
+			r = r.WithContext(ctx)
 
 			// Wrap the response writer to capture the status code and response size, on writes.
 			crw := &captureResponseWriter{ResponseWriter: w}
@@ -72,15 +193,14 @@ func LogRequest(cfg configura.Config) func(http.Handler) http.Handler {
 			// If no logger is in context, it falls back to slog.Default().
 			logger := slogctx.FromCtx(r.Context())
 
-			logger.LogAttrs(r.Context(), slog.LevelInfo,
-				fmt.Sprintf("HTTP request processed: %s %s", r.Method, r.URL.Path),
+			attrs := []slog.Attr{
 				slog.Duration(configura.Fallback(cfg.String(REQUEST_LOG_FIELD_DURATION), "duration"), time.Since(start)),
 				slog.String(configura.Fallback(cfg.String(REQUEST_LOG_FIELD_REQUEST_METHOD), "method"), r.Method),
 				slog.String(configura.Fallback(cfg.String(REQUEST_LOG_FIELD_REQUEST_URL), "request_url"), r.URL.String()),
 				slog.Int(configura.Fallback(cfg.String(REQUEST_LOG_FIELD_STATUS_CODE), "status_code"), crw.statusCode),
 				slog.Int(configura.Fallback(cfg.String(REQUEST_LOG_FIELD_RESPONSE_SIZE), "response_size"), crw.size),
 				slog.String(configura.Fallback(cfg.String(REQUEST_LOG_FIELD_HOST), "host"), r.Header.Get("Host")),
-				slog.String(configura.Fallback(cfg.String(REQUEST_LOG_FIELD_FINGERPRINT), "fingerprint"), r.Header.Get("X-Fingerprint")),
+				slog.String(configura.Fallback(cfg.String(REQUEST_LOG_FIELD_FINGERPRINT), "fingerprint"), GetFingerprintFromContext(r.Context())),
 				slog.String(configura.Fallback(cfg.String(REQUEST_LOG_FIELD_USER_AGENT), "user_agent"), r.Header.Get("User-Agent")),
 				slog.String(configura.Fallback(cfg.String(REQUEST_LOG_FIELD_REQUEST_SIZE), "request_size"), strconv.FormatInt(r.ContentLength, 10)),
 				slog.String(configura.Fallback(cfg.String(REQUEST_LOG_FIELD_REMOTE_IP), "remote_ip"), r.RemoteAddr),
@@ -88,7 +208,72 @@ func LogRequest(cfg configura.Config) func(http.Handler) http.Handler {
 				slog.String(configura.Fallback(cfg.String(REQUEST_LOG_FIELD_PROTOCOL), "protocol"), r.Proto),
 				slog.String(configura.Fallback(cfg.String(REQUEST_LOG_FIELD_REAL_IP), "real_ip"), GetIPAddressFromContext(r.Context())),
 				slog.String(configura.Fallback(cfg.String(REQUEST_LOG_FIELD_REQUEST_ID), "request_id"), middleware.GetReqID(r.Context())),
-			)
+			}
+
+			// The SNI server name is only sent by TLS clients, and only when they connect by host name.
+			if r.TLS != nil && r.TLS.ServerName != "" {
+				attrs = append(attrs, slog.String(configura.Fallback(cfg.String(REQUEST_LOG_FIELD_TLS_SERVER_NAME), "tls_server_name"), r.TLS.ServerName))
+			}
+			// The country is only known if the GeoIP middleware ran before this middleware, with a resolver configured.
+			if country := GetCountryFromContext(r.Context()); country != "" {
+				attrs = append(attrs, slog.String(configura.Fallback(cfg.String(REQUEST_LOG_FIELD_COUNTRY), "country"), country))
+			}
+
+			// The edge latency is the time between the edge proxy receiving the request, and this service handling it.
+			if edgeStart, ok := parseRequestStart(r.Header.Get("X-Request-Start")); ok {
+				attrs = append(attrs, slog.Duration(configura.Fallback(cfg.String(REQUEST_LOG_FIELD_EDGE_LATENCY), "edge_latency"), max(start.Sub(edgeStart), 0)))
+			}
+
+			// A write error means the response was truncated, typically because the client disconnected mid-response.
+			if err := crw.WriteError(); err != nil {
+				attrs = append(attrs, slog.String(configura.Fallback(cfg.String(REQUEST_LOG_FIELD_WRITE_ERROR), "write_error"), err.Error()))
+			}
+
+			// The handler of a streamed response returns once the stream ends, so the duration and size cover the full
+			// stream. A hijacked connection is out of the server's hands, its duration and size only cover the handler.
+			if crw.streamed {
+				attrs = append(attrs, slog.Bool(configura.Fallback(cfg.String(REQUEST_LOG_FIELD_STREAMED), "streamed"), true))
+			}
+			if crw.hijacked {
+				attrs = append(attrs, slog.Bool(configura.Fallback(cfg.String(REQUEST_LOG_FIELD_HIJACKED), "hijacked"), true))
+			}
+
+			// Selected query parameters are logged as discrete fields, for filtering. Missing parameters are left out.
+			if len(queryParams) > 0 {
+				query := r.URL.Query()
+				for _, name := range queryParams {
+					if query.Has(name) {
+						attrs = append(attrs, slog.String("query_"+name, redact.value(name, query.Get(name))))
+					}
+				}
+			}
+
+			// Only allowlisted cookies are logged, as the others may carry sessions. Values are still redacted by name.
+			for _, name := range cookieNames {
+				if cookie, err := r.Cookie(name); err == nil {
+					attrs = append(attrs, slog.String("cookie_"+name, redact.value(name, cookie.Value)))
+				}
+			}
+
+			// The URL parameters of the matched route, e.g. {id}, tell which entity the request touched. chi fills them in
+			// while routing, so they are read once the handler returned. Values are redacted by name.
+			if logRouteParams {
+				if rctx := chi.RouteContext(r.Context()); rctx != nil && len(rctx.URLParams.Keys) > 0 {
+					params := make([]any, 0, len(rctx.URLParams.Keys))
+					for i, name := range rctx.URLParams.Keys {
+						params = append(params, slog.String(name, redact.value(name, rctx.URLParams.Values[i])))
+					}
+					attrs = append(attrs, slog.Group(configura.Fallback(cfg.String(REQUEST_LOG_FIELD_ROUTE_PARAMS), "route_params"), params...))
+				}
+			}
+
+			if upstream != nil {
+				if attr, ok := upstream.attr(configura.Fallback(cfg.String(REQUEST_LOG_FIELD_UPSTREAM_TIMING), "upstream_timing")); ok {
+					attrs = append(attrs, attr)
+				}
+			}
+
+			logger.LogAttrs(r.Context(), slog.LevelInfo, fmt.Sprintf("HTTP request processed: %s %s", r.Method, r.URL.Path), attrs...)
 		})
 	}
 }
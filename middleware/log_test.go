@@ -11,9 +11,11 @@ import (
 	"net/url"
 	"strconv"
 	"strings"
+	"syscall"
 	"testing"
 	"time"
 
+	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/ponrove/configura"
 	"github.com/stretchr/testify/assert"
@@ -312,3 +314,271 @@ func TestCaptureResponseWriter_Write_PreWriteHeader(t *testing.T) {
 	assert.Equal(t, http.StatusCreated, rr.Code)
 	assert.Equal(t, string(testBody), rr.Body.String())
 }
+
+func TestCaptureResponseWriter_FlushNotSupported(t *testing.T) {
+	// The embedded interface hides the Flush method of the recorder.
+	rr := httptest.NewRecorder()
+	crw := &captureResponseWriter{ResponseWriter: struct{ http.ResponseWriter }{rr}}
+
+	assert.ErrorIs(t, http.NewResponseController(crw).Flush(), http.ErrNotSupported)
+	assert.False(t, crw.streamed, "A response that wasn't flushed isn't streamed")
+	assert.False(t, rr.Flushed)
+}
+
+func TestLogRequest_EdgeLatency(t *testing.T) {
+	receivedAt := time.Now().Add(-250 * time.Millisecond)
+
+	tests := []struct {
+		name          string
+		header        string
+		expectPresent bool
+	}{
+		{name: "nginx seconds format", header: fmt.Sprintf("t=%d.%06d", receivedAt.Unix(), receivedAt.Nanosecond()/1000), expectPresent: true},
+		{name: "Milliseconds", header: strconv.FormatInt(receivedAt.UnixMilli(), 10), expectPresent: true},
+		{name: "Microseconds with prefix", header: "t=" + strconv.FormatInt(receivedAt.UnixMicro(), 10), expectPresent: true},
+		{name: "Missing header", header: "", expectPresent: false},
+		{name: "Invalid header", header: "t=yesterday", expectPresent: false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var logBuffer bytes.Buffer
+			originalDefaultLogger := slog.Default()
+			slog.SetDefault(slog.New(slog.NewJSONHandler(&logBuffer, nil)))
+			t.Cleanup(func() { slog.SetDefault(originalDefaultLogger) })
+
+			handler := LogRequest(defaultLogRequestConfig())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+			req := httptest.NewRequest(http.MethodGet, "/queued", nil)
+			if tc.header != "" {
+				req.Header.Set("X-Request-Start", tc.header)
+			}
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			var logged map[string]any
+			require.NoError(t, json.Unmarshal(logBuffer.Bytes(), &logged))
+
+			edgeLatency, ok := logged["edge_latency"]
+			if !tc.expectPresent {
+				assert.False(t, ok, "edge_latency should be absent")
+				return
+			}
+			require.True(t, ok, "edge_latency should be present")
+			latency := time.Duration(edgeLatency.(float64))
+			assert.GreaterOrEqual(t, latency, 249*time.Millisecond)
+			assert.Less(t, latency, 5*time.Second)
+		})
+	}
+}
+
+func TestLogRequest_QueryParams(t *testing.T) {
+	var logBuffer bytes.Buffer
+	originalDefaultLogger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&logBuffer, nil)))
+	t.Cleanup(func() { slog.SetDefault(originalDefaultLogger) })
+
+	cfg := defaultLogRequestConfig()
+	err := configura.WriteConfiguration(cfg, map[configura.Variable[string]]string{
+		REQUEST_LOG_QUERY_PARAMS: "tenant, page, token, missing",
+	})
+	require.NoError(t, err)
+
+	handler := LogRequest(cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	req := httptest.NewRequest(http.MethodGet, "/search?tenant=acme&page=2&token=s3cr3t&sort=desc", nil)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	var logged map[string]any
+	require.NoError(t, json.Unmarshal(logBuffer.Bytes(), &logged))
+
+	assert.Equal(t, "acme", logged["query_tenant"])
+	assert.Equal(t, "2", logged["query_page"])
+	assert.Equal(t, "[REDACTED]", logged["query_token"], "Credential parameters should be redacted")
+	assert.NotContains(t, logged, "query_missing", "Missing parameters should produce no field")
+	assert.NotContains(t, logged, "query_sort", "Parameters that aren't configured should not be logged")
+}
+
+func TestLogRequest_Cookies(t *testing.T) {
+	var logBuffer bytes.Buffer
+	originalDefaultLogger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&logBuffer, nil)))
+	t.Cleanup(func() { slog.SetDefault(originalDefaultLogger) })
+
+	cfg := defaultLogRequestConfig()
+	err := configura.WriteConfiguration(cfg, map[configura.Variable[string]]string{
+		REQUEST_LOG_COOKIE_NAMES: "theme, experiment, session, missing",
+	})
+	require.NoError(t, err)
+
+	handler := LogRequest(cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(&http.Cookie{Name: "theme", Value: "dark"})
+	req.AddCookie(&http.Cookie{Name: "experiment", Value: "b"})
+	req.AddCookie(&http.Cookie{Name: "session", Value: "s3cr3t"})
+	req.AddCookie(&http.Cookie{Name: "sid", Value: "s3cr3t"})
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	var logged map[string]any
+	require.NoError(t, json.Unmarshal(logBuffer.Bytes(), &logged))
+
+	assert.Equal(t, "dark", logged["cookie_theme"])
+	assert.Equal(t, "b", logged["cookie_experiment"])
+	assert.Equal(t, "[REDACTED]", logged["cookie_session"], "Credential cookies should be redacted")
+	assert.NotContains(t, logged, "cookie_missing", "Missing cookies should produce no field")
+	assert.NotContains(t, logged, "cookie_sid", "Cookies that aren't allowlisted should not be logged")
+	assert.NotContains(t, logBuffer.String(), "s3cr3t")
+}
+
+func TestLogRequest_RouteParams(t *testing.T) {
+	var logBuffer bytes.Buffer
+	originalDefaultLogger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&logBuffer, nil)))
+	t.Cleanup(func() { slog.SetDefault(originalDefaultLogger) })
+
+	cfg := defaultLogRequestConfig()
+	err := configura.WriteConfiguration(cfg, map[configura.Variable[bool]]bool{
+		REQUEST_LOG_ROUTE_PARAMS: true,
+	})
+	require.NoError(t, err)
+
+	router := chi.NewRouter()
+	router.Use(LogRequest(cfg))
+	router.Get("/users/{id}/sessions/{session}", func(w http.ResponseWriter, r *http.Request) {})
+	router.Get("/health", func(w http.ResponseWriter, r *http.Request) {})
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users/123/sessions/s3cr3t", nil))
+
+	var logged map[string]any
+	require.NoError(t, json.Unmarshal(logBuffer.Bytes(), &logged))
+	assert.Equal(t, map[string]any{"id": "123", "session": "[REDACTED]"}, logged["route_params"], "Credential parameters should be redacted")
+
+	logBuffer.Reset()
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/health", nil))
+	logged = nil
+	require.NoError(t, json.Unmarshal(logBuffer.Bytes(), &logged))
+	assert.NotContains(t, logged, "route_params", "Routes without parameters should produce no field")
+}
+
+func TestLogRequest_RouteParamsDisabled(t *testing.T) {
+	var logBuffer bytes.Buffer
+	originalDefaultLogger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&logBuffer, nil)))
+	t.Cleanup(func() { slog.SetDefault(originalDefaultLogger) })
+
+	router := chi.NewRouter()
+	router.Use(LogRequest(defaultLogRequestConfig()))
+	router.Get("/users/{id}", func(w http.ResponseWriter, r *http.Request) {})
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users/123", nil))
+
+	var logged map[string]any
+	require.NoError(t, json.Unmarshal(logBuffer.Bytes(), &logged))
+	assert.NotContains(t, logged, "route_params", "Route parameters should only be logged when enabled")
+}
+
+// brokenPipeResponseWriter simulates a client that disconnected after the headers were written.
+type brokenPipeResponseWriter struct {
+	*httptest.ResponseRecorder
+}
+
+func (w brokenPipeResponseWriter) Write(b []byte) (int, error) {
+	return 0, syscall.EPIPE
+}
+
+func TestLogRequest_WriteError(t *testing.T) {
+	var logBuffer bytes.Buffer
+	originalDefaultLogger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&logBuffer, nil)))
+	t.Cleanup(func() { slog.SetDefault(originalDefaultLogger) })
+
+	var handlerErr error
+	handler := LogRequest(defaultLogRequestConfig())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, handlerErr = w.Write([]byte("partial response"))
+	}))
+	handler.ServeHTTP(brokenPipeResponseWriter{httptest.NewRecorder()}, httptest.NewRequest(http.MethodGet, "/download", nil))
+
+	assert.ErrorIs(t, handlerErr, syscall.EPIPE, "The write error should still reach the handler")
+
+	var logged map[string]any
+	require.NoError(t, json.Unmarshal(logBuffer.Bytes(), &logged))
+	assert.Equal(t, syscall.EPIPE.Error(), logged["write_error"])
+	assert.Equal(t, float64(0), logged["response_size"])
+}
+
+func TestLogRequest_NoWriteError(t *testing.T) {
+	var logBuffer bytes.Buffer
+	originalDefaultLogger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&logBuffer, nil)))
+	t.Cleanup(func() { slog.SetDefault(originalDefaultLogger) })
+
+	handler := LogRequest(defaultLogRequestConfig())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	var logged map[string]any
+	require.NoError(t, json.Unmarshal(logBuffer.Bytes(), &logged))
+	assert.NotContains(t, logged, "write_error")
+}
+
+func TestLogRequest_Streamed(t *testing.T) {
+	var logBuffer bytes.Buffer
+	originalDefaultLogger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&logBuffer, nil)))
+	t.Cleanup(func() { slog.SetDefault(originalDefaultLogger) })
+
+	const events = 5
+	handler := LogRequest(defaultLogRequestConfig())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		flusher, ok := w.(http.Flusher)
+		require.True(t, ok, "The response writer should support flushing")
+		for i := range events {
+			_, _ = fmt.Fprintf(w, "data: event %d\n\n", i)
+			flusher.Flush()
+			time.Sleep(5 * time.Millisecond)
+		}
+	}))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/events", nil))
+
+	assert.True(t, rr.Flushed, "The flushes should reach the underlying writer")
+
+	var logged map[string]any
+	require.NoError(t, json.Unmarshal(logBuffer.Bytes(), &logged))
+	assert.Equal(t, float64(rr.Body.Len()), logged["response_size"], "The logged size should match the bytes streamed")
+	assert.Equal(t, true, logged["streamed"])
+	assert.Equal(t, float64(http.StatusOK), logged["status_code"])
+	assert.GreaterOrEqual(t, time.Duration(logged["duration"].(float64)), events*5*time.Millisecond,
+		"The duration should cover the full stream")
+}
+
+func TestLogRequest_TLSServerName(t *testing.T) {
+	var logBuffer bytes.Buffer
+	originalDefaultLogger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&logBuffer, nil)))
+	t.Cleanup(func() { slog.SetDefault(originalDefaultLogger) })
+
+	handler := LogRequest(defaultLogRequestConfig())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+	ts := httptest.NewTLSServer(handler)
+	defer ts.Close()
+
+	// The test certificate is valid for example.com, which the client requests through SNI.
+	client := ts.Client()
+	transport := client.Transport.(*http.Transport).Clone()
+	transport.TLSClientConfig.ServerName = "example.com"
+	client.Transport = transport
+
+	resp, err := client.Get(ts.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	var logged map[string]any
+	require.NoError(t, json.Unmarshal(logBuffer.Bytes(), &logged))
+	assert.Equal(t, "example.com", logged["tls_server_name"])
+
+	logBuffer.Reset()
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	var plainLogged map[string]any
+	require.NoError(t, json.Unmarshal(logBuffer.Bytes(), &plainLogged))
+	assert.NotContains(t, plainLogged, "tls_server_name", "The field should be omitted for plain HTTP")
+}
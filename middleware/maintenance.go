@@ -0,0 +1,129 @@
+package middleware
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"sync/atomic"
+
+	"github.com/open-feature/go-sdk/openfeature"
+	"github.com/ponrove/configura"
+	slogctx "github.com/veqryn/slog-context"
+)
+
+const (
+	MAINTENANCE_ENABLED      configura.Variable[bool]   = "MAINTENANCE_ENABLED"      // Start the service in maintenance mode
+	MAINTENANCE_FLAG         configura.Variable[string] = "MAINTENANCE_FLAG"         // OpenFeature boolean flag turning maintenance mode on, empty for none
+	MAINTENANCE_MESSAGE      configura.Variable[string] = "MAINTENANCE_MESSAGE"      // Detail of the 503 response while in maintenance mode
+	MAINTENANCE_EXEMPT_PATHS configura.Variable[string] = "MAINTENANCE_EXEMPT_PATHS" // Comma separated paths served in maintenance mode, defaults to the internal endpoints
+	MAINTENANCE_ADMIN_PATH   configura.Variable[string] = "MAINTENANCE_ADMIN_PATH"   // Path of the endpoint toggling maintenance mode, empty disables it
+	MAINTENANCE_ADMIN_TOKEN  configura.Variable[string] = "MAINTENANCE_ADMIN_TOKEN"  // Bearer token required to toggle maintenance mode, required with MAINTENANCE_ADMIN_PATH
+)
+
+// ErrMissingMaintenanceAdminToken is returned by Start when MAINTENANCE_ADMIN_PATH is set without
+// MAINTENANCE_ADMIN_TOKEN, as anyone could then put the service in maintenance.
+var ErrMissingMaintenanceAdminToken = errors.New("MAINTENANCE_ADMIN_PATH is set without MAINTENANCE_ADMIN_TOKEN")
+
+// maintenance is whether maintenance mode was turned on, with SetMaintenance or the admin endpoint.
+var maintenance atomic.Bool
+
+// SetMaintenance turns maintenance mode on or off, for the Maintenance middleware.
+func SetMaintenance(enabled bool) {
+	maintenance.Store(enabled)
+}
+
+// InMaintenance reports whether maintenance mode was turned on with SetMaintenance, the admin endpoint or
+// MAINTENANCE_ENABLED. The MAINTENANCE_FLAG feature flag is evaluated per request, and isn't reflected.
+func InMaintenance() bool {
+	return maintenance.Load()
+}
+
+// inMaintenance reports whether maintenance mode is on for the request, either toggled or through the feature flag.
+func inMaintenance(ctx context.Context, client *openfeature.Client, flag string) bool {
+	if maintenance.Load() {
+		return true
+	}
+	if client == nil {
+		return false
+	}
+	// A flag that can't be evaluated leaves the service up.
+	return client.Boolean(ctx, flag, false, openfeature.EvaluationContext{})
+}
+
+// Maintenance is a middleware putting the service in maintenance mode without a redeploy: requests are rejected with
+// 503 Service Unavailable and MAINTENANCE_MESSAGE as detail, except for the paths in MAINTENANCE_EXEMPT_PATHS and
+// MAINTENANCE_ADMIN_PATH. The exempt paths default to the internalPaths of the server (the health checks, metrics and
+// version endpoints, /livez and /readyz if none is given), so the orchestrator doesn't restart a service in maintenance.
+// Maintenance mode is on while the OpenFeature flag MAINTENANCE_FLAG is true, or once turned on with SetMaintenance, the
+// MaintenanceAdmin endpoint or MAINTENANCE_ENABLED at startup. The mode is reset to MAINTENANCE_ENABLED when the
+// middleware is built, so a server started after another one doesn't inherit its mode.
+func Maintenance(cfg configura.Config, internalPaths ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		SetMaintenance(cfg.Bool(MAINTENANCE_ENABLED))
+		var client *openfeature.Client
+		flag := cfg.String(MAINTENANCE_FLAG)
+		if flag != "" {
+			client = openfeature.NewClient("ponrunner-maintenance")
+		}
+		exempt := exemptPaths(cfg.String(MAINTENANCE_EXEMPT_PATHS), internalPaths)
+		if path := cfg.String(MAINTENANCE_ADMIN_PATH); path != "" {
+			exempt = append(exempt, path)
+		}
+		message := configura.Fallback(cfg.String(MAINTENANCE_MESSAGE), "The service is under maintenance")
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if slices.Contains(exempt, r.URL.Path) || !inMaintenance(r.Context(), client, flag) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			Reject(cfg, w, r, http.StatusServiceUnavailable, message)
+		})
+	}
+}
+
+// maintenanceState is the body of the MaintenanceAdmin endpoint.
+type maintenanceState struct {
+	Maintenance bool `json:"maintenance"`
+}
+
+// MaintenanceAdmin returns the handler of the admin endpoint toggling maintenance mode, served on
+// MAINTENANCE_ADMIN_PATH. GET returns whether maintenance mode is on, as `{"maintenance": true}`, and PUT turns it on or
+// off with the same body. PUT requests must carry MAINTENANCE_ADMIN_TOKEN as a bearer token, and are forbidden if it is
+// not set.
+func MaintenanceAdmin(cfg configura.Config) http.Handler {
+	token := cfg.String(MAINTENANCE_ADMIN_TOKEN)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead:
+		case http.MethodPut:
+			if token == "" {
+				Reject(cfg, w, r, http.StatusForbidden, "maintenance mode can't be toggled without MAINTENANCE_ADMIN_TOKEN")
+				return
+			}
+			bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || subtle.ConstantTimeCompare([]byte(bearer), []byte(token)) != 1 {
+				Reject(cfg, w, r, http.StatusUnauthorized, "a valid bearer token is required")
+				return
+			}
+			var state maintenanceState
+			if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1024)).Decode(&state); err != nil {
+				Reject(cfg, w, r, http.StatusBadRequest, "the body must be {\"maintenance\": true|false}")
+				return
+			}
+			SetMaintenance(state.Maintenance)
+			slogctx.FromCtx(r.Context()).Info("Maintenance mode toggled", slog.Bool("maintenance", state.Maintenance))
+		default:
+			w.Header().Set("Allow", "GET, HEAD, PUT")
+			Reject(cfg, w, r, http.StatusMethodNotAllowed, "")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(maintenanceState{Maintenance: InMaintenance()})
+	})
+}
@@ -0,0 +1,111 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/ponrove/configura"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func maintenanceRouter(t *testing.T, token string) http.Handler {
+	t.Helper()
+	// Not parallel, maintenance mode is global.
+	t.Cleanup(func() { SetMaintenance(false) })
+
+	cfg := configura.NewConfigImpl()
+	err := configura.WriteConfiguration(cfg, map[configura.Variable[string]]string{
+		MAINTENANCE_ADMIN_PATH:  "/admin/maintenance",
+		MAINTENANCE_ADMIN_TOKEN: token,
+	})
+	require.NoError(t, err)
+
+	router := chi.NewRouter()
+	router.Use(Maintenance(cfg))
+	ok := func(w http.ResponseWriter, r *http.Request) { _, _ = w.Write([]byte("ok")) }
+	router.Get("/readyz", ok)
+	router.Get("/users", ok)
+	router.Handle("/admin/maintenance", MaintenanceAdmin(cfg))
+	return router
+}
+
+func serveMaintenance(handler http.Handler, method, path, body, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestMaintenance(t *testing.T) {
+	router := maintenanceRouter(t, "secret")
+
+	assert.Equal(t, http.StatusOK, serveMaintenance(router, http.MethodGet, "/users", "", "").Code)
+
+	rr := serveMaintenance(router, http.MethodPut, "/admin/maintenance", `{"maintenance": true}`, "secret")
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"maintenance": true}`, rr.Body.String())
+
+	rr = serveMaintenance(router, http.MethodGet, "/users", "", "")
+	assert.Equal(t, http.StatusServiceUnavailable, rr.Code, "App routes should be rejected in maintenance mode")
+	assert.Contains(t, rr.Body.String(), "The service is under maintenance")
+	assert.Equal(t, http.StatusOK, serveMaintenance(router, http.MethodGet, "/readyz", "", "").Code, "Health checks should be served")
+	assert.JSONEq(t, `{"maintenance": true}`, serveMaintenance(router, http.MethodGet, "/admin/maintenance", "", "").Body.String())
+
+	require.Equal(t, http.StatusOK, serveMaintenance(router, http.MethodPut, "/admin/maintenance", `{"maintenance": false}`, "secret").Code)
+	assert.Equal(t, http.StatusOK, serveMaintenance(router, http.MethodGet, "/users", "", "").Code)
+}
+
+func TestMaintenanceAdmin_Token(t *testing.T) {
+	router := maintenanceRouter(t, "secret")
+
+	assert.Equal(t, http.StatusUnauthorized, serveMaintenance(router, http.MethodPut, "/admin/maintenance", `{"maintenance": true}`, "").Code)
+	assert.Equal(t, http.StatusUnauthorized, serveMaintenance(router, http.MethodPut, "/admin/maintenance", `{"maintenance": true}`, "wrong").Code)
+	assert.False(t, InMaintenance())
+
+	assert.Equal(t, http.StatusOK, serveMaintenance(router, http.MethodPut, "/admin/maintenance", `{"maintenance": true}`, "secret").Code)
+	assert.True(t, InMaintenance())
+}
+
+func TestMaintenanceAdmin_NoToken(t *testing.T) {
+	router := maintenanceRouter(t, "")
+
+	assert.Equal(t, http.StatusForbidden, serveMaintenance(router, http.MethodPut, "/admin/maintenance", `{"maintenance": true}`, "").Code)
+	assert.False(t, InMaintenance(), "Maintenance mode can't be toggled without a token")
+	assert.Equal(t, http.StatusOK, serveMaintenance(router, http.MethodGet, "/admin/maintenance", "", "").Code)
+}
+
+func TestMaintenanceAdmin_InvalidBody(t *testing.T) {
+	router := maintenanceRouter(t, "secret")
+
+	assert.Equal(t, http.StatusBadRequest, serveMaintenance(router, http.MethodPut, "/admin/maintenance", `on`, "secret").Code)
+	assert.Equal(t, http.StatusMethodNotAllowed, serveMaintenance(router, http.MethodPost, "/admin/maintenance", `{}`, "secret").Code)
+	assert.False(t, InMaintenance())
+}
+
+func TestMaintenance_ResetWhenBuilt(t *testing.T) {
+	// Not parallel, maintenance mode is global.
+	t.Cleanup(func() { SetMaintenance(false) })
+
+	enabled := configura.NewConfigImpl()
+	err := configura.WriteConfiguration(enabled, map[configura.Variable[bool]]bool{
+		MAINTENANCE_ENABLED: true,
+	})
+	require.NoError(t, err)
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	first := Maintenance(enabled)(ok)
+	assert.True(t, InMaintenance(), "MAINTENANCE_ENABLED should turn maintenance mode on")
+	assert.Equal(t, http.StatusServiceUnavailable, serveMaintenance(first, http.MethodGet, "/users", "", "").Code)
+
+	// A second server in the same process starts with its own configuration, not the mode of the first one.
+	second := Maintenance(configura.NewConfigImpl())(ok)
+	assert.False(t, InMaintenance())
+	assert.Equal(t, http.StatusOK, serveMaintenance(second, http.MethodGet, "/users", "", "").Code)
+}
@@ -0,0 +1,178 @@
+package middleware
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/ponrove/configura"
+	"github.com/ponrove/ponrunner/utils"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/metric"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+)
+
+const (
+	METRICS_EXCLUDE_PATHS    configura.Variable[string] = "METRICS_EXCLUDE_PATHS"    // Comma separated routes recorded under the "other" route label
+	METRICS_DURATION_BUCKETS configura.Variable[string] = "METRICS_DURATION_BUCKETS" // Comma separated bucket boundaries of the request duration histogram, in seconds
+	METRICS_SIZE_BUCKETS     configura.Variable[string] = "METRICS_SIZE_BUCKETS"     // Comma separated bucket boundaries of the body size histograms, in bytes
+)
+
+var (
+	// defaultDurationBuckets are the bucket boundaries of the request duration, as recommended by the semantic
+	// conventions of the HTTP metrics.
+	defaultDurationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.075, 0.1, 0.25, 0.5, 0.75, 1, 2.5, 5, 7.5, 10}
+	// defaultSizeBuckets are the bucket boundaries of the body sizes, from 100B to 10MB.
+	defaultSizeBuckets = []float64{100, 1000, 10_000, 100_000, 1_000_000, 10_000_000}
+)
+
+const (
+	// metricsInstrumentationName is the instrumentation scope of the request metrics.
+	metricsInstrumentationName = "github.com/ponrove/ponrunner/middleware"
+	// metricsOtherRoute is the route label excluded (and unmatched) requests are recorded under.
+	metricsOtherRoute = "other"
+)
+
+// Metrics is a middleware that records request metrics with the global OpenTelemetry meter provider: the duration of
+// the requests (http.server.request.duration), the size of their request and response bodies
+// (http.server.request.body.size and http.server.response.body.size), labelled with the method, route pattern and
+// status code of the request, and the requests in flight (http.server.active_requests), labelled with the method.
+// Routes listed in METRICS_EXCLUDE_PATHS, matched against the route pattern or the request path, are aggregated under
+// the "other" route label to bound the cardinality of high-traffic internal routes. The bucket boundaries of the
+// histograms are set with METRICS_DURATION_BUCKETS and METRICS_SIZE_BUCKETS.
+func Metrics(cfg configura.Config) func(http.Handler) http.Handler {
+	return newMetrics(cfg, otel.GetMeterProvider())
+}
+
+// metricsBuckets parses the comma separated, increasing bucket boundaries of a histogram. If value is empty, or
+// invalid, which is reported to the OpenTelemetry error handler, defaults are returned.
+func metricsBuckets(key configura.Variable[string], value string, defaults []float64) []float64 {
+	fields := utils.SplitCommaSeparated(value)
+	if len(fields) == 0 {
+		return defaults
+	}
+	buckets := make([]float64, 0, len(fields))
+	for _, field := range fields {
+		bucket, err := strconv.ParseFloat(field, 64)
+		if err != nil {
+			otel.Handle(fmt.Errorf("invalid %s %q, using the default buckets: %w", key, value, err))
+			return defaults
+		}
+		buckets = append(buckets, bucket)
+	}
+	if !sort.Float64sAreSorted(buckets) {
+		otel.Handle(fmt.Errorf("invalid %s %q, using the default buckets: the boundaries must be increasing", key, value))
+		return defaults
+	}
+	return buckets
+}
+
+// countingReader counts the bytes read from a request body.
+type countingReader struct {
+	io.ReadCloser
+	n int64
+}
+
+// Read reads from the body, counting the bytes read.
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.ReadCloser.Read(p)
+	cr.n += int64(n)
+	return n, err
+}
+
+// newMetrics returns the Metrics middleware, recording with the given meter provider.
+func newMetrics(cfg configura.Config, mp metric.MeterProvider) func(http.Handler) http.Handler {
+	excluded := make(map[string]struct{})
+	for _, path := range utils.SplitCommaSeparated(cfg.String(METRICS_EXCLUDE_PATHS)) {
+		excluded[path] = struct{}{}
+	}
+	sizeBuckets := metricsBuckets(METRICS_SIZE_BUCKETS, cfg.String(METRICS_SIZE_BUCKETS), defaultSizeBuckets)
+
+	meter := mp.Meter(metricsInstrumentationName)
+	duration, err := meter.Float64Histogram("http.server.request.duration",
+		metric.WithDescription("Duration of HTTP server requests."),
+		metric.WithUnit("s"),
+		metric.WithExplicitBucketBoundaries(metricsBuckets(METRICS_DURATION_BUCKETS, cfg.String(METRICS_DURATION_BUCKETS), defaultDurationBuckets)...),
+	)
+	if err != nil {
+		otel.Handle(err)
+	}
+	requestSize, err := meter.Int64Histogram("http.server.request.body.size",
+		metric.WithDescription("Size of HTTP server request bodies."),
+		metric.WithUnit("By"),
+		metric.WithExplicitBucketBoundaries(sizeBuckets...),
+	)
+	if err != nil {
+		otel.Handle(err)
+	}
+	responseSize, err := meter.Int64Histogram("http.server.response.body.size",
+		metric.WithDescription("Size of HTTP server response bodies."),
+		metric.WithUnit("By"),
+		metric.WithExplicitBucketBoundaries(sizeBuckets...),
+	)
+	if err != nil {
+		otel.Handle(err)
+	}
+	activeRequests, err := meter.Int64UpDownCounter("http.server.active_requests",
+		metric.WithDescription("Number of active HTTP server requests."),
+		metric.WithUnit("{request}"),
+	)
+	if err != nil {
+		otel.Handle(err)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// The route isn't known until the request is routed, the requests in flight are only labelled with the method.
+			active := metric.WithAttributes(semconv.HTTPRequestMethodKey.String(r.Method))
+			activeRequests.Add(r.Context(), 1, active)
+			defer activeRequests.Add(r.Context(), -1, active)
+
+			start := time.Now()
+			var body *countingReader
+			if r.Body != nil && r.Body != http.NoBody {
+				body = &countingReader{ReadCloser: r.Body}
+				r.Body = body
+			}
+			crw := &captureResponseWriter{ResponseWriter: w}
+			next.ServeHTTP(crw, r)
+
+			route := metricsRoute(r, excluded)
+			attrs := metric.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(r.Method),
+				semconv.HTTPRouteKey.String(route),
+				semconv.HTTPResponseStatusCodeKey.Int(max(crw.statusCode, http.StatusOK)),
+			)
+			duration.Record(r.Context(), time.Since(start).Seconds(), attrs)
+			var requestBytes int64
+			if body != nil {
+				requestBytes = body.n
+			}
+			requestSize.Record(r.Context(), requestBytes, attrs)
+			responseSize.Record(r.Context(), int64(crw.size), attrs)
+		})
+	}
+}
+
+// metricsRoute returns the route label of the request, which is the route pattern matched by chi. Excluded routes, and
+// requests that didn't match a route, are labelled "other".
+func metricsRoute(r *http.Request, excluded map[string]struct{}) string {
+	var route string
+	if rctx := chi.RouteContext(r.Context()); rctx != nil {
+		route = rctx.RoutePattern()
+	}
+	if route == "" {
+		return metricsOtherRoute
+	}
+	if _, ok := excluded[route]; ok {
+		return metricsOtherRoute
+	}
+	if _, ok := excluded[r.URL.Path]; ok {
+		return metricsOtherRoute
+	}
+	return route
+}
@@ -0,0 +1,178 @@
+package middleware
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/ponrove/configura"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+)
+
+// recordedRoutes collects the request count per route label from the duration histogram.
+func recordedRoutes(t *testing.T, reader *sdkmetric.ManualReader) map[string]uint64 {
+	t.Helper()
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+
+	routes := make(map[string]uint64)
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name != "http.server.request.duration" {
+				continue
+			}
+			hist, ok := m.Data.(metricdata.Histogram[float64])
+			require.True(t, ok, "Duration should be a float64 histogram")
+			for _, dp := range hist.DataPoints {
+				route, _ := dp.Attributes.Value(semconv.HTTPRouteKey)
+				routes[route.AsString()] += dp.Count
+			}
+		}
+	}
+	return routes
+}
+
+func TestMetrics_ExcludePaths(t *testing.T) {
+	cfg := configura.NewConfigImpl()
+	err := configura.WriteConfiguration(cfg, map[configura.Variable[string]]string{
+		METRICS_EXCLUDE_PATHS: "/internal/cache/{key}, /internal/ping",
+	})
+	require.NoError(t, err)
+
+	reader := sdkmetric.NewManualReader()
+	r := chi.NewRouter()
+	r.Use(newMetrics(cfg, sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))))
+	ok := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) }
+	r.Get("/users/{id}", ok)
+	r.Get("/internal/cache/{key}", ok)
+	r.Get("/internal/ping", ok)
+
+	for _, path := range []string{"/users/1", "/users/2", "/internal/cache/a", "/internal/cache/b", "/internal/ping"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	routes := recordedRoutes(t, reader)
+	assert.Equal(t, map[string]uint64{
+		"/users/{id}": 2,
+		"other":       3,
+	}, routes, "Excluded routes should aggregate under the other label")
+}
+
+func TestMetrics_PerRouteByDefault(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	r := chi.NewRouter()
+	r.Use(newMetrics(configura.NewConfigImpl(), sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))))
+	r.Get("/internal/ping", func(w http.ResponseWriter, r *http.Request) {})
+
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/internal/ping", nil))
+
+	assert.Equal(t, map[string]uint64{"/internal/ping": 1}, recordedRoutes(t, reader))
+}
+
+// collectMetrics collects the metrics of the middleware by name.
+func collectMetrics(t *testing.T, reader *sdkmetric.ManualReader) map[string]metricdata.Metrics {
+	t.Helper()
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+
+	metrics := make(map[string]metricdata.Metrics)
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			metrics[m.Name] = m
+		}
+	}
+	return metrics
+}
+
+func TestMetrics_Instruments(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	r := chi.NewRouter()
+	r.Use(newMetrics(configura.NewConfigImpl(), sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))))
+
+	var activeDuringRequest int64
+	r.Post("/orders/{id}", func(w http.ResponseWriter, r *http.Request) {
+		active := collectMetrics(t, reader)["http.server.active_requests"].Data.(metricdata.Sum[int64])
+		require.Len(t, active.DataPoints, 1)
+		activeDuringRequest = active.DataPoints[0].Value
+
+		_, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte("created"))
+	})
+
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/orders/42", strings.NewReader(`{"item":1}`)))
+	assert.Equal(t, int64(1), activeDuringRequest, "The request should be active while it's served")
+
+	metrics := collectMetrics(t, reader)
+	expectedAttrs := attribute.NewSet(
+		semconv.HTTPRequestMethodKey.String(http.MethodPost),
+		semconv.HTTPRouteKey.String("/orders/{id}"),
+		semconv.HTTPResponseStatusCodeKey.Int(http.StatusCreated),
+	)
+
+	duration := metrics["http.server.request.duration"].Data.(metricdata.Histogram[float64])
+	require.Len(t, duration.DataPoints, 1)
+	assert.Equal(t, expectedAttrs, duration.DataPoints[0].Attributes)
+	assert.Equal(t, uint64(1), duration.DataPoints[0].Count)
+	assert.Equal(t, defaultDurationBuckets, duration.DataPoints[0].Bounds)
+
+	requestSize := metrics["http.server.request.body.size"].Data.(metricdata.Histogram[int64])
+	require.Len(t, requestSize.DataPoints, 1)
+	assert.Equal(t, expectedAttrs, requestSize.DataPoints[0].Attributes)
+	assert.Equal(t, int64(len(`{"item":1}`)), requestSize.DataPoints[0].Sum)
+
+	responseSize := metrics["http.server.response.body.size"].Data.(metricdata.Histogram[int64])
+	require.Len(t, responseSize.DataPoints, 1)
+	assert.Equal(t, expectedAttrs, responseSize.DataPoints[0].Attributes)
+	assert.Equal(t, int64(len("created")), responseSize.DataPoints[0].Sum)
+
+	active := metrics["http.server.active_requests"].Data.(metricdata.Sum[int64])
+	require.Len(t, active.DataPoints, 1)
+	assert.Equal(t, int64(0), active.DataPoints[0].Value, "No request should be active once served")
+	method, _ := active.DataPoints[0].Attributes.Value(semconv.HTTPRequestMethodKey)
+	assert.Equal(t, http.MethodPost, method.AsString())
+}
+
+func TestMetrics_Buckets(t *testing.T) {
+	tests := []struct {
+		name             string
+		duration         string
+		size             string
+		expectedDuration []float64
+		expectedSize     []float64
+	}{
+		{name: "Defaults", expectedDuration: defaultDurationBuckets, expectedSize: defaultSizeBuckets},
+		{name: "Configured", duration: "0.1, 0.5, 1", size: "1024,1048576", expectedDuration: []float64{0.1, 0.5, 1}, expectedSize: []float64{1024, 1048576}},
+		{name: "Invalid boundaries fall back to the defaults", duration: "0.1,fast", size: "1000,10", expectedDuration: defaultDurationBuckets, expectedSize: defaultSizeBuckets},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := configura.NewConfigImpl()
+			err := configura.WriteConfiguration(cfg, map[configura.Variable[string]]string{
+				METRICS_DURATION_BUCKETS: tc.duration,
+				METRICS_SIZE_BUCKETS:     tc.size,
+			})
+			require.NoError(t, err)
+
+			reader := sdkmetric.NewManualReader()
+			handler := newMetrics(cfg, sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+			metrics := collectMetrics(t, reader)
+			duration := metrics["http.server.request.duration"].Data.(metricdata.Histogram[float64])
+			assert.Equal(t, tc.expectedDuration, duration.DataPoints[0].Bounds)
+			responseSize := metrics["http.server.response.body.size"].Data.(metricdata.Histogram[int64])
+			assert.Equal(t, tc.expectedSize, responseSize.DataPoints[0].Bounds)
+		})
+	}
+}
@@ -0,0 +1,151 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ponrove/configura"
+	"github.com/ponrove/ponrunner/utils"
+	slogctx "github.com/veqryn/slog-context"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+const (
+	MIRROR_URL            configura.Variable[string]  = "MIRROR_URL"            // Base URL of the shadow backend requests are mirrored to, empty disables
+	MIRROR_PERCENT        configura.Variable[float64] = "MIRROR_PERCENT"        // Percentage of requests mirrored, from 0 to 100
+	MIRROR_TIMEOUT        configura.Variable[int64]   = "MIRROR_TIMEOUT"        // Seconds a mirrored request may take, defaults to 5
+	MIRROR_MAX_BODY_BYTES configura.Variable[int64]   = "MIRROR_MAX_BODY_BYTES" // Largest request body mirrored, defaults to 1MB
+	MIRROR_MAX_IN_FLIGHT  configura.Variable[int64]   = "MIRROR_MAX_IN_FLIGHT"  // Most mirrored requests in flight, further ones aren't mirrored, defaults to 100
+	MIRROR_HEADERS        configura.Variable[string]  = "MIRROR_HEADERS"        // Comma separated request headers copied to the mirrored requests, defaults to the ones describing the request
+)
+
+// Defaults of the mirrored requests, if MIRROR_TIMEOUT, MIRROR_MAX_BODY_BYTES or MIRROR_MAX_IN_FLIGHT are not set.
+const (
+	defaultMirrorTimeout      = 5 * time.Second
+	defaultMirrorMaxBodyBytes = 1 << 20
+	defaultMirrorMaxInFlight  = 100
+)
+
+// defaultMirrorHeaders are the request headers copied to the mirrored requests if MIRROR_HEADERS is not set. Only
+// headers describing the request are copied, so the credentials of the clients (e.g. Authorization, Cookie or API
+// keys) never reach the shadow backend.
+var defaultMirrorHeaders = []string{"Accept", "Accept-Language", "Content-Type", "Content-Encoding", "User-Agent", "X-Request-Id"}
+
+// readCloser combines the reader of a request body with the closer of the original body.
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// mirrorBody reads up to maxBytes of the request body, for the mirrored request, and restores the body of the request
+// so the handler reads it in full. It reports false if the body is larger than maxBytes, or can't be read.
+func mirrorBody(r *http.Request, maxBytes int64) ([]byte, bool) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil, true
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxBytes+1))
+	r.Body = readCloser{Reader: io.MultiReader(bytes.NewReader(body), r.Body), Closer: r.Body}
+	return body, err == nil && int64(len(body)) <= maxBytes
+}
+
+// Mirror is a middleware duplicating MIRROR_PERCENT of the requests to the backend at MIRROR_URL, e.g. to test a new
+// backend with real traffic. The path and query of the request are appended to the URL, and its method, the headers
+// in MIRROR_HEADERS and its body (up to MIRROR_MAX_BODY_BYTES, larger requests aren't mirrored) are copied. Mirrored
+// requests are sent in the background with client, which should be instrumented with OpenTelemetry, like
+// ponrunner.NewHTTPClient (a nil client uses an instrumented one of its own), for up to MIRROR_TIMEOUT. Their
+// responses are discarded: the primary response is never affected, and failures are only logged. At most
+// MIRROR_MAX_IN_FLIGHT requests are mirrored at once, further ones aren't while the shadow backend is slow. The
+// middleware is disabled unless MIRROR_URL and MIRROR_PERCENT are set.
+func Mirror(cfg configura.Config, client *http.Client) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		target := cfg.String(MIRROR_URL)
+		percent := cfg.Float64(MIRROR_PERCENT)
+		if target == "" || percent <= 0 {
+			return next
+		}
+		base, err := url.Parse(target)
+		if err != nil || base.Scheme == "" || base.Host == "" {
+			slog.Warn("Invalid mirror URL, requests are not mirrored", slog.String("url", target))
+			return next
+		}
+		maxBodyBytes := configura.Fallback(cfg.Int64(MIRROR_MAX_BODY_BYTES), defaultMirrorMaxBodyBytes)
+		timeout := configura.Fallback(time.Duration(cfg.Int64(MIRROR_TIMEOUT))*time.Second, defaultMirrorTimeout)
+		maxInFlight := cfg.Int64(MIRROR_MAX_IN_FLIGHT)
+		if maxInFlight <= 0 {
+			maxInFlight = defaultMirrorMaxInFlight
+		}
+		inFlight := make(chan struct{}, maxInFlight)
+		headers := utils.SplitCommaSeparated(cfg.String(MIRROR_HEADERS))
+		if len(headers) == 0 {
+			headers = defaultMirrorHeaders
+		}
+		if client == nil {
+			client = &http.Client{Transport: otelhttp.NewTransport(http.DefaultTransport.(*http.Transport).Clone())}
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if rand.Float64()*100 >= percent {
+				next.ServeHTTP(w, r)
+				return
+			}
+			select {
+			case inFlight <- struct{}{}:
+			default:
+				slogctx.FromCtx(r.Context()).Debug("Too many mirrored requests in flight, the request is not mirrored")
+				next.ServeHTTP(w, r)
+				return
+			}
+			body, ok := mirrorBody(r, maxBodyBytes)
+			if !ok {
+				<-inFlight
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			mirrorURL := *base
+			mirrorURL.Path = strings.TrimSuffix(base.Path, "/") + r.URL.Path
+			mirrorURL.RawPath = ""
+			mirrorURL.RawQuery = r.URL.RawQuery
+			// The mirrored request outlives the primary one, but keeps its trace and logger.
+			ctx, cancel := context.WithTimeout(context.WithoutCancel(r.Context()), timeout)
+			req, err := http.NewRequestWithContext(ctx, r.Method, mirrorURL.String(), bytes.NewReader(body))
+			if err != nil {
+				cancel()
+				<-inFlight
+				slogctx.FromCtx(r.Context()).Warn("Failed to mirror request", slog.Any("error", err))
+				next.ServeHTTP(w, r)
+				return
+			}
+			for _, h := range headers {
+				if values := r.Header.Values(h); len(values) > 0 {
+					req.Header[http.CanonicalHeaderKey(h)] = slices.Clone(values)
+				}
+			}
+
+			go func() {
+				defer func() { <-inFlight }()
+				defer cancel()
+				resp, err := client.Do(req)
+				if err != nil {
+					slogctx.FromCtx(req.Context()).Warn("Failed to mirror request",
+						slog.String("method", req.Method),
+						slog.String("path", req.URL.Path),
+						slog.Any("error", err))
+					return
+				}
+				_, _ = io.Copy(io.Discard, resp.Body)
+				_ = resp.Body.Close()
+			}()
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
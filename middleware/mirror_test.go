@@ -0,0 +1,231 @@
+package middleware
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ponrove/configura"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mirrorConfig returns a configuration mirroring percent of the requests to target.
+func mirrorConfig(t *testing.T, target string, percent float64) *configura.ConfigImpl {
+	t.Helper()
+	cfg := configura.NewConfigImpl()
+	err := configura.WriteConfiguration(cfg, map[configura.Variable[string]]string{
+		MIRROR_URL: target,
+	})
+	require.NoError(t, err)
+	err = configura.WriteConfiguration(cfg, map[configura.Variable[float64]]float64{
+		MIRROR_PERCENT: percent,
+	})
+	require.NoError(t, err)
+	return cfg
+}
+
+// primaryHandler echoes the request body, like the primary backend.
+var primaryHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	w.WriteHeader(http.StatusCreated)
+	_, _ = w.Write(append([]byte("primary:"), body...))
+})
+
+func TestMirror_AllRequests(t *testing.T) {
+	var mu sync.Mutex
+	var mirrored []string
+	shadow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		mirrored = append(mirrored, r.Method+" "+r.URL.RequestURI()+" "+r.Header.Get("X-Tenant")+" "+r.Header.Get("Authorization")+" "+string(body))
+		mu.Unlock()
+		// The shadow failing slowly must not affect the primary response.
+		time.Sleep(50 * time.Millisecond)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer shadow.Close()
+
+	cfg := mirrorConfig(t, shadow.URL+"/shadow/", 100)
+	err := configura.WriteConfiguration(cfg, map[configura.Variable[string]]string{
+		MIRROR_URL:     shadow.URL + "/shadow/",
+		MIRROR_HEADERS: "X-Tenant",
+	})
+	require.NoError(t, err)
+	handler := Mirror(cfg, nil)(primaryHandler)
+
+	req := httptest.NewRequest(http.MethodPost, "/orders?dry_run=1", strings.NewReader(`{"id":1}`))
+	req.Header.Set("X-Tenant", "acme")
+	req.Header.Set("Authorization", "Bearer secret")
+	rec := httptest.NewRecorder()
+	start := time.Now()
+	handler.ServeHTTP(rec, req)
+
+	assert.Less(t, time.Since(start), 50*time.Millisecond, "The primary response should not wait for the mirror")
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Equal(t, `primary:{"id":1}`, rec.Body.String(), "The handler should read the full body")
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(mirrored) == 1
+	}, 2*time.Second, 10*time.Millisecond, "The request should be mirrored")
+	assert.Equal(t, `POST /shadow/orders?dry_run=1 acme  {"id":1}`, mirrored[0], "Only the headers in MIRROR_HEADERS should be copied")
+}
+
+func TestMirror_Percentage(t *testing.T) {
+	var mirrored atomic.Int64
+	shadow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mirrored.Add(1)
+	}))
+	defer shadow.Close()
+
+	cfg := mirrorConfig(t, shadow.URL, 25)
+	err := configura.WriteConfiguration(cfg, map[configura.Variable[int64]]int64{
+		MIRROR_MAX_IN_FLIGHT: 1000,
+	})
+	require.NoError(t, err)
+	handler := Mirror(cfg, nil)(primaryHandler)
+	const requests = 1000
+	for range requests {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		require.Equal(t, http.StatusCreated, rec.Code)
+	}
+
+	// 25% of 1000 requests, with a margin of over 7 standard deviations.
+	require.Eventually(t, func() bool {
+		return mirrored.Load() >= 150
+	}, 5*time.Second, 10*time.Millisecond, "About a quarter of the requests should be mirrored")
+	time.Sleep(100 * time.Millisecond)
+	assert.LessOrEqual(t, mirrored.Load(), int64(350), "About a quarter of the requests should be mirrored")
+}
+
+func TestMirror_ShadowUnreachable(t *testing.T) {
+	var logBuffer syncBuffer
+	originalDefaultLogger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&logBuffer, nil)))
+	t.Cleanup(func() { slog.SetDefault(originalDefaultLogger) })
+
+	shadow := httptest.NewServer(http.NotFoundHandler())
+	shadow.Close() // Nothing listens on the shadow URL anymore.
+
+	handler := Mirror(mirrorConfig(t, shadow.URL, 100), nil)(primaryHandler)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/orders", nil))
+
+	assert.Equal(t, http.StatusCreated, rec.Code, "Mirror errors should not be propagated")
+	assert.Eventually(t, func() bool {
+		return strings.Contains(logBuffer.String(), "Failed to mirror request")
+	}, 2*time.Second, 10*time.Millisecond, "Mirror errors should be logged")
+	assert.Contains(t, logBuffer.String(), `"path":"/orders"`)
+}
+
+func TestMirror_LargeBodyNotMirrored(t *testing.T) {
+	var mirrored atomic.Int64
+	shadow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mirrored.Add(1)
+	}))
+	defer shadow.Close()
+
+	cfg := mirrorConfig(t, shadow.URL, 100)
+	err := configura.WriteConfiguration(cfg, map[configura.Variable[int64]]int64{
+		MIRROR_MAX_BODY_BYTES: 4,
+	})
+	require.NoError(t, err)
+	handler := Mirror(cfg, nil)(primaryHandler)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", bytes.NewReader([]byte("too large"))))
+	assert.Equal(t, "primary:too large", rec.Body.String(), "The handler should read the full body")
+	time.Sleep(100 * time.Millisecond)
+	assert.Zero(t, mirrored.Load(), "Requests with a body over the limit should not be mirrored")
+}
+
+func TestMirror_DisabledByDefault(t *testing.T) {
+	handler := Mirror(configura.NewConfigImpl(), nil)(primaryHandler)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusCreated, rec.Code)
+}
+
+func TestMirror_DefaultHeaders(t *testing.T) {
+	headers := make(chan http.Header, 1)
+	shadow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers <- r.Header
+	}))
+	defer shadow.Close()
+
+	handler := Mirror(mirrorConfig(t, shadow.URL, 100), nil)(primaryHandler)
+	req := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(`{"id":1}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer secret")
+	req.Header.Set("Cookie", "session=secret")
+	req.Header.Set("X-Api-Key", "secret")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	select {
+	case header := <-headers:
+		assert.Equal(t, "application/json", header.Get("Content-Type"))
+		assert.Empty(t, header.Get("Authorization"), "Credentials should not reach the shadow backend")
+		assert.Empty(t, header.Get("Cookie"), "Credentials should not reach the shadow backend")
+		assert.Empty(t, header.Get("X-Api-Key"), "Credentials should not reach the shadow backend")
+	case <-time.After(2 * time.Second):
+		t.Fatal("The request was not mirrored")
+	}
+}
+
+func TestMirror_MaxInFlight(t *testing.T) {
+	var mirrored atomic.Int64
+	release := make(chan struct{})
+	shadow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mirrored.Add(1)
+		<-release
+	}))
+	defer shadow.Close()
+	defer close(release)
+
+	cfg := mirrorConfig(t, shadow.URL, 100)
+	err := configura.WriteConfiguration(cfg, map[configura.Variable[int64]]int64{
+		MIRROR_MAX_IN_FLIGHT: 2,
+	})
+	require.NoError(t, err)
+	handler := Mirror(cfg, nil)(primaryHandler)
+
+	for range 5 {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		assert.Equal(t, http.StatusCreated, rec.Code, "Requests should be served while the mirror is full")
+	}
+	require.Eventually(t, func() bool { return mirrored.Load() == 2 }, 2*time.Second, 10*time.Millisecond)
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, int64(2), mirrored.Load(), "Requests over MIRROR_MAX_IN_FLIGHT should not be mirrored")
+}
+
+// countingTransport counts the requests sent through it.
+type countingTransport struct {
+	requests atomic.Int64
+}
+
+func (t *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.requests.Add(1)
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestMirror_Client(t *testing.T) {
+	shadow := httptest.NewServer(http.NotFoundHandler())
+	defer shadow.Close()
+
+	transport := &countingTransport{}
+	handler := Mirror(mirrorConfig(t, shadow.URL, 100), &http.Client{Transport: transport})(primaryHandler)
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.Eventually(t, func() bool { return transport.requests.Load() == 1 }, 2*time.Second, 10*time.Millisecond,
+		"The requests should be mirrored with the client passed in")
+}
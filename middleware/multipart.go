@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"errors"
+	"mime"
+	"net/http"
+
+	"github.com/ponrove/configura"
+)
+
+const (
+	SERVER_MULTIPART_MAX_MEMORY configura.Variable[int64] = "SERVER_MULTIPART_MAX_MEMORY" // Bytes of a multipart form kept in memory, defaults to 32MB once SERVER_MULTIPART_MAX_BYTES is set
+	SERVER_MULTIPART_MAX_BYTES  configura.Variable[int64] = "SERVER_MULTIPART_MAX_BYTES"  // Total size cap of a multipart upload, unlimited by default
+)
+
+// defaultMultipartMaxMemory matches the in-memory limit used by http.Request.FormFile.
+const defaultMultipartMaxMemory = 32 << 20
+
+// MultipartLimit is a middleware that parses multipart/form-data request bodies up front, keeping at most
+// SERVER_MULTIPART_MAX_MEMORY bytes in memory and spilling the rest of the file parts to disk. If
+// SERVER_MULTIPART_MAX_BYTES is set, uploads larger than it are rejected with 413 Request Entity Too Large. Handlers (and
+// huma) find the parsed form on the request, and don't parse it again with their own limits.
+//
+// The middleware is disabled unless one of SERVER_MULTIPART_MAX_MEMORY or SERVER_MULTIPART_MAX_BYTES is set, as it
+// parses the uploads before routing, of unknown paths and unauthenticated requests included, and consumes the body of
+// handlers streaming them with r.MultipartReader.
+func MultipartLimit(cfg configura.Config) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		maxMemory, maxBytes := cfg.Int64(SERVER_MULTIPART_MAX_MEMORY), cfg.Int64(SERVER_MULTIPART_MAX_BYTES)
+		if maxMemory <= 0 && maxBytes <= 0 {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+			if err != nil || mediaType != "multipart/form-data" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if maxBytes > 0 {
+				r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+			}
+
+			err = r.ParseMultipartForm(configura.Fallback(maxMemory, defaultMultipartMaxMemory))
+			if err != nil {
+				var maxBytesErr *http.MaxBytesError
+				if errors.As(err, &maxBytesErr) {
+					Reject(cfg, w, r, http.StatusRequestEntityTooLarge, "multipart upload exceeds the maximum size")
+					return
+				}
+				Reject(cfg, w, r, http.StatusBadRequest, "invalid multipart form")
+				return
+			}
+			defer r.MultipartForm.RemoveAll()
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package middleware
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ponrove/configura"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newMultipartRequest(t *testing.T, fileSize int) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreateFormFile("file", "upload.bin")
+	require.NoError(t, err)
+	_, err = part.Write(bytes.Repeat([]byte("a"), fileSize))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+
+	req := httptest.NewRequest(http.MethodPost, "/upload", &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	return req
+}
+
+func multipartLimitConfig(t *testing.T, maxMemory, maxBytes int64) configura.Config {
+	t.Helper()
+	cfg := configura.NewConfigImpl()
+	err := configura.WriteConfiguration(cfg, map[configura.Variable[int64]]int64{
+		SERVER_MULTIPART_MAX_MEMORY: maxMemory,
+		SERVER_MULTIPART_MAX_BYTES:  maxBytes,
+	})
+	require.NoError(t, err)
+	return cfg
+}
+
+func TestMultipartLimit_AllowedUpload(t *testing.T) {
+	var uploadedSize int64
+	handler := MultipartLimit(multipartLimitConfig(t, 512, 4096))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NotNil(t, r.MultipartForm, "Form should be parsed by the middleware")
+		f, header, err := r.FormFile("file")
+		require.NoError(t, err)
+		defer f.Close()
+		uploadedSize = header.Size
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, newMultipartRequest(t, 2048))
+
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	assert.Equal(t, int64(2048), uploadedSize)
+}
+
+func TestMultipartLimit_OverLimitUpload(t *testing.T) {
+	handlerCalled := false
+	handler := MultipartLimit(multipartLimitConfig(t, 512, 4096))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handlerCalled = true
+	}))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, newMultipartRequest(t, 8192))
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rr.Code)
+	assert.False(t, handlerCalled, "Handler should not be called for an over-limit upload")
+}
+
+func TestMultipartLimit_NonMultipartPassesThrough(t *testing.T) {
+	handler := MultipartLimit(multipartLimitConfig(t, 16, 16))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		_, _ = w.Write(body)
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/json", bytes.NewReader(bytes.Repeat([]byte("b"), 64)))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, 64, rr.Body.Len(), "Non-multipart bodies are not limited")
+}
+
+func TestMultipartLimit_DisabledByDefault(t *testing.T) {
+	var streamedSize int64
+	handler := MultipartLimit(configura.NewConfigImpl())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Nil(t, r.MultipartForm, "Form should be left to the handler")
+		reader, err := r.MultipartReader()
+		require.NoError(t, err, "The body should be left to the handler to stream")
+		part, err := reader.NextPart()
+		require.NoError(t, err)
+		streamedSize, err = io.Copy(io.Discard, part)
+		require.NoError(t, err)
+	}))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, newMultipartRequest(t, 2048))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, int64(2048), streamedSize)
+}
@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/ponrove/configura"
+)
+
+const (
+	SERVER_MAX_QUERY_PARAMS configura.Variable[int64] = "SERVER_MAX_QUERY_PARAMS" // Most query parameters of a request, unlimited by default
+)
+
+// queryParamsExceed reports whether the raw query has more than limit parameters. The query isn't parsed, and counting
+// stops at the limit, so an abusive query costs no more than an allowed one.
+func queryParamsExceed(rawQuery string, limit int64) bool {
+	var count int64
+	for rawQuery != "" {
+		var param string
+		param, rawQuery, _ = strings.Cut(rawQuery, "&")
+		if param == "" {
+			continue
+		}
+		count++
+		if count > limit {
+			return true
+		}
+	}
+	return false
+}
+
+// MaxQueryParams is a middleware that rejects requests with more than SERVER_MAX_QUERY_PARAMS query parameters with
+// 400 Bad Request, before anything parses their query string. Repeated parameters count once per occurrence. The
+// middleware is disabled unless SERVER_MAX_QUERY_PARAMS is set.
+func MaxQueryParams(cfg configura.Config) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		limit := cfg.Int64(SERVER_MAX_QUERY_PARAMS)
+		if limit <= 0 {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if queryParamsExceed(r.URL.RawQuery, limit) {
+				Reject(cfg, w, r, http.StatusBadRequest, fmt.Sprintf("Too many query parameters, at most %d are allowed", limit))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
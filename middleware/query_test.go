@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ponrove/configura"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaxQueryParams(t *testing.T) {
+	tests := []struct {
+		name           string
+		limit          int64
+		query          string
+		expectedStatus int
+	}{
+		{name: "Allowed", limit: 3, query: "a=1&b=2&c=3", expectedStatus: http.StatusOK},
+		{name: "Over the limit", limit: 3, query: "a=1&b=2&c=3&d=4", expectedStatus: http.StatusBadRequest},
+		{name: "Repeated parameters count", limit: 3, query: "a=1&a=2&a=3&a=4", expectedStatus: http.StatusBadRequest},
+		{name: "Empty segments don't count", limit: 3, query: "a=1&&b=2&&&c=3&", expectedStatus: http.StatusOK},
+		{name: "No query", limit: 3, expectedStatus: http.StatusOK},
+		{name: "Unlimited by default", query: "a=1&b=2&c=3&d=4", expectedStatus: http.StatusOK},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := configura.NewConfigImpl()
+			err := configura.WriteConfiguration(cfg, map[configura.Variable[int64]]int64{
+				SERVER_MAX_QUERY_PARAMS: tc.limit,
+			})
+			require.NoError(t, err)
+
+			handler := MaxQueryParams(cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/search?"+tc.query, nil))
+
+			assert.Equal(t, tc.expectedStatus, rr.Code)
+		})
+	}
+}
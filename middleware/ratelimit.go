@@ -0,0 +1,84 @@
+package middleware
+
+import (
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ponrove/configura"
+	"github.com/ponrove/ponrunner/utils"
+	slogctx "github.com/veqryn/slog-context"
+)
+
+const (
+	RATE_LIMIT_REQUESTS configura.Variable[int64] = "RATE_LIMIT_REQUESTS" // Requests a client may make per window, disabled by default
+	RATE_LIMIT_WINDOW   configura.Variable[int64] = "RATE_LIMIT_WINDOW"   // Seconds of the rate limit window, defaults to 60
+)
+
+// clientAddress returns the address of the client of a request: its IP address as resolved by the IPAddress middleware
+// for requests from one of the trusted proxies, otherwise the remote address of the connection, as clients could
+// otherwise claim a new address in X-Forwarded-For with each request.
+func clientAddress(r *http.Request, trusted []*net.IPNet) string {
+	if ip := GetIPAddressFromContext(r.Context()); ip != "" && utils.IsTrustedProxy(r.RemoteAddr, trusted) {
+		return ip
+	}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
+
+// RateLimit is a middleware limiting each client, by IP address, to RATE_LIMIT_REQUESTS requests per
+// RATE_LIMIT_WINDOW seconds (a minute by default), in fixed windows. The forwarded address of the client is only used
+// for requests from HTTP_TRUSTED_PROXIES. Requests over the limit are rejected with 429 Too Many Requests and a
+// Retry-After header until the window ends. The requests are counted in store, in memory
+// if it is nil, so a store shared by the replicas of a service limits clients across all of them. If the store fails,
+// a warning is logged and the request is served. The middleware is disabled unless RATE_LIMIT_REQUESTS is set.
+func RateLimit(cfg configura.Config, store Store) func(http.Handler) http.Handler {
+	window := time.Duration(configura.Fallback(cfg.Int64(RATE_LIMIT_WINDOW), 60)) * time.Second
+	return rateLimit(cfg, store, "ratelimit:", cfg.Int64(RATE_LIMIT_REQUESTS), window)
+}
+
+// RateLimitScope is a middleware limiting each client to limit requests per window, like RateLimit, for the routes of
+// a group rather than the whole server, e.g. a stricter limit on a public API. The requests are counted per scope,
+// separately from RateLimit's, so a client's requests to the group count against both limits, but never twice against
+// the same one. The window is a minute if it isn't positive, and the middleware is disabled if limit isn't.
+func RateLimitScope(cfg configura.Config, store Store, scope string, limit int64, window time.Duration) func(http.Handler) http.Handler {
+	if window <= 0 {
+		window = time.Minute
+	}
+	return rateLimit(cfg, store, "ratelimit:"+scope+":", limit, window)
+}
+
+// rateLimit limits each client to limit requests per window, counted in store under keys starting with prefix.
+func rateLimit(cfg configura.Config, store Store, prefix string, limit int64, window time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if limit <= 0 {
+			return next
+		}
+		if store == nil {
+			store = NewMemoryStore()
+		}
+		trusted := utils.ParseTrustedProxies(cfg.String(HTTP_TRUSTED_PROXIES))
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			now := time.Now()
+			start := now.Truncate(window)
+			key := prefix + clientAddress(r, trusted) + ":" + strconv.FormatInt(start.Unix(), 10)
+			n, err := store.Incr(r.Context(), key, window)
+			if err != nil {
+				slogctx.FromCtx(r.Context()).Warn("Rate limit store failed, serving the request", slog.Any("error", err))
+				next.ServeHTTP(w, r)
+				return
+			}
+			if n > limit {
+				SetRetryAfter(cfg, w, start.Add(window).Sub(now))
+				Reject(cfg, w, r, http.StatusTooManyRequests, "rate limit exceeded")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ponrove/configura"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newRateLimitConfig(t *testing.T) configura.Config {
+	t.Helper()
+	cfg := configura.NewConfigImpl()
+	err := configura.WriteConfiguration(cfg, map[configura.Variable[int64]]int64{
+		RATE_LIMIT_REQUESTS: 2,
+		RATE_LIMIT_WINDOW:   60,
+	})
+	require.NoError(t, err)
+	return cfg
+}
+
+func TestRateLimit(t *testing.T) {
+	store := newRecordingStore()
+	handler := RateLimit(newRateLimitConfig(t), store)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	get := func(remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = remoteAddr
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	assert.Equal(t, http.StatusOK, get("192.0.2.1:1234").Code)
+	assert.Equal(t, http.StatusOK, get("192.0.2.1:1234").Code)
+	limited := get("192.0.2.1:1234")
+	assert.Equal(t, http.StatusTooManyRequests, limited.Code, "The third request in the window should be limited")
+	retryAfter, err := strconv.Atoi(limited.Header().Get("Retry-After"))
+	require.NoError(t, err)
+	assert.True(t, retryAfter > 0 && retryAfter <= 60, "Retry-After should be the rest of the window, got %d", retryAfter)
+
+	assert.Equal(t, http.StatusOK, get("192.0.2.2:1234").Code, "Another client has its own limit")
+
+	calls := store.Calls()
+	require.Len(t, calls, 4)
+	for _, call := range calls {
+		assert.True(t, strings.HasPrefix(call, "Incr ratelimit:192.0.2."), "unexpected call %q", call)
+		assert.True(t, strings.HasSuffix(call, " 1m0s"), "The counter should expire with the window, got %q", call)
+	}
+	assert.Equal(t, calls[0], calls[2], "Requests of a client in the same window should share a counter")
+	assert.NotEqual(t, calls[0], calls[3])
+}
+
+func TestRateLimit_StoreFailure(t *testing.T) {
+	store := newRecordingStore()
+	store.err = errStoreUnavailable
+	handler := RateLimit(newRateLimitConfig(t), store)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for range 3 {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+		assert.Equal(t, http.StatusOK, rr.Code, "Requests should be served when the store fails")
+	}
+}
+
+func TestRateLimit_DisabledByDefault(t *testing.T) {
+	store := newRecordingStore()
+	handler := RateLimit(configura.NewConfigImpl(), store)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Empty(t, store.Calls())
+}
+
+func TestRateLimitScope(t *testing.T) {
+	store := newRecordingStore()
+	final := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	global := RateLimit(newRateLimitConfig(t), store)
+	scoped := RateLimitScope(configura.NewConfigImpl(), store, "public", 1, time.Minute)
+	handler := global(scoped(final))
+
+	get := func() int {
+		req := httptest.NewRequest(http.MethodGet, "/public", nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	assert.Equal(t, http.StatusOK, get())
+	assert.Equal(t, http.StatusTooManyRequests, get(), "The second request should exceed the limit of the scope")
+
+	calls := store.Calls()
+	require.Len(t, calls, 4)
+	assert.True(t, strings.HasPrefix(calls[0], "Incr ratelimit:192.0.2.1:"), "unexpected call %q", calls[0])
+	assert.True(t, strings.HasPrefix(calls[1], "Incr ratelimit:public:192.0.2.1:"), "The scope should have counters of its own, got %q", calls[1])
+}
+
+func TestRateLimit_ForwardedAddress(t *testing.T) {
+	tests := []struct {
+		name       string
+		remoteAddr string
+		expected   []int
+	}{
+		{name: "Untrusted peer", remoteAddr: "198.51.100.1:1234", expected: []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests}},
+		{name: "Trusted proxy", remoteAddr: "10.0.0.1:1234", expected: []int{http.StatusOK, http.StatusOK, http.StatusOK}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := newRateLimitConfig(t)
+			err := configura.WriteConfiguration(cfg, map[configura.Variable[string]]string{
+				HTTP_TRUSTED_PROXIES: "10.0.0.0/8",
+			})
+			require.NoError(t, err)
+			handler := IPAddress(cfg)(RateLimit(cfg, nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+
+			// Each request claims another client address, which only a trusted proxy may forward.
+			for i, expected := range tc.expected {
+				req := httptest.NewRequest(http.MethodGet, "/", nil)
+				req.RemoteAddr = tc.remoteAddr
+				req.Header.Set("X-Forwarded-For", "1.1.1."+strconv.Itoa(i+1))
+				rr := httptest.NewRecorder()
+				handler.ServeHTTP(rr, req)
+				assert.Equal(t, expected, rr.Code, "request %d", i+1)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package middleware
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/ponrove/configura"
+	slogctx "github.com/veqryn/slog-context"
+)
+
+const (
+	REQUEST_LOG_FIELD_PANIC          configura.Variable[string] = "REQUEST_LOG_FIELD_PANIC"
+	REQUEST_LOG_FIELD_PANIC_TYPE     configura.Variable[string] = "REQUEST_LOG_FIELD_PANIC_TYPE"
+	REQUEST_LOG_FIELD_PANIC_IS_ERROR configura.Variable[string] = "REQUEST_LOG_FIELD_PANIC_IS_ERROR"
+	REQUEST_LOG_FIELD_STACK          configura.Variable[string] = "REQUEST_LOG_FIELD_STACK"
+)
+
+// Recoverer is a middleware that recovers from panics in downstream handlers, and responds with 500 Internal Server
+// Error. Unlike chi's Recoverer, which prints to stderr, the panic is logged through the context logger with the same
+// correlation fields as the access logs (request ID and client IP), so it can be found alongside them. The Go type of
+// the recovered value, and whether it is an error, are logged with it, to tell a runtime error (e.g. a write to a nil
+// map) from a custom panic. The stack of the panic is attached with LOG_INCLUDE_STACK_ON_ERROR, like the other error
+// logs. Like chi's Recoverer, http.ErrAbortHandler is not recovered, so the response to the client is aborted.
+func Recoverer(cfg configura.Config) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer func() {
+				rvr := recover()
+				if rvr == nil {
+					return
+				}
+				if rvr == http.ErrAbortHandler {
+					panic(rvr)
+				}
+				_, isError := rvr.(error)
+
+				attrs := []slog.Attr{
+					slog.String(configura.Fallback(cfg.String(REQUEST_LOG_FIELD_PANIC), "panic"), fmt.Sprint(rvr)),
+					slog.String(configura.Fallback(cfg.String(REQUEST_LOG_FIELD_PANIC_TYPE), "panic_type"), fmt.Sprintf("%T", rvr)),
+					slog.Bool(configura.Fallback(cfg.String(REQUEST_LOG_FIELD_PANIC_IS_ERROR), "panic_is_error"), isError),
+				}
+				// The stack is taken in the deferred function, so it still has the frames of the panicking handler.
+				attrs = append(attrs, ErrorStackAttrs(cfg)...)
+				attrs = append(attrs,
+					slog.String(configura.Fallback(cfg.String(REQUEST_LOG_FIELD_REQUEST_METHOD), "method"), r.Method),
+					slog.String(configura.Fallback(cfg.String(REQUEST_LOG_FIELD_REQUEST_URL), "request_url"), r.URL.String()),
+					slog.String(configura.Fallback(cfg.String(REQUEST_LOG_FIELD_REAL_IP), "real_ip"), GetIPAddressFromContext(r.Context())),
+					slog.String(configura.Fallback(cfg.String(REQUEST_LOG_FIELD_REQUEST_ID), "request_id"), middleware.GetReqID(r.Context())),
+				)
+				slogctx.FromCtx(r.Context()).LogAttrs(r.Context(), slog.LevelError,
+					fmt.Sprintf("Panic recovered while handling request: %s %s", r.Method, r.URL.Path), attrs...)
+
+				if r.Header.Get("Connection") != "Upgrade" {
+					w.WriteHeader(http.StatusInternalServerError)
+				}
+			}()
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
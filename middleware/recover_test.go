@@ -0,0 +1,135 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/ponrove/configura"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecoverer_LogsPanicWithCorrelationFields(t *testing.T) {
+	var logBuffer bytes.Buffer
+	originalDefaultLogger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&logBuffer, nil)))
+	t.Cleanup(func() { slog.SetDefault(originalDefaultLogger) })
+
+	cfg := configura.NewConfigImpl()
+	handler := IPAddress(cfg)(middleware.RequestID(Recoverer(cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("something went terribly wrong")
+	}))))
+
+	req := httptest.NewRequest(http.MethodGet, "/explode", nil)
+	req.RemoteAddr = "8.8.8.8:1234"
+	req.Header.Set(middleware.RequestIDHeader, "req-123")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusInternalServerError, rr.Code)
+
+	var logged map[string]any
+	require.NoError(t, json.Unmarshal(logBuffer.Bytes(), &logged), "Expected a single structured log line: %s", logBuffer.String())
+	assert.Equal(t, "ERROR", logged["level"])
+	assert.Equal(t, "something went terribly wrong", logged["panic"])
+	assert.Equal(t, "string", logged["panic_type"])
+	assert.Equal(t, false, logged["panic_is_error"])
+	assert.Equal(t, "req-123", logged["request_id"])
+	assert.Equal(t, "8.8.8.8", logged["real_ip"])
+	assert.Equal(t, http.MethodGet, logged["method"])
+	assert.NotContains(t, logged, "stack", "Stacks should be off by default")
+}
+
+func TestRecoverer_AbortHandlerIsNotRecovered(t *testing.T) {
+	handler := Recoverer(configura.NewConfigImpl())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+
+	assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	})
+}
+
+// customPanic is a panic value of a type of the application.
+type customPanic struct {
+	code int
+}
+
+func TestRecoverer_LogsPanicType(t *testing.T) {
+	tests := []struct {
+		name            string
+		panic           func()
+		expectedPanic   string
+		expectedType    string
+		expectedIsError bool
+	}{
+		{
+			name: "Nil map write",
+			panic: func() {
+				var m map[string]int
+				m["key"] = 1
+			},
+			expectedPanic:   "assignment to entry in nil map",
+			expectedType:    "runtime.plainError",
+			expectedIsError: true,
+		},
+		{
+			name: "Index out of range",
+			panic: func() {
+				var s []int
+				_ = s[len(s)]
+			},
+			expectedPanic:   "runtime error: index out of range [0] with length 0",
+			expectedType:    "runtime.boundsError",
+			expectedIsError: true,
+		},
+		{
+			name:            "Custom error",
+			panic:           func() { panic(fmt.Errorf("wrapped: %w", errors.New("payment declined"))) },
+			expectedPanic:   "wrapped: payment declined",
+			expectedType:    "*fmt.wrapError",
+			expectedIsError: true,
+		},
+		{
+			name:          "Custom value",
+			panic:         func() { panic(customPanic{code: 7}) },
+			expectedPanic: "{7}",
+			expectedType:  "middleware.customPanic",
+		},
+		{
+			name:          "Integer",
+			panic:         func() { panic(42) },
+			expectedPanic: "42",
+			expectedType:  "int",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var logBuffer bytes.Buffer
+			originalDefaultLogger := slog.Default()
+			slog.SetDefault(slog.New(slog.NewJSONHandler(&logBuffer, nil)))
+			t.Cleanup(func() { slog.SetDefault(originalDefaultLogger) })
+
+			handler := Recoverer(configura.NewConfigImpl())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				tc.panic()
+			}))
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+			assert.Equal(t, http.StatusInternalServerError, rr.Code)
+
+			var logged map[string]any
+			require.NoError(t, json.Unmarshal(logBuffer.Bytes(), &logged))
+			assert.Equal(t, tc.expectedPanic, logged["panic"])
+			assert.Equal(t, tc.expectedType, logged["panic_type"])
+			assert.Equal(t, tc.expectedIsError, logged["panic_is_error"])
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"strings"
+
+	"github.com/ponrove/configura"
+	"github.com/ponrove/ponrunner/utils"
+)
+
+const (
+	REQUEST_LOG_REDACT_NAMES configura.Variable[string] = "REQUEST_LOG_REDACT_NAMES" // Comma separated names whose values are redacted in the logs
+)
+
+// redactedValue replaces the value of a redacted name in the logs.
+const redactedValue = "[REDACTED]"
+
+// defaultRedactedNames are the names redacted when REQUEST_LOG_REDACT_NAMES is not set, commonly used for credentials.
+var defaultRedactedNames = "password,passwd,secret,token,access_token,refresh_token,id_token,api_key,apikey,authorization,session,code"
+
+// redactor redacts the values of sensitive names (query parameters, cookies, route parameters) logged by LogRequest.
+type redactor map[string]struct{}
+
+// newRedactor returns a redactor for the case insensitive names in REQUEST_LOG_REDACT_NAMES, or a default set of
+// credential names if it's not set.
+func newRedactor(cfg configura.Config) redactor {
+	r := make(redactor)
+	for _, name := range utils.SplitCommaSeparated(configura.Fallback(cfg.String(REQUEST_LOG_REDACT_NAMES), defaultRedactedNames)) {
+		r[strings.ToLower(name)] = struct{}{}
+	}
+	return r
+}
+
+// value returns the value to log for the name, which is redactedValue if the name is redacted.
+func (r redactor) value(name, value string) string {
+	if _, ok := r[strings.ToLower(name)]; ok {
+		return redactedValue
+	}
+	return value
+}
@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/ponrove/configura"
+)
+
+const (
+	REJECTION_RESPONSE_FORMAT configura.Variable[string] = "REJECTION_RESPONSE_FORMAT" // "problem" (default, application/problem+json) or "text"
+)
+
+// rejectionProblem is the RFC 9457 problem details body of a rejected request.
+type rejectionProblem struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail,omitempty"`
+	Instance  string `json:"instance,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// Reject writes the response of a request rejected by a middleware (e.g. a timeout or an oversized body), so all
+// rejections share the same format. By default the body is application/problem+json, including the request ID for
+// correlation with the logs; with REJECTION_RESPONSE_FORMAT=text it is a plain text line, as written by http.Error.
+func Reject(cfg configura.Config, w http.ResponseWriter, r *http.Request, status int, detail string) {
+	requestID := middleware.GetReqID(r.Context())
+
+	if cfg.String(REJECTION_RESPONSE_FORMAT) == "text" {
+		if detail == "" {
+			detail = http.StatusText(status)
+		}
+		if requestID != "" {
+			detail += " (request_id: " + requestID + ")"
+		}
+		http.Error(w, detail, status)
+		return
+	}
+
+	body, err := json.Marshal(rejectionProblem{
+		Type:      "about:blank",
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    detail,
+		Instance:  r.URL.Path,
+		RequestID: requestID,
+	})
+	if err != nil {
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+
+	w.Header().Del("Content-Length")
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	_, _ = w.Write(append(body, '\n'))
+}
@@ -0,0 +1,105 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/ponrove/configura"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// rejectionCase is a request rejected by one of the rejection middlewares.
+type rejectionCase struct {
+	name    string
+	status  int
+	handler http.Handler
+	request *http.Request
+}
+
+// rejectionCases returns a request rejected by each of the rejection middlewares, configured with cfg.
+func rejectionCases(t *testing.T, cfg *configura.ConfigImpl) []rejectionCase {
+	t.Helper()
+	slow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	})
+	noop := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	err := configura.WriteConfiguration(cfg, map[configura.Variable[int64]]int64{
+		SERVER_MULTIPART_MAX_BYTES: 16,
+	})
+	require.NoError(t, err)
+	hardCfg := configura.NewConfigImpl()
+	err = configura.WriteConfiguration(hardCfg, map[configura.Variable[string]]string{
+		SERVER_REQUEST_TIMEOUT_MODE: "hard",
+	})
+	require.NoError(t, err)
+
+	return []rejectionCase{
+		{
+			name:    "Soft timeout",
+			status:  http.StatusGatewayTimeout,
+			handler: Timeout(cfg, 10*time.Millisecond)(slow),
+			request: httptest.NewRequest(http.MethodGet, "/slow", nil),
+		},
+		{
+			name:    "Hard timeout",
+			status:  http.StatusGatewayTimeout,
+			handler: Timeout(configura.Merge(cfg, hardCfg), 10*time.Millisecond)(slow),
+			request: httptest.NewRequest(http.MethodGet, "/slow", nil),
+		},
+		{
+			name:    "Multipart over limit",
+			status:  http.StatusRequestEntityTooLarge,
+			handler: MultipartLimit(cfg)(noop),
+			request: newMultipartRequest(t, 1024),
+		},
+	}
+}
+
+func TestReject_ProblemJSON(t *testing.T) {
+	for _, tc := range rejectionCases(t, configura.NewConfigImpl()) {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.request.Header.Set(middleware.RequestIDHeader, "req-123")
+			rr := httptest.NewRecorder()
+			middleware.RequestID(tc.handler).ServeHTTP(rr, tc.request)
+
+			assert.Equal(t, tc.status, rr.Code)
+			assert.Equal(t, "application/problem+json", rr.Header().Get("Content-Type"))
+
+			var problem map[string]any
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &problem), "Body should be problem+json: %s", rr.Body.String())
+			assert.Equal(t, "about:blank", problem["type"])
+			assert.Equal(t, http.StatusText(tc.status), problem["title"])
+			assert.Equal(t, float64(tc.status), problem["status"])
+			assert.NotEmpty(t, problem["detail"])
+			assert.Equal(t, tc.request.URL.Path, problem["instance"])
+			assert.Equal(t, "req-123", problem["request_id"])
+		})
+	}
+}
+
+func TestReject_PlainText(t *testing.T) {
+	cfg := configura.NewConfigImpl()
+	err := configura.WriteConfiguration(cfg, map[configura.Variable[string]]string{
+		REJECTION_RESPONSE_FORMAT: "text",
+	})
+	require.NoError(t, err)
+
+	for _, tc := range rejectionCases(t, cfg) {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.request.Header.Set(middleware.RequestIDHeader, "req-123")
+			rr := httptest.NewRecorder()
+			middleware.RequestID(tc.handler).ServeHTTP(rr, tc.request)
+
+			assert.Equal(t, tc.status, rr.Code)
+			assert.Equal(t, "text/plain; charset=utf-8", rr.Header().Get("Content-Type"))
+			assert.True(t, strings.HasSuffix(rr.Body.String(), " (request_id: req-123)\n"), "unexpected body %q", rr.Body.String())
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/ponrove/configura"
+	slogctx "github.com/veqryn/slog-context"
+)
+
+const (
+	SERVER_MAX_RESPONSE_BYTES configura.Variable[int64] = "SERVER_MAX_RESPONSE_BYTES" // Most bytes a handler may write in a response body, unlimited by default
+)
+
+// ErrResponseTooLarge is returned by the writes of a handler exceeding SERVER_MAX_RESPONSE_BYTES.
+var ErrResponseTooLarge = errors.New("response exceeds the maximum size")
+
+// maxBytesResponseWriter fails the writes that would take the response body over max bytes, counted by the embedded
+// captureResponseWriter.
+type maxBytesResponseWriter struct {
+	*captureResponseWriter
+	max      int
+	exceeded bool
+}
+
+// Interceptor that fails the writes exceeding the maximum size, and all writes after them, so a truncated body is never
+// mistaken for a complete one.
+func (mw *maxBytesResponseWriter) Write(b []byte) (int, error) {
+	if mw.exceeded || mw.size+len(b) > mw.max {
+		mw.exceeded = true
+		return 0, ErrResponseTooLarge
+	}
+	return mw.captureResponseWriter.Write(b)
+}
+
+// MaxResponseBytes is a middleware capping the bytes a handler may write in a response body at
+// SERVER_MAX_RESPONSE_BYTES, to catch pathological handlers before their responses reach clients. The write exceeding
+// the cap fails with ErrResponseTooLarge, and an error is logged once the handler returns. If nothing was written yet,
+// the client gets a 500 Internal Server Error; otherwise the response is aborted, so the client sees it incomplete.
+// The middleware is disabled unless SERVER_MAX_RESPONSE_BYTES is set.
+func MaxResponseBytes(cfg configura.Config) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		maxBytes := cfg.Int64(SERVER_MAX_RESPONSE_BYTES)
+		if maxBytes <= 0 {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mw := &maxBytesResponseWriter{captureResponseWriter: &captureResponseWriter{ResponseWriter: w}, max: int(maxBytes)}
+			next.ServeHTTP(mw, r)
+			if !mw.exceeded {
+				return
+			}
+
+			slogctx.FromCtx(r.Context()).LogAttrs(context.Background(), slog.LevelError, "Response exceeds the maximum size, aborted",
+				slog.Int64("max_response_bytes", maxBytes),
+				slog.Int("written_bytes", mw.size),
+				slog.String("method", r.Method),
+				slog.String("path", r.URL.Path),
+			)
+			if mw.statusCode == 0 {
+				Reject(cfg, w, r, http.StatusInternalServerError, "response exceeds the maximum size")
+				return
+			}
+			panic(http.ErrAbortHandler)
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package middleware
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ponrove/configura"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// maxResponseBytesConfig returns a configuration capping responses at limit bytes.
+func maxResponseBytesConfig(t *testing.T, limit int64) configura.Config {
+	t.Helper()
+	cfg := configura.NewConfigImpl()
+	err := configura.WriteConfiguration(cfg, map[configura.Variable[int64]]int64{
+		SERVER_MAX_RESPONSE_BYTES: limit,
+	})
+	require.NoError(t, err)
+	return cfg
+}
+
+func TestMaxResponseBytes_WithinLimit(t *testing.T) {
+	handler := MaxResponseBytes(maxResponseBytesConfig(t, 10))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("hello"))
+		_, _ = w.Write([]byte("world"))
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "helloworld", rec.Body.String())
+}
+
+func TestMaxResponseBytes_ExceededBeforeResponse(t *testing.T) {
+	var logBuffer bytes.Buffer
+	originalDefaultLogger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&logBuffer, nil)))
+	t.Cleanup(func() { slog.SetDefault(originalDefaultLogger) })
+
+	var writeErr error
+	handler := MaxResponseBytes(maxResponseBytesConfig(t, 10))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, writeErr = w.Write([]byte(strings.Repeat("x", 11)))
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/export", nil))
+	assert.ErrorIs(t, writeErr, ErrResponseTooLarge)
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.NotContains(t, rec.Body.String(), "xxx", "The oversized body should not reach the client")
+	assert.Contains(t, logBuffer.String(), `"level":"ERROR"`)
+	assert.Contains(t, logBuffer.String(), "Response exceeds the maximum size, aborted")
+	assert.Contains(t, logBuffer.String(), `"path":"/export"`)
+}
+
+func TestMaxResponseBytes_ExceededMidResponse(t *testing.T) {
+	var logBuffer bytes.Buffer
+	originalDefaultLogger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&logBuffer, nil)))
+	t.Cleanup(func() { slog.SetDefault(originalDefaultLogger) })
+
+	var writeErrs []error
+	handler := MaxResponseBytes(maxResponseBytesConfig(t, 10))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for range 5 {
+			_, err := w.Write([]byte("chunk"))
+			writeErrs = append(writeErrs, err)
+		}
+	}))
+
+	rec := httptest.NewRecorder()
+	assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/export", nil))
+	}, "The response should be aborted once started")
+	assert.Equal(t, "chunkchunk", rec.Body.String(), "Nothing past the cap should be written")
+	assert.NoError(t, writeErrs[1])
+	for _, err := range writeErrs[2:] {
+		assert.ErrorIs(t, err, ErrResponseTooLarge, "All writes after the cap should fail")
+	}
+	assert.Contains(t, logBuffer.String(), "Response exceeds the maximum size, aborted")
+	assert.Contains(t, logBuffer.String(), `"written_bytes":10`)
+}
+
+func TestMaxResponseBytes_UnlimitedByDefault(t *testing.T) {
+	handler := MaxResponseBytes(configura.NewConfigImpl())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(strings.Repeat("x", 1<<20)))
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, 1<<20, rec.Body.Len())
+}
@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ponrove/configura"
+)
+
+const (
+	RETRY_AFTER_JITTER configura.Variable[int64] = "RETRY_AFTER_JITTER" // Max seconds randomly added to Retry-After headers, 0 disables
+)
+
+// SetRetryAfter sets the Retry-After header of a response telling the client to back off, such as a 429 Too Many
+// Requests or a 503 Service Unavailable. Middleware limiting requests should use it for their Retry-After values, so
+// the jitter applies to all of them. With RETRY_AFTER_JITTER set, a random number of seconds up to that value is added,
+// so clients limited at the same moment don't all retry at the same moment.
+func SetRetryAfter(cfg configura.Config, w http.ResponseWriter, after time.Duration) {
+	w.Header().Set("Retry-After", strconv.FormatInt(retryAfterSeconds(cfg, after), 10))
+}
+
+// retryAfterSeconds returns the Retry-After value in whole seconds, rounded up, with the configured jitter added.
+func retryAfterSeconds(cfg configura.Config, after time.Duration) int64 {
+	seconds := int64(max((after+time.Second-1)/time.Second, 0))
+	if jitter := cfg.Int64(RETRY_AFTER_JITTER); jitter > 0 {
+		seconds += rand.Int64N(jitter + 1)
+	}
+	return seconds
+}
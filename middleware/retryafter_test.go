@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/ponrove/configura"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetRetryAfter_Jitter(t *testing.T) {
+	cfg := configura.NewConfigImpl()
+	err := configura.WriteConfiguration(cfg, map[configura.Variable[int64]]int64{
+		RETRY_AFTER_JITTER: 10,
+	})
+	require.NoError(t, err)
+
+	seen := make(map[int64]struct{})
+	for range 200 {
+		rr := httptest.NewRecorder()
+		SetRetryAfter(cfg, rr, 30*time.Second)
+
+		value, err := strconv.ParseInt(rr.Header().Get("Retry-After"), 10, 64)
+		require.NoError(t, err)
+		assert.GreaterOrEqual(t, value, int64(30))
+		assert.LessOrEqual(t, value, int64(40), "The jitter should stay within the configured window")
+		seen[value] = struct{}{}
+	}
+	assert.Greater(t, len(seen), 1, "The Retry-After values should vary")
+}
+
+func TestSetRetryAfter_NoJitter(t *testing.T) {
+	rr := httptest.NewRecorder()
+	SetRetryAfter(configura.NewConfigImpl(), rr, 1500*time.Millisecond)
+
+	assert.Equal(t, "2", rr.Header().Get("Retry-After"), "Partial seconds should be rounded up")
+}
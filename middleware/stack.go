@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"log/slog"
+	"runtime"
+	"runtime/debug"
+
+	"github.com/ponrove/configura"
+)
+
+const (
+	LOG_INCLUDE_STACK_ON_ERROR configura.Variable[bool] = "LOG_INCLUDE_STACK_ON_ERROR" // Attach a stack trace to error logs, e.g. in dev, off by default
+)
+
+// maxAllStacksSize caps the size of the stacks of all goroutines attached to an error log.
+const maxAllStacksSize = 1 << 20
+
+// ErrorStackAttrs returns the stack of the calling goroutine as a stack attribute (REQUEST_LOG_FIELD_STACK), to attach
+// to an error log, if LOG_INCLUDE_STACK_ON_ERROR is enabled. Otherwise it returns nil, so stacks stay out of the logs
+// in production, where they are noise and may reveal internals.
+func ErrorStackAttrs(cfg configura.Config) []slog.Attr {
+	if !cfg.Bool(LOG_INCLUDE_STACK_ON_ERROR) {
+		return nil
+	}
+	return []slog.Attr{slog.String(configura.Fallback(cfg.String(REQUEST_LOG_FIELD_STACK), "stack"), string(debug.Stack()))}
+}
+
+// allStacksAttrs is ErrorStackAttrs with the stacks of all goroutines, for errors detected away from the goroutine at
+// fault, e.g. a handler still running after its timeout. The stacks are truncated to maxAllStacksSize.
+func allStacksAttrs(cfg configura.Config) []slog.Attr {
+	if !cfg.Bool(LOG_INCLUDE_STACK_ON_ERROR) {
+		return nil
+	}
+	buf := make([]byte, maxAllStacksSize)
+	n := runtime.Stack(buf, true)
+	return []slog.Attr{slog.String(configura.Fallback(cfg.String(REQUEST_LOG_FIELD_STACK), "stack"), string(buf[:n]))}
+}
@@ -0,0 +1,75 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ponrove/configura"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestErrorStackAttrs(t *testing.T) {
+	assert.Empty(t, ErrorStackAttrs(configura.NewConfigImpl()), "Stacks should be off by default")
+
+	cfg := configura.NewConfigImpl()
+	err := configura.WriteConfiguration(cfg, map[configura.Variable[bool]]bool{
+		LOG_INCLUDE_STACK_ON_ERROR: true,
+	})
+	require.NoError(t, err)
+	err = configura.WriteConfiguration(cfg, map[configura.Variable[string]]string{
+		REQUEST_LOG_FIELD_STACK: "trace",
+	})
+	require.NoError(t, err)
+
+	attrs := ErrorStackAttrs(cfg)
+	require.Len(t, attrs, 1)
+	assert.Equal(t, "trace", attrs[0].Key, "The stack field name should be configurable")
+	assert.Contains(t, attrs[0].Value.String(), "TestErrorStackAttrs", "The stack should be the caller's")
+}
+
+func TestRecoverer_Stack(t *testing.T) {
+	tests := []struct {
+		name        string
+		enabled     bool
+		expectStack bool
+	}{
+		{name: "Off by default", enabled: false, expectStack: false},
+		{name: "Enabled", enabled: true, expectStack: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var logBuffer bytes.Buffer
+			originalDefaultLogger := slog.Default()
+			slog.SetDefault(slog.New(slog.NewJSONHandler(&logBuffer, nil)))
+			t.Cleanup(func() { slog.SetDefault(originalDefaultLogger) })
+
+			cfg := configura.NewConfigImpl()
+			err := configura.WriteConfiguration(cfg, map[configura.Variable[bool]]bool{
+				LOG_INCLUDE_STACK_ON_ERROR: tc.enabled,
+			})
+			require.NoError(t, err)
+
+			handler := Recoverer(cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				panic("something went terribly wrong")
+			}))
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/explode", nil))
+			assert.Equal(t, http.StatusInternalServerError, rr.Code)
+
+			var logged map[string]any
+			require.NoError(t, json.Unmarshal(logBuffer.Bytes(), &logged), "Expected a single structured log line: %s", logBuffer.String())
+			if !tc.expectStack {
+				assert.NotContains(t, logged, "stack", "No stack should be logged with LOG_INCLUDE_STACK_ON_ERROR off")
+				return
+			}
+			require.Contains(t, logged, "stack")
+			assert.Contains(t, logged["stack"], "stack_test.go", "The stack should point at the panicking handler")
+		})
+	}
+}
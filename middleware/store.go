@@ -0,0 +1,110 @@
+package middleware
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Store is the key-value store the RateLimit and Idempotency middleware keep their state in. The default store is in
+// memory, so the state is per instance; a store shared by the replicas of a service, e.g. backed by Redis, makes rate
+// limits and idempotency keys apply across all of them. Keys are prefixed by the middleware using them, so a single
+// store can be shared. Implementations must be safe for concurrent use.
+type Store interface {
+	// Get returns the value of the key, and whether the key exists and hasn't expired.
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	// Set sets the value of the key, expiring after ttl.
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// Incr atomically increments the integer value of the key and returns it. A missing or expired key starts from
+	// zero and expires after ttl; incrementing an existing key keeps its expiry, like INCR and EXPIRE NX in Redis.
+	Incr(ctx context.Context, key string, ttl time.Duration) (int64, error)
+	// Delete removes the key, if it exists.
+	Delete(ctx context.Context, key string) error
+}
+
+// memoryStoreItem is a value of the memoryStore, with its expiry.
+type memoryStoreItem struct {
+	value   []byte
+	expires time.Time
+}
+
+// memoryStore is the in-memory Store, its expired keys are swept at most once a minute.
+type memoryStore struct {
+	mu        sync.Mutex
+	items     map[string]memoryStoreItem
+	lastSweep time.Time
+}
+
+// NewMemoryStore returns a Store keeping its keys in memory, the default store of the middleware.
+func NewMemoryStore() Store {
+	return &memoryStore{items: make(map[string]memoryStoreItem)}
+}
+
+// get returns the item of the key if it hasn't expired, the lock must be held.
+func (s *memoryStore) get(key string, now time.Time) (memoryStoreItem, bool) {
+	// Expired items are swept at most once a minute, rather than on every call.
+	if now.Sub(s.lastSweep) > time.Minute {
+		s.lastSweep = now
+		for k, item := range s.items {
+			if now.After(item.expires) {
+				delete(s.items, k)
+			}
+		}
+	}
+
+	item, ok := s.items[key]
+	if !ok || now.After(item.expires) {
+		return memoryStoreItem{}, false
+	}
+	return item, true
+}
+
+// Get returns the value of the key.
+func (s *memoryStore) Get(_ context.Context, key string) ([]byte, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	item, ok := s.get(key, time.Now())
+	return item.value, ok, nil
+}
+
+// Set sets the value of the key.
+func (s *memoryStore) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.items[key] = memoryStoreItem{value: value, expires: time.Now().Add(ttl)}
+	return nil
+}
+
+// Incr increments the value of the key.
+func (s *memoryStore) Incr(_ context.Context, key string, ttl time.Duration) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	item, ok := s.get(key, now)
+	var n int64
+	if ok {
+		var err error
+		if n, err = strconv.ParseInt(string(item.value), 10, 64); err != nil {
+			return 0, err
+		}
+	} else {
+		item.expires = now.Add(ttl)
+	}
+	n++
+	item.value = strconv.AppendInt(nil, n, 10)
+	s.items[key] = item
+	return n, nil
+}
+
+// Delete removes the key.
+func (s *memoryStore) Delete(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.items, key)
+	return nil
+}
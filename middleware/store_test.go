@@ -0,0 +1,119 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingStore is a Store recording the calls made to it, backed by the memory store unless it fails with err.
+type recordingStore struct {
+	Store
+	mu    sync.Mutex
+	calls []string
+	err   error
+}
+
+func newRecordingStore() *recordingStore {
+	return &recordingStore{Store: NewMemoryStore()}
+}
+
+func (s *recordingStore) record(call string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls = append(s.calls, call)
+	return s.err
+}
+
+func (s *recordingStore) Calls() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.calls...)
+}
+
+func (s *recordingStore) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	if err := s.record("Get " + key); err != nil {
+		return nil, false, err
+	}
+	return s.Store.Get(ctx, key)
+}
+
+func (s *recordingStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	if err := s.record("Set " + key + " " + ttl.String()); err != nil {
+		return err
+	}
+	return s.Store.Set(ctx, key, value, ttl)
+}
+
+func (s *recordingStore) Incr(ctx context.Context, key string, ttl time.Duration) (int64, error) {
+	if err := s.record("Incr " + key + " " + ttl.String()); err != nil {
+		return 0, err
+	}
+	return s.Store.Incr(ctx, key, ttl)
+}
+
+func (s *recordingStore) Delete(ctx context.Context, key string) error {
+	if err := s.record("Delete " + key); err != nil {
+		return err
+	}
+	return s.Store.Delete(ctx, key)
+}
+
+var errStoreUnavailable = errors.New("store unavailable")
+
+func TestMemoryStore_GetSet(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+
+	_, ok, err := store.Get(ctx, "key")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	require.NoError(t, store.Set(ctx, "key", []byte("value"), time.Minute))
+	value, ok, err := store.Get(ctx, "key")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "value", string(value))
+
+	require.NoError(t, store.Delete(ctx, "key"))
+	_, ok, err = store.Get(ctx, "key")
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestMemoryStore_Expiry(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+
+	require.NoError(t, store.Set(ctx, "key", []byte("value"), 10*time.Millisecond))
+	time.Sleep(20 * time.Millisecond)
+	_, ok, err := store.Get(ctx, "key")
+	require.NoError(t, err)
+	assert.False(t, ok, "An expired key should not be found")
+}
+
+func TestMemoryStore_Incr(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+
+	for expected := int64(1); expected <= 3; expected++ {
+		n, err := store.Incr(ctx, "counter", 20*time.Millisecond)
+		require.NoError(t, err)
+		assert.Equal(t, expected, n)
+	}
+
+	// Incrementing keeps the expiry of the first increment, so the counter restarts once it passed.
+	time.Sleep(30 * time.Millisecond)
+	n, err := store.Incr(ctx, "counter", time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+
+	require.NoError(t, store.Set(ctx, "text", []byte("abc"), time.Minute))
+	_, err = store.Incr(ctx, "text", time.Minute)
+	assert.Error(t, err, "A value that isn't an integer can't be incremented")
+}
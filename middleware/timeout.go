@@ -0,0 +1,217 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ponrove/configura"
+	slogctx "github.com/veqryn/slog-context"
+)
+
+const (
+	SERVER_REQUEST_TIMEOUT_MODE  configura.Variable[string] = "SERVER_REQUEST_TIMEOUT_MODE"  // "soft" (default) or "hard"
+	SERVER_REQUEST_TIMEOUT_GRACE configura.Variable[int64]  = "SERVER_REQUEST_TIMEOUT_GRACE" // Seconds a hard timed out handler gets to stop, defaults to 1
+
+	SERVER_REQUEST_TIMEOUT_GET    configura.Variable[int64] = "SERVER_REQUEST_TIMEOUT_GET"    // Seconds before a GET request times out, defaults to the global timeout
+	SERVER_REQUEST_TIMEOUT_HEAD   configura.Variable[int64] = "SERVER_REQUEST_TIMEOUT_HEAD"   // Seconds before a HEAD request times out, defaults to the global timeout
+	SERVER_REQUEST_TIMEOUT_POST   configura.Variable[int64] = "SERVER_REQUEST_TIMEOUT_POST"   // Seconds before a POST request times out, defaults to the global timeout
+	SERVER_REQUEST_TIMEOUT_PUT    configura.Variable[int64] = "SERVER_REQUEST_TIMEOUT_PUT"    // Seconds before a PUT request times out, defaults to the global timeout
+	SERVER_REQUEST_TIMEOUT_PATCH  configura.Variable[int64] = "SERVER_REQUEST_TIMEOUT_PATCH"  // Seconds before a PATCH request times out, defaults to the global timeout
+	SERVER_REQUEST_TIMEOUT_DELETE configura.Variable[int64] = "SERVER_REQUEST_TIMEOUT_DELETE" // Seconds before a DELETE request times out, defaults to the global timeout
+)
+
+// methodTimeoutKeys are the configuration keys of the per-method request timeouts.
+var methodTimeoutKeys = map[string]configura.Variable[int64]{
+	http.MethodGet:    SERVER_REQUEST_TIMEOUT_GET,
+	http.MethodHead:   SERVER_REQUEST_TIMEOUT_HEAD,
+	http.MethodPost:   SERVER_REQUEST_TIMEOUT_POST,
+	http.MethodPut:    SERVER_REQUEST_TIMEOUT_PUT,
+	http.MethodPatch:  SERVER_REQUEST_TIMEOUT_PATCH,
+	http.MethodDelete: SERVER_REQUEST_TIMEOUT_DELETE,
+}
+
+// methodTimeouts returns the per-method request timeouts that are configured.
+func methodTimeouts(cfg configura.Config) map[string]time.Duration {
+	timeouts := make(map[string]time.Duration)
+	for method, key := range methodTimeoutKeys {
+		if seconds := cfg.Int64(key); seconds > 0 {
+			timeouts[method] = time.Duration(seconds) * time.Second
+		}
+	}
+	return timeouts
+}
+
+// timeoutResponseWriter buffers the response of a handler running under a hard timeout, so the timeout response can be
+// written without racing the handler's writes.
+type timeoutResponseWriter struct {
+	mu          sync.Mutex
+	header      http.Header
+	buf         bytes.Buffer
+	code        int
+	wroteHeader bool
+	timedOut    bool
+}
+
+// Ensure the timeoutResponseWriter implements the http.ResponseWriter interface at compile time.
+var _ http.ResponseWriter = &timeoutResponseWriter{}
+
+// Header returns the buffered response headers.
+func (tw *timeoutResponseWriter) Header() http.Header {
+	return tw.header
+}
+
+// Interceptor that buffers the status code, unless the request already timed out.
+func (tw *timeoutResponseWriter) WriteHeader(code int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut || tw.wroteHeader {
+		return
+	}
+	tw.wroteHeader = true
+	tw.code = code
+}
+
+// Interceptor that buffers the response body, returning http.ErrHandlerTimeout once the request timed out.
+func (tw *timeoutResponseWriter) Write(b []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	if !tw.wroteHeader {
+		tw.wroteHeader = true
+		tw.code = http.StatusOK
+	}
+	return tw.buf.Write(b)
+}
+
+// Timeout is a middleware that cancels the request context after the given timeout, and responds with 504 Gateway
+// Timeout if the handler did not finish in time. Requests of a method with its own timeout configured, e.g.
+// SERVER_REQUEST_TIMEOUT_POST, use that timeout instead. A timeout of zero or less disables the middleware for the
+// requests it applies to.
+//
+// In the default "soft" mode it behaves like chi's Timeout middleware, the timeout response is written once the handler
+// returns. In "hard" mode (SERVER_REQUEST_TIMEOUT_MODE=hard) the handler runs in its own goroutine with a buffered
+// response, and the timeout response is written as soon as the deadline passes. The handler's context is cancelled, and
+// it is logged whether the handler stopped within SERVER_REQUEST_TIMEOUT_GRACE. Hard mode buffers the whole response,
+// so it is not suitable for streaming endpoints.
+func Timeout(cfg configura.Config, timeout time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		perMethod := methodTimeouts(cfg)
+		if timeout <= 0 && len(perMethod) == 0 {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			timeout := timeout
+			if methodTimeout, ok := perMethod[r.Method]; ok {
+				timeout = methodTimeout
+			}
+			if timeout <= 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if cfg.String(SERVER_REQUEST_TIMEOUT_MODE) == "hard" {
+				serveWithHardTimeout(cfg, timeout, next, w, r)
+				return
+			}
+
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			crw := &captureResponseWriter{ResponseWriter: w}
+			defer func() {
+				cancel()
+				// The timeout response can only be written if the handler didn't already start its response.
+				if ctx.Err() == context.DeadlineExceeded && crw.statusCode == 0 {
+					Reject(cfg, w, r, http.StatusGatewayTimeout, "request timed out")
+				}
+			}()
+
+			next.ServeHTTP(crw, r.WithContext(ctx))
+		})
+	}
+}
+
+// serveWithHardTimeout runs the handler in its own goroutine, and writes a timeout response as soon as the deadline
+// passes, without waiting for the handler to return.
+func serveWithHardTimeout(cfg configura.Config, timeout time.Duration, next http.Handler, w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+
+	tw := &timeoutResponseWriter{header: make(http.Header)}
+	done := make(chan struct{})
+	panicChan := make(chan any, 1)
+	go func() {
+		defer func() {
+			if p := recover(); p != nil {
+				panicChan <- p
+			}
+		}()
+		next.ServeHTTP(tw, r.WithContext(ctx))
+		close(done)
+	}()
+
+	select {
+	case p := <-panicChan:
+		// Re-panic in the serving goroutine, so the recoverer middleware can handle it.
+		panic(p)
+	case <-done:
+		tw.mu.Lock()
+		defer tw.mu.Unlock()
+		dst := w.Header()
+		for k, v := range tw.header {
+			dst[k] = v
+		}
+		if !tw.wroteHeader {
+			tw.code = http.StatusOK
+		}
+		w.WriteHeader(tw.code)
+		_, _ = w.Write(tw.buf.Bytes())
+	case <-ctx.Done():
+		tw.mu.Lock()
+		tw.timedOut = true
+		tw.mu.Unlock()
+
+		Reject(cfg, w, r, http.StatusGatewayTimeout, "request timed out")
+		go logHandlerCancellation(cfg, r, done, panicChan, time.Now(), time.Duration(configura.Fallback(cfg.Int64(SERVER_REQUEST_TIMEOUT_GRACE), 1))*time.Second)
+	}
+}
+
+// logHandlerCancellation waits for a timed out handler to return, and logs whether it respected the cancellation of its
+// context within the grace period. If it didn't, the stacks of all goroutines are attached with
+// LOG_INCLUDE_STACK_ON_ERROR, to find where the handler is stuck.
+func logHandlerCancellation(cfg configura.Config, r *http.Request, done <-chan struct{}, panicChan <-chan any, timedOutAt time.Time, grace time.Duration) {
+	logger := slogctx.FromCtx(r.Context())
+	timer := time.NewTimer(grace)
+	defer timer.Stop()
+
+	select {
+	case <-done:
+		logger.LogAttrs(context.Background(), slog.LevelWarn, "Request timed out, handler respected the cancellation",
+			slog.Bool("respected_cancellation", true),
+			slog.Duration("stopped_after", time.Since(timedOutAt)),
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+		)
+	case <-panicChan:
+		logger.LogAttrs(context.Background(), slog.LevelWarn, "Request timed out, handler panicked after the cancellation",
+			slog.Bool("respected_cancellation", true),
+			slog.Duration("stopped_after", time.Since(timedOutAt)),
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+		)
+	case <-timer.C:
+		attrs := []slog.Attr{
+			slog.Bool("respected_cancellation", false),
+			slog.Duration("grace", grace),
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+		}
+		logger.LogAttrs(context.Background(), slog.LevelError, "Request timed out, handler did not stop within the grace period",
+			append(attrs, allStacksAttrs(cfg)...)...)
+	}
+}
@@ -0,0 +1,212 @@
+package middleware
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ponrove/configura"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// syncBuffer is a bytes.Buffer safe for concurrent use, for capturing logs written from other goroutines.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func hardTimeoutConfig(t *testing.T) configura.Config {
+	t.Helper()
+	cfg := configura.NewConfigImpl()
+	err := configura.WriteConfiguration(cfg, map[configura.Variable[string]]string{
+		SERVER_REQUEST_TIMEOUT_MODE: "hard",
+	})
+	require.NoError(t, err)
+	return cfg
+}
+
+func TestTimeout_SoftMode(t *testing.T) {
+	handler := Timeout(configura.NewConfigImpl(), 50*time.Millisecond)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.Equal(t, http.StatusGatewayTimeout, rr.Code)
+}
+
+func TestTimeout_HardMode_ContextAwareHandlerStopsPromptly(t *testing.T) {
+	var logBuffer syncBuffer
+	originalDefaultLogger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&logBuffer, nil)))
+	t.Cleanup(func() { slog.SetDefault(originalDefaultLogger) })
+
+	timeout := 50 * time.Millisecond
+	stopped := make(chan time.Time, 1)
+	handler := Timeout(hardTimeoutConfig(t), timeout)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+			_, _ = w.Write([]byte("too late"))
+		}
+		stopped <- time.Now()
+	}))
+
+	start := time.Now()
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/slow", nil))
+	elapsed := time.Since(start)
+
+	assert.Equal(t, http.StatusGatewayTimeout, rr.Code)
+	assert.NotContains(t, rr.Body.String(), "too late", "Nothing written by the handler should reach the client")
+	assert.Less(t, elapsed, timeout+200*time.Millisecond, "Timeout response should be written as soon as the deadline passes")
+
+	select {
+	case stoppedAt := <-stopped:
+		assert.Less(t, stoppedAt.Sub(start), timeout+200*time.Millisecond, "Handler should stop promptly after the timeout")
+	case <-time.After(time.Second):
+		t.Fatal("Handler did not stop after the timeout")
+	}
+
+	assert.Eventually(t, func() bool {
+		return bytes.Contains([]byte(logBuffer.String()), []byte(`"respected_cancellation":true`))
+	}, time.Second, 10*time.Millisecond, "Expected a log line reporting the handler respected the cancellation")
+}
+
+func TestTimeout_HardMode_HandlerIgnoringCancellationIsLogged(t *testing.T) {
+	var logBuffer syncBuffer
+	originalDefaultLogger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&logBuffer, nil)))
+	t.Cleanup(func() { slog.SetDefault(originalDefaultLogger) })
+
+	cfg := hardTimeoutConfig(t)
+	release := make(chan struct{})
+	t.Cleanup(func() { close(release) })
+	handler := Timeout(cfg, 20*time.Millisecond)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/stuck", nil))
+	assert.Equal(t, http.StatusGatewayTimeout, rr.Code)
+
+	assert.Eventually(t, func() bool {
+		return bytes.Contains([]byte(logBuffer.String()), []byte(`"respected_cancellation":false`))
+	}, 2*time.Second, 10*time.Millisecond, "Expected a log line reporting the handler ignored the cancellation")
+}
+
+func TestTimeout_HardMode_CompletesInTime(t *testing.T) {
+	handler := Timeout(hardTimeoutConfig(t), time.Second)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Test", "value")
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte("created"))
+	}))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/", nil))
+
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	assert.Equal(t, "value", rr.Header().Get("X-Test"))
+	assert.Equal(t, "created", rr.Body.String())
+}
+
+func TestTimeout_PerMethod(t *testing.T) {
+	cfg := configura.NewConfigImpl()
+	err := configura.WriteConfiguration(cfg, map[configura.Variable[int64]]int64{
+		SERVER_REQUEST_TIMEOUT_POST: 1,
+	})
+	require.NoError(t, err)
+
+	// The handler takes 1.5s, longer than the POST timeout but within the global timeout.
+	handler := Timeout(cfg, 10*time.Second)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(1500 * time.Millisecond):
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+
+	start := time.Now()
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/", nil))
+	assert.Equal(t, http.StatusGatewayTimeout, rr.Code, "A slow POST should time out at its method-specific timeout")
+	assert.Less(t, time.Since(start), 1500*time.Millisecond)
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusOK, rr.Code, "A GET should use the global timeout")
+}
+
+func TestTimeout_PerMethodWithoutGlobal(t *testing.T) {
+	cfg := configura.NewConfigImpl()
+	err := configura.WriteConfiguration(cfg, map[configura.Variable[int64]]int64{
+		SERVER_REQUEST_TIMEOUT_DELETE: 1,
+	})
+	require.NoError(t, err)
+
+	handler := Timeout(cfg, 0)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, hasDeadline := r.Context().Deadline()
+		assert.Equal(t, r.Method == http.MethodDelete, hasDeadline)
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodDelete, "/", nil))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+}
+
+func TestTimeout_HardMode_StackOnError(t *testing.T) {
+	for _, enabled := range []bool{false, true} {
+		t.Run(fmt.Sprintf("enabled=%t", enabled), func(t *testing.T) {
+			var logBuffer syncBuffer
+			originalDefaultLogger := slog.Default()
+			slog.SetDefault(slog.New(slog.NewJSONHandler(&logBuffer, nil)))
+			t.Cleanup(func() { slog.SetDefault(originalDefaultLogger) })
+
+			cfg := configura.NewConfigImpl()
+			err := configura.WriteConfiguration(cfg, map[configura.Variable[string]]string{
+				SERVER_REQUEST_TIMEOUT_MODE: "hard",
+			})
+			require.NoError(t, err)
+			err = configura.WriteConfiguration(cfg, map[configura.Variable[bool]]bool{
+				LOG_INCLUDE_STACK_ON_ERROR: enabled,
+			})
+			require.NoError(t, err)
+			release := make(chan struct{})
+			t.Cleanup(func() { close(release) })
+			handler := Timeout(cfg, 20*time.Millisecond)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				<-release
+			}))
+
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/stuck", nil))
+			require.Eventually(t, func() bool {
+				return strings.Contains(logBuffer.String(), `"respected_cancellation":false`)
+			}, 2*time.Second, 10*time.Millisecond, "Expected a log line reporting the handler ignored the cancellation")
+
+			if enabled {
+				assert.Contains(t, logBuffer.String(), `"stack":"goroutine`)
+				assert.Contains(t, logBuffer.String(), "TestTimeout_HardMode_StackOnError", "The stuck handler's stack should be included")
+			} else {
+				assert.NotContains(t, logBuffer.String(), `"stack"`)
+			}
+		})
+	}
+}
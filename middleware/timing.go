@@ -0,0 +1,141 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ponrove/configura"
+)
+
+const (
+	SERVER_TIMING_ENABLED configura.Variable[bool] = "SERVER_TIMING_ENABLED" // Emit Server-Timing headers, off by default
+)
+
+// ctxServerTimingKey is a context key for storing the server timings collected during a request.
+type ctxServerTimingKey struct{}
+
+// serverTimingEntry is a single named metric in the Server-Timing header.
+type serverTimingEntry struct {
+	name        string
+	description string
+	duration    time.Duration
+}
+
+// serverTimings collects the named sub-timings added by handlers during a request.
+type serverTimings struct {
+	mu      sync.Mutex
+	entries []serverTimingEntry
+}
+
+// AddServerTiming records a named sub-timing for the current request, which is emitted in the Server-Timing response
+// header by the ServerTiming middleware. Timings must be added before the handler writes the response headers. It is a
+// no-op if the ServerTiming middleware is not installed or disabled.
+func AddServerTiming(ctx context.Context, name string, duration time.Duration, description string) {
+	timings, ok := ctx.Value(ctxServerTimingKey{}).(*serverTimings)
+	if !ok {
+		return
+	}
+
+	timings.mu.Lock()
+	defer timings.mu.Unlock()
+	timings.entries = append(timings.entries, serverTimingEntry{name: name, description: description, duration: duration})
+}
+
+// serverTimingResponseWriter sets the Server-Timing header right before the response headers are written.
+type serverTimingResponseWriter struct {
+	http.ResponseWriter
+	start       time.Time
+	timings     *serverTimings
+	wroteHeader bool
+}
+
+// Ensure the serverTimingResponseWriter implements the http.ResponseWriter interface at compile time.
+var _ http.ResponseWriter = &serverTimingResponseWriter{}
+
+// Interceptor that adds the Server-Timing header before the status code is written.
+func (w *serverTimingResponseWriter) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		w.Header().Set("Server-Timing", w.timings.header(time.Since(w.start)))
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Interceptor that makes sure the Server-Timing header is added on implicit 200 responses.
+func (w *serverTimingResponseWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush adds the Server-Timing header if the handler flushes the headers before writing the body, and flushes the
+// response to the client.
+func (w *serverTimingResponseWriter) Flush() {
+	_ = w.FlushError()
+}
+
+// FlushError is Flush returning the error of the underlying writer, for http.ResponseController.
+func (w *serverTimingResponseWriter) FlushError() error {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Unwrap returns the wrapped http.ResponseWriter, for http.ResponseController.
+func (w *serverTimingResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// header formats the collected timings and the total duration as a Server-Timing header value, e.g.
+// `db;desc="Query users";dur=12.5, total;dur=20.125`.
+func (t *serverTimings) header(total time.Duration) string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	metrics := make([]string, 0, len(t.entries)+1)
+	for _, e := range t.entries {
+		metric := e.name
+		if e.description != "" {
+			metric += ";desc=" + strconv.Quote(e.description)
+		}
+		metrics = append(metrics, metric+";dur="+formatServerTimingDuration(e.duration))
+	}
+	metrics = append(metrics, "total;dur="+formatServerTimingDuration(total))
+	return strings.Join(metrics, ", ")
+}
+
+// formatServerTimingDuration formats a duration in milliseconds, as required by the Server-Timing specification.
+func formatServerTimingDuration(d time.Duration) string {
+	return fmt.Sprintf("%.3f", float64(d)/float64(time.Millisecond))
+}
+
+// ServerTiming is a middleware that emits a Server-Timing response header with the total handler duration, and any
+// named sub-timings added through AddServerTiming. The middleware is disabled unless SERVER_TIMING_ENABLED is set, to
+// avoid leaking timing information in production. If LogRequest runs before this middleware, the request start time
+// captured by LogRequest is reused for the total duration.
+func ServerTiming(cfg configura.Config) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !cfg.Bool(SERVER_TIMING_ENABLED) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			start, ok := GetRequestStartFromContext(r.Context())
+			if !ok {
+				start = time.Now()
+			}
+
+			timings := &serverTimings{}
+			stw := &serverTimingResponseWriter{ResponseWriter: w, start: start, timings: timings}
+			next.ServeHTTP(stw, r.WithContext(context.WithValue(r.Context(), ctxServerTimingKey{}, timings)))
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/ponrove/configura"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerTiming_Disabled(t *testing.T) {
+	cfg := configura.NewConfigImpl()
+
+	handler := ServerTiming(cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		AddServerTiming(r.Context(), "db", time.Millisecond, "")
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Empty(t, rr.Header().Get("Server-Timing"), "Server-Timing should not be emitted when disabled")
+}
+
+func TestServerTiming_HeaderFormat(t *testing.T) {
+	cfg := configura.NewConfigImpl()
+	err := configura.WriteConfiguration(cfg, map[configura.Variable[bool]]bool{
+		SERVER_TIMING_ENABLED: true,
+	})
+	require.NoError(t, err)
+
+	handler := LogRequest(cfg)(ServerTiming(cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		AddServerTiming(r.Context(), "db", 12500*time.Microsecond, "Query users")
+		AddServerTiming(r.Context(), "cache", 250*time.Microsecond, "")
+		_, _ = w.Write([]byte("ok"))
+	})))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	header := rr.Header().Get("Server-Timing")
+	assert.Regexp(t, regexp.MustCompile(`^db;desc="Query users";dur=12\.500, cache;dur=0\.250, total;dur=\d+\.\d{3}$`), header)
+}
+
+func TestServerTiming_ExplicitStatusCode(t *testing.T) {
+	cfg := configura.NewConfigImpl()
+	err := configura.WriteConfiguration(cfg, map[configura.Variable[bool]]bool{
+		SERVER_TIMING_ENABLED: true,
+	})
+	require.NoError(t, err)
+
+	handler := ServerTiming(cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+	assert.Regexp(t, regexp.MustCompile(`^total;dur=\d+\.\d{3}$`), rr.Header().Get("Server-Timing"))
+}
+
+func TestServerTiming_Flush(t *testing.T) {
+	cfg := configura.NewConfigImpl()
+	err := configura.WriteConfiguration(cfg, map[configura.Variable[bool]]bool{
+		SERVER_TIMING_ENABLED: true,
+	})
+	require.NoError(t, err)
+
+	handler := ServerTiming(cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, http.NewResponseController(w).Flush())
+	}))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.True(t, rr.Flushed, "The flush should reach the underlying ResponseWriter")
+	assert.Regexp(t, regexp.MustCompile(`^total;dur=\d+\.\d{3}$`), rr.Header().Get("Server-Timing"), "Flushing the headers should add the Server-Timing header")
+}
@@ -0,0 +1,135 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/ponrove/configura"
+	slogctx "github.com/veqryn/slog-context"
+)
+
+const (
+	SERVER_MIN_UPLOAD_RATE       configura.Variable[int64] = "SERVER_MIN_UPLOAD_RATE"       // Lowest average rate of a request body upload in bytes per second, disabled by default
+	SERVER_MIN_UPLOAD_RATE_GRACE configura.Variable[int64] = "SERVER_MIN_UPLOAD_RATE_GRACE" // Seconds an upload gets before the minimum rate is enforced, defaults to 5
+)
+
+// ErrUploadTooSlow is returned by the reads of a request body uploaded slower than SERVER_MIN_UPLOAD_RATE.
+var ErrUploadTooSlow = errors.New("request body upload is too slow")
+
+// minRateBody is a request body that fails its reads once the upload falls behind the minimum rate. Before each read
+// the read deadline of the connection is moved to when the next byte is due, so a stalled client is cut off at the
+// deadline instead of holding the handler until the read timeout.
+type minRateBody struct {
+	io.ReadCloser
+	rc      *http.ResponseController
+	rate    int64
+	grace   time.Duration
+	start   time.Time
+	read    int64
+	tooSlow bool
+}
+
+// due returns the time the next byte of the body is due, for the upload to keep the minimum rate after the grace period.
+func (b *minRateBody) due() time.Time {
+	return b.start.Add(b.grace + time.Duration(float64(b.read+1)/float64(b.rate)*float64(time.Second)))
+}
+
+// Interceptor that extends the read deadline of the connection as the body is read, failing with ErrUploadTooSlow once
+// the deadline passes. Writers not supporting read deadlines, e.g. in tests, are checked after each read instead.
+func (b *minRateBody) Read(p []byte) (int, error) {
+	if b.tooSlow {
+		return 0, ErrUploadTooSlow
+	}
+
+	due := b.due()
+	_ = b.rc.SetReadDeadline(due)
+	n, err := b.ReadCloser.Read(p)
+	b.read += int64(n)
+	if errors.Is(err, os.ErrDeadlineExceeded) || (err == nil && time.Now().After(due)) {
+		b.tooSlow = true
+		return n, ErrUploadTooSlow
+	}
+	if err == io.EOF {
+		// Lift the deadline, so it can't interrupt the server's check for a disconnected client while the handler runs.
+		_ = b.rc.SetReadDeadline(time.Time{})
+	}
+	return n, err
+}
+
+// minRateResponseWriter discards the response of a handler once its request body upload was too slow, so the
+// 408 Request Timeout can be written instead of the handler's own error.
+type minRateResponseWriter struct {
+	*captureResponseWriter
+	body *minRateBody
+}
+
+// Interceptor that discards the status code once the upload was too slow.
+func (mw *minRateResponseWriter) WriteHeader(code int) {
+	if mw.body.tooSlow {
+		return
+	}
+	mw.captureResponseWriter.WriteHeader(code)
+}
+
+// Interceptor that fails the writes once the upload was too slow.
+func (mw *minRateResponseWriter) Write(b []byte) (int, error) {
+	if mw.body.tooSlow {
+		return 0, ErrUploadTooSlow
+	}
+	return mw.captureResponseWriter.Write(b)
+}
+
+// MinUploadRate is a middleware that aborts request body uploads whose average rate falls below SERVER_MIN_UPLOAD_RATE
+// bytes per second once SERVER_MIN_UPLOAD_RATE_GRACE has passed, responding with 408 Request Timeout. This protects
+// against clients tying up handlers by trickling a large body in, which the header timeout doesn't cover. The read
+// deadline of the connection is extended as the body arrives, in place of SERVER_READ_TIMEOUT, so set it accordingly.
+// Reads of a body that is too slow fail with ErrUploadTooSlow, and a warning is logged once the handler returns. The
+// middleware is disabled unless SERVER_MIN_UPLOAD_RATE is set.
+func MinUploadRate(cfg configura.Config) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		rate := cfg.Int64(SERVER_MIN_UPLOAD_RATE)
+		if rate <= 0 {
+			return next
+		}
+		grace := time.Duration(configura.Fallback(cfg.Int64(SERVER_MIN_UPLOAD_RATE_GRACE), 5)) * time.Second
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Body == nil || r.Body == http.NoBody {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			body := &minRateBody{
+				ReadCloser: r.Body,
+				rc:         http.NewResponseController(w),
+				rate:       rate,
+				grace:      grace,
+				start:      time.Now(),
+			}
+			r.Body = body
+			mw := &minRateResponseWriter{captureResponseWriter: &captureResponseWriter{ResponseWriter: w}, body: body}
+			next.ServeHTTP(mw, r)
+			if !body.tooSlow {
+				return
+			}
+
+			slogctx.FromCtx(r.Context()).LogAttrs(context.Background(), slog.LevelWarn, "Request body upload too slow, aborted",
+				slog.Int64("min_upload_rate", rate),
+				slog.Int64("read_bytes", body.read),
+				slog.Duration("duration", time.Since(body.start)),
+				slog.String("method", r.Method),
+				slog.String("path", r.URL.Path),
+			)
+			if mw.statusCode == 0 {
+				// The connection can't be reused, the rest of the body is still on its way.
+				w.Header().Set("Connection", "close")
+				Reject(cfg, w, r, http.StatusRequestTimeout, "request body upload is too slow")
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package middleware
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ponrove/configura"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func minUploadRateServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	cfg := configura.NewConfigImpl()
+	err := configura.WriteConfiguration(cfg, map[configura.Variable[int64]]int64{
+		SERVER_MIN_UPLOAD_RATE:       1000,
+		SERVER_MIN_UPLOAD_RATE_GRACE: 1,
+	})
+	require.NoError(t, err)
+
+	srv := httptest.NewServer(MinUploadRate(cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := io.ReadAll(r.Body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestMinUploadRate_SlowUpload(t *testing.T) {
+	var logBuffer syncBuffer
+	originalDefaultLogger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&logBuffer, nil)))
+	t.Cleanup(func() { slog.SetDefault(originalDefaultLogger) })
+
+	srv := minUploadRateServer(t)
+	conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// Announce a large body, then stall after the first bytes.
+	_, err = io.WriteString(conn, "POST /upload HTTP/1.1\r\nHost: example.com\r\nContent-Length: 100000\r\n\r\nabcdefghij")
+	require.NoError(t, err)
+
+	start := time.Now()
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusRequestTimeout, resp.StatusCode)
+	assert.Less(t, time.Since(start), 3*time.Second, "The upload should be aborted shortly after the grace period")
+
+	var logged map[string]any
+	require.NoError(t, json.Unmarshal([]byte(logBuffer.String()), &logged))
+	assert.Equal(t, "Request body upload too slow, aborted", logged["msg"])
+	assert.Equal(t, float64(10), logged["read_bytes"])
+}
+
+func TestMinUploadRate_FastUpload(t *testing.T) {
+	srv := minUploadRateServer(t)
+	resp, err := http.Post(srv.URL, "text/plain", strings.NewReader(strings.Repeat("x", 100000)))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+}
+
+func TestMinUploadRate_DisabledByDefault(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	handler := MinUploadRate(configura.NewConfigImpl())(next)
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/", strings.NewReader("body")))
+	assert.Equal(t, http.StatusOK, rr.Code)
+}
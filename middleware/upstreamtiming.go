@@ -0,0 +1,110 @@
+package middleware
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ponrove/configura"
+)
+
+const (
+	REQUEST_LOG_UPSTREAM_TIMING configura.Variable[bool] = "REQUEST_LOG_UPSTREAM_TIMING" // Log the Server-Timing reported by upstreams in an upstream_timing group
+)
+
+// ctxUpstreamTimingKey is a context key for storing the timings reported by the upstreams called during a request.
+type ctxUpstreamTimingKey struct{}
+
+// upstreamTimings aggregates the Server-Timing metrics reported by upstreams, summed per upstream and metric name, in
+// the order they were first reported.
+type upstreamTimings struct {
+	mu        sync.Mutex
+	upstreams []string
+	metrics   map[string][]serverTimingEntry
+}
+
+// parseServerTiming parses the metrics of Server-Timing header values, e.g. `db;desc="Query users";dur=12.5, cache`.
+// Metrics without a duration are left out.
+func parseServerTiming(values []string) []serverTimingEntry {
+	var entries []serverTimingEntry
+	for _, value := range values {
+		for _, metric := range strings.Split(value, ",") {
+			params := strings.Split(metric, ";")
+			name := strings.TrimSpace(params[0])
+			if name == "" {
+				continue
+			}
+			for _, param := range params[1:] {
+				key, v, ok := strings.Cut(strings.TrimSpace(param), "=")
+				if !ok || !strings.EqualFold(strings.TrimSpace(key), "dur") {
+					continue
+				}
+				ms, err := strconv.ParseFloat(strings.Trim(strings.TrimSpace(v), `"`), 64)
+				if err != nil || ms < 0 {
+					continue
+				}
+				entries = append(entries, serverTimingEntry{name: name, duration: time.Duration(ms * float64(time.Millisecond))})
+				break
+			}
+		}
+	}
+	return entries
+}
+
+// RecordUpstreamServerTiming records the Server-Timing header values of a response from upstream (e.g. its host), to
+// be logged by LogRequest in the upstream_timing group of the access log, with the durations of each metric summed
+// over the calls to the upstream. The HTTP client returned by ponrunner.NewHTTPClient records them for every response.
+// It is a no-op unless REQUEST_LOG_UPSTREAM_TIMING is set, and LogRequest runs before the handler.
+func RecordUpstreamServerTiming(ctx context.Context, upstream string, values []string) {
+	timings, ok := ctx.Value(ctxUpstreamTimingKey{}).(*upstreamTimings)
+	if !ok || len(values) == 0 {
+		return
+	}
+	entries := parseServerTiming(values)
+	if len(entries) == 0 {
+		return
+	}
+
+	timings.mu.Lock()
+	defer timings.mu.Unlock()
+	metrics, known := timings.metrics[upstream]
+	if !known {
+		timings.upstreams = append(timings.upstreams, upstream)
+	}
+	for _, entry := range entries {
+		found := false
+		for i := range metrics {
+			if metrics[i].name == entry.name {
+				metrics[i].duration += entry.duration
+				found = true
+				break
+			}
+		}
+		if !found {
+			metrics = append(metrics, entry)
+		}
+	}
+	timings.metrics[upstream] = metrics
+}
+
+// attr returns the timings as a group per upstream, or false if no upstream reported any.
+func (t *upstreamTimings) attr(key string) (slog.Attr, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.upstreams) == 0 {
+		return slog.Attr{}, false
+	}
+
+	groups := make([]any, 0, len(t.upstreams))
+	for _, upstream := range t.upstreams {
+		metrics := make([]any, 0, len(t.metrics[upstream]))
+		for _, entry := range t.metrics[upstream] {
+			metrics = append(metrics, slog.Duration(entry.name, entry.duration))
+		}
+		groups = append(groups, slog.Group(upstream, metrics...))
+	}
+	return slog.Group(key, groups...), true
+}
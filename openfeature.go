@@ -57,5 +57,5 @@ func setOpenFeatureProvider(cfg configura.Config) error {
 		return fmt.Errorf("%w: %s", ErrUnsupportedOpenFeatureProvider, cfg.String(SERVER_OPENFEATURE_PROVIDER_NAME))
 	}
 
-	return openfeature.SetProviderAndWait(provider)
+	return openfeature.SetProviderAndWait(newBreakerProvider(cfg, provider))
 }
@@ -0,0 +1,214 @@
+package ponrunner
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/open-feature/go-sdk/openfeature"
+	"github.com/ponrove/configura"
+)
+
+const (
+	SERVER_OPENFEATURE_BREAKER_THRESHOLD configura.Variable[int64] = "SERVER_OPENFEATURE_BREAKER_THRESHOLD" // Consecutive provider failures opening the breaker, defaults to 5, negative disables
+	SERVER_OPENFEATURE_BREAKER_COOLDOWN  configura.Variable[int64] = "SERVER_OPENFEATURE_BREAKER_COOLDOWN"  // Seconds the breaker stays open before retrying the provider, defaults to 30
+)
+
+// Breaker states, as logged on transitions.
+const (
+	breakerClosed   = "closed"
+	breakerOpen     = "open"
+	breakerHalfOpen = "half-open"
+)
+
+// flagBreaker is a circuit breaker over the evaluations of a feature flag provider. After threshold consecutive
+// provider failures it opens, and evaluations fail fast for the cooldown. Then it lets evaluations through again
+// (half-open), closing on the first success or reopening on the first failure.
+type flagBreaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	failures  int
+	state     string
+	openUntil time.Time
+}
+
+// allow reports whether an evaluation may reach the provider.
+func (b *flagBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state != breakerOpen {
+		return true
+	}
+	if time.Now().Before(b.openUntil) {
+		return false
+	}
+	b.transition(breakerHalfOpen)
+	return true
+}
+
+// record updates the breaker with the outcome of an evaluation. Only errors hinting at an unhealthy provider count as
+// failures, a missing flag or a type mismatch is a problem of the flag, not of the provider.
+func (b *flagBreaker) record(detail openfeature.ProviderResolutionDetail) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch detail.ResolutionDetail().ErrorCode {
+	case openfeature.GeneralCode, openfeature.ProviderNotReadyCode, openfeature.ProviderFatalCode, openfeature.ParseErrorCode:
+		b.failures++
+		if b.state == breakerHalfOpen || b.failures >= b.threshold {
+			b.openUntil = time.Now().Add(b.cooldown)
+			b.transition(breakerOpen)
+		}
+	default:
+		b.failures = 0
+		b.transition(breakerClosed)
+	}
+}
+
+// transition changes the state of the breaker, logging the change. It must be called with the lock held.
+func (b *flagBreaker) transition(state string) {
+	if b.state == state {
+		return
+	}
+	level := slog.LevelInfo
+	if state == breakerOpen {
+		level = slog.LevelWarn
+	}
+	slog.Log(context.Background(), level, "Feature flag circuit breaker state changed",
+		slog.String("from", b.state),
+		slog.String("to", state),
+		slog.Int("consecutive_failures", b.failures))
+	b.state = state
+}
+
+// openDetail is the resolution of an evaluation short-circuited by the open breaker. Like any resolution error, it
+// makes the OpenFeature client return the default value of the evaluation.
+func (b *flagBreaker) openDetail() openfeature.ProviderResolutionDetail {
+	return openfeature.ProviderResolutionDetail{
+		ResolutionError: openfeature.NewGeneralResolutionError("feature flag provider circuit breaker is open"),
+		Reason:          openfeature.ErrorReason,
+	}
+}
+
+// breakerProvider wraps a feature flag provider with a flagBreaker. Failed evaluations always resolve to the default
+// value, so handlers behave predictably while the provider is unhealthy.
+type breakerProvider struct {
+	openfeature.FeatureProvider
+	breaker *flagBreaker
+}
+
+// breakerEventProvider is a breakerProvider forwarding the events of a provider implementing openfeature.EventHandler.
+type breakerEventProvider struct {
+	*breakerProvider
+	events openfeature.EventHandler
+}
+
+// EventChannel forwards the events of the wrapped provider.
+func (p *breakerEventProvider) EventChannel() <-chan openfeature.Event {
+	return p.events.EventChannel()
+}
+
+// newBreakerProvider wraps the provider with a circuit breaker configured by SERVER_OPENFEATURE_BREAKER_THRESHOLD and
+// SERVER_OPENFEATURE_BREAKER_COOLDOWN. The provider is returned as is if the breaker is disabled.
+func newBreakerProvider(cfg configura.Config, provider openfeature.FeatureProvider) openfeature.FeatureProvider {
+	threshold := configura.Fallback(cfg.Int64(SERVER_OPENFEATURE_BREAKER_THRESHOLD), 5)
+	if threshold < 0 {
+		return provider
+	}
+
+	p := &breakerProvider{
+		FeatureProvider: provider,
+		breaker: &flagBreaker{
+			threshold: int(threshold),
+			cooldown:  time.Duration(configura.Fallback(cfg.Int64(SERVER_OPENFEATURE_BREAKER_COOLDOWN), 30)) * time.Second,
+			state:     breakerClosed,
+		},
+	}
+	if events, ok := provider.(openfeature.EventHandler); ok {
+		return &breakerEventProvider{breakerProvider: p, events: events}
+	}
+	return p
+}
+
+// Init initializes the wrapped provider, if it implements openfeature.StateHandler.
+func (p *breakerProvider) Init(evalCtx openfeature.EvaluationContext) error {
+	if handler, ok := p.FeatureProvider.(openfeature.StateHandler); ok {
+		return handler.Init(evalCtx)
+	}
+	return nil
+}
+
+// Shutdown shuts the wrapped provider down, if it implements openfeature.StateHandler.
+func (p *breakerProvider) Shutdown() {
+	if handler, ok := p.FeatureProvider.(openfeature.StateHandler); ok {
+		handler.Shutdown()
+	}
+}
+
+// BooleanEvaluation evaluates a boolean flag through the breaker.
+func (p *breakerProvider) BooleanEvaluation(ctx context.Context, flag string, defaultValue bool, evalCtx openfeature.FlattenedContext) openfeature.BoolResolutionDetail {
+	if !p.breaker.allow() {
+		return openfeature.BoolResolutionDetail{Value: defaultValue, ProviderResolutionDetail: p.breaker.openDetail()}
+	}
+	res := p.FeatureProvider.BooleanEvaluation(ctx, flag, defaultValue, evalCtx)
+	p.breaker.record(res.ProviderResolutionDetail)
+	if res.Error() != nil {
+		res.Value = defaultValue
+	}
+	return res
+}
+
+// StringEvaluation evaluates a string flag through the breaker.
+func (p *breakerProvider) StringEvaluation(ctx context.Context, flag string, defaultValue string, evalCtx openfeature.FlattenedContext) openfeature.StringResolutionDetail {
+	if !p.breaker.allow() {
+		return openfeature.StringResolutionDetail{Value: defaultValue, ProviderResolutionDetail: p.breaker.openDetail()}
+	}
+	res := p.FeatureProvider.StringEvaluation(ctx, flag, defaultValue, evalCtx)
+	p.breaker.record(res.ProviderResolutionDetail)
+	if res.Error() != nil {
+		res.Value = defaultValue
+	}
+	return res
+}
+
+// FloatEvaluation evaluates a float flag through the breaker.
+func (p *breakerProvider) FloatEvaluation(ctx context.Context, flag string, defaultValue float64, evalCtx openfeature.FlattenedContext) openfeature.FloatResolutionDetail {
+	if !p.breaker.allow() {
+		return openfeature.FloatResolutionDetail{Value: defaultValue, ProviderResolutionDetail: p.breaker.openDetail()}
+	}
+	res := p.FeatureProvider.FloatEvaluation(ctx, flag, defaultValue, evalCtx)
+	p.breaker.record(res.ProviderResolutionDetail)
+	if res.Error() != nil {
+		res.Value = defaultValue
+	}
+	return res
+}
+
+// IntEvaluation evaluates an integer flag through the breaker.
+func (p *breakerProvider) IntEvaluation(ctx context.Context, flag string, defaultValue int64, evalCtx openfeature.FlattenedContext) openfeature.IntResolutionDetail {
+	if !p.breaker.allow() {
+		return openfeature.IntResolutionDetail{Value: defaultValue, ProviderResolutionDetail: p.breaker.openDetail()}
+	}
+	res := p.FeatureProvider.IntEvaluation(ctx, flag, defaultValue, evalCtx)
+	p.breaker.record(res.ProviderResolutionDetail)
+	if res.Error() != nil {
+		res.Value = defaultValue
+	}
+	return res
+}
+
+// ObjectEvaluation evaluates an object flag through the breaker.
+func (p *breakerProvider) ObjectEvaluation(ctx context.Context, flag string, defaultValue any, evalCtx openfeature.FlattenedContext) openfeature.InterfaceResolutionDetail {
+	if !p.breaker.allow() {
+		return openfeature.InterfaceResolutionDetail{Value: defaultValue, ProviderResolutionDetail: p.breaker.openDetail()}
+	}
+	res := p.FeatureProvider.ObjectEvaluation(ctx, flag, defaultValue, evalCtx)
+	p.breaker.record(res.ProviderResolutionDetail)
+	if res.Error() != nil {
+		res.Value = defaultValue
+	}
+	return res
+}
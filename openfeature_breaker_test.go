@@ -0,0 +1,83 @@
+package ponrunner
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+
+	"github.com/open-feature/go-sdk/openfeature"
+	"github.com/ponrove/configura"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// failingProvider is a feature flag provider whose evaluations fail, as when its backend is unreachable.
+type failingProvider struct {
+	openfeature.NoopProvider
+	calls atomic.Int64
+}
+
+// BooleanEvaluation fails with a general error, returning a value other than the default.
+func (p *failingProvider) BooleanEvaluation(ctx context.Context, flag string, defaultValue bool, evalCtx openfeature.FlattenedContext) openfeature.BoolResolutionDetail {
+	p.calls.Add(1)
+	return openfeature.BoolResolutionDetail{
+		Value: !defaultValue,
+		ProviderResolutionDetail: openfeature.ProviderResolutionDetail{
+			ResolutionError: openfeature.NewGeneralResolutionError("connection refused"),
+			Reason:          openfeature.ErrorReason,
+		},
+	}
+}
+
+func TestBreakerProvider_ProviderErrors(t *testing.T) {
+	cfg := configura.NewConfigImpl()
+	err := configura.WriteConfiguration(cfg, map[configura.Variable[int64]]int64{
+		SERVER_OPENFEATURE_BREAKER_THRESHOLD: 3,
+		SERVER_OPENFEATURE_BREAKER_COOLDOWN:  60,
+	})
+	require.NoError(t, err)
+
+	failing := &failingProvider{}
+	provider := newBreakerProvider(cfg, failing)
+	require.NoError(t, openfeature.SetNamedProviderAndWait("breaker-test", provider))
+	client := openfeature.NewClient("breaker-test")
+
+	for range 10 {
+		value, err := client.BooleanValue(context.Background(), "new-checkout", true, openfeature.EvaluationContext{})
+		assert.Error(t, err)
+		assert.True(t, value, "The default value should be returned when the provider fails")
+	}
+
+	assert.Equal(t, int64(3), failing.calls.Load(), "The breaker should open after 3 failures and stop calling the provider")
+	assert.Equal(t, breakerOpen, provider.(*breakerProvider).breaker.state)
+}
+
+func TestBreakerProvider_HalfOpen(t *testing.T) {
+	breaker := &flagBreaker{threshold: 1, state: breakerClosed}
+	failure := openfeature.ProviderResolutionDetail{ResolutionError: openfeature.NewGeneralResolutionError("timeout")}
+
+	breaker.record(failure)
+	assert.Equal(t, breakerOpen, breaker.state)
+
+	// With no cooldown, the next evaluation is let through to probe the provider.
+	assert.True(t, breaker.allow())
+	assert.Equal(t, breakerHalfOpen, breaker.state)
+
+	breaker.record(openfeature.ProviderResolutionDetail{Reason: openfeature.StaticReason})
+	assert.Equal(t, breakerClosed, breaker.state)
+
+	// A missing flag is not a provider failure.
+	breaker.record(openfeature.ProviderResolutionDetail{ResolutionError: openfeature.NewFlagNotFoundResolutionError("unknown")})
+	assert.Equal(t, breakerClosed, breaker.state)
+}
+
+func TestBreakerProvider_Disabled(t *testing.T) {
+	cfg := configura.NewConfigImpl()
+	err := configura.WriteConfiguration(cfg, map[configura.Variable[int64]]int64{
+		SERVER_OPENFEATURE_BREAKER_THRESHOLD: -1,
+	})
+	require.NoError(t, err)
+
+	failing := &failingProvider{}
+	assert.Same(t, failing, newBreakerProvider(cfg, failing))
+}
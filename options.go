@@ -0,0 +1,136 @@
+package ponrunner
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"os"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/danielgtaylor/huma/v2/adapters/humachi"
+	"github.com/go-chi/chi/v5"
+	"github.com/ponrove/configura"
+	"github.com/ponrove/ponrunner/middleware"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// APIFactory builds the huma.API that routes are registered on. The router is the chi router Start serves, with
+// ponrunner's middleware already applied; an API built on a different adapter is expected to be mounted on it, so it
+// still shares the server's lifecycle, telemetry and shutdown.
+type APIFactory func(cfg configura.Config, router chi.Router, config huma.Config) huma.API
+
+// Option configures optional behaviour of Start.
+type Option func(*options)
+
+// options holds the optional settings of Start, see the With* functions for the available options.
+type options struct {
+	apiFactory          APIFactory
+	geoIPResolver       middleware.GeoIPResolver
+	listener            net.Listener
+	middleware          []func(http.Handler) http.Handler
+	noDefaultMiddleware bool
+	store               middleware.Store
+	onListen            []func(net.Addr) error
+	onShutdown          []shutdownHook
+	shutdownSignals     []os.Signal
+	spanExporter        sdktrace.SpanExporter
+}
+
+// newOptions returns the default options with the given options applied.
+func newOptions(opts ...Option) *options {
+	o := &options{
+		apiFactory:      defaultAPIFactory,
+		shutdownSignals: defaultShutdownSignals,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// defaultAPIFactory builds the huma.API with the chi adapter, directly on the router.
+func defaultAPIFactory(_ configura.Config, router chi.Router, config huma.Config) huma.API {
+	return humachi.New(router, config)
+}
+
+// WithAPIFactory replaces the chi adapter used to build the huma.API, e.g. to use humago or humamux for teams that
+// standardize on a different router. A nil factory keeps the default.
+func WithAPIFactory(factory APIFactory) Option {
+	return func(o *options) {
+		if factory != nil {
+			o.apiFactory = factory
+		}
+	}
+}
+
+// WithGeoIPResolver enables the middleware.GeoIP middleware, resolving the country of clients with the resolver, e.g. a
+// lookup in a MaxMind database supplied by the application. The country is logged in the access log.
+func WithGeoIPResolver(resolver middleware.GeoIPResolver) Option {
+	return func(o *options) {
+		o.geoIPResolver = resolver
+	}
+}
+
+// WithOnListen registers a function run once the listener is bound, after the routes are registered and right before
+// the server starts accepting connections, with the address it listens on, e.g. to warm caches or register the
+// instance with service discovery. Functions run in the order they are given; an error aborts the startup, and is
+// returned by Start.
+func WithOnListen(fn func(addr net.Addr) error) Option {
+	return func(o *options) {
+		o.onListen = append(o.onListen, fn)
+	}
+}
+
+// WithOnShutdown registers a function run once the server has shut down, after the workers have stopped and the
+// shutdown hooks have run (in the default SHUTDOWN_ORDER), e.g. to deregister the instance from service discovery.
+// Functions run in the reverse order they are given, sharing SERVER_SHUTDOWN_TIMEOUT, and their errors are logged. They
+// only run if the server started listening.
+func WithOnShutdown(fn func(ctx context.Context) error) Option {
+	return func(o *options) {
+		o.onShutdown = append(o.onShutdown, shutdownHook{name: "OnShutdown", run: fn})
+	}
+}
+
+// WithShutdownSignals replaces the OS signals triggering a graceful shutdown, SIGHUP, SIGINT, SIGTERM and SIGQUIT by
+// default, e.g. to leave SIGHUP to the application for reloading its configuration. Without any signal, the server
+// only shuts down when the context passed to Start is canceled.
+func WithShutdownSignals(signals ...os.Signal) Option {
+	return func(o *options) {
+		o.shutdownSignals = signals
+	}
+}
+
+// WithStore keeps the state of the RateLimit and Idempotency middleware in store instead of in memory, e.g. a store
+// backed by Redis, so rate limits and idempotency keys apply across the replicas of the service.
+func WithStore(store middleware.Store) Option {
+	return func(o *options) {
+		o.store = store
+	}
+}
+
+// WithSpanExporter exports the spans to exporter instead of the exporter configured with the OTEL_EXPORTER_OTLP_* keys,
+// e.g. the exporter of a vendor's SDK, or an in-memory exporter in tests. The spans are still batched with the OTEL_BSP_*
+// keys and bounded by OTEL_EXPORT_MAX_QUEUE_SIZE, and only exported with OTEL_ENABLED and OTEL_TRACES_ENABLED. The
+// exporter is shut down with the tracer provider.
+func WithSpanExporter(exporter sdktrace.SpanExporter) Option {
+	return func(o *options) {
+		o.spanExporter = exporter
+	}
+}
+
+// WithMiddleware adds middleware to the router, after ponrunner's default middleware (if any), in the given order.
+func WithMiddleware(middleware ...func(http.Handler) http.Handler) Option {
+	return func(o *options) {
+		o.middleware = append(o.middleware, middleware...)
+	}
+}
+
+// WithNoDefaultMiddleware leaves ponrunner's default middleware out of the router, for full control of the chain with
+// WithMiddleware. Without it, there is no access log, request ID, recovery from panics, request timeout or rejection of
+// requests while draining, and the context getters of the middleware package (e.g. GetIPAddressFromContext) return
+// empty values. The health endpoints, telemetry and graceful shutdown are unaffected.
+func WithNoDefaultMiddleware() Option {
+	return func(o *options) {
+		o.noDefaultMiddleware = true
+	}
+}
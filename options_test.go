@@ -0,0 +1,156 @@
+package ponrunner
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/danielgtaylor/huma/v2/adapters/humago"
+	"github.com/go-chi/chi/v5"
+	"github.com/ponrove/configura"
+	"github.com/ponrove/ponrunner/middleware"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type greetingOutput struct {
+	Body struct {
+		Message string `json:"message"`
+	}
+}
+
+func TestStart_WithAPIFactory(t *testing.T) {
+	t.Parallel()
+
+	freePort, err := getFreePort()
+	require.NoError(t, err, "Failed to get free port")
+
+	emptyCfg := configura.NewConfigImpl()
+	err = configura.WriteConfiguration(emptyCfg, map[configura.Variable[int64]]int64{
+		SERVER_PORT: int64(freePort),
+	})
+	require.NoError(t, err, "Failed to write free port to configuration")
+	finalCfg := configura.Merge(newDefaultCfg(), emptyCfg)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var factoryAPI huma.API
+	factory := func(cfg configura.Config, router chi.Router, config huma.Config) huma.API {
+		mux := http.NewServeMux()
+		router.Mount("/", mux)
+		factoryAPI = humago.New(mux, config)
+		return factoryAPI
+	}
+
+	startErrChan := make(chan error, 1)
+	go func() {
+		startErrChan <- Start(ctx, finalCfg, chi.NewRouter(), func(cfg configura.Config, r chi.Router, api huma.API) error {
+			assert.Same(t, factoryAPI, api, "Routes should be registered on the API built by the factory")
+			huma.Get(api, "/greeting", func(ctx context.Context, input *struct{}) (*greetingOutput, error) {
+				out := &greetingOutput{}
+				out.Body.Message = "hello from humago"
+				return out, nil
+			})
+			return nil
+		}, WithAPIFactory(factory))
+	}()
+
+	url := fmt.Sprintf("http://localhost:%d/greeting", freePort)
+	var resp *http.Response
+	require.Eventually(t, func() bool {
+		resp, err = http.Get(url)
+		return err == nil
+	}, 2*time.Second, 50*time.Millisecond, "server never started")
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, string(body), "hello from humago")
+
+	cancel()
+	select {
+	case err := <-startErrChan:
+		assert.NoError(t, err, "Start should exit gracefully without error")
+	case <-time.After(3 * time.Second):
+		t.Fatal("Start did not exit after context cancellation")
+	}
+}
+
+func TestStart_WithNoDefaultMiddleware(t *testing.T) {
+	// Not parallel, the process's stdout is replaced to capture the logs.
+	stdout, err := os.CreateTemp(t.TempDir(), "stdout")
+	require.NoError(t, err)
+	originalStdout := os.Stdout
+	os.Stdout = stdout
+	originalSlogLogger := slog.Default()
+	t.Cleanup(func() {
+		os.Stdout = originalStdout
+		slog.SetDefault(originalSlogLogger)
+	})
+
+	freePort, err := getFreePort()
+	require.NoError(t, err, "Failed to get free port")
+
+	emptyCfg := configura.NewConfigImpl()
+	err = configura.WriteConfiguration(emptyCfg, map[configura.Variable[int64]]int64{
+		SERVER_PORT: int64(freePort),
+	})
+	require.NoError(t, err, "Failed to write free port to configuration")
+	finalCfg := configura.Merge(newDefaultCfg(), emptyCfg)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	custom := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Custom", "yes")
+			next.ServeHTTP(w, r)
+		})
+	}
+
+	startErrChan := make(chan error, 1)
+	go func() {
+		startErrChan <- Start(ctx, finalCfg, chi.NewRouter(), func(cfg configura.Config, r chi.Router, api huma.API) error {
+			r.Get("/ip", func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte("ip=" + middleware.GetIPAddressFromContext(r.Context())))
+			})
+			return nil
+		}, WithNoDefaultMiddleware(), WithMiddleware(custom))
+	}()
+
+	url := fmt.Sprintf("http://localhost:%d/ip", freePort)
+	var resp *http.Response
+	require.Eventually(t, func() bool {
+		resp, err = http.Get(url)
+		return err == nil
+	}, 2*time.Second, 50*time.Millisecond, "server never started")
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "ip=", string(body), "Context helpers should return empty values without their middleware")
+	assert.Equal(t, "yes", resp.Header.Get("X-Custom"), "The caller's middleware should be installed")
+	assert.Empty(t, resp.Header.Get("Cache-Control"), "The default middleware should not be installed")
+
+	cancel()
+	select {
+	case err := <-startErrChan:
+		assert.NoError(t, err, "Start should exit gracefully without error")
+	case <-time.After(3 * time.Second):
+		t.Fatal("Start did not exit after context cancellation")
+	}
+
+	logs, err := os.ReadFile(stdout.Name())
+	require.NoError(t, err)
+	assert.Contains(t, string(logs), "Starting server", "The server logs should be captured")
+	assert.NotContains(t, string(logs), "HTTP request processed", "There should be no access log")
+}
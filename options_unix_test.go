@@ -0,0 +1,83 @@
+//go:build unix
+
+package ponrunner
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/go-chi/chi/v5"
+	"github.com/ponrove/configura"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// startWithShutdownSignals starts a server in the background, shutting down on SIGUSR2 only.
+func startWithShutdownSignals(t *testing.T) *Server {
+	t.Helper()
+	cfg := configura.NewConfigImpl()
+	err := configura.WriteConfiguration(cfg, map[configura.Variable[string]]string{
+		SERVER_HOST: "127.0.0.1",
+	})
+	require.NoError(t, err)
+	err = configura.WriteConfiguration(cfg, map[configura.Variable[int64]]int64{
+		SERVER_PORT: 0,
+	})
+	require.NoError(t, err)
+
+	server, err := StartAsync(context.Background(), configura.Merge(newDefaultCfg(), cfg), chi.NewRouter(), func(c configura.Config, r chi.Router, a huma.API) error {
+		return nil
+	}, WithShutdownSignals(syscall.SIGUSR2))
+	require.NoError(t, err)
+	return server
+}
+
+// waitForServer returns the error of the server's Wait, or false if it's still running after timeout.
+func waitForServer(server *Server, timeout time.Duration) (error, bool) {
+	done := make(chan error, 1)
+	go func() { done <- server.Wait() }()
+	select {
+	case err := <-done:
+		return err, true
+	case <-time.After(timeout):
+		return nil, false
+	}
+}
+
+func TestWithShutdownSignals(t *testing.T) {
+	// Not parallel, the signal is sent to the process.
+	server := startWithShutdownSignals(t)
+
+	require.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGUSR2))
+	err, stopped := waitForServer(server, 5*time.Second)
+	require.True(t, stopped, "The server should shut down on the configured signal")
+	assert.NoError(t, err, "The server should shut down gracefully")
+}
+
+func TestWithShutdownSignals_ExcludedSignal(t *testing.T) {
+	// Not parallel, the signal is sent to the process.
+	server := startWithShutdownSignals(t)
+
+	// The application handles SIGHUP itself, e.g. to reload its configuration.
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+	defer signal.Stop(reload)
+
+	require.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGHUP))
+	select {
+	case <-reload:
+	case <-time.After(time.Second):
+		t.Fatal("The application should receive SIGHUP")
+	}
+	_, stopped := waitForServer(server, 500*time.Millisecond)
+	assert.False(t, stopped, "SIGHUP should not shut the server down")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	assert.NoError(t, server.Shutdown(ctx))
+}
@@ -0,0 +1,58 @@
+package ponrunner
+
+import "github.com/ponrove/configura"
+
+// RequiredOTelKeys returns the configuration keys that must be registered for Start to set up OpenTelemetry, typed as
+// the arguments of configura.Config.ConfigurationKeysRegistered. RegisterOTelDefaults registers all of them at once.
+func RequiredOTelKeys() []any {
+	return []any{
+		OTEL_ENABLED,
+		OTEL_SDK_DISABLED,
+		OTEL_LOGS_ENABLED,
+		OTEL_METRICS_ENABLED,
+		OTEL_TRACES_ENABLED,
+		OTEL_SERVICE_NAME,
+		OTEL_EXPORTER_OTLP_ENDPOINT,
+		OTEL_EXPORTER_OTLP_TRACES_ENDPOINT,
+		OTEL_EXPORTER_OTLP_METRICS_ENDPOINT,
+		OTEL_EXPORTER_OTLP_LOGS_ENDPOINT,
+		OTEL_EXPORTER_OTLP_HEADERS,
+		OTEL_EXPORTER_OTLP_TRACES_HEADERS,
+		OTEL_EXPORTER_OTLP_METRICS_HEADERS,
+		OTEL_EXPORTER_OTLP_LOGS_HEADERS,
+		OTEL_EXPORTER_OTLP_TIMEOUT,
+		OTEL_EXPORTER_OTLP_TRACES_TIMEOUT,
+		OTEL_EXPORTER_OTLP_METRICS_TIMEOUT,
+		OTEL_EXPORTER_OTLP_LOGS_TIMEOUT,
+		OTEL_EXPORTER_OTLP_PROTOCOL,
+		OTEL_EXPORTER_OTLP_TRACES_PROTOCOL,
+		OTEL_EXPORTER_OTLP_METRICS_PROTOCOL,
+		OTEL_EXPORTER_OTLP_LOGS_PROTOCOL,
+	}
+}
+
+// RegisterOTelDefaults loads the keys returned by RequiredOTelKeys from the environment into the configuration, with
+// defaults for the ones that aren't set: OpenTelemetry disabled (and OTEL_SDK_DISABLED unset), all signals enabled once it is, exported to stdout
+// (no endpoint) with a 10 second timeout.
+func RegisterOTelDefaults(cfg *configura.ConfigImpl) {
+	for _, key := range []configura.Variable[bool]{OTEL_LOGS_ENABLED, OTEL_METRICS_ENABLED, OTEL_TRACES_ENABLED} {
+		configura.LoadEnvironment(cfg, key, true)
+	}
+	configura.LoadEnvironment(cfg, OTEL_ENABLED, false)
+	configura.LoadEnvironment(cfg, OTEL_SDK_DISABLED, false)
+
+	for _, key := range []configura.Variable[string]{
+		OTEL_SERVICE_NAME,
+		OTEL_EXPORTER_OTLP_ENDPOINT, OTEL_EXPORTER_OTLP_TRACES_ENDPOINT, OTEL_EXPORTER_OTLP_METRICS_ENDPOINT, OTEL_EXPORTER_OTLP_LOGS_ENDPOINT,
+		OTEL_EXPORTER_OTLP_HEADERS, OTEL_EXPORTER_OTLP_TRACES_HEADERS, OTEL_EXPORTER_OTLP_METRICS_HEADERS, OTEL_EXPORTER_OTLP_LOGS_HEADERS,
+		OTEL_EXPORTER_OTLP_PROTOCOL, OTEL_EXPORTER_OTLP_TRACES_PROTOCOL, OTEL_EXPORTER_OTLP_METRICS_PROTOCOL, OTEL_EXPORTER_OTLP_LOGS_PROTOCOL,
+	} {
+		configura.LoadEnvironment(cfg, key, "")
+	}
+
+	for _, key := range []configura.Variable[int64]{
+		OTEL_EXPORTER_OTLP_TIMEOUT, OTEL_EXPORTER_OTLP_TRACES_TIMEOUT, OTEL_EXPORTER_OTLP_METRICS_TIMEOUT, OTEL_EXPORTER_OTLP_LOGS_TIMEOUT,
+	} {
+		configura.LoadEnvironment(cfg, key, 10)
+	}
+}
@@ -0,0 +1,56 @@
+package ponrunner
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+
+	"github.com/ponrove/configura"
+)
+
+const (
+	OTEL_EXPORTER_OTLP_CERTIFICATE        configura.Variable[string] = "OTEL_EXPORTER_OTLP_CERTIFICATE"        // PEM CA certificates the OTLP collector's certificate is verified with, the system's by default
+	OTEL_EXPORTER_OTLP_CLIENT_CERTIFICATE configura.Variable[string] = "OTEL_EXPORTER_OTLP_CLIENT_CERTIFICATE" // PEM client certificate presented to the OTLP collector for mutual TLS, requires OTEL_EXPORTER_OTLP_CLIENT_KEY
+	OTEL_EXPORTER_OTLP_CLIENT_KEY         configura.Variable[string] = "OTEL_EXPORTER_OTLP_CLIENT_KEY"         // PEM private key of OTEL_EXPORTER_OTLP_CLIENT_CERTIFICATE
+)
+
+// otlpTLSConfig returns the TLS configuration of the OTLP exporters, trusting the CA certificates of
+// OTEL_EXPORTER_OTLP_CERTIFICATE and presenting the client certificate of OTEL_EXPORTER_OTLP_CLIENT_CERTIFICATE and
+// OTEL_EXPORTER_OTLP_CLIENT_KEY, or nil to use the defaults of the exporters if none of them is set. The files are
+// loaded up front, so a missing or invalid file fails the setup rather than every export.
+func otlpTLSConfig(cfg configura.Config) (*tls.Config, error) {
+	caFile := cfg.String(OTEL_EXPORTER_OTLP_CERTIFICATE)
+	certFile, keyFile := cfg.String(OTEL_EXPORTER_OTLP_CLIENT_CERTIFICATE), cfg.String(OTEL_EXPORTER_OTLP_CLIENT_KEY)
+	if caFile == "" && certFile == "" && keyFile == "" {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read OTEL_EXPORTER_OTLP_CERTIFICATE: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("OTEL_EXPORTER_OTLP_CERTIFICATE %s holds no PEM certificate", caFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	switch {
+	case certFile == "" && keyFile == "":
+	case certFile == "":
+		return nil, fmt.Errorf("%w: OTEL_EXPORTER_OTLP_CLIENT_KEY is set without OTEL_EXPORTER_OTLP_CLIENT_CERTIFICATE", ErrIncompleteTLSConfig)
+	case keyFile == "":
+		return nil, fmt.Errorf("%w: OTEL_EXPORTER_OTLP_CLIENT_CERTIFICATE is set without OTEL_EXPORTER_OTLP_CLIENT_KEY", ErrIncompleteTLSConfig)
+	default:
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load the OTLP client key pair: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
@@ -0,0 +1,200 @@
+package ponrunner
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ponrove/configura"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeClientCert writes a self-signed client certificate and its key to PEM files, and returns their paths with a
+// pool trusting the certificate.
+func writeClientCert(t *testing.T) (certFile, keyFile string, pool *x509.CertPool) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "ponrunner-client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	certFile, keyFile = filepath.Join(dir, "client.pem"), filepath.Join(dir, "client-key.pem")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	pool = x509.NewCertPool()
+	pool.AddCert(cert)
+	return certFile, keyFile, pool
+}
+
+func TestOTLPTLSConfig(t *testing.T) {
+	t.Parallel()
+	caFile, _, _ := writeSelfSignedCert(t)
+	clientCert, clientKey, _ := writeClientCert(t)
+	notPEM := filepath.Join(t.TempDir(), "ca.txt")
+	require.NoError(t, os.WriteFile(notPEM, []byte("not a certificate"), 0o600))
+
+	tests := []struct {
+		name           string
+		caFile         string
+		certFile       string
+		keyFile        string
+		expectTLS      bool
+		expectRootCAs  bool
+		expectCerts    int
+		expectedErr    error
+		expectedErrMsg string
+	}{
+		{name: "Exporter defaults"},
+		{name: "CA certificate", caFile: caFile, expectTLS: true, expectRootCAs: true},
+		{name: "Client certificate", certFile: clientCert, keyFile: clientKey, expectTLS: true, expectCerts: 1},
+		{name: "Mutual TLS", caFile: caFile, certFile: clientCert, keyFile: clientKey, expectTLS: true, expectRootCAs: true, expectCerts: 1},
+		{name: "Client certificate only", certFile: clientCert, expectedErr: ErrIncompleteTLSConfig},
+		{name: "Client key only", keyFile: clientKey, expectedErr: ErrIncompleteTLSConfig},
+		{name: "Missing CA file", caFile: filepath.Join(t.TempDir(), "missing.pem"), expectedErr: os.ErrNotExist},
+		{name: "CA file without certificates", caFile: notPEM, expectedErrMsg: "holds no PEM certificate"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := configura.NewConfigImpl()
+			err := configura.WriteConfiguration(cfg, map[configura.Variable[string]]string{
+				OTEL_EXPORTER_OTLP_CERTIFICATE:        tc.caFile,
+				OTEL_EXPORTER_OTLP_CLIENT_CERTIFICATE: tc.certFile,
+				OTEL_EXPORTER_OTLP_CLIENT_KEY:         tc.keyFile,
+			})
+			require.NoError(t, err)
+
+			tlsConfig, err := otlpTLSConfig(cfg)
+			switch {
+			case tc.expectedErr != nil:
+				assert.ErrorIs(t, err, tc.expectedErr)
+				assert.Nil(t, tlsConfig)
+				return
+			case tc.expectedErrMsg != "":
+				assert.ErrorContains(t, err, tc.expectedErrMsg)
+				assert.Nil(t, tlsConfig)
+				return
+			}
+			require.NoError(t, err)
+			if !tc.expectTLS {
+				assert.Nil(t, tlsConfig)
+				return
+			}
+			require.NotNil(t, tlsConfig)
+			assert.Equal(t, tc.expectRootCAs, tlsConfig.RootCAs != nil)
+			assert.Len(t, tlsConfig.Certificates, tc.expectCerts)
+		})
+	}
+}
+
+func TestOTLPInsecure(t *testing.T) {
+	tlsConfig := &tls.Config{}
+	tests := []struct {
+		name      string
+		endpoint  string
+		tlsConfig *tls.Config
+		expected  bool
+	}{
+		{name: "http URL", endpoint: "http://collector:4318", expected: true},
+		{name: "http URL with TLS configuration", endpoint: "http://collector:4318", tlsConfig: tlsConfig, expected: true},
+		{name: "https URL", endpoint: "https://collector:4318", expected: false},
+		{name: "Bare gRPC endpoint", endpoint: "collector:4317", expected: true},
+		{name: "Bare gRPC endpoint with TLS configuration", endpoint: "collector:4317", tlsConfig: tlsConfig, expected: false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, otlpInsecure(tc.endpoint, tc.tlsConfig))
+		})
+	}
+}
+
+func TestNewTracerProvider_MutualTLS(t *testing.T) {
+	serverCert, serverKey, _ := writeSelfSignedCert(t)
+	clientCert, clientKey, clientPool := writeClientCert(t)
+
+	clients := make(chan string, 1)
+	collector := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/traces" && len(r.TLS.PeerCertificates) > 0 {
+			select {
+			case clients <- r.TLS.PeerCertificates[0].Subject.CommonName:
+			default:
+			}
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	keyPair, err := tls.LoadX509KeyPair(serverCert, serverKey)
+	require.NoError(t, err)
+	collector.TLS = &tls.Config{
+		Certificates: []tls.Certificate{keyPair},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientPool,
+	}
+	collector.StartTLS()
+	defer collector.Close()
+
+	cfg := configura.NewConfigImpl()
+	require.NoError(t, configura.WriteConfiguration(cfg, map[configura.Variable[bool]]bool{
+		OTEL_TRACES_ENABLED: true,
+	}))
+	require.NoError(t, configura.WriteConfiguration(cfg, map[configura.Variable[string]]string{
+		OTEL_EXPORTER_OTLP_TRACES_ENDPOINT:    collector.URL + "/v1/traces",
+		OTEL_EXPORTER_OTLP_TRACES_PROTOCOL:    "http/protobuf",
+		OTEL_EXPORTER_OTLP_CERTIFICATE:        serverCert,
+		OTEL_EXPORTER_OTLP_CLIENT_CERTIFICATE: clientCert,
+		OTEL_EXPORTER_OTLP_CLIENT_KEY:         clientKey,
+	}))
+	require.NoError(t, configura.WriteConfiguration(cfg, map[configura.Variable[int64]]int64{
+		OTEL_EXPORTER_OTLP_TRACES_TIMEOUT: 5,
+	}))
+
+	originalSlogLogger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	defer slog.SetDefault(originalSlogLogger)
+
+	ctx := context.Background()
+	tp, err := newTracerProvider(ctx, nil, cfg, nil, nil)
+	require.NoError(t, err)
+	_, span := tp.Tracer("test").Start(ctx, "request")
+	span.End()
+
+	shutdownCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	require.NoError(t, tp.Shutdown(shutdownCtx), "The export should verify the collector with the CA certificate")
+
+	select {
+	case client := <-clients:
+		assert.Equal(t, "ponrunner-client", client, "The exporter should present the client certificate")
+	default:
+		t.Fatal("No trace export reached the collector")
+	}
+}
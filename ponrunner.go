@@ -8,28 +8,38 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
 	"time"
 
 	"github.com/danielgtaylor/huma/v2"
-	"github.com/danielgtaylor/huma/v2/adapters/humachi"
 	"github.com/go-chi/chi/v5"
 	chim "github.com/go-chi/chi/v5/middleware"
 	"github.com/ponrove/configura"
 	"github.com/ponrove/ponrunner/middleware"
+	slogctx "github.com/veqryn/slog-context"
 	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
 )
 
 const (
-	SERVER_PORT             configura.Variable[int64]  = "SERVER_PORT"
-	SERVER_WRITE_TIMEOUT    configura.Variable[int64]  = "SERVER_WRITE_TIMEOUT"
-	SERVER_READ_TIMEOUT     configura.Variable[int64]  = "SERVER_READ_TIMEOUT"
-	SERVER_REQUEST_TIMEOUT  configura.Variable[int64]  = "SERVER_REQUEST_TIMEOUT"
-	SERVER_SHUTDOWN_TIMEOUT configura.Variable[int64]  = "SERVER_SHUTDOWN_TIMEOUT"
-	SERVER_LOG_LEVEL        configura.Variable[string] = "SERVER_LOG_LEVEL"
-	SERVER_LOG_FORMAT       configura.Variable[string] = "SERVER_LOG_FORMAT"
+	SERVER_HOST                configura.Variable[string] = "SERVER_HOST" // Host or IP address of the interface to listen on, all interfaces by default
+	SERVER_PORT                configura.Variable[int64]  = "SERVER_PORT"
+	SERVER_WRITE_TIMEOUT       configura.Variable[int64]  = "SERVER_WRITE_TIMEOUT"
+	SERVER_READ_TIMEOUT        configura.Variable[int64]  = "SERVER_READ_TIMEOUT"
+	SERVER_IDLE_TIMEOUT        configura.Variable[int64]  = "SERVER_IDLE_TIMEOUT"        // Seconds an idle keep-alive connection is kept open, 120 by default
+	SERVER_READ_HEADER_TIMEOUT configura.Variable[int64]  = "SERVER_READ_HEADER_TIMEOUT" // Seconds allowed to read the headers of a request, 5 by default
+	SERVER_REQUEST_TIMEOUT     configura.Variable[int64]  = "SERVER_REQUEST_TIMEOUT"
+	SERVER_SHUTDOWN_TIMEOUT    configura.Variable[int64]  = "SERVER_SHUTDOWN_TIMEOUT"
+	SERVER_LOG_LEVEL           configura.Variable[string] = "SERVER_LOG_LEVEL"
+	SERVER_LOG_FORMAT          configura.Variable[string] = "SERVER_LOG_FORMAT"
+
+	SERVER_TCP_KEEPALIVE_PERIOD configura.Variable[int64] = "SERVER_TCP_KEEPALIVE_PERIOD" // Seconds between TCP keep-alive probes, negative disables
 )
 
+// defaultShutdownSignals are the OS signals triggering a graceful shutdown, unless replaced with WithShutdownSignals.
+var defaultShutdownSignals = []os.Signal{syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT}
+
 // APIBundle is a function type that takes a configura.Config and huma.API,
 type APIBundle func(configura.Config, huma.API) error
 
@@ -86,11 +96,137 @@ func handleServerShutdown(shutdownParentCtx context.Context, srv serverControl,
 	return nil
 }
 
+// shutdownTelemetry flushes and shuts down the OpenTelemetry providers within shutdownTimeout, so the spans, metrics
+// and logs of the last requests are exported before the process exits.
+func shutdownTelemetry(ctx context.Context, otelShutdown shutdownFunc, shutdownTimeout time.Duration) {
+	// A fresh context, as ctx is likely canceled by now.
+	shutdownOpCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := otelShutdown(shutdownOpCtx); err != nil {
+		slog.ErrorContext(ctx, "error during OpenTelemetry shutdown", slog.Any("error", err))
+	}
+}
+
+// humaContext aliases huma.Context, so it can be embedded without its field name clashing with the Context method.
+type humaContext = huma.Context
+
+// externalHostContext overrides the host of a huma.Context, so self-referential URLs (e.g. the `$schema` links added
+// to responses) point at the host the client used, rather than the internal address behind a proxy.
+type externalHostContext struct {
+	humaContext
+	host string
+}
+
+// Host returns the external host of the request.
+func (c *externalHostContext) Host() string {
+	return c.host
+}
+
+// externalHostMiddleware is a huma middleware that exposes the external host resolved by middleware.ExternalHost to
+// huma operations and transformers.
+func externalHostMiddleware(ctx huma.Context, next func(huma.Context)) {
+	if host := middleware.GetExternalHostFromContext(ctx.Context()); host != "" && host != ctx.Host() {
+		ctx = &externalHostContext{humaContext: ctx, host: host}
+	}
+	next(ctx)
+}
+
+// newListenConfig returns the configuration of the server's listener. SERVER_TCP_KEEPALIVE_PERIOD sets the TCP
+// keep-alive period of accepted connections, so dead peers are detected and their resources reclaimed; zero keeps Go's
+// default (15s), and a negative value disables keep-alives.
+func newListenConfig(cfg configura.Config) *net.ListenConfig {
+	return &net.ListenConfig{
+		KeepAlive: time.Duration(cfg.Int64(SERVER_TCP_KEEPALIVE_PERIOD)) * time.Second,
+	}
+}
+
+// listenAddress returns the address the server listens on, SERVER_PORT on the interface of SERVER_HOST, e.g.
+// 127.0.0.1 to only accept connections from the same host, or on all interfaces if SERVER_HOST is empty.
+func listenAddress(cfg configura.Config) string {
+	return net.JoinHostPort(cfg.String(SERVER_HOST), strconv.FormatInt(cfg.Int64(SERVER_PORT), 10))
+}
+
+// setServerTimeouts sets the timeouts of the server from the configuration. SERVER_IDLE_TIMEOUT and
+// SERVER_READ_HEADER_TIMEOUT default to 120s and 5s, so idle keep-alive connections are eventually closed and slow
+// clients can't hold connections open by trickling their headers in.
+func setServerTimeouts(cfg configura.Config, srv *http.Server) {
+	srv.ReadTimeout = time.Duration(cfg.Int64(SERVER_READ_TIMEOUT)) * time.Second
+	srv.WriteTimeout = time.Duration(cfg.Int64(SERVER_WRITE_TIMEOUT)) * time.Second
+	srv.IdleTimeout = time.Duration(configura.Fallback(cfg.Int64(SERVER_IDLE_TIMEOUT), 120)) * time.Second
+	srv.ReadHeaderTimeout = time.Duration(configura.Fallback(cfg.Int64(SERVER_READ_HEADER_TIMEOUT), 5)) * time.Second
+}
+
+// RegisterRoutes is a function type that registers routes on the chi router, or operations on the huma.API.
 type RegisterRoutes func(configura.Config, chi.Router, huma.API) error
 
+// ChainRegister combines multiple RegisterRoutes functions into one, to pass to Start. They are run in order, stopping
+// at the first error, which is returned.
+func ChainRegister(fns ...RegisterRoutes) RegisterRoutes {
+	return func(cfg configura.Config, router chi.Router, api huma.API) error {
+		for _, fn := range fns {
+			if err := fn(cfg, router, api); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+}
+
+// logRegistrationError logs a failure to register the routes of the bundles, with a stack trace if
+// LOG_INCLUDE_STACK_ON_ERROR is enabled.
+func logRegistrationError(ctx context.Context, cfg configura.Config, msg string, err error) {
+	slog.LogAttrs(ctx, slog.LevelError, msg, append([]slog.Attr{slog.Any("error", err)}, middleware.ErrorStackAttrs(cfg)...)...)
+}
+
+// runListenHooks runs the WithOnListen functions in order with the address of the listener, stopping at the first
+// error, which is returned.
+func runListenHooks(addr net.Addr, hooks []func(net.Addr) error) error {
+	for _, hook := range hooks {
+		if err := hook(addr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// StartWithListener is Start, serving on ln rather than on a listener bound to SERVER_PORT, e.g. one bound to port 0
+// to let the OS choose a free port, which ln.Addr returns. SERVER_TCP_KEEPALIVE_PERIOD doesn't apply to ln. The
+// listener is closed when StartWithListener returns.
+func StartWithListener(ctx context.Context, cfg configura.Config, router chi.Router, register RegisterRoutes, ln net.Listener, opts ...Option) error {
+	defer ln.Close()
+	return Start(ctx, cfg, router, register, append(opts, func(o *options) { o.listener = ln })...)
+}
+
+// StartAsync is Start, returning once the listener is bound rather than when the server has shut down, for
+// applications managing the lifecycle of the server themselves. The returned Server is shut down with its Shutdown
+// method, or when ctx is canceled, and its Wait method returns the error Start would return. If the server fails to
+// start, e.g. because the port is in use, the error is returned instead.
+func StartAsync(ctx context.Context, cfg configura.Config, router chi.Router, register RegisterRoutes, opts ...Option) (*Server, error) {
+	server := newServer(ctx)
+	go server.serve(cfg, router, register, opts)
+
+	select {
+	case <-server.listening:
+		return server, nil
+	case <-server.done:
+		return nil, server.err
+	}
+}
+
 // Start initializes and starts the Ponrove server. It sets up the HTTP server with the provided configuration and API
-// bundles, and handles graceful shutdown on receiving OS signals.
-func Start(ctx context.Context, cfg configura.Config, router chi.Router, register RegisterRoutes) error {
+// bundles, and handles graceful shutdown on receiving OS signals. Optional behaviour, such as the huma adapter, is
+// configured with opts.
+func Start(ctx context.Context, cfg configura.Config, router chi.Router, register RegisterRoutes, opts ...Option) error {
+	server := newServer(ctx)
+	server.serve(cfg, router, register, opts)
+	return server.err
+}
+
+// start runs the server until it's shut down, see Start.
+func start(ctx context.Context, server *Server, cfg configura.Config, router chi.Router, register RegisterRoutes, opts ...Option) error {
+	o := newOptions(opts...)
+
 	// Ensure the configuration contains all required keys, it's up to the caller to ensure that the configuration
 	// is loaded with the necessary values before calling Start.
 	err := cfg.ConfigurationKeysRegistered(
@@ -98,6 +234,8 @@ func Start(ctx context.Context, cfg configura.Config, router chi.Router, registe
 		SERVER_REQUEST_TIMEOUT,
 		SERVER_READ_TIMEOUT,
 		SERVER_WRITE_TIMEOUT,
+		SERVER_IDLE_TIMEOUT,
+		SERVER_READ_HEADER_TIMEOUT,
 		SERVER_SHUTDOWN_TIMEOUT,
 		SERVER_OPENFEATURE_PROVIDER_NAME,
 		SERVER_OPENFEATURE_PROVIDER_URL,
@@ -108,36 +246,29 @@ func Start(ctx context.Context, cfg configura.Config, router chi.Router, registe
 		return err
 	}
 
+	// Fill in the defaults of the deployment environment, before any of them is read.
+	cfg = applyEnvironmentProfile(ctx, cfg)
+
 	// Set up the logger based on the configuration.
-	logLevelStr := configura.Fallback(cfg.String(SERVER_LOG_LEVEL), "info")
-	var logLevel slog.Level
-	switch logLevelStr {
-	case "trace": // slog doesn't have trace, map to debug
-		logLevel = slog.LevelDebug
-	case "debug":
-		logLevel = slog.LevelDebug
-	case "info":
-		logLevel = slog.LevelInfo
-	case "warn":
-		logLevel = slog.LevelWarn
-	case "error":
-		logLevel = slog.LevelError
-	default:
-		slog.WarnContext(ctx, "Unsupported or unmappable log level configured, defaulting to INFO", slog.String("configuredLevel", logLevelStr))
-		logLevel = slog.LevelInfo // Default to Info level.
-	}
-
-	logFormat := configura.Fallback(cfg.String(SERVER_LOG_FORMAT), "text") // Default to text
-	var handler slog.Handler
-	opts := &slog.HandlerOptions{Level: logLevel}
-
-	if logFormat == "json" {
-		handler = slog.NewJSONHandler(os.Stdout, opts)
-	} else {
-		handler = slog.NewTextHandler(os.Stdout, opts)
-	}
-	defaultLogger := slog.New(handler)
-	slog.SetDefault(defaultLogger)
+	slog.SetDefault(slog.New(slogctx.NewHandler(newLogHandler(ctx, cfg, os.Stdout, os.Stderr), nil)))
+
+	// Load the TLS key pair before anything else is set up, so a misconfiguration fails fast.
+	tlsConfig, err := newTLSConfig(cfg)
+	if err != nil {
+		slog.ErrorContext(ctx, "Invalid TLS configuration", slog.Any("error", err))
+		return err
+	}
+
+	if cfg.String(middleware.MAINTENANCE_ADMIN_PATH) != "" && cfg.String(middleware.MAINTENANCE_ADMIN_TOKEN) == "" {
+		slog.ErrorContext(ctx, "Invalid maintenance configuration", slog.Any("error", middleware.ErrMissingMaintenanceAdminToken))
+		return middleware.ErrMissingMaintenanceAdminToken
+	}
+
+	order, err := shutdownOrder(cfg)
+	if err != nil {
+		slog.ErrorContext(ctx, "Invalid shutdown order", slog.Any("error", err))
+		return err
+	}
 
 	// Set the open feature provider if configured.
 	err = setOpenFeatureProvider(cfg)
@@ -148,62 +279,171 @@ func Start(ctx context.Context, cfg configura.Config, router chi.Router, registe
 
 	// Initialize OpenTelemetry if enabled
 	// Pass the slog-augmented context to setupOTelSDK
-	otelShutdown, otelSetupErr := setupOTelSDK(ctx, cfg)
+	shutdownTimeout := time.Duration(cfg.Int64(SERVER_SHUTDOWN_TIMEOUT)) * time.Second
+	otelShutdown, otelSetupErr := setupOTelSDK(ctx, cfg, o.spanExporter)
 	if otelSetupErr != nil {
 		return fmt.Errorf("Failed to setup OpenTelemetry SDK: %w", otelSetupErr)
 	} else if otelShutdown != nil { // Check otelShutdown is not nil (i.e., OTel actually initialized)
 		slog.InfoContext(ctx, "OpenTelemetry SDK initialized successfully.")
-		// Defer the shutdown of OpenTelemetry to ensure it's called on exit.
-		// Use a background context for shutdown as the main context might be canceled.
+		// OpenTelemetry is shut down explicitly at the end of the shutdown sequence, this covers the early returns.
 		defer func() {
-			// Use a background context for OTel shutdown, as the parent ctx might be done.
-			// Logs from here will use the slog.Default logger.
-			if err := otelShutdown(context.Background()); err != nil {
-				slog.ErrorContext(ctx, "error during OpenTelemetry shutdown", slog.Any("error", err))
+			if otelShutdown != nil {
+				shutdownTelemetry(ctx, otelShutdown, shutdownTimeout)
 			}
 		}()
 	}
 
 	// serverCtx is canceled when an OS signal is received, used for server's BaseContext.
-	serverCtx, stopSignalNotify := signal.NotifyContext(ctx, syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
+	serverCtx, stopSignalNotify := context.WithCancel(ctx)
+	if len(o.shutdownSignals) > 0 {
+		serverCtx, stopSignalNotify = signal.NotifyContext(ctx, o.shutdownSignals...)
+	}
 	defer stopSignalNotify() // Ensures signal notifications are stopped when Runtime exits.
+	serverCtx, stopMaxLifetime := withMaxLifetime(serverCtx, cfg)
+	defer stopMaxLifetime()
 
-	router.Use(
-		middleware.IPAddress(cfg), // Adds the client's IP address to the request context.
-		chim.RequestID,            // Adds a unique request ID to each request.
-		chim.Recoverer,
-		middleware.LogRequest(cfg), // Custom middleware to log requests.
-		chim.Timeout(time.Duration(cfg.Int64(SERVER_REQUEST_TIMEOUT))*time.Second),
-	)
+	// The lifecycle tracks readiness, the warmup period starts now that the server is about to be set up.
+	lc := newLifecycle(time.Duration(cfg.Int64(SERVER_WARMUP_PERIOD)) * time.Second)
+	defer lc.stop()
+	currentLifecycle.Store(lc)
+
+	var chain []namedMiddleware
+	if !o.noDefaultMiddleware {
+		internal := internalPaths(cfg)
+		chain = []namedMiddleware{
+			{"IPAddress", middleware.IPAddress(cfg)},                              // Adds the client's IP address to the request context.
+			{"ExternalHost", middleware.ExternalHost(cfg)},                        // Adds the host the client used to reach the service to the request context.
+			{"GeoIP", middleware.GeoIP(cfg, o.geoIPResolver)},                     // Adds the client's country to the request context, if a resolver is set.
+			{"Fingerprint", middleware.Fingerprint(cfg)},                          // Adds the client's TLS fingerprint forwarded by a trusted proxy to the request context.
+			{"RequestID", chim.RequestID},                                         // Adds a unique request ID to each request.
+			{"RequestIDBaggage", middleware.RequestIDBaggage(cfg)},                // Adds the request ID to the OpenTelemetry baggage, if enabled.
+			{"Recoverer", middleware.Recoverer(cfg)},                              // Recovers from panics, logging them with the request's correlation fields.
+			{"LogRequest", middleware.LogRequest(cfg)},                            // Custom middleware to log requests.
+			{"Metrics", middleware.Metrics(cfg)},                                  // Records request metrics with the OpenTelemetry meter provider.
+			{"Drain", rejectWhileDraining(cfg, lc)},                               // Rejects requests with 503 while the server drains, except the health checks.
+			{"Maintenance", middleware.Maintenance(cfg, internal...)},             // Rejects requests with 503 in maintenance mode, except the health checks.
+			{"RateLimit", middleware.RateLimit(cfg, o.store)},                     // Rejects clients making too many requests with 429, if enabled.
+			{"MaxQueryParams", middleware.MaxQueryParams(cfg)},                    // Rejects requests with too many query parameters, if enabled.
+			{"HeaderLimits", middleware.HeaderLimits(cfg)},                        // Rejects requests with an oversized header value, if enabled.
+			{"RequireHTTPS", middleware.RequireHTTPS(cfg, internal...)},           // Redirects or rejects plain HTTP requests, if enabled.
+			{"RequireAPIVersion", middleware.RequireAPIVersion(cfg, internal...)}, // Rejects requests without a supported API version, if enabled.
+			{"Accept", middleware.Accept(cfg, internal...)},                       // Rejects requests accepting none of the supported media types, if enabled.
+			{"ServerTiming", middleware.ServerTiming(cfg)},                        // Emits Server-Timing headers, if enabled.
+			{"CacheControl", middleware.CacheControl(cfg)},                        // Sets a default Cache-Control header on responses.
+			{"ETag", middleware.ETag(cfg)},                                        // Sets ETag headers on GET responses, if enabled.
+			{"Idempotency", middleware.IdempotencyWithStore(cfg, o.store)},        // Replays the responses of requests with a known Idempotency-Key, if enabled.
+			{"Mirror", middleware.Mirror(cfg, NewHTTPClient(cfg))},                // Duplicates a share of the requests to a shadow backend, if enabled.
+			{"MaxResponseBytes", middleware.MaxResponseBytes(cfg)},                // Aborts responses larger than the maximum size, if enabled.
+			{"MinUploadRate", middleware.MinUploadRate(cfg)},                      // Aborts request body uploads slower than the minimum rate, if enabled.
+			{"MultipartLimit", middleware.MultipartLimit(cfg)},                    // Bounds the memory and size of multipart uploads, if enabled.
+			{"MaxJSONDepth", middleware.MaxJSONDepth(cfg)},                        // Rejects JSON request bodies nested too deeply, if enabled.
+			{"Timeout", middleware.Timeout(cfg, time.Duration(cfg.Int64(SERVER_REQUEST_TIMEOUT))*time.Second)},
+		}
+	}
+	for _, mw := range o.middleware {
+		chain = append(chain, namedMiddleware{name: middlewareName(mw), handler: mw})
+	}
+	for _, mw := range chain {
+		router.Use(mw.handler)
+		server.middlewareChain = append(server.middlewareChain, mw.name)
+	}
+	currentServer.Store(server)
+	defer currentServer.CompareAndSwap(server, nil)
+
+	registerHealthEndpoints(cfg, router, lc)
+	registerVersionEndpoint(cfg, router)
+	registerPrometheusEndpoint(cfg, router)
+	if path := cfg.String(middleware.MAINTENANCE_ADMIN_PATH); path != "" {
+		router.Handle(path, middleware.MaintenanceAdmin(cfg))
+	}
+
+	humaConfig := newHumaConfig(cfg)
+	if err := applySecurityScheme(cfg, humaConfig.OpenAPI); err != nil {
+		slog.ErrorContext(ctx, "Invalid OpenAPI configuration", slog.Any("error", err))
+		return err
+	}
+	h := o.apiFactory(cfg, router, humaConfig)
+	h.UseMiddleware(externalHostMiddleware)
 
-	h := humachi.New(router, huma.DefaultConfig("Ponrove Backend API", "1.0.0"))
+	err = register(cfg, router, h)
+	registeredWorkers := takeWorkers() // Taken regardless of the error, so they aren't started by another server.
+	// The idle connections of the HTTP clients are closed last, once the hooks that may still use them have run.
+	registeredHooks := append([]shutdownHook{{name: "http-client", run: closeHTTPClientConnections}}, takeShutdownHooks()...)
+	if err != nil {
+		logRegistrationError(ctx, cfg, "Failed to register routes", err)
+		return err
+	}
 
-	if err := register(cfg, router, h); err != nil {
-		slog.ErrorContext(ctx, "Failed to register routes", slog.Any("error", err))
+	if err := checkOperationLimit(cfg, h); err != nil {
+		logRegistrationError(ctx, cfg, "Failed to register routes", err)
 		return err
 	}
 
+	if !cfg.Bool(API_SKIP_OPENAPI_VALIDATION) {
+		if err := validateOpenAPI(h); err != nil {
+			logRegistrationError(ctx, cfg, "Failed to generate the OpenAPI document", err)
+			return err
+		}
+	}
+
 	srv := &http.Server{ // Use a pointer to satisfy serverControl if http.Server is passed directly.
-		Addr: fmt.Sprintf(":%d", cfg.Int64(SERVER_PORT)),
+		Addr: listenAddress(cfg),
 		// BaseContext ensures the server stops accepting new connections when serverCtx is canceled.
-		BaseContext:  func(_ net.Listener) context.Context { return serverCtx },
-		ReadTimeout:  time.Duration(cfg.Int64(SERVER_READ_TIMEOUT)) * time.Second,
-		WriteTimeout: time.Duration(cfg.Int64(SERVER_WRITE_TIMEOUT)) * time.Second,
-		Handler:      router, // This will be wrapped if OTel is enabled
+		BaseContext: func(_ net.Listener) context.Context { return serverCtx },
+		Handler:     router, // This will be wrapped if OTel is enabled
+		TLSConfig:   tlsConfig,
+	}
+	setServerTimeouts(cfg, srv)
+	if o.listener != nil {
+		srv.Addr = o.listener.Addr().String()
 	}
 
 	// Wrap the main router with OpenTelemetry HTTP instrumentation if enabled
 	if otelShutdown != nil { // otelShutdown check ensures setup was successful
 		slog.InfoContext(ctx, "Wrapping HTTP handler with OpenTelemetry instrumentation.")
-		srv.Handler = otelhttp.NewHandler(router, "http.server")
+		srv.Handler = forceTraceHandler(cfg, otelhttp.NewHandler(router, "http.server"))
+	}
+	limitConnectionAge(cfg, srv)
+	stopDiagnostics := watchDiagnosticsSignal(ctx, cfg, srv, router)
+	defer stopDiagnostics()
+
+	// Lifecycle metrics are recorded with the meter provider set up above, if OpenTelemetry is enabled.
+	lm := newLifecycleMetrics(otel.GetMeterProvider(), time.Now())
+	defer lm.unregister()
+
+	ln := o.listener
+	if ln == nil {
+		if ln, err = listen(serverCtx, cfg, srv.Addr); err != nil {
+			slog.ErrorContext(ctx, "Failed to listen", slog.String("address", srv.Addr), slog.Any("error", err))
+			runShutdownHooks(ctx, registeredHooks, shutdownTimeout)
+			return err
+		}
+		defer ln.Close()
 	}
+	if err := runListenHooks(ln.Addr(), o.onListen); err != nil {
+		slog.ErrorContext(ctx, "OnListen hook failed, aborting startup", slog.Any("error", err))
+		runShutdownHooks(ctx, registeredHooks, shutdownTimeout)
+		return err
+	}
+
+	server.addr = ln.Addr()
+	close(server.listening)
+
+	// Workers run with the server context, and are stopped after the server during shutdown.
+	stopWorkers := startWorkers(serverCtx, registeredWorkers)
 
 	srvListenAndServeErrChan := make(chan error, 1)
 	go func() {
-		slog.InfoContext(ctx, "Starting server", slog.String("address", srv.Addr))
-		// ListenAndServe blocks until the server is shut down.
+		slog.InfoContext(ctx, "Starting server", slog.String("address", ln.Addr().String()), slog.Bool("tls", tlsConfig != nil))
+		// Serve blocks until the server is shut down.
 		// It returns http.ErrServerClosed if Shutdown is called successfully.
-		lsErr := srv.ListenAndServe()
+		var lsErr error
+		if tlsConfig != nil {
+			// The key pair is already in srv.TLSConfig.
+			lsErr = srv.ServeTLS(newAcceptBackoffListener(cfg, ln), "", "")
+		} else {
+			lsErr = srv.Serve(newAcceptBackoffListener(cfg, ln))
+		}
 		if lsErr != nil && lsErr != http.ErrServerClosed {
 			srvListenAndServeErrChan <- lsErr
 		} else {
@@ -222,8 +462,8 @@ func Start(ctx context.Context, cfg configura.Config, router chi.Router, registe
 		} else { // Channel closed: ListenAndServe returned nil or http.ErrServerClosed.
 			slog.InfoContext(ctx, "Server stopped (ListenAndServe returned nil or http.ErrServerClosed before any signal).")
 		}
-	case <-serverCtx.Done(): // OS signal received. serverCtx is now canceled.
-		slog.InfoContext(ctx, "Shutdown signal received. serverCtx is Done. Proceeding to shutdown.")
+	case <-serverCtx.Done(): // OS signal received, or the maximum lifetime elapsed. serverCtx is now canceled.
+		slog.InfoContext(ctx, "Shutdown signal received. serverCtx is Done. Proceeding to shutdown.", slog.String("reason", shutdownReason(serverCtx)))
 		// stopSignalNotify() is deferred. It will clean up signal handling.
 		// If we call stopSignalNotify() here, it ensures no new signals for this NotifyContext are processed during shutdown.
 		// This can be useful if shutdown is lengthy. signal.Stop is safe to call multiple times.
@@ -232,8 +472,40 @@ func Start(ctx context.Context, cfg configura.Config, router chi.Router, registe
 
 	// Proceed with shutdown logic regardless of how the select statement was exited.
 	slog.InfoContext(ctx, "Initiating shutdown procedure via handleServerShutdown...")
-	shutdownTimeout := time.Duration(cfg.Int64(SERVER_SHUTDOWN_TIMEOUT)) * time.Second
-	shutdownErr := handleServerShutdown(context.Background(), srv, shutdownTimeout)
+	lm.shutdown(ctx)
+	// The phases share one deadline rather than taking SERVER_SHUTDOWN_TIMEOUT each, so the whole shutdown takes at most
+	// SERVER_DRAIN_PERIOD and SERVER_SHUTDOWN_TIMEOUT, within the termination grace period of the orchestrator.
+	deadline := time.Now().Add(shutdownTimeout)
+	if listenAndServeError == nil {
+		deadline = deadline.Add(drainPeriod(cfg))
+	}
+	remaining := func() time.Duration { return max(time.Until(deadline), 0) }
+	var shutdownErr error
+	withShutdownDiagnostics(ctx, cfg, func() {
+		runShutdownPhases(ctx, order, map[string]func(){
+			shutdownPhaseHTTP: func() {
+				if listenAndServeError == nil {
+					drainServer(ctx, cfg, lc)
+				}
+				shutdownErr = handleServerShutdown(context.Background(), srv, remaining())
+			},
+			shutdownPhaseWorkers: func() {
+				stopWorkers(remaining())
+			},
+			shutdownPhaseHooks: func() {
+				runShutdownHooks(ctx, registeredHooks, remaining())
+				runShutdownHooks(ctx, o.onShutdown, remaining())
+			},
+			// By default, the telemetry of the drained requests, workers and hooks is flushed last.
+			shutdownPhaseTelemetry: func() {
+				if otelShutdown != nil {
+					shutdownTelemetry(ctx, otelShutdown, remaining())
+					otelShutdown = nil
+				}
+			},
+			shutdownPhaseOpenFeature: shutdownOpenFeature,
+		})
+	})
 
 	if listenAndServeError != nil {
 		// If ListenAndServe failed, that's the primary error to return.
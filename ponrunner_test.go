@@ -1,18 +1,27 @@
 package ponrunner
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"log/slog"
 	"net"
 	"net/http"
+	"net/http/httptest"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/danielgtaylor/huma/v2"
+	"github.com/danielgtaylor/huma/v2/adapters/humachi"
 	"github.com/go-chi/chi/v5"
 	"github.com/ponrove/configura"
+	"github.com/ponrove/ponrunner/middleware"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
@@ -48,6 +57,8 @@ func newDefaultCfg() *configura.ConfigImpl {
 		SERVER_SHUTDOWN_TIMEOUT:            30,
 		SERVER_READ_TIMEOUT:                30,
 		SERVER_WRITE_TIMEOUT:               30,
+		SERVER_IDLE_TIMEOUT:                120,
+		SERVER_READ_HEADER_TIMEOUT:         5,
 		OTEL_EXPORTER_OTLP_TIMEOUT:         5,
 		OTEL_EXPORTER_OTLP_TRACES_TIMEOUT:  5,
 		OTEL_EXPORTER_OTLP_METRICS_TIMEOUT: 5,
@@ -58,6 +69,7 @@ func newDefaultCfg() *configura.ConfigImpl {
 	}
 	err = configura.WriteConfiguration(cfg, map[configura.Variable[bool]]bool{
 		OTEL_ENABLED:         false,
+		OTEL_SDK_DISABLED:    false,
 		OTEL_LOGS_ENABLED:    true,
 		OTEL_METRICS_ENABLED: true,
 		OTEL_TRACES_ENABLED:  true,
@@ -304,6 +316,134 @@ func TestStart_ListenAndServeFails(t *testing.T) {
 	}
 }
 
+func TestStartWithListener(t *testing.T) {
+	t.Parallel()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err, "Failed to create a listener")
+	port := listener.Addr().(*net.TCPAddr).Port
+	require.NotZero(t, port, "The OS should have chosen a port")
+
+	// SERVER_PORT is ignored, the server uses the listener.
+	finalCfg := configura.Merge(newDefaultCfg(), configura.NewConfigImpl())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	startErrChan := make(chan error, 1)
+	go func() {
+		startErrChan <- StartWithListener(ctx, finalCfg, chi.NewRouter(), func(c configura.Config, router chi.Router, a huma.API) error {
+			router.Get("/hello", func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte("hello"))
+			})
+			return nil
+		}, listener)
+	}()
+
+	var body []byte
+	require.Eventually(t, func() bool {
+		resp, err := http.Get(fmt.Sprintf("http://127.0.0.1:%d/hello", port))
+		if err != nil {
+			return false
+		}
+		defer resp.Body.Close()
+		body, err = io.ReadAll(resp.Body)
+		return err == nil && resp.StatusCode == http.StatusOK
+	}, 2*time.Second, 50*time.Millisecond, "server never started on the listener")
+	assert.Equal(t, "hello", string(body))
+
+	cancel()
+	select {
+	case err := <-startErrChan:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("StartWithListener did not return after the context was canceled")
+	}
+	_, err = net.Dial("tcp", listener.Addr().String())
+	assert.Error(t, err, "The listener should be closed once StartWithListener returns")
+}
+
+func TestStart_LifecycleHooks(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err, "Failed to create a listener")
+
+	var mu sync.Mutex
+	var order []string
+	record := func(event string) {
+		mu.Lock()
+		defer mu.Unlock()
+		order = append(order, event)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	startErrChan := make(chan error, 1)
+	go func() {
+		startErrChan <- StartWithListener(ctx, newDefaultCfg(), chi.NewRouter(), func(c configura.Config, router chi.Router, a huma.API) error {
+			record("register")
+			RegisterShutdownHook("db", func(ctx context.Context) error {
+				record("shutdown hook")
+				return nil
+			})
+			return nil
+		}, listener,
+			WithOnListen(func(addr net.Addr) error {
+				assert.Equal(t, listener.Addr().String(), addr.String())
+				record("listen")
+				return nil
+			}),
+			WithOnShutdown(func(ctx context.Context) error {
+				_, hasDeadline := ctx.Deadline()
+				assert.True(t, hasDeadline, "OnShutdown should be bounded by the shutdown timeout")
+				record("shutdown")
+				return nil
+			}),
+		)
+	}()
+
+	require.Eventually(t, func() bool {
+		resp, err := http.Get("http://" + listener.Addr().String() + "/livez")
+		if err != nil {
+			return false
+		}
+		resp.Body.Close()
+		return resp.StatusCode == http.StatusOK
+	}, 2*time.Second, 50*time.Millisecond, "server never started on the listener")
+
+	cancel()
+	select {
+	case err := <-startErrChan:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Start did not return after the context was canceled")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{"register", "listen", "shutdown hook", "shutdown"}, order,
+		"OnListen should run after the routes are registered, and OnShutdown once the server has shut down")
+}
+
+func TestStart_OnListenErrorAbortsStartup(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err, "Failed to create a listener")
+
+	hookErr := errors.New("service discovery unavailable")
+	shutdownCalled := false
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err = StartWithListener(ctx, newDefaultCfg(), chi.NewRouter(), func(c configura.Config, router chi.Router, a huma.API) error {
+		return nil
+	}, listener,
+		WithOnListen(func(addr net.Addr) error { return hookErr }),
+		WithOnShutdown(func(ctx context.Context) error {
+			shutdownCalled = true
+			return nil
+		}),
+	)
+
+	assert.ErrorIs(t, err, hookErr, "Start should return the error of the OnListen hook")
+	assert.False(t, shutdownCalled, "OnShutdown should not run if the server never served")
+	_, err = net.Dial("tcp", listener.Addr().String())
+	assert.Error(t, err, "The listener should be closed once Start returns")
+}
+
 func TestStart_APIBundleRegistrationFails(t *testing.T) {
 	t.Parallel()
 	const unset_fake_configuration_flag configura.Variable[bool] = "unset_fake_configuration_flag"
@@ -334,6 +474,21 @@ func TestStart_APIBundleRegistrationFails(t *testing.T) {
 	assert.True(t, errors.Is(runErr, configura.ErrMissingVariable), "Start should return the error from API bundle registration. Got: %v", runErr)
 }
 
+func TestStart_MaintenanceAdminRequiresToken(t *testing.T) {
+	emptyCfg := configura.NewConfigImpl()
+	err := configura.WriteConfiguration(emptyCfg, map[configura.Variable[string]]string{
+		middleware.MAINTENANCE_ADMIN_PATH: "/admin/maintenance",
+	})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	runErr := Start(ctx, configura.Merge(newDefaultCfg(), emptyCfg), chi.NewRouter(), func(cfg configura.Config, r chi.Router, a huma.API) error {
+		return nil
+	})
+	assert.ErrorIs(t, runErr, middleware.ErrMissingMaintenanceAdminToken, "Start should refuse to serve the admin endpoint unauthenticated")
+}
+
 func TestStart_RequestTimeout(t *testing.T) {
 	t.Parallel()
 
@@ -388,3 +543,374 @@ func TestStart_RequestTimeout(t *testing.T) {
 	// chi.Timeout middleware returns a 504 Gateway Timeout status on timeout.
 	assert.Equal(t, http.StatusGatewayTimeout, resp.StatusCode)
 }
+
+func TestStart_WriteTimeoutIndependentOfRequestTimeout(t *testing.T) {
+	t.Parallel()
+	freePort, err := getFreePort()
+	require.NoError(t, err, "Failed to get free port")
+
+	emptyCfg := configura.NewConfigImpl()
+	err = configura.WriteConfiguration(emptyCfg, map[configura.Variable[int64]]int64{
+		SERVER_PORT:            int64(freePort),
+		SERVER_REQUEST_TIMEOUT: 3,
+		SERVER_WRITE_TIMEOUT:   1,
+	})
+	require.NoError(t, err, "Failed to write configuration")
+	finalCfg := configura.Merge(newDefaultCfg(), emptyCfg)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		_ = Start(ctx, finalCfg, chi.NewRouter(), func(c configura.Config, router chi.Router, a huma.API) error {
+			router.Get("/deadline", func(w http.ResponseWriter, r *http.Request) {
+				deadline, ok := r.Context().Deadline()
+				if !ok {
+					w.WriteHeader(http.StatusInternalServerError)
+					return
+				}
+				_, _ = w.Write([]byte(time.Until(deadline).Round(time.Second).String()))
+			})
+			// Outlives the write timeout, but not the request timeout.
+			router.Get("/slow", func(w http.ResponseWriter, r *http.Request) {
+				time.Sleep(1500 * time.Millisecond)
+				_, _ = w.Write([]byte("done"))
+			})
+			return nil
+		})
+	}()
+
+	serverAddr := fmt.Sprintf("localhost:%d", freePort)
+	require.Eventually(t, func() bool {
+		conn, err := net.Dial("tcp", serverAddr)
+		if err != nil {
+			return false
+		}
+		conn.Close()
+		return true
+	}, 2*time.Second, 50*time.Millisecond, "server never started")
+
+	resp, err := http.Get(fmt.Sprintf("http://%s/deadline", serverAddr))
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.NoError(t, err)
+	assert.Equal(t, "3s", string(body), "The Timeout middleware should use SERVER_REQUEST_TIMEOUT")
+
+	resp, err = http.Get(fmt.Sprintf("http://%s/slow", serverAddr))
+	if err == nil {
+		_, err = io.ReadAll(resp.Body)
+		resp.Body.Close()
+	}
+	assert.Error(t, err, "The http.Server WriteTimeout should be SERVER_WRITE_TIMEOUT, cutting the response off")
+}
+
+func TestSetServerTimeouts(t *testing.T) {
+	tests := []struct {
+		name                      string
+		values                    map[configura.Variable[int64]]int64
+		expectedIdleTimeout       time.Duration
+		expectedReadHeaderTimeout time.Duration
+	}{
+		{
+			name: "Configured",
+			values: map[configura.Variable[int64]]int64{
+				SERVER_IDLE_TIMEOUT:        60,
+				SERVER_READ_HEADER_TIMEOUT: 2,
+			},
+			expectedIdleTimeout:       60 * time.Second,
+			expectedReadHeaderTimeout: 2 * time.Second,
+		},
+		{
+			name: "Defaults",
+			values: map[configura.Variable[int64]]int64{
+				SERVER_IDLE_TIMEOUT:        0,
+				SERVER_READ_HEADER_TIMEOUT: 0,
+			},
+			expectedIdleTimeout:       120 * time.Second,
+			expectedReadHeaderTimeout: 5 * time.Second,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := configura.NewConfigImpl()
+			tc.values[SERVER_READ_TIMEOUT] = 10
+			tc.values[SERVER_WRITE_TIMEOUT] = 20
+			require.NoError(t, configura.WriteConfiguration(cfg, tc.values))
+
+			srv := &http.Server{}
+			setServerTimeouts(cfg, srv)
+
+			assert.Equal(t, 10*time.Second, srv.ReadTimeout)
+			assert.Equal(t, 20*time.Second, srv.WriteTimeout)
+			assert.Equal(t, tc.expectedIdleTimeout, srv.IdleTimeout)
+			assert.Equal(t, tc.expectedReadHeaderTimeout, srv.ReadHeaderTimeout)
+		})
+	}
+}
+
+func TestExternalHostMiddleware_SchemaLink(t *testing.T) {
+	cfg := configura.NewConfigImpl()
+	err := configura.WriteConfiguration(cfg, map[configura.Variable[string]]string{
+		middleware.HTTP_TRUSTED_PROXIES: "10.0.0.0/8",
+	})
+	require.NoError(t, err)
+
+	type greetingResponse struct {
+		Body struct {
+			Message string `json:"message"`
+		}
+	}
+
+	r := chi.NewRouter()
+	r.Use(middleware.ExternalHost(cfg))
+	api := humachi.New(r, huma.DefaultConfig("Test API", "1.0.0"))
+	api.UseMiddleware(externalHostMiddleware)
+	huma.Get(api, "/greeting", func(ctx context.Context, input *struct{}) (*greetingResponse, error) {
+		resp := &greetingResponse{}
+		resp.Body.Message = "hello"
+		return resp, nil
+	})
+
+	tests := []struct {
+		name           string
+		remoteAddr     string
+		expectedSchema string
+	}{
+		{
+			name:           "Trusted proxy",
+			remoteAddr:     "10.0.0.1:1234",
+			expectedSchema: "https://api.example.com/schemas/",
+		},
+		{
+			name:           "Untrusted peer",
+			remoteAddr:     "203.0.113.10:1234",
+			expectedSchema: "https://internal.svc:8080/schemas/",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/greeting", nil)
+			req.Host = "internal.svc:8080"
+			req.RemoteAddr = tc.remoteAddr
+			req.Header.Set("X-Forwarded-Host", "api.example.com")
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+
+			require.Equal(t, http.StatusOK, rr.Code)
+			var body map[string]any
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &body))
+			assert.True(t, strings.HasPrefix(body["$schema"].(string), tc.expectedSchema), "unexpected $schema %q", body["$schema"])
+		})
+	}
+}
+
+func TestNewListenConfig_KeepAlive(t *testing.T) {
+	tests := []struct {
+		name     string
+		period   int64
+		expected time.Duration
+	}{
+		{name: "Unset keeps the default", period: 0, expected: 0},
+		{name: "Configured period", period: 30, expected: 30 * time.Second},
+		{name: "Negative disables keep-alives", period: -1, expected: -time.Second},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := configura.NewConfigImpl()
+			err := configura.WriteConfiguration(cfg, map[configura.Variable[int64]]int64{
+				SERVER_TCP_KEEPALIVE_PERIOD: tc.period,
+			})
+			require.NoError(t, err)
+
+			lc := newListenConfig(cfg)
+			assert.Equal(t, tc.expected, lc.KeepAlive)
+
+			// The listener is created with the configuration, and accepts connections.
+			ln, err := lc.Listen(context.Background(), "tcp", "127.0.0.1:0")
+			require.NoError(t, err)
+			defer ln.Close()
+			conn, err := net.Dial("tcp", ln.Addr().String())
+			require.NoError(t, err)
+			conn.Close()
+		})
+	}
+}
+
+func TestListenAddress(t *testing.T) {
+	tests := []struct {
+		name     string
+		host     string
+		expected string
+	}{
+		{name: "All interfaces by default", host: "", expected: ":8080"},
+		{name: "IPv4 interface", host: "127.0.0.1", expected: "127.0.0.1:8080"},
+		{name: "IPv6 interface", host: "::1", expected: "[::1]:8080"},
+		{name: "Host name", host: "localhost", expected: "localhost:8080"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := configura.NewConfigImpl()
+			err := configura.WriteConfiguration(cfg, map[configura.Variable[string]]string{
+				SERVER_HOST: tc.host,
+			})
+			require.NoError(t, err)
+			err = configura.WriteConfiguration(cfg, map[configura.Variable[int64]]int64{
+				SERVER_PORT: 8080,
+			})
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.expected, listenAddress(cfg))
+		})
+	}
+}
+
+// externalIPv4 returns a non-loopback IPv4 address of the host, or nil if it has none.
+func externalIPv4() net.IP {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return nil
+	}
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok && !ipNet.IP.IsLoopback() && ipNet.IP.To4() != nil {
+			return ipNet.IP
+		}
+	}
+	return nil
+}
+
+func TestStart_ServerHost(t *testing.T) {
+	t.Parallel()
+
+	freePort, err := getFreePort()
+	require.NoError(t, err, "Failed to get free port")
+	hostCfg := configura.NewConfigImpl()
+	err = configura.WriteConfiguration(hostCfg, map[configura.Variable[string]]string{
+		SERVER_HOST: "127.0.0.1",
+	})
+	require.NoError(t, err)
+	err = configura.WriteConfiguration(hostCfg, map[configura.Variable[int64]]int64{
+		SERVER_PORT: int64(freePort),
+	})
+	require.NoError(t, err)
+	finalCfg := configura.Merge(newDefaultCfg(), hostCfg)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	startErrChan := make(chan error, 1)
+	go func() {
+		startErrChan <- Start(ctx, finalCfg, chi.NewRouter(), func(c configura.Config, r chi.Router, a huma.API) error { return nil })
+	}()
+
+	loopbackAddr := net.JoinHostPort("127.0.0.1", strconv.Itoa(freePort))
+	require.Eventually(t, func() bool {
+		conn, err := net.DialTimeout("tcp", loopbackAddr, 50*time.Millisecond)
+		if err != nil {
+			return false
+		}
+		conn.Close()
+		return true
+	}, 2*time.Second, 50*time.Millisecond, "Server did not start listening on %s", loopbackAddr)
+
+	// Connections to another interface of the host are refused.
+	if ip := externalIPv4(); ip != nil {
+		conn, err := net.DialTimeout("tcp", net.JoinHostPort(ip.String(), strconv.Itoa(freePort)), 500*time.Millisecond)
+		if err == nil {
+			conn.Close()
+		}
+		assert.Error(t, err, "The server should only accept connections on 127.0.0.1")
+	} else {
+		t.Log("No non-loopback interface, skipping the external connection check")
+	}
+
+	cancel()
+	select {
+	case err := <-startErrChan:
+		assert.NoError(t, err)
+	case <-time.After(3 * time.Second):
+		t.Fatal("Start did not exit after context cancellation")
+	}
+}
+
+func TestChainRegister(t *testing.T) {
+	var order []string
+	humaBundle := func(cfg configura.Config, r chi.Router, api huma.API) error {
+		order = append(order, "huma")
+		huma.Get(api, "/greeting", func(ctx context.Context, input *struct{}) (*greetingOutput, error) {
+			out := &greetingOutput{}
+			out.Body.Message = "hello"
+			return out, nil
+		})
+		return nil
+	}
+	chiRoute := func(cfg configura.Config, r chi.Router, api huma.API) error {
+		order = append(order, "chi")
+		r.Get("/ping", func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte("pong"))
+		})
+		return nil
+	}
+
+	r := chi.NewRouter()
+	api := humachi.New(r, huma.DefaultConfig("Test API", "1.0.0"))
+	err := ChainRegister(humaBundle, chiRoute)(configura.NewConfigImpl(), r, api)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"huma", "chi"}, order, "Functions should run in order")
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/greeting", nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), "hello")
+
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/ping", nil))
+	assert.Equal(t, "pong", rr.Body.String())
+}
+
+func TestChainRegister_StopsAtFirstError(t *testing.T) {
+	errFirst := errors.New("first failed")
+	secondCalled := false
+	err := ChainRegister(
+		func(cfg configura.Config, r chi.Router, api huma.API) error { return errFirst },
+		func(cfg configura.Config, r chi.Router, api huma.API) error {
+			secondCalled = true
+			return nil
+		},
+	)(configura.NewConfigImpl(), chi.NewRouter(), nil)
+
+	assert.ErrorIs(t, err, errFirst)
+	assert.False(t, secondCalled, "Functions after a failure should not run")
+}
+
+func TestLogRegistrationError_Stack(t *testing.T) {
+	// Not parallel, the default logger is replaced.
+	originalSlogLogger := slog.Default()
+	t.Cleanup(func() { slog.SetDefault(originalSlogLogger) })
+
+	for _, enabled := range []bool{false, true} {
+		t.Run(fmt.Sprintf("enabled=%t", enabled), func(t *testing.T) {
+			var buf bytes.Buffer
+			slog.SetDefault(slog.New(slog.NewJSONHandler(&buf, nil)))
+
+			cfg := configura.NewConfigImpl()
+			err := configura.WriteConfiguration(cfg, map[configura.Variable[bool]]bool{
+				middleware.LOG_INCLUDE_STACK_ON_ERROR: enabled,
+			})
+			require.NoError(t, err)
+
+			logRegistrationError(context.Background(), cfg, "Failed to register routes", errors.New("bundle failed"))
+
+			var entry map[string]any
+			require.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+			assert.Equal(t, "bundle failed", entry["error"])
+			if enabled {
+				assert.Contains(t, entry["stack"], "logRegistrationError")
+			} else {
+				assert.NotContains(t, entry, "stack", "Stacks should only be logged when enabled")
+			}
+		})
+	}
+}
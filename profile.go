@@ -0,0 +1,74 @@
+package ponrunner
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+
+	"github.com/ponrove/configura"
+)
+
+const (
+	SERVER_ENV configura.Variable[string] = "SERVER_ENV" // Deployment environment (dev, staging or prod) selecting a profile of defaults, empty for none
+)
+
+// environmentProfiles are the defaults of each deployment environment, applied to the keys that aren't set.
+var environmentProfiles = map[string]map[configura.Variable[string]]string{
+	"dev": {
+		SERVER_LOG_FORMAT: "text",
+		SERVER_LOG_LEVEL:  "debug",
+	},
+	"staging": {
+		SERVER_LOG_FORMAT: "json",
+		SERVER_LOG_LEVEL:  "debug",
+	},
+	"prod": {
+		SERVER_LOG_FORMAT:              "json",
+		SERVER_LOG_LEVEL:               "info",
+		OTEL_LOGS_MIN_LEVEL:            "info",
+		OTEL_EXPORTER_OTLP_COMPRESSION: "gzip",
+	},
+}
+
+// environmentAliases maps the other common names of the environments to their profile.
+var environmentAliases = map[string]string{
+	"development": "dev",
+	"production":  "prod",
+}
+
+// applyEnvironmentProfile returns the configuration with the defaults of the SERVER_ENV profile (e.g. readable debug
+// logs in dev, JSON logs in prod) for the keys that are empty. Set keys always take precedence. The configuration is
+// returned unchanged if SERVER_ENV is unset or unknown.
+func applyEnvironmentProfile(ctx context.Context, cfg configura.Config) configura.Config {
+	env := strings.ToLower(cfg.String(SERVER_ENV))
+	if env == "" {
+		return cfg
+	}
+	if alias, ok := environmentAliases[env]; ok {
+		env = alias
+	}
+	profile, ok := environmentProfiles[env]
+	if !ok {
+		slog.WarnContext(ctx, "Unknown environment configured, no profile applied", slog.String("configuredEnvironment", env))
+		return cfg
+	}
+	// Merging is only supported by configura for its own implementation.
+	impl, ok := cfg.(*configura.ConfigImpl)
+	if !ok {
+		slog.WarnContext(ctx, "Environment profiles require a *configura.ConfigImpl, no profile applied", slog.String("environment", env))
+		return cfg
+	}
+
+	defaults := make(map[configura.Variable[string]]string, len(profile))
+	for key, value := range profile {
+		if cfg.String(key) == "" {
+			defaults[key] = value
+		}
+	}
+	overlay := configura.NewConfigImpl()
+	if err := configura.WriteConfiguration(overlay, defaults); err != nil {
+		slog.WarnContext(ctx, "Failed to apply the environment profile", slog.String("environment", env), slog.Any("error", err))
+		return cfg
+	}
+	return configura.Merge(impl, overlay)
+}
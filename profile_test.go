@@ -0,0 +1,63 @@
+package ponrunner
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/ponrove/configura"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyEnvironmentProfile(t *testing.T) {
+	tests := []struct {
+		name      string
+		env       string
+		logFormat string
+		json      bool
+	}{
+		{name: "dev logs text", env: "dev", json: false},
+		{name: "prod logs json", env: "prod", json: true},
+		{name: "alias", env: "Production", json: true},
+		{name: "explicit format overrides the profile", env: "prod", logFormat: "text", json: false},
+		{name: "no environment keeps the default", env: "", json: false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := configura.NewConfigImpl()
+			err := configura.WriteConfiguration(cfg, map[configura.Variable[string]]string{
+				SERVER_ENV:        tc.env,
+				SERVER_LOG_FORMAT: tc.logFormat,
+			})
+			require.NoError(t, err)
+
+			var stdout bytes.Buffer
+			ctx := context.Background()
+			slog.New(newLogHandler(ctx, applyEnvironmentProfile(ctx, cfg), &stdout, io.Discard)).Info("request served")
+
+			assert.Equal(t, tc.json, strings.HasPrefix(stdout.String(), "{"), "Unexpected log format: %s", stdout.String())
+		})
+	}
+}
+
+func TestApplyEnvironmentProfile_KeepsOtherKeys(t *testing.T) {
+	cfg := configura.NewConfigImpl()
+	require.NoError(t, configura.WriteConfiguration(cfg, map[configura.Variable[string]]string{
+		SERVER_ENV:       "dev",
+		SERVER_LOG_LEVEL: "warn",
+	}))
+	require.NoError(t, configura.WriteConfiguration(cfg, map[configura.Variable[int64]]int64{
+		SERVER_PORT: 8080,
+	}))
+
+	profiled := applyEnvironmentProfile(context.Background(), cfg)
+	assert.Equal(t, "warn", profiled.String(SERVER_LOG_LEVEL), "Set keys should take precedence")
+	assert.Equal(t, "text", profiled.String(SERVER_LOG_FORMAT))
+	assert.Equal(t, int64(8080), profiled.Int64(SERVER_PORT))
+	assert.NoError(t, profiled.ConfigurationKeysRegistered(SERVER_PORT, SERVER_LOG_LEVEL))
+}
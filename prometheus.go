@@ -0,0 +1,53 @@
+package ponrunner
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/ponrove/configura"
+	promclient "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/otel/exporters/prometheus"
+	"go.opentelemetry.io/otel/sdk/metric"
+)
+
+const (
+	OTEL_METRICS_EXPORTER configura.Variable[string] = "OTEL_METRICS_EXPORTER" // Exporter of the metrics, otlp (the default) or prometheus
+	OTEL_PROMETHEUS_PATH  configura.Variable[string] = "OTEL_PROMETHEUS_PATH"  // Path Prometheus scrapes the metrics on, defaults to /metrics
+)
+
+const (
+	OTEL_PROMETHEUS_OPENMETRICS configura.Variable[bool] = "OTEL_PROMETHEUS_OPENMETRICS" // Serve the OpenMetrics format, with exemplars, to scrapers asking for it
+)
+
+// metricsExporterPrometheus is the OTEL_METRICS_EXPORTER value exposing the metrics to Prometheus.
+const metricsExporterPrometheus = "prometheus"
+
+// newPrometheusReader returns a metric reader collecting the metrics when Prometheus scrapes the returned handler, in
+// place of the periodic reader pushing them over OTLP. The metrics are registered with a registry of their own, so
+// they don't clash with collectors registered globally by the application.
+//
+// With OTEL_PROMETHEUS_OPENMETRICS, scrapers accepting application/openmetrics-text get the OpenMetrics format, which
+// carries the exemplars of the histograms and counters: the trace and span IDs of sampled requests, for Grafana to link
+// the metrics to their traces. Exemplars are only recorded while a sampled span is in the context of the measurement.
+func newPrometheusReader(cfg configura.Config) (metric.Reader, http.Handler, error) {
+	registry := promclient.NewRegistry()
+	exporter, err := prometheus.New(prometheus.WithRegisterer(registry))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create Prometheus metric exporter: %w", err)
+	}
+	return exporter, promhttp.HandlerFor(registry, promhttp.HandlerOpts{
+		EnableOpenMetrics: configura.Fallback(cfg.Bool(OTEL_PROMETHEUS_OPENMETRICS), false),
+	}), nil
+}
+
+// registerPrometheusEndpoint mounts the endpoint Prometheus scrapes the metrics on at OTEL_PROMETHEUS_PATH, if the
+// meter provider was set up with OTEL_METRICS_EXPORTER=prometheus.
+func registerPrometheusEndpoint(cfg configura.Config, router chi.Router) {
+	p := currentProviders.Load()
+	if p == nil || p.metricsHandler == nil {
+		return
+	}
+	router.Handle(configura.Fallback(cfg.String(OTEL_PROMETHEUS_PATH), "/metrics"), p.metricsHandler)
+}
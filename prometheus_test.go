@@ -0,0 +1,141 @@
+package ponrunner
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/go-chi/chi/v5"
+	"github.com/ponrove/configura"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	otelmetric "go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestStart_PrometheusEndpoint(t *testing.T) {
+	// Not parallel, the OpenTelemetry providers and the default logger are global.
+	originalMeterProvider := otel.GetMeterProvider()
+	originalLogger := slog.Default()
+	t.Cleanup(func() {
+		otel.SetMeterProvider(originalMeterProvider)
+		slog.SetDefault(originalLogger)
+	})
+
+	cfg := configura.NewConfigImpl()
+	err := configura.WriteConfiguration(cfg, map[configura.Variable[bool]]bool{
+		OTEL_ENABLED:         true,
+		OTEL_TRACES_ENABLED:  false,
+		OTEL_METRICS_ENABLED: true,
+		OTEL_LOGS_ENABLED:    false,
+	})
+	require.NoError(t, err)
+	err = configura.WriteConfiguration(cfg, map[configura.Variable[string]]string{
+		SERVER_HOST:           "127.0.0.1",
+		OTEL_METRICS_EXPORTER: "prometheus",
+		OTEL_PROMETHEUS_PATH:  "/internal/metrics",
+	})
+	require.NoError(t, err)
+	err = configura.WriteConfiguration(cfg, map[configura.Variable[int64]]int64{
+		SERVER_PORT: 0,
+	})
+	require.NoError(t, err)
+
+	server, err := StartAsync(context.Background(), configura.Merge(newDefaultCfg(), cfg), chi.NewRouter(), func(c configura.Config, r chi.Router, a huma.API) error {
+		counter, err := MeterProvider().Meter("ponrunner-test").Int64Counter("orders.created")
+		if err != nil {
+			return err
+		}
+		counter.Add(context.Background(), 3)
+		return nil
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = server.Shutdown(ctx)
+		_ = server.Wait()
+	})
+
+	resp, err := http.Get("http://" + server.Addr().String() + "/internal/metrics")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Regexp(t, `(?m)^orders_created_total\{[^}]*otel_scope_name="ponrunner-test"[^}]*\} 3$`, string(body))
+}
+
+func TestNewMeterProvider_UnsupportedExporter(t *testing.T) {
+	cfg := configura.NewConfigImpl()
+	err := configura.WriteConfiguration(cfg, map[configura.Variable[string]]string{
+		OTEL_METRICS_EXPORTER: "statsd",
+	})
+	require.NoError(t, err)
+
+	_, _, err = newMeterProvider(context.Background(), nil, cfg)
+	assert.ErrorContains(t, err, "unsupported OTEL_METRICS_EXPORTER")
+}
+
+func TestNewPrometheusReader_OpenMetricsExemplars(t *testing.T) {
+	tests := []struct {
+		name              string
+		openMetrics       bool
+		expectedExemplars bool
+	}{
+		{name: "OpenMetrics", openMetrics: true, expectedExemplars: true},
+		{name: "Prometheus text format by default", openMetrics: false, expectedExemplars: false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := configura.NewConfigImpl()
+			err := configura.WriteConfiguration(cfg, map[configura.Variable[bool]]bool{
+				OTEL_PROMETHEUS_OPENMETRICS: tc.openMetrics,
+			})
+			require.NoError(t, err)
+
+			reader, handler, err := newPrometheusReader(cfg)
+			require.NoError(t, err)
+			mp := metric.NewMeterProvider(metric.WithReader(reader))
+			t.Cleanup(func() { _ = mp.Shutdown(context.Background()) })
+
+			// The measurement is made within a sampled span, whose IDs become the exemplar.
+			spanCtx := trace.NewSpanContext(trace.SpanContextConfig{
+				TraceID:    trace.TraceID{0x0a, 0xf7, 0x65, 0x19, 0x16, 0xcd, 0x43, 0xdd, 0x84, 0x48, 0xeb, 0x21, 0x1c, 0x80, 0x31, 0x9c},
+				SpanID:     trace.SpanID{0xb7, 0xad, 0x6b, 0x71, 0x69, 0x20, 0x33, 0x31},
+				TraceFlags: trace.FlagsSampled,
+			})
+			ctx := trace.ContextWithSpanContext(context.Background(), spanCtx)
+			histogram, err := mp.Meter("ponrunner-test").Float64Histogram("checkout.duration", otelmetric.WithUnit("s"))
+			require.NoError(t, err)
+			histogram.Record(ctx, 0.25)
+
+			req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+			req.Header.Set("Accept", "application/openmetrics-text; version=1.0.0")
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+			require.Equal(t, http.StatusOK, rr.Code)
+
+			// The labels of the exemplar are in no particular order.
+			exemplar := regexp.MustCompile(`(?m)^checkout_duration_seconds_bucket\{.*\} 1 # \{(span_id="b7ad6b7169203331",trace_id="0af7651916cd43dd8448eb211c80319c"|trace_id="0af7651916cd43dd8448eb211c80319c",span_id="b7ad6b7169203331")\} 0\.25 `)
+			if tc.expectedExemplars {
+				assert.Contains(t, rr.Header().Get("Content-Type"), "application/openmetrics-text")
+				assert.Regexp(t, exemplar, rr.Body.String())
+			} else {
+				assert.Contains(t, rr.Header().Get("Content-Type"), "text/plain")
+				assert.NotRegexp(t, exemplar, rr.Body.String())
+				assert.Contains(t, rr.Body.String(), "checkout_duration_seconds_bucket")
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package ponrunner
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
+
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// telemetryProviders are the OpenTelemetry providers set up by Start. A provider is nil if its signal is disabled.
+// metricsHandler serves the metrics to Prometheus, if they are exported with OTEL_METRICS_EXPORTER=prometheus.
+type telemetryProviders struct {
+	tracer         *sdktrace.TracerProvider
+	meter          *sdkmetric.MeterProvider
+	logger         *sdklog.LoggerProvider
+	metricsHandler http.Handler
+}
+
+// forceFlush exports the spans, metrics and log records the providers hold.
+func (p *telemetryProviders) forceFlush(ctx context.Context) error {
+	var err error
+	if p.tracer != nil {
+		err = errors.Join(err, p.tracer.ForceFlush(ctx))
+	}
+	if p.meter != nil {
+		err = errors.Join(err, p.meter.ForceFlush(ctx))
+	}
+	if p.logger != nil {
+		err = errors.Join(err, p.logger.ForceFlush(ctx))
+	}
+	return err
+}
+
+// currentProviders holds the providers of the running server, for the package accessors. It's nil while OpenTelemetry
+// is disabled, and once it's shut down.
+var currentProviders atomic.Pointer[telemetryProviders]
+
+// TracerProvider returns the tracer provider set up by the running server, for creating spans with the exact provider
+// ponrunner configured rather than the global one. It returns nil if tracing is disabled or the server isn't running.
+func TracerProvider() *sdktrace.TracerProvider {
+	if p := currentProviders.Load(); p != nil {
+		return p.tracer
+	}
+	return nil
+}
+
+// MeterProvider returns the meter provider set up by the running server, for creating instruments with the exact
+// provider ponrunner configured rather than the global one. It returns nil if metrics are disabled or the server isn't
+// running.
+func MeterProvider() *sdkmetric.MeterProvider {
+	if p := currentProviders.Load(); p != nil {
+		return p.meter
+	}
+	return nil
+}
+
+// LoggerProvider returns the logger provider set up by the running server. It returns nil if OpenTelemetry logging is
+// disabled or the server isn't running.
+func LoggerProvider() *sdklog.LoggerProvider {
+	if p := currentProviders.Load(); p != nil {
+		return p.logger
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package ponrunner
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/go-chi/chi/v5"
+	"github.com/ponrove/configura"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/log"
+	otelglobal "go.opentelemetry.io/otel/log/global"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestStart_ExposesProviders(t *testing.T) {
+	// Not parallel, the providers are registered globally.
+	originalTracerProvider := otel.GetTracerProvider()
+	originalMeterProvider := otel.GetMeterProvider()
+	originalLoggerProvider := otelglobal.GetLoggerProvider()
+	originalSlogLogger := slog.Default()
+	t.Cleanup(func() {
+		otel.SetTracerProvider(originalTracerProvider)
+		otel.SetMeterProvider(originalMeterProvider)
+		otelglobal.SetLoggerProvider(originalLoggerProvider)
+		slog.SetDefault(originalSlogLogger)
+	})
+
+	freePort, err := getFreePort()
+	require.NoError(t, err, "Failed to get free port")
+
+	emptyCfg := configura.NewConfigImpl()
+	err = configura.WriteConfiguration(emptyCfg, map[configura.Variable[int64]]int64{
+		SERVER_PORT: int64(freePort),
+	})
+	require.NoError(t, err, "Failed to write configuration")
+	err = configura.WriteConfiguration(emptyCfg, map[configura.Variable[bool]]bool{
+		OTEL_ENABLED:         true,
+		OTEL_TRACES_ENABLED:  true,
+		OTEL_METRICS_ENABLED: true,
+		OTEL_LOGS_ENABLED:    true,
+	})
+	require.NoError(t, err, "Failed to write configuration")
+	finalCfg := configura.Merge(newDefaultCfg(), emptyCfg)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+
+	var (
+		globalTracerProvider trace.TracerProvider
+		globalMeterProvider  metric.MeterProvider
+		globalLoggerProvider log.LoggerProvider
+	)
+	err = Start(ctx, finalCfg, chi.NewRouter(), func(cfg configura.Config, r chi.Router, api huma.API) error {
+		globalTracerProvider = otel.GetTracerProvider()
+		globalMeterProvider = otel.GetMeterProvider()
+		globalLoggerProvider = otelglobal.GetLoggerProvider()
+
+		require.NotNil(t, TracerProvider(), "The tracer provider should be exposed while running")
+		require.NotNil(t, MeterProvider(), "The meter provider should be exposed while running")
+		require.NotNil(t, LoggerProvider(), "The logger provider should be exposed while running")
+		assert.Same(t, globalTracerProvider, TracerProvider())
+		assert.Same(t, globalMeterProvider, MeterProvider())
+		assert.Same(t, globalLoggerProvider, LoggerProvider())
+		return nil
+	})
+	require.NoError(t, err)
+
+	assert.NotNil(t, globalTracerProvider, "Routes should have been registered")
+	assert.Nil(t, TracerProvider(), "The providers should not be exposed once shut down")
+	assert.Nil(t, MeterProvider())
+	assert.Nil(t, LoggerProvider())
+}
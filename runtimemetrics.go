@@ -0,0 +1,50 @@
+package ponrunner
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/ponrove/configura"
+	"go.opentelemetry.io/contrib/instrumentation/runtime"
+	"go.opentelemetry.io/otel/metric"
+)
+
+const (
+	OTEL_RUNTIME_METRICS_ENABLED configura.Variable[string] = "OTEL_RUNTIME_METRICS_ENABLED" // Collect the Go runtime metrics with the meter provider, true unless set to false
+)
+
+const (
+	OTEL_RUNTIME_METRICS_INTERVAL configura.Variable[int64] = "OTEL_RUNTIME_METRICS_INTERVAL" // Minimum interval in seconds between reads of the runtime memory stats, defaults to 15
+)
+
+// startRuntimeMetrics registers the Go runtime metrics, e.g. the goroutine count, heap usage and GC goal, with the meter
+// provider, unless OTEL_RUNTIME_METRICS_ENABLED is false. The metrics are observed on each collection of the provider,
+// reading the memory stats at most every OTEL_RUNTIME_METRICS_INTERVAL, and stop with the provider's shutdown.
+//
+// OTEL_RUNTIME_METRICS_ENABLED is a string rather than a bool, as it's on when unset.
+func startRuntimeMetrics(ctx context.Context, cfg configura.Config, mp metric.MeterProvider) error {
+	enabled := true
+	if value := cfg.String(OTEL_RUNTIME_METRICS_ENABLED); value != "" {
+		var err error
+		if enabled, err = strconv.ParseBool(value); err != nil {
+			return fmt.Errorf("invalid OTEL_RUNTIME_METRICS_ENABLED %q: %w", value, err)
+		}
+	}
+	if !enabled {
+		slog.DebugContext(ctx, "Go runtime metrics disabled via OTEL_RUNTIME_METRICS_ENABLED.")
+		return nil
+	}
+
+	interval := runtime.DefaultMinimumReadMemStatsInterval
+	if seconds := cfg.Int64(OTEL_RUNTIME_METRICS_INTERVAL); seconds > 0 {
+		interval = time.Duration(seconds) * time.Second
+	}
+	if err := runtime.Start(runtime.WithMeterProvider(mp), runtime.WithMinimumReadMemStatsInterval(interval)); err != nil {
+		return fmt.Errorf("failed to start Go runtime metrics: %w", err)
+	}
+	slog.InfoContext(ctx, "Go runtime metrics started.", slog.Duration("interval", interval))
+	return nil
+}
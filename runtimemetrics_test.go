@@ -0,0 +1,73 @@
+package ponrunner
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ponrove/configura"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/contrib/instrumentation/runtime"
+	"go.opentelemetry.io/otel"
+)
+
+func TestInitializeMeterProvider_RuntimeMetrics(t *testing.T) {
+	// Not parallel, the meter provider is registered globally.
+	originalMeterProvider := otel.GetMeterProvider()
+	t.Cleanup(func() { otel.SetMeterProvider(originalMeterProvider) })
+
+	// The runtime metrics are scraped with the scope of the runtime instrumentation.
+	runtimeScope := `otel_scope_name="` + runtime.ScopeName + `"`
+	scrape := func(handler http.Handler) string {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		return rr.Body.String()
+	}
+
+	tests := []struct {
+		name            string
+		enabled         string
+		expectedRuntime bool
+		expectErr       bool
+	}{
+		{name: "Enabled by default", expectedRuntime: true},
+		{name: "Enabled", enabled: "true", expectedRuntime: true},
+		{name: "Disabled", enabled: "false"},
+		{name: "Invalid", enabled: "sometimes", expectErr: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := configura.NewConfigImpl()
+			err := configura.WriteConfiguration(cfg, map[configura.Variable[string]]string{
+				OTEL_METRICS_EXPORTER:        "prometheus",
+				OTEL_RUNTIME_METRICS_ENABLED: tc.enabled,
+			})
+			require.NoError(t, err)
+			err = configura.WriteConfiguration(cfg, map[configura.Variable[int64]]int64{
+				OTEL_RUNTIME_METRICS_INTERVAL: 1,
+			})
+			require.NoError(t, err)
+
+			_, handler, shutdown, err := initializeMeterProvider(context.Background(), nil, cfg)
+			if tc.expectErr {
+				assert.ErrorContains(t, err, "invalid OTEL_RUNTIME_METRICS_ENABLED")
+				return
+			}
+			require.NoError(t, err)
+
+			if !tc.expectedRuntime {
+				assert.NotContains(t, scrape(handler), runtimeScope)
+				require.NoError(t, shutdown(context.Background()))
+				return
+			}
+			assert.Contains(t, scrape(handler), runtimeScope)
+
+			// The runtime metrics stop being collected with the meter provider.
+			require.NoError(t, shutdown(context.Background()))
+			assert.NotContains(t, scrape(handler), runtimeScope)
+		})
+	}
+}
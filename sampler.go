@@ -0,0 +1,106 @@
+package ponrunner
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/ponrove/configura"
+	"github.com/ponrove/ponrunner/middleware"
+	"github.com/ponrove/ponrunner/utils"
+	"go.opentelemetry.io/otel/sdk/trace"
+)
+
+const (
+	OTEL_FORCE_TRACE_HEADER configura.Variable[string] = "OTEL_FORCE_TRACE_HEADER" // Header forcing a request to be sampled, defaults to X-Force-Trace
+	OTEL_TRACES_SAMPLER     configura.Variable[string] = "OTEL_TRACES_SAMPLER"     // Sampler of the traces, defaults to parentbased_always_on
+	OTEL_TRACES_SAMPLER_ARG configura.Variable[string] = "OTEL_TRACES_SAMPLER_ARG" // Sampling ratio of the traceidratio samplers, defaults to 1.0
+)
+
+// newSampler returns the sampler selected by OTEL_TRACES_SAMPLER, as in the OpenTelemetry specification: always_on,
+// always_off, traceidratio, parentbased_always_on (the default), parentbased_always_off or parentbased_traceidratio.
+// The ratio of the traceidratio samplers is OTEL_TRACES_SAMPLER_ARG, between 0 and 1 (1 by default).
+func newSampler(cfg configura.Config) (trace.Sampler, error) {
+	ratio := func() (float64, error) {
+		arg := strings.TrimSpace(cfg.String(OTEL_TRACES_SAMPLER_ARG))
+		if arg == "" {
+			return 1, nil
+		}
+		ratio, err := strconv.ParseFloat(arg, 64)
+		if err != nil || ratio < 0 || ratio > 1 {
+			return 0, fmt.Errorf("invalid OTEL_TRACES_SAMPLER_ARG %q, expected a ratio between 0 and 1", arg)
+		}
+		return ratio, nil
+	}
+
+	switch sampler := strings.ToLower(strings.TrimSpace(cfg.String(OTEL_TRACES_SAMPLER))); sampler {
+	case "always_on":
+		return trace.AlwaysSample(), nil
+	case "always_off":
+		return trace.NeverSample(), nil
+	case "traceidratio":
+		r, err := ratio()
+		if err != nil {
+			return nil, err
+		}
+		return trace.TraceIDRatioBased(r), nil
+	case "", "parentbased_always_on":
+		return trace.ParentBased(trace.AlwaysSample()), nil
+	case "parentbased_always_off":
+		return trace.ParentBased(trace.NeverSample()), nil
+	case "parentbased_traceidratio":
+		r, err := ratio()
+		if err != nil {
+			return nil, err
+		}
+		return trace.ParentBased(trace.TraceIDRatioBased(r)), nil
+	default:
+		return nil, fmt.Errorf("unsupported OTEL_TRACES_SAMPLER %q, expected always_on, always_off, traceidratio, "+
+			"parentbased_always_on, parentbased_always_off or parentbased_traceidratio", sampler)
+	}
+}
+
+// ctxForceTraceKey is a context key marking a request whose spans must be sampled.
+type ctxForceTraceKey struct{}
+
+// forceTraceSampler wraps the configured sampler, and samples every span started with a context marked by
+// forceTraceHandler, regardless of the decision the configured sampler would make.
+type forceTraceSampler struct {
+	base trace.Sampler
+}
+
+// Ensure the forceTraceSampler implements the trace.Sampler interface at compile time.
+var _ trace.Sampler = forceTraceSampler{}
+
+// ShouldSample records and samples forced requests, and defers to the configured sampler otherwise.
+func (s forceTraceSampler) ShouldSample(p trace.SamplingParameters) trace.SamplingResult {
+	if forced, _ := p.ParentContext.Value(ctxForceTraceKey{}).(bool); forced {
+		result := s.base.ShouldSample(p)
+		result.Decision = trace.RecordAndSample
+		return result
+	}
+	return s.base.ShouldSample(p)
+}
+
+// Description returns the description of the sampler.
+func (s forceTraceSampler) Description() string {
+	return "ForceTrace{" + s.base.Description() + "}"
+}
+
+// forceTraceHandler marks requests with a truthy OTEL_FORCE_TRACE_HEADER (X-Force-Trace by default) header, so the
+// span started for them by otelhttp is always sampled. The header is only honored from the proxies listed in
+// HTTP_TRUSTED_PROXIES, so clients can't drive up tracing costs; it must wrap the otelhttp handler, as the sampling
+// decision is made when the span starts.
+func forceTraceHandler(cfg configura.Config, next http.Handler) http.Handler {
+	header := configura.Fallback(cfg.String(OTEL_FORCE_TRACE_HEADER), "X-Force-Trace")
+	trusted := utils.ParseTrustedProxies(cfg.String(middleware.HTTP_TRUSTED_PROXIES))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if forced, _ := strconv.ParseBool(r.Header.Get(header)); forced && utils.IsTrustedProxy(r.RemoteAddr, trusted) {
+			r = r.WithContext(context.WithValue(r.Context(), ctxForceTraceKey{}, true))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,141 @@
+package ponrunner
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ponrove/configura"
+	"github.com/ponrove/ponrunner/middleware"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/propagation"
+	sdkresource "go.opentelemetry.io/otel/sdk/resource"
+	"go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+func TestForceTraceHandler(t *testing.T) {
+	t.Parallel()
+
+	cfg := configura.NewConfigImpl()
+	err := configura.WriteConfiguration(cfg, map[configura.Variable[string]]string{
+		middleware.HTTP_TRUSTED_PROXIES: "10.0.0.0/8",
+	})
+	require.NoError(t, err)
+
+	// The configured sampler never samples, so only forced requests are recorded.
+	recorder := tracetest.NewSpanRecorder()
+	tp := trace.NewTracerProvider(
+		trace.WithSpanProcessor(recorder),
+		trace.WithSampler(forceTraceSampler{base: trace.ParentBased(trace.TraceIDRatioBased(0))}),
+	)
+	handler := forceTraceHandler(cfg, otelhttp.NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), "http.server",
+		otelhttp.WithTracerProvider(tp),
+		otelhttp.WithPropagators(propagation.TraceContext{}),
+	))
+
+	tests := []struct {
+		name        string
+		remoteAddr  string
+		header      string
+		wantSampled bool
+	}{
+		{name: "No header follows the configured ratio", remoteAddr: "10.0.0.1:1234"},
+		{name: "Header from trusted proxy is sampled", remoteAddr: "10.0.0.1:1234", header: "1", wantSampled: true},
+		{name: "Header from untrusted peer is ignored", remoteAddr: "203.0.113.7:1234", header: "true"},
+		{name: "Falsy header is ignored", remoteAddr: "10.0.0.1:1234", header: "false"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			before := len(recorder.Ended())
+			req := httptest.NewRequest(http.MethodGet, "/debug", nil)
+			req.RemoteAddr = tc.remoteAddr
+			if tc.header != "" {
+				req.Header.Set("X-Force-Trace", tc.header)
+			}
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			ended := recorder.Ended()[before:]
+			if tc.wantSampled {
+				require.Len(t, ended, 1, "Forced request should be sampled")
+				assert.True(t, ended[0].SpanContext().IsSampled())
+			} else {
+				assert.Empty(t, ended, "Request should not be sampled")
+			}
+		})
+	}
+}
+
+func TestNewTracerProvider_Sampler(t *testing.T) {
+	originalSlogLogger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	defer slog.SetDefault(originalSlogLogger)
+
+	tests := []struct {
+		name    string
+		sampler string
+		arg     string
+		// wantRoot is whether a new trace is sampled, wantUnsampledParent a span whose remote parent wasn't sampled.
+		wantRoot            bool
+		wantUnsampledParent bool
+		wantErr             bool
+	}{
+		{name: "Default is parentbased_always_on", wantRoot: true},
+		{name: "always_on", sampler: "always_on", wantRoot: true, wantUnsampledParent: true},
+		{name: "always_off", sampler: "always_off"},
+		{name: "traceidratio of 1", sampler: "traceidratio", arg: "1", wantRoot: true, wantUnsampledParent: true},
+		{name: "traceidratio of 0", sampler: "traceidratio", arg: "0"},
+		{name: "traceidratio defaults to 1", sampler: "traceidratio", wantRoot: true, wantUnsampledParent: true},
+		{name: "parentbased_always_on", sampler: "parentbased_always_on", wantRoot: true},
+		{name: "parentbased_always_off", sampler: "parentbased_always_off"},
+		{name: "parentbased_traceidratio of 1", sampler: "parentbased_traceidratio", arg: "1.0", wantRoot: true},
+		{name: "parentbased_traceidratio of 0", sampler: "parentbased_traceidratio", arg: "0"},
+		{name: "Unknown sampler", sampler: "jaeger_remote", wantErr: true},
+		{name: "Ratio out of range", sampler: "traceidratio", arg: "1.5", wantErr: true},
+		{name: "Ratio not a number", sampler: "parentbased_traceidratio", arg: "half", wantErr: true},
+	}
+
+	ctx := context.Background()
+	res, err := sdkresource.New(ctx)
+	require.NoError(t, err)
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			samplerCfg := configura.NewConfigImpl()
+			require.NoError(t, configura.WriteConfiguration(samplerCfg, map[configura.Variable[string]]string{
+				OTEL_TRACES_SAMPLER:     tc.sampler,
+				OTEL_TRACES_SAMPLER_ARG: tc.arg,
+			}))
+			cfg := configura.Merge(newDefaultCfg(), samplerCfg)
+
+			tp, err := newTracerProvider(ctx, res, cfg, nil, tracetest.NewInMemoryExporter())
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			defer tp.Shutdown(ctx)
+			tracer := tp.Tracer("sampler-test")
+
+			_, root := tracer.Start(ctx, "root")
+			root.End()
+			assert.Equal(t, tc.wantRoot, root.SpanContext().IsSampled(), "sampling of a new trace")
+
+			parent := oteltrace.NewSpanContext(oteltrace.SpanContextConfig{
+				TraceID: oteltrace.TraceID{1},
+				SpanID:  oteltrace.SpanID{1},
+				Remote:  true,
+			})
+			_, child := tracer.Start(oteltrace.ContextWithRemoteSpanContext(ctx, parent), "child")
+			child.End()
+			assert.Equal(t, tc.wantUnsampledParent, child.SpanContext().IsSampled(), "sampling under an unsampled parent")
+		})
+	}
+}
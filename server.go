@@ -0,0 +1,102 @@
+package ponrunner
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"reflect"
+	"runtime"
+	"slices"
+	"strings"
+	"sync/atomic"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/ponrove/configura"
+)
+
+// namedMiddleware is a middleware of the router, with the name it's reported under by Server.MiddlewareChain.
+type namedMiddleware struct {
+	name    string
+	handler func(http.Handler) http.Handler
+}
+
+// middlewareName returns the name of a middleware added with WithMiddleware, the name of its function without the
+// path of its package, e.g. middleware.RequestID. Middleware built by a constructor is named after the closure, e.g.
+// middleware.Logger.func1.
+func middlewareName(mw func(http.Handler) http.Handler) string {
+	fn := runtime.FuncForPC(reflect.ValueOf(mw).Pointer())
+	if fn == nil {
+		return "unknown"
+	}
+	name := fn.Name()
+	return name[strings.LastIndex(name, "/")+1:]
+}
+
+// Server describes a server started by Start or StartAsync, and controls its lifecycle.
+type Server struct {
+	middlewareChain []string
+	addr            net.Addr
+	listening       chan struct{} // Closed once the listener is bound.
+	done            chan struct{} // Closed once the server has shut down, err is set by then.
+	err             error
+	ctx             context.Context
+	cancel          context.CancelFunc
+}
+
+// newServer returns a server that isn't started yet, running until ctx is canceled or it's shut down.
+func newServer(ctx context.Context) *Server {
+	s := &Server{listening: make(chan struct{}), done: make(chan struct{})}
+	s.ctx, s.cancel = context.WithCancel(ctx)
+	return s
+}
+
+// serve runs the server until it's shut down, keeping the error Start returns.
+func (s *Server) serve(cfg configura.Config, router chi.Router, register RegisterRoutes, opts []Option) {
+	defer close(s.done)
+	defer s.cancel()
+	s.err = start(s.ctx, s, cfg, router, register, opts...)
+}
+
+// Addr returns the address the server listens on, e.g. the port chosen by the OS for SERVER_PORT 0, or nil if the
+// listener isn't bound yet.
+func (s *Server) Addr() net.Addr {
+	select {
+	case <-s.listening:
+		return s.addr
+	default:
+		return nil
+	}
+}
+
+// Wait blocks until the server has shut down, and returns the error Start would return: nil after a graceful shutdown.
+func (s *Server) Wait() error {
+	<-s.done
+	return s.err
+}
+
+// Shutdown shuts the server down gracefully, as an OS signal would, and waits for the shutdown to complete or ctx to
+// be done. It returns the error of the shutdown, the same as Wait, or the error of ctx if it's done first.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.cancel()
+	select {
+	case <-s.done:
+		return s.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// currentServer is the server started last, returned by RunningServer.
+var currentServer atomic.Pointer[Server]
+
+// RunningServer returns the running server, or nil if no server is running.
+func RunningServer() *Server {
+	return currentServer.Load()
+}
+
+// MiddlewareChain returns the names of the middleware of the router, in the order they handle requests: ponrunner's
+// default middleware (e.g. LogRequest), disabled ones included as they pass requests through, followed by the ones
+// added with WithMiddleware.
+func (s *Server) MiddlewareChain() []string {
+	return slices.Clone(s.middlewareChain)
+}
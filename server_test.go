@@ -0,0 +1,257 @@
+package ponrunner
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/go-chi/chi/v5"
+	chim "github.com/go-chi/chi/v5/middleware"
+	"github.com/ponrove/configura"
+	"github.com/ponrove/ponrunner/middleware"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStart_MiddlewareChain(t *testing.T) {
+	// Not parallel, RunningServer returns the server started last.
+	freePort, err := getFreePort()
+	require.NoError(t, err, "Failed to get free port")
+
+	emptyCfg := configura.NewConfigImpl()
+	err = configura.WriteConfiguration(emptyCfg, map[configura.Variable[int64]]int64{
+		SERVER_PORT: int64(freePort),
+	})
+	require.NoError(t, err, "Failed to write configuration")
+	finalCfg := configura.Merge(newDefaultCfg(), emptyCfg)
+
+	custom := func(next http.Handler) http.Handler { return next }
+
+	ctx, cancel := context.WithCancel(context.Background())
+	startErrChan := make(chan error, 1)
+	go func() {
+		startErrChan <- Start(ctx, finalCfg, chi.NewRouter(), func(cfg configura.Config, r chi.Router, a huma.API) error {
+			return nil
+		}, WithMiddleware(chim.NoCache, custom))
+	}()
+
+	require.Eventually(t, func() bool {
+		conn, err := net.Dial("tcp", fmt.Sprintf("localhost:%d", freePort))
+		if err != nil {
+			return false
+		}
+		conn.Close()
+		return true
+	}, 2*time.Second, 50*time.Millisecond, "server never started")
+
+	server := RunningServer()
+	require.NotNil(t, server)
+	assert.Equal(t, []string{
+		"IPAddress", "ExternalHost", "GeoIP", "Fingerprint", "RequestID", "RequestIDBaggage", "Recoverer", "LogRequest", "Metrics",
+		"Drain", "Maintenance", "RateLimit", "MaxQueryParams", "HeaderLimits", "RequireHTTPS", "RequireAPIVersion", "Accept",
+		"ServerTiming", "CacheControl", "ETag", "Idempotency", "Mirror", "MaxResponseBytes", "MinUploadRate", "MultipartLimit", "MaxJSONDepth",
+		"Timeout",
+		"middleware.NoCache", "ponrunner.TestStart_MiddlewareChain.func1",
+	}, server.MiddlewareChain())
+
+	cancel()
+	select {
+	case err := <-startErrChan:
+		assert.NoError(t, err, "Start should exit gracefully without error")
+	case <-time.After(3 * time.Second):
+		t.Fatal("Start did not exit after context cancellation")
+	}
+	assert.Nil(t, RunningServer(), "No server should be running after shutdown")
+}
+
+func TestStartAsync(t *testing.T) {
+	cfg := configura.NewConfigImpl()
+	err := configura.WriteConfiguration(cfg, map[configura.Variable[string]]string{
+		SERVER_HOST: "127.0.0.1",
+	})
+	require.NoError(t, err)
+	err = configura.WriteConfiguration(cfg, map[configura.Variable[int64]]int64{
+		SERVER_PORT: 0, // The OS chooses a free port, returned by Addr.
+	})
+	require.NoError(t, err)
+
+	server, err := StartAsync(context.Background(), configura.Merge(newDefaultCfg(), cfg), chi.NewRouter(), func(c configura.Config, r chi.Router, a huma.API) error {
+		r.Get("/hello", func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte("hello"))
+		})
+		return nil
+	})
+	require.NoError(t, err)
+	require.NotNil(t, server.Addr(), "StartAsync should return once the listener is bound")
+	assert.NotZero(t, server.Addr().(*net.TCPAddr).Port)
+
+	resp, err := http.Get("http://" + server.Addr().String() + "/hello")
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "hello", string(body))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	assert.NoError(t, server.Shutdown(ctx))
+	assert.NoError(t, server.Wait(), "Wait should return nil after a graceful shutdown")
+
+	_, err = net.Dial("tcp", server.Addr().String())
+	assert.Error(t, err, "The server should not accept connections after Shutdown")
+}
+
+func TestStart_Streaming(t *testing.T) {
+	cfg := configura.NewConfigImpl()
+	err := configura.WriteConfiguration(cfg, map[configura.Variable[string]]string{
+		SERVER_HOST: "127.0.0.1",
+	})
+	require.NoError(t, err)
+	err = configura.WriteConfiguration(cfg, map[configura.Variable[int64]]int64{
+		SERVER_PORT: 0,
+	})
+	require.NoError(t, err)
+
+	// The handler holds the response open after flushing the first event, so the client only gets it if the flush went
+	// through every response writer of the default middleware chain.
+	release := make(chan struct{})
+	server, err := StartAsync(context.Background(), configura.Merge(newDefaultCfg(), cfg), chi.NewRouter(), func(c configura.Config, r chi.Router, a huma.API) error {
+		r.Get("/events", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/event-stream")
+			_, _ = w.Write([]byte("data: 1\n"))
+			w.(http.Flusher).Flush()
+			select {
+			case <-release:
+			case <-r.Context().Done():
+			}
+		})
+		return nil
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = server.Shutdown(ctx)
+		_ = server.Wait()
+	})
+	defer close(release)
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Get("http://" + server.Addr().String() + "/events")
+	require.NoError(t, err, "The headers should be flushed before the handler returns")
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "no-store", resp.Header.Get("Cache-Control"))
+
+	line, err := bufio.NewReader(resp.Body).ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, "data: 1\n", line)
+}
+
+func TestStart_ResponseController(t *testing.T) {
+	cfg := configura.NewConfigImpl()
+	err := configura.WriteConfiguration(cfg, map[configura.Variable[string]]string{
+		SERVER_HOST:                  "127.0.0.1",
+		middleware.IDEMPOTENCY_PATHS: "/*",
+	})
+	require.NoError(t, err)
+	// Every middleware wrapping the response writer is enabled.
+	err = configura.WriteConfiguration(cfg, map[configura.Variable[bool]]bool{
+		middleware.SERVER_TIMING_ENABLED: true,
+		middleware.CACHE_ETAG_ENABLED:    true,
+	})
+	require.NoError(t, err)
+	err = configura.WriteConfiguration(cfg, map[configura.Variable[int64]]int64{
+		SERVER_PORT:                          0,
+		middleware.SERVER_MAX_RESPONSE_BYTES: 1 << 20,
+		middleware.SERVER_MIN_UPLOAD_RATE:    1,
+	})
+	require.NoError(t, err)
+
+	flushErrs := make(chan error, 2)
+	hijackErrs := make(chan error, 1)
+	release := make(chan struct{})
+	server, err := StartAsync(context.Background(), configura.Merge(newDefaultCfg(), cfg), chi.NewRouter(), func(c configura.Config, r chi.Router, a huma.API) error {
+		r.HandleFunc("/flush", func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte("data: 1\n"))
+			flushErrs <- http.NewResponseController(w).Flush()
+			select {
+			case <-release:
+			case <-r.Context().Done():
+			}
+		})
+		r.Get("/hijack", func(w http.ResponseWriter, r *http.Request) {
+			conn, _, err := http.NewResponseController(w).Hijack()
+			hijackErrs <- err
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+			_, _ = conn.Write([]byte("HTTP/1.1 200 OK\r\nContent-Length: 8\r\nConnection: close\r\n\r\nhijacked"))
+		})
+		return nil
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = server.Shutdown(ctx)
+		_ = server.Wait()
+	})
+	defer close(release)
+	baseURL := "http://" + server.Addr().String()
+	client := &http.Client{Timeout: 5 * time.Second}
+
+	// The idempotency writer only wraps unsafe requests with a key, the ETag writer only GET requests.
+	for _, method := range []string{http.MethodGet, http.MethodPost} {
+		req, err := http.NewRequest(method, baseURL+"/flush", nil)
+		require.NoError(t, err)
+		req.Header.Set("Idempotency-Key", "flush")
+		resp, err := client.Do(req)
+		require.NoError(t, err, "%s: the headers should be flushed before the handler returns", method)
+		assert.NoError(t, <-flushErrs, "%s: ResponseController.Flush should reach the server's writer", method)
+		assert.NotEmpty(t, resp.Header.Get("Server-Timing"), "%s: the Server-Timing header should be flushed", method)
+		assert.Equal(t, "no-store", resp.Header.Get("Cache-Control"), "%s: the Cache-Control header should be flushed", method)
+		line, err := bufio.NewReader(resp.Body).ReadString('\n')
+		resp.Body.Close()
+		require.NoError(t, err)
+		assert.Equal(t, "data: 1\n", line)
+	}
+
+	resp, err := client.Get(baseURL + "/hijack")
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.NoError(t, err)
+	assert.NoError(t, <-hijackErrs, "ResponseController.Hijack should reach the server's writer")
+	assert.Equal(t, "hijacked", string(body))
+}
+
+func TestStartAsync_ListenFails(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	cfg := configura.NewConfigImpl()
+	err = configura.WriteConfiguration(cfg, map[configura.Variable[string]]string{
+		SERVER_HOST: "127.0.0.1",
+	})
+	require.NoError(t, err)
+	err = configura.WriteConfiguration(cfg, map[configura.Variable[int64]]int64{
+		SERVER_PORT: int64(listener.Addr().(*net.TCPAddr).Port),
+	})
+	require.NoError(t, err)
+
+	server, err := StartAsync(context.Background(), configura.Merge(newDefaultCfg(), cfg), chi.NewRouter(), func(c configura.Config, r chi.Router, a huma.API) error {
+		return nil
+	})
+	assert.Error(t, err, "StartAsync should return the error of a server failing to start")
+	assert.Nil(t, server)
+}
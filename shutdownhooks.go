@@ -0,0 +1,55 @@
+package ponrunner
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// shutdownHook is a function registered with RegisterShutdownHook.
+type shutdownHook struct {
+	name string
+	run  func(ctx context.Context) error
+}
+
+var (
+	shutdownHooksMu sync.Mutex
+	shutdownHooks   []shutdownHook
+)
+
+// RegisterShutdownHook registers a function releasing a resource when the server shuts down, e.g. closing a database
+// pool. Hooks registered before or while routes are registered are run by Start once the server and the workers have
+// stopped (in the default SHUTDOWN_ORDER), in the reverse order of their registration, sharing
+// SERVER_SHUTDOWN_TIMEOUT. Errors returned by a hook are logged, they don't stop the other hooks.
+func RegisterShutdownHook(name string, hook func(ctx context.Context) error) {
+	shutdownHooksMu.Lock()
+	defer shutdownHooksMu.Unlock()
+	shutdownHooks = append(shutdownHooks, shutdownHook{name: name, run: hook})
+}
+
+// takeShutdownHooks returns the registered shutdown hooks, and clears the registry so they're only run once.
+func takeShutdownHooks() []shutdownHook {
+	shutdownHooksMu.Lock()
+	defer shutdownHooksMu.Unlock()
+	registered := shutdownHooks
+	shutdownHooks = nil
+	return registered
+}
+
+// runShutdownHooks runs the hooks in the reverse order of their registration, with a context canceled after the
+// timeout. The context keeps the values of ctx, but not its cancellation, as ctx is usually already canceled by then.
+func runShutdownHooks(ctx context.Context, hooks []shutdownHook, timeout time.Duration) {
+	if len(hooks) == 0 {
+		return
+	}
+	hookCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), timeout)
+	defer cancel()
+
+	for i := len(hooks) - 1; i >= 0; i-- {
+		if err := hooks[i].run(hookCtx); err != nil {
+			slog.ErrorContext(hookCtx, "Shutdown hook failed", slog.String("hook", hooks[i].name), slog.Any("error", err))
+		}
+	}
+	slog.InfoContext(hookCtx, "All shutdown hooks run.")
+}
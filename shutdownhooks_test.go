@@ -0,0 +1,45 @@
+package ponrunner
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunShutdownHooks_ReverseOrder(t *testing.T) {
+	var buf bytes.Buffer
+	originalSlogLogger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&buf, nil)))
+	t.Cleanup(func() { slog.SetDefault(originalSlogLogger) })
+
+	var order []string
+	hooks := []shutdownHook{
+		{name: "db", run: func(ctx context.Context) error {
+			order = append(order, "db")
+			return nil
+		}},
+		{name: "cache", run: func(ctx context.Context) error {
+			order = append(order, "cache")
+			return errors.New("flush failed")
+		}},
+		{name: "client", run: func(ctx context.Context) error {
+			order = append(order, "client")
+			_, hasDeadline := ctx.Deadline()
+			assert.True(t, hasDeadline, "Hooks should be bounded by the timeout")
+			return nil
+		}},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	runShutdownHooks(ctx, hooks, time.Second)
+
+	assert.Equal(t, []string{"client", "cache", "db"}, order, "Hooks should run in reverse order, despite errors")
+	assert.Contains(t, buf.String(), "Shutdown hook failed")
+	assert.Contains(t, buf.String(), "flush failed")
+}
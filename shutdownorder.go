@@ -0,0 +1,81 @@
+package ponrunner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+
+	"github.com/open-feature/go-sdk/openfeature"
+	"github.com/ponrove/configura"
+	"github.com/ponrove/ponrunner/utils"
+)
+
+const (
+	SHUTDOWN_ORDER configura.Variable[string] = "SHUTDOWN_ORDER" // Comma separated order of the shutdown phases, defaults to http,workers,hooks,telemetry,openfeature
+)
+
+// The phases of the shutdown, run in the order of SHUTDOWN_ORDER.
+const (
+	shutdownPhaseHTTP        = "http"        // Drains and shuts down the HTTP server.
+	shutdownPhaseWorkers     = "workers"     // Stops the workers registered with RegisterWorker.
+	shutdownPhaseHooks       = "hooks"       // Runs the shutdown hooks, then the WithOnShutdown functions.
+	shutdownPhaseTelemetry   = "telemetry"   // Flushes and shuts down the OpenTelemetry providers.
+	shutdownPhaseOpenFeature = "openfeature" // Shuts down the OpenFeature provider.
+)
+
+// defaultShutdownOrder is the order of the shutdown phases if SHUTDOWN_ORDER is not set: the in-flight requests are
+// drained first, so the workers and hooks can still serve them, and the telemetry of all of them is flushed last but
+// for the feature flags, which are evaluated until then.
+var defaultShutdownOrder = []string{
+	shutdownPhaseHTTP,
+	shutdownPhaseWorkers,
+	shutdownPhaseHooks,
+	shutdownPhaseTelemetry,
+	shutdownPhaseOpenFeature,
+}
+
+// ErrInvalidShutdownOrder is returned by Start when SHUTDOWN_ORDER has an unknown or repeated phase.
+var ErrInvalidShutdownOrder = errors.New("invalid shutdown order")
+
+// shutdownOrder returns the order of the shutdown phases from SHUTDOWN_ORDER. Phases it leaves out run after the
+// listed ones, in their default order, so none of them is skipped.
+func shutdownOrder(cfg configura.Config) ([]string, error) {
+	var order []string
+	for _, phase := range utils.SplitCommaSeparated(cfg.String(SHUTDOWN_ORDER)) {
+		phase = strings.ToLower(phase)
+		if !slices.Contains(defaultShutdownOrder, phase) {
+			return nil, fmt.Errorf("%w: unknown phase %q, expected one of %s", ErrInvalidShutdownOrder, phase, strings.Join(defaultShutdownOrder, ", "))
+		}
+		if slices.Contains(order, phase) {
+			return nil, fmt.Errorf("%w: phase %q is repeated", ErrInvalidShutdownOrder, phase)
+		}
+		order = append(order, phase)
+	}
+	for _, phase := range defaultShutdownOrder {
+		if !slices.Contains(order, phase) {
+			order = append(order, phase)
+		}
+	}
+	return order, nil
+}
+
+// runShutdownPhases runs the phases in order, each phase running once the previous one has returned.
+func runShutdownPhases(ctx context.Context, order []string, phases map[string]func()) {
+	for _, phase := range order {
+		slog.InfoContext(ctx, "Running shutdown phase", slog.String("phase", phase))
+		phases[phase]()
+	}
+}
+
+// shutdownOpenFeature replaces the OpenFeature provider with the no-op provider, so flags evaluate to their defaults
+// from then on, and the SDK shuts the replaced provider down, e.g. stopping the polling of go-feature-flag. Replacing it
+// rather than calling openfeature.Shutdown leaves no stopped provider behind to be shut down again when the next server
+// sets its own.
+func shutdownOpenFeature() {
+	if err := openfeature.SetProviderAndWait(openfeature.NoopProvider{}); err != nil {
+		slog.Error("Failed to shut down the OpenFeature provider", slog.Any("error", err))
+	}
+}
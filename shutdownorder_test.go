@@ -0,0 +1,176 @@
+package ponrunner
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/go-chi/chi/v5"
+	"github.com/ponrove/configura"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShutdownOrder(t *testing.T) {
+	tests := []struct {
+		name      string
+		order     string
+		expected  []string
+		expectErr bool
+	}{
+		{name: "Default", expected: []string{"http", "workers", "hooks", "telemetry", "openfeature"}},
+		{name: "Full order", order: "workers,http,hooks,openfeature,telemetry", expected: []string{"workers", "http", "hooks", "openfeature", "telemetry"}},
+		{name: "Missing phases run last", order: " Telemetry , workers", expected: []string{"telemetry", "workers", "http", "hooks", "openfeature"}},
+		{name: "Unknown phase", order: "http,database", expectErr: true},
+		{name: "Repeated phase", order: "http,workers,http", expectErr: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := configura.NewConfigImpl()
+			err := configura.WriteConfiguration(cfg, map[configura.Variable[string]]string{
+				SHUTDOWN_ORDER: tc.order,
+			})
+			require.NoError(t, err)
+
+			order, err := shutdownOrder(cfg)
+			if tc.expectErr {
+				assert.ErrorIs(t, err, ErrInvalidShutdownOrder)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, order)
+		})
+	}
+}
+
+func TestStart_ShutdownOrder(t *testing.T) {
+	// Not parallel, the process's stdout is replaced to capture the logs.
+	stdout, err := os.CreateTemp(t.TempDir(), "stdout")
+	require.NoError(t, err)
+	originalStdout := os.Stdout
+	os.Stdout = stdout
+	originalSlogLogger := slog.Default()
+	t.Cleanup(func() {
+		os.Stdout = originalStdout
+		slog.SetDefault(originalSlogLogger)
+	})
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err, "Failed to create a listener")
+	url := "http://" + listener.Addr().String() + "/livez"
+
+	orderCfg := configura.NewConfigImpl()
+	err = configura.WriteConfiguration(orderCfg, map[configura.Variable[string]]string{
+		SHUTDOWN_ORDER: "hooks,telemetry,http",
+	})
+	require.NoError(t, err)
+	cfg := configura.Merge(newDefaultCfg(), orderCfg)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	startErrChan := make(chan error, 1)
+	go func() {
+		startErrChan <- StartWithListener(ctx, cfg, chi.NewRouter(), func(c configura.Config, router chi.Router, a huma.API) error {
+			return nil
+		}, listener, WithOnShutdown(func(ctx context.Context) error {
+			slog.InfoContext(ctx, "OnShutdown called")
+			return nil
+		}))
+	}()
+
+	require.Eventually(t, func() bool {
+		resp, err := http.Get(url)
+		if err != nil {
+			return false
+		}
+		resp.Body.Close()
+		return resp.StatusCode == http.StatusOK
+	}, 2*time.Second, 50*time.Millisecond, "server never started on the listener")
+
+	cancel()
+	select {
+	case err := <-startErrChan:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Start did not return after the context was canceled")
+	}
+
+	_, err = stdout.Seek(0, 0)
+	require.NoError(t, err)
+	// The phases as logged, with the OnShutdown function in the hooks phase.
+	var phases []string
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		var logged map[string]any
+		if json.Unmarshal(scanner.Bytes(), &logged) != nil {
+			continue
+		}
+		switch logged["msg"] {
+		case "Running shutdown phase":
+			phases = append(phases, logged["phase"].(string))
+		case "OnShutdown called":
+			phases = append(phases, "OnShutdown")
+		}
+	}
+	assert.Equal(t, []string{"hooks", "OnShutdown", "telemetry", "http", "workers", "openfeature"}, phases)
+}
+
+func TestStart_ShutdownDeadline(t *testing.T) {
+	// Not parallel, workers and shutdown hooks are registered globally.
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err, "Failed to create a listener")
+	url := "http://" + listener.Addr().String() + "/livez"
+
+	timeoutCfg := configura.NewConfigImpl()
+	err = configura.WriteConfiguration(timeoutCfg, map[configura.Variable[int64]]int64{
+		SERVER_SHUTDOWN_TIMEOUT: 1,
+	})
+	require.NoError(t, err)
+	cfg := configura.Merge(newDefaultCfg(), timeoutCfg)
+
+	// A worker ignoring its cancellation, and a hook running until its context expires: each would take the whole
+	// timeout of its own.
+	release := make(chan struct{})
+	t.Cleanup(func() { close(release) })
+	ctx, cancel := context.WithCancel(context.Background())
+	startErrChan := make(chan error, 1)
+	go func() {
+		startErrChan <- StartWithListener(ctx, cfg, chi.NewRouter(), func(c configura.Config, router chi.Router, a huma.API) error {
+			RegisterWorker("stuck", func(ctx context.Context) error {
+				<-release
+				return nil
+			})
+			RegisterShutdownHook("slow", func(ctx context.Context) error {
+				<-ctx.Done()
+				return ctx.Err()
+			})
+			return nil
+		}, listener)
+	}()
+
+	require.Eventually(t, func() bool {
+		resp, err := http.Get(url)
+		if err != nil {
+			return false
+		}
+		resp.Body.Close()
+		return resp.StatusCode == http.StatusOK
+	}, 2*time.Second, 50*time.Millisecond, "server never started on the listener")
+
+	shutdownStarted := time.Now()
+	cancel()
+	select {
+	case err := <-startErrChan:
+		assert.NoError(t, err)
+		assert.Less(t, time.Since(shutdownStarted), 1800*time.Millisecond, "The phases should share SERVER_SHUTDOWN_TIMEOUT")
+	case <-time.After(5 * time.Second):
+		t.Fatal("Start did not return after the context was canceled")
+	}
+}
@@ -0,0 +1,108 @@
+package ponrunner
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"runtime"
+	"sync/atomic"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/ponrove/configura"
+)
+
+const (
+	SERVER_SIGNAL_DIAGNOSTICS configura.Variable[bool] = "SERVER_SIGNAL_DIAGNOSTICS" // Log diagnostics on SIGUSR1 while serving
+)
+
+// diagnosticsConfigKeys are the configuration keys summarized in the signal diagnostics. Keys that may hold secrets,
+// e.g. the OTLP headers, are left out.
+var diagnosticsConfigKeys = struct {
+	strings []configura.Variable[string]
+	ints    []configura.Variable[int64]
+	bools   []configura.Variable[bool]
+}{
+	strings: []configura.Variable[string]{SERVER_ENV, SERVER_HOST, SERVER_LOG_LEVEL, SERVER_LOG_FORMAT, OTEL_SERVICE_NAME},
+	ints: []configura.Variable[int64]{
+		SERVER_PORT, SERVER_REQUEST_TIMEOUT, SERVER_READ_TIMEOUT, SERVER_WRITE_TIMEOUT, SERVER_IDLE_TIMEOUT,
+		SERVER_READ_HEADER_TIMEOUT, SERVER_SHUTDOWN_TIMEOUT, SERVER_DRAIN_PERIOD, SERVER_MAX_LIFETIME,
+	},
+	bools: []configura.Variable[bool]{OTEL_ENABLED, OTEL_SDK_DISABLED, OTEL_TRACES_ENABLED, OTEL_METRICS_ENABLED, OTEL_LOGS_ENABLED},
+}
+
+// inFlightHandler counts the requests being served in n.
+func inFlightHandler(n *atomic.Int64, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n.Add(1)
+		defer n.Add(-1)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// watchDiagnosticsSignal logs the goroutine count, memory stats, in-flight requests, a summary of the configuration
+// and the registered routes each time the process receives SIGUSR1, if SERVER_SIGNAL_DIAGNOSTICS is enabled, for live
+// debugging without a restart. Serving is unaffected. The returned function stops watching the signal.
+func watchDiagnosticsSignal(ctx context.Context, cfg configura.Config, srv *http.Server, routes chi.Routes) (stop func()) {
+	if !cfg.Bool(SERVER_SIGNAL_DIAGNOSTICS) {
+		return func() {}
+	}
+	if len(diagnosticsSignals) == 0 {
+		slog.WarnContext(ctx, "Signal diagnostics are not supported on this platform", slog.String("os", runtime.GOOS))
+		return func() {}
+	}
+
+	var inFlight atomic.Int64
+	srv.Handler = inFlightHandler(&inFlight, srv.Handler)
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, diagnosticsSignals...)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-signals:
+				logSignalDiagnostics(ctx, cfg, inFlight.Load(), routes)
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		signal.Stop(signals)
+		close(done)
+	}
+}
+
+// logSignalDiagnostics logs the diagnostics of the running server as a single entry.
+func logSignalDiagnostics(ctx context.Context, cfg configura.Config, inFlight int64, routes chi.Routes) {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	var config []any
+	for _, key := range diagnosticsConfigKeys.strings {
+		config = append(config, slog.String(string(key), cfg.String(key)))
+	}
+	for _, key := range diagnosticsConfigKeys.ints {
+		config = append(config, slog.Int64(string(key), cfg.Int64(key)))
+	}
+	for _, key := range diagnosticsConfigKeys.bools {
+		config = append(config, slog.Bool(string(key), cfg.Bool(key)))
+	}
+
+	var routeList []string
+	_ = chi.Walk(routes, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		routeList = append(routeList, method+" "+route)
+		return nil
+	})
+
+	slog.LogAttrs(ctx, slog.LevelInfo, "Signal diagnostics",
+		slog.Int("goroutines", runtime.NumGoroutine()),
+		slog.Uint64("heap_alloc_bytes", mem.HeapAlloc),
+		slog.Uint64("sys_bytes", mem.Sys),
+		slog.Int64("in_flight_requests", inFlight),
+		slog.Group("config", config...),
+		slog.Any("routes", routeList),
+	)
+}
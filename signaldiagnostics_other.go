@@ -0,0 +1,8 @@
+//go:build !unix
+
+package ponrunner
+
+import "os"
+
+// diagnosticsSignals are the signals triggering the signal diagnostics. SIGUSR1 doesn't exist on this platform.
+var diagnosticsSignals []os.Signal
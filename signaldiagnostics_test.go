@@ -0,0 +1,146 @@
+//go:build unix
+
+package ponrunner
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/go-chi/chi/v5"
+	"github.com/ponrove/configura"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// signalDiagnosticsEntries returns the signal diagnostics entries of the JSON logs in the file.
+func signalDiagnosticsEntries(t *testing.T, name string) []map[string]any {
+	t.Helper()
+	f, err := os.Open(name)
+	require.NoError(t, err)
+	defer f.Close()
+
+	var entries []map[string]any
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var entry map[string]any
+		if json.Unmarshal(scanner.Bytes(), &entry) == nil && entry["msg"] == "Signal diagnostics" {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+func TestStart_SignalDiagnostics(t *testing.T) {
+	// Not parallel, the process's stdout is replaced to capture the logs, and the signal is sent to the process.
+	stdout, err := os.CreateTemp(t.TempDir(), "stdout")
+	require.NoError(t, err)
+	originalStdout := os.Stdout
+	os.Stdout = stdout
+	originalSlogLogger := slog.Default()
+	t.Cleanup(func() {
+		os.Stdout = originalStdout
+		slog.SetDefault(originalSlogLogger)
+	})
+
+	freePort, err := getFreePort()
+	require.NoError(t, err, "Failed to get free port")
+
+	emptyCfg := configura.NewConfigImpl()
+	err = configura.WriteConfiguration(emptyCfg, map[configura.Variable[int64]]int64{
+		SERVER_PORT: int64(freePort),
+	})
+	require.NoError(t, err, "Failed to write free port to configuration")
+	err = configura.WriteConfiguration(emptyCfg, map[configura.Variable[bool]]bool{
+		SERVER_SIGNAL_DIAGNOSTICS: true,
+	})
+	require.NoError(t, err, "Failed to write configuration")
+	finalCfg := configura.Merge(newDefaultCfg(), emptyCfg)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	startErrChan := make(chan error, 1)
+	go func() {
+		startErrChan <- Start(ctx, finalCfg, chi.NewRouter(), func(cfg configura.Config, r chi.Router, api huma.API) error {
+			r.Get("/slow", func(w http.ResponseWriter, r *http.Request) {
+				close(started)
+				<-release
+				_, _ = w.Write([]byte("done"))
+			})
+			return nil
+		})
+	}()
+
+	// A client of its own, whose idle connections are closed before the shutdown, as it waits up to 5 seconds for the
+	// connections that never sent a request, like the spare ones http.DefaultTransport may dial.
+	client := &http.Client{Transport: &http.Transport{}}
+	baseURL := fmt.Sprintf("http://localhost:%d", freePort)
+	require.Eventually(t, func() bool {
+		resp, err := client.Get(baseURL + "/livez")
+		if err != nil {
+			return false
+		}
+		resp.Body.Close()
+		return true
+	}, 2*time.Second, 50*time.Millisecond, "server never started")
+
+	slowDone := make(chan int, 1)
+	go func() {
+		resp, err := client.Get(baseURL + "/slow")
+		if err != nil {
+			slowDone <- 0
+			return
+		}
+		resp.Body.Close()
+		slowDone <- resp.StatusCode
+	}()
+	<-started
+
+	require.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGUSR1))
+	var entries []map[string]any
+	require.Eventually(t, func() bool {
+		entries = signalDiagnosticsEntries(t, stdout.Name())
+		return len(entries) == 1
+	}, 2*time.Second, 20*time.Millisecond, "diagnostics were never logged")
+
+	entry := entries[0]
+	assert.Equal(t, 1.0, entry["in_flight_requests"], "The slow request should be in flight")
+	assert.Greater(t, entry["goroutines"], 0.0)
+	assert.Contains(t, entry["routes"], "GET /slow")
+	assert.Contains(t, entry["routes"], "GET /livez")
+	config, ok := entry["config"].(map[string]any)
+	require.True(t, ok, "The config summary should be a group")
+	assert.Equal(t, float64(freePort), config["SERVER_PORT"])
+	assert.NotContains(t, config, "OTEL_EXPORTER_OTLP_HEADERS", "Keys that may hold secrets should be left out")
+
+	close(release)
+	select {
+	case status := <-slowDone:
+		assert.Equal(t, http.StatusOK, status, "The server should keep serving")
+	case <-time.After(2 * time.Second):
+		t.Fatal("The in-flight request never completed")
+	}
+	resp, err := client.Get(baseURL + "/livez")
+	require.NoError(t, err, "The server should keep serving")
+	resp.Body.Close()
+
+	client.CloseIdleConnections()
+	cancel()
+	select {
+	case err := <-startErrChan:
+		assert.NoError(t, err, "Start should exit gracefully without error")
+	case <-time.After(3 * time.Second):
+		t.Fatal("Start did not exit after context cancellation")
+	}
+}
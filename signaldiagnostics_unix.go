@@ -0,0 +1,11 @@
+//go:build unix
+
+package ponrunner
+
+import (
+	"os"
+	"syscall"
+)
+
+// diagnosticsSignals are the signals triggering the signal diagnostics.
+var diagnosticsSignals = []os.Signal{syscall.SIGUSR1}
@@ -0,0 +1,24 @@
+package ponrunner
+
+import (
+	"context"
+)
+
+// spanInstrumentationName is the instrumentation scope of the spans started with StartSpan.
+const spanInstrumentationName = "github.com/ponrove/ponrunner"
+
+// StartSpan starts a span named name as a child of the span in ctx, e.g. around a database query of a bundle, with
+// the tracer provider ponrunner configured. It returns the context of the span, and a function ending it. When tracing
+// is disabled or the server isn't running, ctx is returned as is with a no-op function, so the call costs next to
+// nothing:
+//
+//	ctx, end := ponrunner.StartSpan(ctx, "db.query")
+//	defer end()
+func StartSpan(ctx context.Context, name string) (context.Context, func()) {
+	tp := TracerProvider()
+	if tp == nil {
+		return ctx, func() {}
+	}
+	ctx, span := tp.Tracer(spanInstrumentationName).Start(ctx, name)
+	return ctx, func() { span.End() }
+}
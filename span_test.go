@@ -0,0 +1,42 @@
+package ponrunner
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestStartSpan(t *testing.T) {
+	// Not parallel, the providers are registered globally.
+	t.Run("Tracing enabled", func(t *testing.T) {
+		recorder := tracetest.NewSpanRecorder()
+		tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+		currentProviders.Store(&telemetryProviders{tracer: tp})
+		t.Cleanup(func() { currentProviders.Store(nil) })
+
+		parentCtx, parent := tp.Tracer("test").Start(context.Background(), "request")
+		ctx, end := StartSpan(parentCtx, "db.query")
+		assert.True(t, trace.SpanFromContext(ctx).IsRecording(), "The span should be in the returned context")
+		end()
+		parent.End()
+
+		spans := recorder.Ended()
+		require.Len(t, spans, 2)
+		assert.Equal(t, "db.query", spans[0].Name())
+		assert.Equal(t, parent.SpanContext().SpanID(), spans[0].Parent().SpanID(), "The span should be a child of the span in ctx")
+		assert.Equal(t, spanInstrumentationName, spans[0].InstrumentationScope().Name)
+	})
+
+	t.Run("Tracing disabled", func(t *testing.T) {
+		ctx := context.Background()
+		spanCtx, end := StartSpan(ctx, "db.query")
+		assert.Equal(t, ctx, spanCtx, "The context should be returned as is")
+		assert.False(t, trace.SpanFromContext(spanCtx).SpanContext().IsValid())
+		assert.NotPanics(t, end)
+	})
+}
@@ -0,0 +1,99 @@
+package ponrunner
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/ponrove/configura"
+)
+
+const (
+	STATIC_PATH_PREFIX   configura.Variable[string] = "STATIC_PATH_PREFIX"   // Path prefix to serve static files under, defaults to "/"
+	STATIC_CACHE_CONTROL configura.Variable[string] = "STATIC_CACHE_CONTROL" // Cache-Control for static assets, defaults to "public, max-age=3600"
+	STATIC_SPA_FALLBACK  configura.Variable[bool]   = "STATIC_SPA_FALLBACK"  // Serve index.html for unmatched paths
+)
+
+// staticIndexFile is the file served for directory requests, and as the SPA fallback.
+const staticIndexFile = "index.html"
+
+// MountStatic serves the files in fsys (e.g. an embed.FS, narrowed with fs.Sub) on the router under
+// STATIC_PATH_PREFIX. Content types are derived from the file extensions. Assets are served with the Cache-Control of
+// STATIC_CACHE_CONTROL, while index.html is always revalidated, so a new deployment is picked up by clients. When
+// STATIC_SPA_FALLBACK is set, index.html is served for paths that don't match a file, so client side routing works.
+// Routes registered on the router for specific paths, like the API operations, take precedence over the static files.
+func MountStatic(cfg configura.Config, router chi.Router, fsys fs.FS) {
+	prefix := "/" + strings.Trim(configura.Fallback(cfg.String(STATIC_PATH_PREFIX), "/"), "/")
+	handler := http.StripPrefix(strings.TrimSuffix(prefix, "/"), staticHandler(cfg, fsys))
+
+	if prefix != "/" {
+		router.Handle(prefix, http.RedirectHandler(prefix+"/", http.StatusMovedPermanently))
+		prefix += "/"
+	}
+	router.Handle(prefix+"*", handler)
+}
+
+// staticHandler serves the files in fsys, using the request path relative to the mount prefix.
+func staticHandler(cfg configura.Config, fsys fs.FS) http.Handler {
+	cacheControl := configura.Fallback(cfg.String(STATIC_CACHE_CONTROL), "public, max-age=3600")
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		name := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+		if name == "" {
+			name = staticIndexFile
+		}
+
+		err := serveStaticFile(w, r, fsys, name, cacheControl)
+		if errors.Is(err, fs.ErrNotExist) && cfg.Bool(STATIC_SPA_FALLBACK) {
+			err = serveStaticFile(w, r, fsys, staticIndexFile, cacheControl)
+		}
+		if errors.Is(err, fs.ErrNotExist) {
+			http.NotFound(w, r)
+			return
+		}
+		if err != nil {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		}
+	})
+}
+
+// serveStaticFile writes a single file from fsys to the response. Directories are served by their index.html. Nothing
+// is written to the response if an error is returned.
+func serveStaticFile(w http.ResponseWriter, r *http.Request, fsys fs.FS, name string, cacheControl string) error {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return serveStaticFile(w, r, fsys, path.Join(name, staticIndexFile), cacheControl)
+	}
+
+	content, ok := f.(io.ReadSeeker)
+	if !ok {
+		return errors.New("static file does not support seeking: " + name)
+	}
+
+	if path.Base(name) == staticIndexFile {
+		w.Header().Set("Cache-Control", "no-cache")
+	} else {
+		w.Header().Set("Cache-Control", cacheControl)
+	}
+	http.ServeContent(w, r, name, info.ModTime(), content)
+	return nil
+}
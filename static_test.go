@@ -0,0 +1,124 @@
+package ponrunner
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/ponrove/configura"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+//go:embed testdata/static
+var testStaticFiles embed.FS
+
+func newStaticRouter(t *testing.T, prefix string, spaFallback bool) chi.Router {
+	t.Helper()
+	cfg := configura.NewConfigImpl()
+	err := configura.WriteConfiguration(cfg, map[configura.Variable[string]]string{
+		STATIC_PATH_PREFIX: prefix,
+	})
+	require.NoError(t, err)
+	err = configura.WriteConfiguration(cfg, map[configura.Variable[bool]]bool{
+		STATIC_SPA_FALLBACK: spaFallback,
+	})
+	require.NoError(t, err)
+
+	fsys, err := fs.Sub(testStaticFiles, "testdata/static")
+	require.NoError(t, err)
+
+	r := chi.NewRouter()
+	r.Get("/api/ping", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("pong"))
+	})
+	MountStatic(cfg, r, fsys)
+	return r
+}
+
+func TestMountStatic(t *testing.T) {
+	tests := []struct {
+		name                string
+		prefix              string
+		spaFallback         bool
+		path                string
+		expectedStatus      int
+		expectedContentType string
+		expectedCache       string
+		expectedBody        string
+	}{
+		{
+			name:                "Index at root",
+			path:                "/",
+			expectedStatus:      http.StatusOK,
+			expectedContentType: "text/html; charset=utf-8",
+			expectedCache:       "no-cache",
+			expectedBody:        `<div id="app">`,
+		},
+		{
+			name:                "JavaScript asset",
+			path:                "/assets/app.js",
+			expectedStatus:      http.StatusOK,
+			expectedContentType: "text/javascript; charset=utf-8",
+			expectedCache:       "public, max-age=3600",
+			expectedBody:        "textContent",
+		},
+		{
+			name:                "CSS asset under prefix",
+			prefix:              "/ui",
+			path:                "/ui/assets/app.css",
+			expectedStatus:      http.StatusOK,
+			expectedContentType: "text/css; charset=utf-8",
+			expectedCache:       "public, max-age=3600",
+			expectedBody:        "margin",
+		},
+		{
+			name:           "Unknown path without SPA fallback",
+			path:           "/settings/profile",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:                "Unknown path with SPA fallback",
+			spaFallback:         true,
+			path:                "/settings/profile",
+			expectedStatus:      http.StatusOK,
+			expectedContentType: "text/html; charset=utf-8",
+			expectedCache:       "no-cache",
+			expectedBody:        `<div id="app">`,
+		},
+		{
+			name:                "API routes take precedence",
+			spaFallback:         true,
+			path:                "/api/ping",
+			expectedStatus:      http.StatusOK,
+			expectedContentType: "text/plain; charset=utf-8",
+			expectedBody:        "pong",
+		},
+		{
+			name:           "Path traversal is contained",
+			path:           "/../static_test.go",
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r := newStaticRouter(t, tc.prefix, tc.spaFallback)
+
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, tc.path, nil))
+
+			assert.Equal(t, tc.expectedStatus, rr.Code)
+			if tc.expectedStatus != http.StatusOK {
+				return
+			}
+			assert.Equal(t, tc.expectedContentType, rr.Header().Get("Content-Type"))
+			assert.Equal(t, tc.expectedCache, rr.Header().Get("Cache-Control"))
+			assert.True(t, strings.Contains(rr.Body.String(), tc.expectedBody), "unexpected body %q", rr.Body.String())
+		})
+	}
+}
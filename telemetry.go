@@ -2,15 +2,23 @@ package ponrunner
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/ponrove/configura"
+	slogctx "github.com/veqryn/slog-context"
 	"go.opentelemetry.io/contrib/bridges/otelslog"
 	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploggrpc"
 	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploghttp"
 	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
@@ -27,32 +35,145 @@ import (
 	"go.opentelemetry.io/otel/sdk/resource"
 	"go.opentelemetry.io/otel/sdk/trace"
 	semconv "go.opentelemetry.io/otel/semconv/v1.24.0" // Use a specific version
+	"google.golang.org/grpc/credentials"
 )
 
 const (
-	OTEL_ENABLED                        configura.Variable[bool]   = "OTEL_ENABLED"
-	OTEL_LOGS_ENABLED                   configura.Variable[bool]   = "OTEL_LOGS_ENABLED"
-	OTEL_METRICS_ENABLED                configura.Variable[bool]   = "OTEL_METRICS_ENABLED"
-	OTEL_TRACES_ENABLED                 configura.Variable[bool]   = "OTEL_TRACES_ENABLED"
-	OTEL_SERVICE_NAME                   configura.Variable[string] = "OTEL_SERVICE_NAME"
-	OTEL_EXPORTER_OTLP_ENDPOINT         configura.Variable[string] = "OTEL_EXPORTER_OTLP_ENDPOINT"
-	OTEL_EXPORTER_OTLP_TRACES_ENDPOINT  configura.Variable[string] = "OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"
-	OTEL_EXPORTER_OTLP_METRICS_ENDPOINT configura.Variable[string] = "OTEL_EXPORTER_OTLP_METRICS_ENDPOINT"
-	OTEL_EXPORTER_OTLP_LOGS_ENDPOINT    configura.Variable[string] = "OTEL_EXPORTER_OTLP_LOGS_ENDPOINT"
-	OTEL_EXPORTER_OTLP_HEADERS          configura.Variable[string] = "OTEL_EXPORTER_OTLP_HEADERS"
-	OTEL_EXPORTER_OTLP_TRACES_HEADERS   configura.Variable[string] = "OTEL_EXPORTER_OTLP_TRACES_HEADERS"
-	OTEL_EXPORTER_OTLP_METRICS_HEADERS  configura.Variable[string] = "OTEL_EXPORTER_OTLP_METRICS_HEADERS"
-	OTEL_EXPORTER_OTLP_LOGS_HEADERS     configura.Variable[string] = "OTEL_EXPORTER_OTLP_LOGS_HEADERS"
-	OTEL_EXPORTER_OTLP_TIMEOUT          configura.Variable[int64]  = "OTEL_EXPORTER_OTLP_TIMEOUT"
-	OTEL_EXPORTER_OTLP_TRACES_TIMEOUT   configura.Variable[int64]  = "OTEL_EXPORTER_OTLP_TRACES_TIMEOUT"
-	OTEL_EXPORTER_OTLP_METRICS_TIMEOUT  configura.Variable[int64]  = "OTEL_EXPORTER_OTLP_METRICS_TIMEOUT"
-	OTEL_EXPORTER_OTLP_LOGS_TIMEOUT     configura.Variable[int64]  = "OTEL_EXPORTER_OTLP_LOGS_TIMEOUT"
-	OTEL_EXPORTER_OTLP_PROTOCOL         configura.Variable[string] = "OTEL_EXPORTER_OTLP_PROTOCOL"
-	OTEL_EXPORTER_OTLP_TRACES_PROTOCOL  configura.Variable[string] = "OTEL_EXPORTER_OTLP_TRACES_PROTOCOL"
-	OTEL_EXPORTER_OTLP_METRICS_PROTOCOL configura.Variable[string] = "OTEL_EXPORTER_OTLP_METRICS_PROTOCOL"
-	OTEL_EXPORTER_OTLP_LOGS_PROTOCOL    configura.Variable[string] = "OTEL_EXPORTER_OTLP_LOGS_PROTOCOL"
+	OTEL_ENABLED                           configura.Variable[bool]   = "OTEL_ENABLED"
+	OTEL_SDK_DISABLED                      configura.Variable[bool]   = "OTEL_SDK_DISABLED" // Disable OpenTelemetry regardless of the other keys, as in the specification
+	OTEL_LOGS_ENABLED                      configura.Variable[bool]   = "OTEL_LOGS_ENABLED"
+	OTEL_METRICS_ENABLED                   configura.Variable[bool]   = "OTEL_METRICS_ENABLED"
+	OTEL_TRACES_ENABLED                    configura.Variable[bool]   = "OTEL_TRACES_ENABLED"
+	OTEL_SERVICE_NAME                      configura.Variable[string] = "OTEL_SERVICE_NAME"
+	OTEL_RESOURCE_ATTRIBUTES               configura.Variable[string] = "OTEL_RESOURCE_ATTRIBUTES" // Comma separated key=value attributes of the resource, e.g. deployment.environment=prod
+	OTEL_EXPORTER_OTLP_ENDPOINT            configura.Variable[string] = "OTEL_EXPORTER_OTLP_ENDPOINT"
+	OTEL_EXPORTER_OTLP_TRACES_ENDPOINT     configura.Variable[string] = "OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"
+	OTEL_EXPORTER_OTLP_METRICS_ENDPOINT    configura.Variable[string] = "OTEL_EXPORTER_OTLP_METRICS_ENDPOINT"
+	OTEL_EXPORTER_OTLP_LOGS_ENDPOINT       configura.Variable[string] = "OTEL_EXPORTER_OTLP_LOGS_ENDPOINT"
+	OTEL_EXPORTER_OTLP_HEADERS             configura.Variable[string] = "OTEL_EXPORTER_OTLP_HEADERS"
+	OTEL_EXPORTER_OTLP_TRACES_HEADERS      configura.Variable[string] = "OTEL_EXPORTER_OTLP_TRACES_HEADERS"
+	OTEL_EXPORTER_OTLP_METRICS_HEADERS     configura.Variable[string] = "OTEL_EXPORTER_OTLP_METRICS_HEADERS"
+	OTEL_EXPORTER_OTLP_LOGS_HEADERS        configura.Variable[string] = "OTEL_EXPORTER_OTLP_LOGS_HEADERS"
+	OTEL_EXPORTER_OTLP_TIMEOUT             configura.Variable[int64]  = "OTEL_EXPORTER_OTLP_TIMEOUT"
+	OTEL_EXPORTER_OTLP_TRACES_TIMEOUT      configura.Variable[int64]  = "OTEL_EXPORTER_OTLP_TRACES_TIMEOUT"
+	OTEL_EXPORTER_OTLP_METRICS_TIMEOUT     configura.Variable[int64]  = "OTEL_EXPORTER_OTLP_METRICS_TIMEOUT"
+	OTEL_EXPORTER_OTLP_LOGS_TIMEOUT        configura.Variable[int64]  = "OTEL_EXPORTER_OTLP_LOGS_TIMEOUT"
+	OTEL_EXPORTER_OTLP_PROTOCOL            configura.Variable[string] = "OTEL_EXPORTER_OTLP_PROTOCOL"
+	OTEL_EXPORTER_OTLP_TRACES_PROTOCOL     configura.Variable[string] = "OTEL_EXPORTER_OTLP_TRACES_PROTOCOL"
+	OTEL_EXPORTER_OTLP_METRICS_PROTOCOL    configura.Variable[string] = "OTEL_EXPORTER_OTLP_METRICS_PROTOCOL"
+	OTEL_EXPORTER_OTLP_LOGS_PROTOCOL       configura.Variable[string] = "OTEL_EXPORTER_OTLP_LOGS_PROTOCOL"
+	OTEL_EXPORTER_OTLP_COMPRESSION         configura.Variable[string] = "OTEL_EXPORTER_OTLP_COMPRESSION"
+	OTEL_EXPORTER_OTLP_TRACES_COMPRESSION  configura.Variable[string] = "OTEL_EXPORTER_OTLP_TRACES_COMPRESSION"
+	OTEL_EXPORTER_OTLP_METRICS_COMPRESSION configura.Variable[string] = "OTEL_EXPORTER_OTLP_METRICS_COMPRESSION"
+	OTEL_EXPORTER_OTLP_LOGS_COMPRESSION    configura.Variable[string] = "OTEL_EXPORTER_OTLP_LOGS_COMPRESSION"
+	OTEL_LOGS_STDOUT                       configura.Variable[bool]   = "OTEL_LOGS_STDOUT"                  // Keep writing logs to stdout alongside the OTLP exporter, off by default
+	OTEL_LOGS_MIN_LEVEL                    configura.Variable[string] = "OTEL_LOGS_MIN_LEVEL"               // Lowest level of the logs exported over OTLP, defaults to all levels
+	OTEL_ATTRIBUTE_VALUE_LENGTH_LIMIT      configura.Variable[int64]  = "OTEL_ATTRIBUTE_VALUE_LENGTH_LIMIT" // Longest span attribute value, longer ones are truncated, unlimited by default
+	OTEL_ATTRIBUTE_COUNT_LIMIT             configura.Variable[int64]  = "OTEL_ATTRIBUTE_COUNT_LIMIT"        // Most attributes of a span, further ones are dropped, defaults to 128
+	OTEL_METRIC_EXPORT_INTERVAL            configura.Variable[int64]  = "OTEL_METRIC_EXPORT_INTERVAL"       // Interval in seconds between two exports of the metrics, defaults to 60
 )
 
+// ErrInvalidOTLPProtocol is returned by setupOTelSDK when a configured OTLP protocol is not supported.
+var ErrInvalidOTLPProtocol = errors.New("invalid OTLP protocol")
+
+// supportedOTLPProtocols lists the OTLP protocols the exporters can be configured with.
+var supportedOTLPProtocols = []string{"grpc", "http", "http/protobuf"}
+
+// validateOTLPProtocols checks the effective protocol of every enabled signal that will get an OTLP exporter (i.e. has
+// an endpoint configured), so a typo is reported before any exporter is created. All invalid values are reported in a
+// single error.
+func validateOTLPProtocols(cfg configura.Config) error {
+	signals := []struct {
+		name        string
+		enabled     configura.Variable[bool]
+		protocolKey configura.Variable[string]
+		endpointKey configura.Variable[string]
+	}{
+		{name: "traces", enabled: OTEL_TRACES_ENABLED, protocolKey: OTEL_EXPORTER_OTLP_TRACES_PROTOCOL, endpointKey: OTEL_EXPORTER_OTLP_TRACES_ENDPOINT},
+		{name: "metrics", enabled: OTEL_METRICS_ENABLED, protocolKey: OTEL_EXPORTER_OTLP_METRICS_PROTOCOL, endpointKey: OTEL_EXPORTER_OTLP_METRICS_ENDPOINT},
+		{name: "logs", enabled: OTEL_LOGS_ENABLED, protocolKey: OTEL_EXPORTER_OTLP_LOGS_PROTOCOL, endpointKey: OTEL_EXPORTER_OTLP_LOGS_ENDPOINT},
+	}
+
+	var invalid []string
+	for _, signal := range signals {
+		if !cfg.Bool(signal.enabled) {
+			continue
+		}
+		if signal.enabled == OTEL_METRICS_ENABLED && strings.EqualFold(cfg.String(OTEL_METRICS_EXPORTER), metricsExporterPrometheus) {
+			continue // The metrics are scraped by Prometheus, no OTLP exporter will be created.
+		}
+		endpoint := configura.Fallback(cfg.String(signal.endpointKey), cfg.String(OTEL_EXPORTER_OTLP_ENDPOINT))
+		if endpoint == "" {
+			continue // No OTLP exporter will be created, the stdout exporter is used instead.
+		}
+		protocol := strings.ToLower(configura.Fallback(cfg.String(signal.protocolKey), cfg.String(OTEL_EXPORTER_OTLP_PROTOCOL)))
+		if !slices.Contains(supportedOTLPProtocols, protocol) {
+			invalid = append(invalid, fmt.Sprintf("%s=%q", signal.name, protocol))
+		}
+	}
+
+	if len(invalid) > 0 {
+		return fmt.Errorf("%w: %s (allowed values: %s)", ErrInvalidOTLPProtocol, strings.Join(invalid, ", "), strings.Join(supportedOTLPProtocols, ", "))
+	}
+	return nil
+}
+
+// ErrUnresolvedOTLPEndpoint is returned by setupOTelSDK when an OTLP endpoint references an environment variable that
+// is not set.
+var ErrUnresolvedOTLPEndpoint = errors.New("unresolved OTLP endpoint")
+
+// endpointVariablePattern matches the ${VAR} references of an OTLP endpoint.
+var endpointVariablePattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// otlpEndpoint returns the effective endpoint of a signal's OTLP exporter, from the signal specific key or
+// OTEL_EXPORTER_OTLP_ENDPOINT. References to environment variables (e.g. https://${OTEL_REGION}.collector:4318) are
+// replaced by their value, so one configuration template can be shared across regions. Referencing a variable that is
+// not set is an error, rather than exporting to a half resolved endpoint.
+func otlpEndpoint(cfg configura.Config, signalKey configura.Variable[string]) (string, error) {
+	key := signalKey
+	if cfg.String(key) == "" {
+		key = OTEL_EXPORTER_OTLP_ENDPOINT
+	}
+	endpoint := cfg.String(key)
+	var unresolved []string
+	endpoint = endpointVariablePattern.ReplaceAllStringFunc(endpoint, func(ref string) string {
+		name := endpointVariablePattern.FindStringSubmatch(ref)[1]
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			unresolved = append(unresolved, name)
+		}
+		return value
+	})
+	if len(unresolved) > 0 {
+		return "", fmt.Errorf("%w: %s references unset environment variables %s", ErrUnresolvedOTLPEndpoint, key, strings.Join(unresolved, ", "))
+	}
+	return endpoint, nil
+}
+
+// validateOTLPEndpoints checks that the endpoint of every enabled signal resolves, so an unset variable is reported
+// before any exporter is created. All unresolved endpoints are reported in a single error, once per key.
+func validateOTLPEndpoints(cfg configura.Config) error {
+	var errs []error
+	var reported []string
+	for _, signal := range []struct {
+		enabled     configura.Variable[bool]
+		endpointKey configura.Variable[string]
+	}{
+		{enabled: OTEL_TRACES_ENABLED, endpointKey: OTEL_EXPORTER_OTLP_TRACES_ENDPOINT},
+		{enabled: OTEL_METRICS_ENABLED, endpointKey: OTEL_EXPORTER_OTLP_METRICS_ENDPOINT},
+		{enabled: OTEL_LOGS_ENABLED, endpointKey: OTEL_EXPORTER_OTLP_LOGS_ENDPOINT},
+	} {
+		if !cfg.Bool(signal.enabled) {
+			continue
+		}
+		if _, err := otlpEndpoint(cfg, signal.endpointKey); err != nil && !slices.Contains(reported, err.Error()) {
+			reported = append(reported, err.Error())
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
 // Helper function to parse header strings (e.g., "key1=value1,key2=value2")
 func parseHeaders(headerStr string) map[string]string {
 	headers := make(map[string]string)
@@ -76,19 +197,103 @@ func parseHeaders(headerStr string) map[string]string {
 // shutdownFunc is a type for functions that perform cleanup.
 type shutdownFunc func(context.Context) error
 
-// initializeResource creates a new OpenTelemetry resource.
+// otlpCompression returns the effective compression of a signal's OTLP exporter, from the signal specific key or
+// OTEL_EXPORTER_OTLP_COMPRESSION. Only "gzip" and "none" (the default) are supported.
+func otlpCompression(cfg configura.Config, signalKey configura.Variable[string]) (gzip bool, err error) {
+	switch compression := strings.ToLower(configura.Fallback(cfg.String(signalKey), cfg.String(OTEL_EXPORTER_OTLP_COMPRESSION))); compression {
+	case "", "none":
+		return false, nil
+	case "gzip":
+		return true, nil
+	default:
+		return false, fmt.Errorf("unsupported OTLP compression %q for %s, expected gzip or none", compression, signalKey)
+	}
+}
+
+// otlpEndpointIsURL reports whether an OTLP endpoint is a URL, e.g. http://collector:4317, rather than a bare
+// host:port, which the gRPC exporters also accept.
+func otlpEndpointIsURL(endpoint string) bool {
+	return strings.Contains(endpoint, "://")
+}
+
+// otlpInsecure reports whether an OTLP exporter connects to the endpoint without TLS, which is the case for http URLs,
+// and for bare gRPC host:port endpoints unless a TLS configuration is set (see otlpTLSConfig). Each signal's exporter
+// decides on its own endpoint, so a signal exported over TLS doesn't make the others use it.
+func otlpInsecure(endpoint string, tlsConfig *tls.Config) bool {
+	if !otlpEndpointIsURL(endpoint) {
+		return tlsConfig == nil
+	}
+	return !strings.HasPrefix(strings.ToLower(endpoint), "https://")
+}
+
+// otlpTimeout returns the export timeout of a signal's OTLP exporter, the signal specific key or
+// OTEL_EXPORTER_OTLP_TIMEOUT in seconds, or the 10 second default of the SDK if neither is set.
+func otlpTimeout(cfg configura.Config, signalKey configura.Variable[int64]) time.Duration {
+	seconds := configura.Fallback(cfg.Int64(signalKey), cfg.Int64(OTEL_EXPORTER_OTLP_TIMEOUT))
+	if seconds <= 0 {
+		return 10 * time.Second
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// metricExportInterval returns the interval between two exports of the metrics from OTEL_METRIC_EXPORT_INTERVAL, or the
+// 60 seconds of the specification if it's not set.
+func metricExportInterval(cfg configura.Config) time.Duration {
+	seconds := cfg.Int64(OTEL_METRIC_EXPORT_INTERVAL)
+	if seconds <= 0 {
+		return 60 * time.Second
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// parseResourceAttributes parses OTEL_RESOURCE_ATTRIBUTES, comma separated key=value pairs with percent-encoded
+// values, e.g. "deployment.environment=prod,team=payments". Surrounding whitespace is trimmed, pairs without a key or
+// value are skipped, and a repeated key takes its last value.
+func parseResourceAttributes(attributesStr string) []attribute.KeyValue {
+	var attrs []attribute.KeyValue
+	index := make(map[string]int)
+	for _, pair := range strings.Split(attributesStr, ",") {
+		key, value, ok := strings.Cut(pair, "=")
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if !ok || key == "" || value == "" {
+			continue
+		}
+		if decoded, err := url.PathUnescape(value); err == nil {
+			value = decoded
+		}
+		if i, seen := index[key]; seen {
+			attrs[i] = attribute.String(key, value)
+			continue
+		}
+		index[key] = len(attrs)
+		attrs = append(attrs, attribute.String(key, value))
+	}
+	return attrs
+}
+
+// initializeResource creates a new OpenTelemetry resource, with the attributes of OTEL_RESOURCE_ATTRIBUTES. The
+// service name is OTEL_SERVICE_NAME, else the service.name of OTEL_RESOURCE_ATTRIBUTES, else "ponrove".
 func initializeResource(ctx context.Context, cfg configura.Config) (*resource.Resource, error) {
 	slog.DebugContext(ctx, "Initializing OpenTelemetry resource.")
+	attrs := parseResourceAttributes(cfg.String(OTEL_RESOURCE_ATTRIBUTES))
+	serviceName := cfg.String(OTEL_SERVICE_NAME)
+	if serviceName == "" {
+		serviceName = "ponrove"
+		for _, attr := range attrs {
+			if attr.Key == semconv.ServiceNameKey {
+				serviceName = attr.Value.AsString()
+			}
+		}
+	}
+	// The service name comes last, so it overrides the service.name of OTEL_RESOURCE_ATTRIBUTES.
 	res, err := resource.New(ctx,
-		resource.WithAttributes(
-			semconv.ServiceName(configura.Fallback(cfg.String(OTEL_SERVICE_NAME), "ponrove")),
-		),
+		resource.WithAttributes(append(attrs, semconv.ServiceName(serviceName))...),
 	)
 	if err != nil {
 		slog.ErrorContext(ctx, "Failed to create OpenTelemetry resource", slog.Any("error", err))
 		return nil, err
 	}
-	slog.InfoContext(ctx, "OpenTelemetry resource initialized.", slog.String("service.name", configura.Fallback(cfg.String(OTEL_SERVICE_NAME), "ponrove")))
+	slog.InfoContext(ctx, "OpenTelemetry resource initialized.", slog.String("service.name", serviceName), slog.Int("attributes", len(attrs)))
 	return res, nil
 }
 
@@ -102,9 +307,9 @@ func initializePropagator(ctx context.Context) {
 }
 
 // initializeTracerProvider sets up the OpenTelemetry tracer provider.
-func initializeTracerProvider(ctx context.Context, res *resource.Resource, cfg configura.Config) (*trace.TracerProvider, shutdownFunc, error) {
+func initializeTracerProvider(ctx context.Context, res *resource.Resource, cfg configura.Config, budget *exportBudget, spanExporter trace.SpanExporter) (*trace.TracerProvider, shutdownFunc, error) {
 	slog.DebugContext(ctx, "Attempting to initialize OpenTelemetry tracer provider.")
-	tracerProvider, err := newTracerProvider(ctx, res, cfg)
+	tracerProvider, err := newTracerProvider(ctx, res, cfg, budget, spanExporter)
 	if err != nil {
 		// newTracerProvider already logs the specifics of its failure
 		slog.ErrorContext(ctx, "Failed to initialize tracer provider", slog.Any("error", err))
@@ -116,79 +321,83 @@ func initializeTracerProvider(ctx context.Context, res *resource.Resource, cfg c
 	return tracerProvider, tracerProvider.Shutdown, nil
 }
 
-// initializeMeterProvider sets up the OpenTelemetry meter provider.
-func initializeMeterProvider(ctx context.Context, res *resource.Resource, cfg configura.Config) (*metric.MeterProvider, shutdownFunc, error) {
+// initializeMeterProvider sets up the OpenTelemetry meter provider, with the Go runtime metrics.
+func initializeMeterProvider(ctx context.Context, res *resource.Resource, cfg configura.Config) (*metric.MeterProvider, http.Handler, shutdownFunc, error) {
 	slog.DebugContext(ctx, "Attempting to initialize OpenTelemetry meter provider.")
-	meterProvider, err := newMeterProvider(ctx, res, cfg)
+	meterProvider, metricsHandler, err := newMeterProvider(ctx, res, cfg)
 	if err != nil {
 		slog.ErrorContext(ctx, "Failed to initialize meter provider", slog.Any("error", err))
-		return nil, nil, err
+		return nil, nil, nil, err
+	}
+
+	if err := startRuntimeMetrics(ctx, cfg, meterProvider); err != nil {
+		slog.ErrorContext(ctx, "Failed to start Go runtime metrics", slog.Any("error", err))
+		return nil, nil, nil, errors.Join(err, meterProvider.Shutdown(ctx))
 	}
 
 	otel.SetMeterProvider(meterProvider)
 	slog.InfoContext(ctx, "OpenTelemetry meter provider set up and registered globally.")
-	return meterProvider, meterProvider.Shutdown, nil
+	return meterProvider, metricsHandler, meterProvider.Shutdown, nil
 }
 
 // initializeLoggerProvider sets up the OpenTelemetry logger provider and configures slog.
-func initializeLoggerProvider(ctx context.Context, res *resource.Resource, cfg configura.Config) (*sdklog.LoggerProvider, shutdownFunc, error) {
+func initializeLoggerProvider(ctx context.Context, res *resource.Resource, cfg configura.Config, budget *exportBudget) (*sdklog.LoggerProvider, shutdownFunc, error) {
 	slog.DebugContext(ctx, "Attempting to initialize OpenTelemetry logger provider.")
-	loggerProvider, err := newLoggerProvider(ctx, res, cfg) // This also calls slog.SetDefault
+	loggerProvider, err := newLoggerProvider(ctx, res, cfg, budget)
 	if err != nil {
 		slog.ErrorContext(ctx, "Failed to initialize logger provider", slog.Any("error", err))
 		return nil, nil, err
 	}
 
 	otelglobal.SetLoggerProvider(loggerProvider)
-	// Note: newLoggerProvider calls slog.SetDefault, so subsequent slog messages go via OTel.
+	// Explicitly route slog through the provider, so subsequent slog messages go via OTel.
 	// This log message will be processed by the OTel pipeline.
+	bridgeSlog(ctx, cfg, loggerProvider)
 	slog.InfoContext(ctx, "OpenTelemetry logger provider configured for OTel SDK and slog global registration completed.")
 	return loggerProvider, loggerProvider.Shutdown, nil
 }
 
 // setupOTelSDK bootstraps the OpenTelemetry pipeline.
 // If it does not return an error, make sure to call the returned shutdown function for proper cleanup.
-func setupOTelSDK(ctx context.Context, cfg configura.Config) (shutdownFunc, error) {
-	err := cfg.ConfigurationKeysRegistered(
-		OTEL_ENABLED,
-		OTEL_LOGS_ENABLED,
-		OTEL_METRICS_ENABLED,
-		OTEL_TRACES_ENABLED,
-		OTEL_SERVICE_NAME,
-		OTEL_EXPORTER_OTLP_ENDPOINT,
-		OTEL_EXPORTER_OTLP_TRACES_ENDPOINT,
-		OTEL_EXPORTER_OTLP_METRICS_ENDPOINT,
-		OTEL_EXPORTER_OTLP_LOGS_ENDPOINT,
-		OTEL_EXPORTER_OTLP_HEADERS,
-		OTEL_EXPORTER_OTLP_TRACES_HEADERS,
-		OTEL_EXPORTER_OTLP_METRICS_HEADERS,
-		OTEL_EXPORTER_OTLP_LOGS_HEADERS,
-		OTEL_EXPORTER_OTLP_TIMEOUT,
-		OTEL_EXPORTER_OTLP_TRACES_TIMEOUT,
-		OTEL_EXPORTER_OTLP_METRICS_TIMEOUT,
-		OTEL_EXPORTER_OTLP_LOGS_TIMEOUT,
-		OTEL_EXPORTER_OTLP_PROTOCOL,
-		OTEL_EXPORTER_OTLP_TRACES_PROTOCOL,
-		OTEL_EXPORTER_OTLP_METRICS_PROTOCOL,
-		OTEL_EXPORTER_OTLP_LOGS_PROTOCOL,
-	)
+func setupOTelSDK(ctx context.Context, cfg configura.Config, spanExporter trace.SpanExporter) (shutdownFunc, error) {
+	err := cfg.ConfigurationKeysRegistered(RequiredOTelKeys()...)
 	if err != nil {
 		slog.ErrorContext(ctx, "OpenTelemetry configuration keys missing", slog.Any("error", err))
 		return nil, err
 	}
 
+	if cfg.Bool(OTEL_SDK_DISABLED) {
+		slog.InfoContext(ctx, "OpenTelemetry is disabled via OTEL_SDK_DISABLED. Skipping SDK setup.")
+		return nil, nil
+	}
 	if !cfg.Bool(OTEL_ENABLED) {
 		slog.InfoContext(ctx, "OpenTelemetry is disabled via OTEL_ENABLED. Skipping SDK setup.")
 		return nil, nil
 	}
 
+	if err := errors.Join(validateOTLPProtocols(cfg), validateOTLPEndpoints(cfg)); err != nil {
+		slog.ErrorContext(ctx, "OpenTelemetry configuration is invalid", slog.Any("error", err))
+		return nil, err
+	}
+	if cfg.Bool(OTEL_REQUIRE_COLLECTOR) {
+		if err := checkCollectors(ctx, cfg); err != nil {
+			slog.ErrorContext(ctx, "OTLP collector is unreachable", slog.Any("error", err))
+			return nil, err
+		}
+	}
+
 	slog.InfoContext(ctx, "OpenTelemetry is enabled. Proceeding with SDK setup.")
 	var shutdownFuncs []shutdownFunc
 	var cumulativeErr error
+	providers := &telemetryProviders{}
 
 	// Master shutdown function that calls all registered component shutdown functions.
 	masterShutdown := func(shutdownCtx context.Context) error {
 		var shutdownErr error
+		// Everything recorded so far is exported first, so the shutdown of one provider can't cut off another's export.
+		if len(shutdownFuncs) > 0 {
+			shutdownErr = providers.forceFlush(shutdownCtx)
+		}
 		// Execute shutdown functions in reverse order of their addition.
 		for i := len(shutdownFuncs) - 1; i >= 0; i-- {
 			fn := shutdownFuncs[i]
@@ -198,6 +407,7 @@ func setupOTelSDK(ctx context.Context, cfg configura.Config) (shutdownFunc, erro
 			}
 		}
 		shutdownFuncs = nil // Prevent multiple calls.
+		currentProviders.CompareAndSwap(providers, nil)
 		if shutdownErr != nil {
 			slog.ErrorContext(shutdownCtx, "OpenTelemetry shutdown completed with errors", slog.Any("error", shutdownErr))
 		} else {
@@ -229,26 +439,32 @@ func setupOTelSDK(ctx context.Context, cfg configura.Config) (shutdownFunc, erro
 	// 2. Initialize Propagator
 	initializePropagator(ctx) // Does not return error or shutdown func.
 
+	// The spans and log records held for export share a single budget.
+	budget := newExportBudget(cfg)
+
 	// 3. Initialize Tracer Provider (if enabled)
 	if configura.Fallback(cfg.Bool(OTEL_TRACES_ENABLED), false) {
-		_, tracerShutdown, tpErr := initializeTracerProvider(ctx, res, cfg)
+		tracerProvider, tracerShutdown, tpErr := initializeTracerProvider(ctx, res, cfg, budget, spanExporter)
 		if tpErr != nil {
 			handleComponentSetupError(tpErr, "TracerProvider")
 			return masterShutdown, cumulativeErr
 		}
 		shutdownFuncs = append(shutdownFuncs, tracerShutdown)
+		providers.tracer = tracerProvider
 	} else {
 		slog.InfoContext(ctx, "OpenTelemetry tracing is disabled via OTEL_TRACES_ENABLED. Skipping tracer provider setup.")
 	}
 
 	// 4. Initialize Meter Provider (if enabled)
 	if configura.Fallback(cfg.Bool(OTEL_METRICS_ENABLED), false) {
-		_, meterShutdown, mpErr := initializeMeterProvider(ctx, res, cfg)
+		meterProvider, metricsHandler, meterShutdown, mpErr := initializeMeterProvider(ctx, res, cfg)
 		if mpErr != nil {
 			handleComponentSetupError(mpErr, "MeterProvider")
 			return masterShutdown, cumulativeErr
 		}
 		shutdownFuncs = append(shutdownFuncs, meterShutdown)
+		providers.meter = meterProvider
+		providers.metricsHandler = metricsHandler
 	} else {
 		slog.InfoContext(ctx, "OpenTelemetry metrics are disabled via OTEL_METRICS_ENABLED. Skipping meter provider setup.")
 	}
@@ -257,12 +473,13 @@ func setupOTelSDK(ctx context.Context, cfg configura.Config) (shutdownFunc, erro
 	// If OTEL_LOGS_ENABLED is true, slog's default logger will be reconfigured.
 	// Subsequent logs from setupOTelSDK itself will go through this OTel pipeline.
 	if configura.Fallback(cfg.Bool(OTEL_LOGS_ENABLED), false) {
-		_, loggerShutdown, lpErr := initializeLoggerProvider(ctx, res, cfg) // This will change slog.Default
+		loggerProvider, loggerShutdown, lpErr := initializeLoggerProvider(ctx, res, cfg, budget) // This will change slog.Default
 		if lpErr != nil {
 			handleComponentSetupError(lpErr, "LoggerProvider")
 			return masterShutdown, cumulativeErr
 		}
 		shutdownFuncs = append(shutdownFuncs, loggerShutdown)
+		providers.logger = loggerProvider
 		// This message goes through the OTel pipeline.
 		slog.InfoContext(ctx, "slog is now bridged to OpenTelemetry logging pipeline.")
 	} else {
@@ -274,26 +491,52 @@ func setupOTelSDK(ctx context.Context, cfg configura.Config) (shutdownFunc, erro
 		return masterShutdown, cumulativeErr
 	}
 
+	currentProviders.Store(providers)
 	slog.InfoContext(ctx, "OpenTelemetry SDK setup completed successfully.")
 	return masterShutdown, nil
 }
 
-// newTracerProvider creates a new trace.TracerProvider.
-// It's kept as an internal detail for creating the specific type of provider.
-func newTracerProvider(ctx context.Context, res *resource.Resource, cfg configura.Config) (*trace.TracerProvider, error) {
-	var spanExporter trace.SpanExporter
+// newSpanLimits returns the span limits of the SDK, with OTEL_ATTRIBUTE_VALUE_LENGTH_LIMIT and
+// OTEL_ATTRIBUTE_COUNT_LIMIT applied if they are set, so misbehaving instrumentation can't bloat the export payloads
+// with huge attributes.
+func newSpanLimits(cfg configura.Config) trace.SpanLimits {
+	limits := trace.NewSpanLimits()
+	if limit := cfg.Int64(OTEL_ATTRIBUTE_VALUE_LENGTH_LIMIT); limit > 0 {
+		limits.AttributeValueLengthLimit = int(limit)
+	}
+	if limit := cfg.Int64(OTEL_ATTRIBUTE_COUNT_LIMIT); limit > 0 {
+		limits.AttributeCountLimit = int(limit)
+	}
+	return limits
+}
+
+// newTracerProvider creates a new trace.TracerProvider, holding no more spans for export than the budget allows.
+// It's kept as an internal detail for creating the specific type of provider. The spans are exported to spanExporter
+// if it isn't nil, as set with WithSpanExporter, instead of the configured exporter.
+func newTracerProvider(ctx context.Context, res *resource.Resource, cfg configura.Config, budget *exportBudget, spanExporter trace.SpanExporter) (*trace.TracerProvider, error) {
 	var err error
 
-	if cfg.Bool(OTEL_TRACES_ENABLED) {
+	if spanExporter == nil && cfg.Bool(OTEL_TRACES_ENABLED) {
 		slog.DebugContext(ctx, "OTLP exporter configured for traces. Attempting to create OTLP trace exporter.")
 		protocol := strings.ToLower(configura.Fallback(cfg.String(OTEL_EXPORTER_OTLP_TRACES_PROTOCOL), cfg.String(OTEL_EXPORTER_OTLP_PROTOCOL)))
-		endpoint := configura.Fallback(cfg.String(OTEL_EXPORTER_OTLP_TRACES_ENDPOINT), cfg.String(OTEL_EXPORTER_OTLP_ENDPOINT))
+		endpoint, endpointErr := otlpEndpoint(cfg, OTEL_EXPORTER_OTLP_TRACES_ENDPOINT)
+		if endpointErr != nil {
+			return nil, endpointErr
+		}
 
 		if endpoint == "" {
 			slog.WarnContext(ctx, "OTLP exporter is enabled but no endpoint is configured for traces. Falling back to stdout trace exporter.")
 		} else {
 			headers := parseHeaders(configura.Fallback(cfg.String(OTEL_EXPORTER_OTLP_TRACES_HEADERS), cfg.String(OTEL_EXPORTER_OTLP_HEADERS)))
-			timeout := configura.Fallback(time.Duration(cfg.Int64(OTEL_EXPORTER_OTLP_TRACES_TIMEOUT))*time.Second, time.Duration(cfg.Int64(OTEL_EXPORTER_OTLP_TIMEOUT))*time.Second)
+			gzip, compressionErr := otlpCompression(cfg, OTEL_EXPORTER_OTLP_TRACES_COMPRESSION)
+			if compressionErr != nil {
+				return nil, compressionErr
+			}
+			timeout := otlpTimeout(cfg, OTEL_EXPORTER_OTLP_TRACES_TIMEOUT)
+			tlsConfig, tlsErr := otlpTLSConfig(cfg)
+			if tlsErr != nil {
+				return nil, tlsErr
+			}
 
 			slog.InfoContext(ctx, "Configuring OTLP trace exporter.",
 				slog.String("protocol", protocol),
@@ -310,20 +553,34 @@ func newTracerProvider(ctx context.Context, res *resource.Resource, cfg configur
 				if len(headers) > 0 {
 					opts = append(opts, otlptracehttp.WithHeaders(headers))
 				}
-				if !strings.Contains(endpoint, "https://") {
+				if otlpInsecure(endpoint, tlsConfig) {
 					opts = append(opts, otlptracehttp.WithInsecure())
+				} else if tlsConfig != nil {
+					opts = append(opts, otlptracehttp.WithTLSClientConfig(tlsConfig))
+				}
+				if gzip {
+					opts = append(opts, otlptracehttp.WithCompression(otlptracehttp.GzipCompression))
 				}
 				spanExporter, err = otlptracehttp.New(ctx, opts...)
 			case "grpc":
 				opts := []otlptracegrpc.Option{
-					otlptracegrpc.WithEndpoint(endpoint),
 					otlptracegrpc.WithTimeout(timeout),
 				}
+				if otlpEndpointIsURL(endpoint) {
+					opts = append(opts, otlptracegrpc.WithEndpointURL(endpoint))
+				} else {
+					opts = append(opts, otlptracegrpc.WithEndpoint(endpoint))
+				}
 				if len(headers) > 0 {
 					opts = append(opts, otlptracegrpc.WithHeaders(headers))
 				}
-				if !strings.Contains(endpoint, "https://") { // Assuming non-https endpoint implies insecure for gRPC too.
+				if otlpInsecure(endpoint, tlsConfig) {
 					opts = append(opts, otlptracegrpc.WithInsecure())
+				} else if tlsConfig != nil {
+					opts = append(opts, otlptracegrpc.WithTLSCredentials(credentials.NewTLS(tlsConfig)))
+				}
+				if gzip {
+					opts = append(opts, otlptracegrpc.WithCompressor("gzip"))
 				}
 				spanExporter, err = otlptracegrpc.New(ctx, opts...)
 			default:
@@ -348,30 +605,63 @@ func newTracerProvider(ctx context.Context, res *resource.Resource, cfg configur
 		slog.InfoContext(ctx, "Stdout trace exporter created.")
 	}
 
+	sampler, err := newSampler(cfg)
+	if err != nil {
+		slog.ErrorContext(ctx, "Invalid trace sampler configuration.", slog.Any("error", err))
+		return nil, err
+	}
 	tp := trace.NewTracerProvider(
-		trace.WithBatcher(spanExporter, trace.WithBatchTimeout(time.Second)), // Default is 5s. Set to 1s for dev/demo.
+		trace.WithSpanProcessor(newBatchSpanProcessor(cfg, spanExporter, budget)),
+		trace.WithSampler(forceTraceSampler{base: sampler}),
+		trace.WithRawSpanLimits(newSpanLimits(cfg)),
 		trace.WithResource(res),
 	)
 	slog.InfoContext(ctx, "Tracer provider created.")
 	return tp, nil
 }
 
-// newMeterProvider creates a new metric.MeterProvider.
+// newMeterProvider creates a new metric.MeterProvider. With OTEL_METRICS_EXPORTER=prometheus, the metrics are
+// collected when Prometheus scrapes the returned handler rather than pushed over OTLP, the handler is nil otherwise.
 // It's kept as an internal detail for creating the specific type of provider.
-func newMeterProvider(ctx context.Context, res *resource.Resource, cfg configura.Config) (*metric.MeterProvider, error) {
+func newMeterProvider(ctx context.Context, res *resource.Resource, cfg configura.Config) (*metric.MeterProvider, http.Handler, error) {
+	switch exporter := strings.ToLower(cfg.String(OTEL_METRICS_EXPORTER)); exporter {
+	case metricsExporterPrometheus:
+		reader, handler, err := newPrometheusReader(cfg)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to create Prometheus metric exporter.", slog.Any("error", err))
+			return nil, nil, err
+		}
+		slog.InfoContext(ctx, "Prometheus metric exporter created.", slog.String("path", configura.Fallback(cfg.String(OTEL_PROMETHEUS_PATH), "/metrics")))
+		return metric.NewMeterProvider(metric.WithReader(reader), metric.WithResource(res)), handler, nil
+	case "", "otlp":
+	default:
+		return nil, nil, fmt.Errorf("unsupported OTEL_METRICS_EXPORTER %q, expected otlp or prometheus", exporter)
+	}
+
 	var metricExporter metric.Exporter
 	var err error
 
 	if configura.Fallback(cfg.Bool(OTEL_METRICS_ENABLED), false) {
 		slog.DebugContext(ctx, "OTLP exporter configured for metrics. Attempting to create OTLP metric exporter.")
 		protocol := strings.ToLower(configura.Fallback(cfg.String(OTEL_EXPORTER_OTLP_METRICS_PROTOCOL), cfg.String(OTEL_EXPORTER_OTLP_PROTOCOL)))
-		endpoint := configura.Fallback(cfg.String(OTEL_EXPORTER_OTLP_METRICS_ENDPOINT), cfg.String(OTEL_EXPORTER_OTLP_ENDPOINT))
+		endpoint, endpointErr := otlpEndpoint(cfg, OTEL_EXPORTER_OTLP_METRICS_ENDPOINT)
+		if endpointErr != nil {
+			return nil, nil, endpointErr
+		}
 
 		if endpoint == "" {
 			slog.WarnContext(ctx, "OTLP exporter is enabled but no endpoint is configured for metrics. Falling back to stdout metric exporter.")
 		} else {
 			headers := parseHeaders(configura.Fallback(cfg.String(OTEL_EXPORTER_OTLP_METRICS_HEADERS), cfg.String(OTEL_EXPORTER_OTLP_HEADERS)))
-			timeout := configura.Fallback(time.Duration(cfg.Int64(OTEL_EXPORTER_OTLP_METRICS_TIMEOUT))*time.Second, time.Duration(cfg.Int64(OTEL_EXPORTER_OTLP_METRICS_TIMEOUT))*time.Second)
+			gzip, compressionErr := otlpCompression(cfg, OTEL_EXPORTER_OTLP_METRICS_COMPRESSION)
+			if compressionErr != nil {
+				return nil, nil, compressionErr
+			}
+			timeout := otlpTimeout(cfg, OTEL_EXPORTER_OTLP_METRICS_TIMEOUT)
+			tlsConfig, tlsErr := otlpTLSConfig(cfg)
+			if tlsErr != nil {
+				return nil, nil, tlsErr
+			}
 
 			slog.InfoContext(ctx, "Configuring OTLP metric exporter.",
 				slog.String("protocol", protocol),
@@ -388,29 +678,43 @@ func newMeterProvider(ctx context.Context, res *resource.Resource, cfg configura
 				if len(headers) > 0 {
 					opts = append(opts, otlpmetrichttp.WithHeaders(headers))
 				}
-				if !strings.Contains(endpoint, "https://") {
+				if otlpInsecure(endpoint, tlsConfig) {
 					opts = append(opts, otlpmetrichttp.WithInsecure())
+				} else if tlsConfig != nil {
+					opts = append(opts, otlpmetrichttp.WithTLSClientConfig(tlsConfig))
+				}
+				if gzip {
+					opts = append(opts, otlpmetrichttp.WithCompression(otlpmetrichttp.GzipCompression))
 				}
 				metricExporter, err = otlpmetrichttp.New(ctx, opts...)
 			case "grpc":
 				opts := []otlpmetricgrpc.Option{
-					otlpmetricgrpc.WithEndpoint(endpoint),
 					otlpmetricgrpc.WithTimeout(timeout),
 				}
+				if otlpEndpointIsURL(endpoint) {
+					opts = append(opts, otlpmetricgrpc.WithEndpointURL(endpoint))
+				} else {
+					opts = append(opts, otlpmetricgrpc.WithEndpoint(endpoint))
+				}
 				if len(headers) > 0 {
 					opts = append(opts, otlpmetricgrpc.WithHeaders(headers))
 				}
-				if !strings.Contains(endpoint, "https://") {
+				if otlpInsecure(endpoint, tlsConfig) {
 					opts = append(opts, otlpmetricgrpc.WithInsecure())
+				} else if tlsConfig != nil {
+					opts = append(opts, otlpmetricgrpc.WithTLSCredentials(credentials.NewTLS(tlsConfig)))
+				}
+				if gzip {
+					opts = append(opts, otlpmetricgrpc.WithCompressor("gzip"))
 				}
 				metricExporter, err = otlpmetricgrpc.New(ctx, opts...)
 			default:
-				return nil, errors.New("unsupported OTLP protocol for metrics: " + protocol)
+				return nil, nil, errors.New("unsupported OTLP protocol for metrics: " + protocol)
 			}
 
 			if err != nil {
 				slog.ErrorContext(ctx, "Failed to create OTLP metric exporter.", slog.Any("error", err), slog.String("protocol", protocol), slog.String("endpoint", endpoint))
-				return nil, fmt.Errorf("failed to create OTLP metric exporter (protocol: %s, endpoint: %s): %w", protocol, endpoint, err)
+				return nil, nil, fmt.Errorf("failed to create OTLP metric exporter (protocol: %s, endpoint: %s): %w", protocol, endpoint, err)
 			}
 			slog.InfoContext(ctx, "OTLP metric exporter created successfully.", slog.String("protocol", protocol), slog.String("endpoint", endpoint))
 		}
@@ -421,36 +725,48 @@ func newMeterProvider(ctx context.Context, res *resource.Resource, cfg configura
 		metricExporter, err = stdoutmetric.New()
 		if err != nil {
 			slog.ErrorContext(ctx, "Failed to create stdout metric exporter.", slog.Any("error", err))
-			return nil, fmt.Errorf("failed to create stdout metric exporter: %w", err)
+			return nil, nil, fmt.Errorf("failed to create stdout metric exporter: %w", err)
 		}
 		slog.InfoContext(ctx, "Stdout metric exporter created.")
 	}
 
+	interval := metricExportInterval(cfg)
 	mp := metric.NewMeterProvider(
-		metric.WithReader(metric.NewPeriodicReader(metricExporter, metric.WithInterval(3*time.Second))), // Default is 1m. Set to 3s for dev/demo.
+		metric.WithReader(metric.NewPeriodicReader(metricExporter, metric.WithInterval(interval))),
 		metric.WithResource(res),
 	)
-	slog.InfoContext(ctx, "Meter provider created.")
-	return mp, nil
+	slog.InfoContext(ctx, "Meter provider created.", slog.Duration("export_interval", interval))
+	return mp, nil, nil
 }
 
-// newLoggerProvider creates an OTel sdklog.LoggerProvider and configures the default slog logger
-// to route its logs through this OTel pipeline.
-// It's kept as an internal detail for creating the specific type of provider and setting up slog.
-func newLoggerProvider(ctx context.Context, res *resource.Resource, cfg configura.Config) (*sdklog.LoggerProvider, error) {
+// newLoggerProvider creates an OTel sdklog.LoggerProvider. It doesn't touch the default slog logger, routing slog
+// through the provider is the separate bridgeSlog step. No more records are held for export than the budget allows.
+// It's kept as an internal detail for creating the specific type of provider.
+func newLoggerProvider(ctx context.Context, res *resource.Resource, cfg configura.Config, budget *exportBudget) (*sdklog.LoggerProvider, error) {
 	var logExporter sdklog.Exporter
 	var err error
 
 	if configura.Fallback(cfg.Bool(OTEL_LOGS_ENABLED), false) {
 		slog.DebugContext(ctx, "OTLP exporter configured for logs. Attempting to create OTLP log exporter.")
 		protocol := strings.ToLower(configura.Fallback(cfg.String(OTEL_EXPORTER_OTLP_LOGS_PROTOCOL), cfg.String(OTEL_EXPORTER_OTLP_PROTOCOL)))
-		endpoint := configura.Fallback(cfg.String(OTEL_EXPORTER_OTLP_LOGS_ENDPOINT), cfg.String(OTEL_EXPORTER_OTLP_ENDPOINT))
+		endpoint, endpointErr := otlpEndpoint(cfg, OTEL_EXPORTER_OTLP_LOGS_ENDPOINT)
+		if endpointErr != nil {
+			return nil, endpointErr
+		}
 
 		if endpoint == "" {
 			slog.WarnContext(ctx, "OTLP exporter is enabled but no endpoint is configured for logs. Falling back to stdout log exporter.")
 		} else {
 			headers := parseHeaders(configura.Fallback(cfg.String(OTEL_EXPORTER_OTLP_LOGS_HEADERS), cfg.String(OTEL_EXPORTER_OTLP_HEADERS)))
-			timeout := configura.Fallback(time.Duration(cfg.Int64(OTEL_EXPORTER_OTLP_LOGS_TIMEOUT))*time.Second, time.Duration(cfg.Int64(OTEL_EXPORTER_OTLP_TIMEOUT))*time.Second)
+			gzip, compressionErr := otlpCompression(cfg, OTEL_EXPORTER_OTLP_LOGS_COMPRESSION)
+			if compressionErr != nil {
+				return nil, compressionErr
+			}
+			timeout := otlpTimeout(cfg, OTEL_EXPORTER_OTLP_LOGS_TIMEOUT)
+			tlsConfig, tlsErr := otlpTLSConfig(cfg)
+			if tlsErr != nil {
+				return nil, tlsErr
+			}
 
 			slog.InfoContext(ctx, "Configuring OTLP log exporter.",
 				slog.String("protocol", protocol),
@@ -467,20 +783,34 @@ func newLoggerProvider(ctx context.Context, res *resource.Resource, cfg configur
 				if len(headers) > 0 {
 					opts = append(opts, otlploghttp.WithHeaders(headers))
 				}
-				if !strings.Contains(endpoint, "https://") {
+				if otlpInsecure(endpoint, tlsConfig) {
 					opts = append(opts, otlploghttp.WithInsecure())
+				} else if tlsConfig != nil {
+					opts = append(opts, otlploghttp.WithTLSClientConfig(tlsConfig))
+				}
+				if gzip {
+					opts = append(opts, otlploghttp.WithCompression(otlploghttp.GzipCompression))
 				}
 				logExporter, err = otlploghttp.New(ctx, opts...)
 			case "grpc":
 				opts := []otlploggrpc.Option{
-					otlploggrpc.WithEndpoint(endpoint),
 					otlploggrpc.WithTimeout(timeout),
 				}
+				if otlpEndpointIsURL(endpoint) {
+					opts = append(opts, otlploggrpc.WithEndpointURL(endpoint))
+				} else {
+					opts = append(opts, otlploggrpc.WithEndpoint(endpoint))
+				}
 				if len(headers) > 0 {
 					opts = append(opts, otlploggrpc.WithHeaders(headers))
 				}
-				if !strings.Contains(endpoint, "https://") {
+				if otlpInsecure(endpoint, tlsConfig) {
 					opts = append(opts, otlploggrpc.WithInsecure())
+				} else if tlsConfig != nil {
+					opts = append(opts, otlploggrpc.WithTLSCredentials(credentials.NewTLS(tlsConfig)))
+				}
+				if gzip {
+					opts = append(opts, otlploggrpc.WithCompressor("gzip"))
 				}
 				logExporter, err = otlploggrpc.New(ctx, opts...)
 			default:
@@ -508,18 +838,35 @@ func newLoggerProvider(ctx context.Context, res *resource.Resource, cfg configur
 	slog.DebugContext(ctx, "Creating OTel SDK LoggerProvider.")
 	// This is the OTel LoggerProvider that the OTel SDK will use.
 	lp := sdklog.NewLoggerProvider(
-		sdklog.WithProcessor(sdklog.NewBatchProcessor(logExporter)),
+		sdklog.WithProcessor(newBatchLogProcessor(logExporter, budget)),
 		sdklog.WithResource(res),
 	)
 	slog.InfoContext(ctx, "OTel SDK LoggerProvider created.")
+	return lp, nil
+}
 
-	// Configure the default slog logger to use an otelslog.Handler.
-	// This handler will take slog records and forward them to the OTel LoggerProvider (lp).
-	// Effectively, application logs made via slog will now go through the OTel logging pipeline.
-	slog.SetDefault(slog.New(otelslog.NewHandler("", otelslog.WithLoggerProvider(lp))))
+// bridgeSlog configures the default slog logger to use an otelslog.Handler, which forwards slog records to the OTel
+// LoggerProvider. Effectively, application logs made via slog will now go through the OTel logging pipeline. Only the
+// records at or above OTEL_LOGS_MIN_LEVEL are forwarded, and with OTEL_LOGS_STDOUT set the previous default handler
+// (stdout) keeps receiving the records at its own level.
+func bridgeSlog(ctx context.Context, cfg configura.Config, lp *sdklog.LoggerProvider) {
+	// The attributes added to the context with slogctx.Prepend and slogctx.Append are exported as attributes of the
+	// records, like the attributes of loggers derived with With.
+	var handler slog.Handler = slogctx.NewHandler(otelslog.NewHandler("", otelslog.WithLoggerProvider(lp)), nil)
+	if minLevelStr := cfg.String(OTEL_LOGS_MIN_LEVEL); minLevelStr != "" {
+		minLevel, ok := parseLogLevel(minLevelStr)
+		if !ok {
+			slog.WarnContext(ctx, "Unsupported OpenTelemetry log level configured, forwarding all levels", slog.String("configuredLevel", minLevelStr))
+		} else {
+			handler = levelHandler{Handler: handler, level: minLevel}
+		}
+	}
+	if cfg.Bool(OTEL_LOGS_STDOUT) {
+		handler = multiHandler{slog.Default().Handler(), handler}
+	}
+	slog.SetDefault(slog.New(handler))
 
 	// Important: From this point on, slog.InfoContext, slog.DebugContext, etc., from anywhere in the application
 	// (that uses the default slog logger) will route through the OTel pipeline.
 	slog.InfoContext(ctx, "Default slog logger replaced. Application logs via slog will now be processed by the OTel logging pipeline.")
-	return lp, nil
 }
@@ -9,21 +9,31 @@ import (
 	"net/http/httptest"
 	"os"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/go-chi/chi/v5"
 	"github.com/ponrove/configura"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	slogctx "github.com/veqryn/slog-context"
 	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	otellog "go.opentelemetry.io/otel/log"
 	otelglobal "go.opentelemetry.io/otel/log/global"
 	sdklog "go.opentelemetry.io/otel/sdk/log"
 	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
 	sdkresource "go.opentelemetry.io/otel/sdk/resource"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/protobuf/types/known/emptypb"
 )
 
 // MemoryWriter captures log output for assertions.
@@ -73,7 +83,7 @@ func TestSetupOTelSDK_Disabled(t *testing.T) {
 	originalMeterProvider := otel.GetMeterProvider()
 	originalLoggerProvider := otelglobal.GetLoggerProvider()
 
-	shutdown, err := setupOTelSDK(ctx, finalCfg)
+	shutdown, err := setupOTelSDK(ctx, finalCfg, nil)
 	require.NoError(t, err, "setupOTelSDK should not return an error when OTel is disabled")
 	require.Nil(t, shutdown, "shutdown function should be nil when OTel is disabled")
 
@@ -82,6 +92,36 @@ func TestSetupOTelSDK_Disabled(t *testing.T) {
 	assert.Equal(t, originalLoggerProvider, otelglobal.GetLoggerProvider(), "LoggerProvider should not have been changed")
 }
 
+func TestSetupOTelSDK_SDKDisabled(t *testing.T) {
+	ctx := context.Background()
+	emptyCfg := configura.NewConfigImpl()
+	err := configura.WriteConfiguration(emptyCfg, map[configura.Variable[bool]]bool{
+		OTEL_ENABLED:         true,
+		OTEL_SDK_DISABLED:    true,
+		OTEL_TRACES_ENABLED:  true,
+		OTEL_METRICS_ENABLED: true,
+		OTEL_LOGS_ENABLED:    true,
+	})
+	require.NoError(t, err)
+	finalCfg := configura.Merge(newDefaultCfg(), emptyCfg)
+
+	originalSlogLogger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	defer slog.SetDefault(originalSlogLogger)
+
+	originalTracerProvider := otel.GetTracerProvider()
+	originalMeterProvider := otel.GetMeterProvider()
+	originalLoggerProvider := otelglobal.GetLoggerProvider()
+
+	shutdown, err := setupOTelSDK(ctx, finalCfg, nil)
+	require.NoError(t, err)
+	require.Nil(t, shutdown, "OTEL_SDK_DISABLED should skip the setup like OTEL_ENABLED=false")
+
+	assert.Equal(t, originalTracerProvider, otel.GetTracerProvider(), "TracerProvider should not have been changed")
+	assert.Equal(t, originalMeterProvider, otel.GetMeterProvider(), "MeterProvider should not have been changed")
+	assert.Equal(t, originalLoggerProvider, otelglobal.GetLoggerProvider(), "LoggerProvider should not have been changed")
+}
+
 func TestSetupOTelSDK_Enabled_DefaultServiceName(t *testing.T) {
 	ctx := context.Background()
 	emptyCfg := configura.NewConfigImpl()
@@ -108,7 +148,7 @@ func TestSetupOTelSDK_Enabled_DefaultServiceName(t *testing.T) {
 		slog.SetDefault(originalSlogLogger) // Ensure slog is restored
 	}()
 
-	shutdown, err := setupOTelSDK(ctx, finalCfg)
+	shutdown, err := setupOTelSDK(ctx, finalCfg, nil)
 	require.NoError(t, err, "setupOTelSDK should succeed when OTel is enabled")
 	require.NotNil(t, shutdown, "shutdown function should be non-nil")
 
@@ -154,7 +194,7 @@ func TestSetupOTelSDK_Enabled_CustomServiceName(t *testing.T) {
 		slog.SetDefault(originalSlogLogger)
 	}()
 
-	shutdown, err := setupOTelSDK(ctx, finalCfg)
+	shutdown, err := setupOTelSDK(ctx, finalCfg, nil)
 	require.NoError(t, err, "setupOTelSDK should succeed with custom service name")
 	require.NotNil(t, shutdown)
 
@@ -176,7 +216,7 @@ func TestNewTracerProvider_Success(t *testing.T) {
 	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{Level: slog.LevelDebug})))
 	defer slog.SetDefault(originalSlogLogger)
 
-	tp, err := newTracerProvider(ctx, res, cfg)
+	tp, err := newTracerProvider(ctx, res, cfg, nil, nil)
 	require.NoError(t, err, "newTracerProvider should succeed")
 	require.NotNil(t, tp, "TracerProvider should not be nil")
 
@@ -194,9 +234,10 @@ func TestNewMeterProvider_Success(t *testing.T) {
 	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{Level: slog.LevelDebug})))
 	defer slog.SetDefault(originalSlogLogger)
 
-	mp, err := newMeterProvider(ctx, res, cfg)
+	mp, metricsHandler, err := newMeterProvider(ctx, res, cfg)
 	require.NoError(t, err, "newMeterProvider should succeed")
 	require.NotNil(t, mp, "MeterProvider should not be nil")
+	assert.Nil(t, metricsHandler, "Only the Prometheus exporter is scraped")
 
 	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
@@ -204,6 +245,74 @@ func TestNewMeterProvider_Success(t *testing.T) {
 	assert.NoError(t, err, "MeterProvider shutdown should succeed")
 }
 
+func TestMetricExportInterval(t *testing.T) {
+	tests := []struct {
+		name     string
+		interval int64
+		expected time.Duration
+	}{
+		{name: "Unset", expected: 60 * time.Second},
+		{name: "Negative", interval: -1, expected: 60 * time.Second},
+		{name: "Configured", interval: 3, expected: 3 * time.Second},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := configura.NewConfigImpl()
+			err := configura.WriteConfiguration(cfg, map[configura.Variable[int64]]int64{
+				OTEL_METRIC_EXPORT_INTERVAL: tc.interval,
+			})
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.expected, metricExportInterval(cfg))
+		})
+	}
+}
+
+func TestNewMeterProvider_ExportInterval(t *testing.T) {
+	var exports atomic.Int64
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/metrics" {
+			exports.Add(1)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer collector.Close()
+
+	cfg := configura.NewConfigImpl()
+	require.NoError(t, configura.WriteConfiguration(cfg, map[configura.Variable[bool]]bool{
+		OTEL_METRICS_ENABLED: true,
+	}))
+	require.NoError(t, configura.WriteConfiguration(cfg, map[configura.Variable[string]]string{
+		OTEL_EXPORTER_OTLP_METRICS_ENDPOINT: collector.URL + "/v1/metrics",
+		OTEL_EXPORTER_OTLP_METRICS_PROTOCOL: "http/protobuf",
+	}))
+	require.NoError(t, configura.WriteConfiguration(cfg, map[configura.Variable[int64]]int64{
+		OTEL_METRIC_EXPORT_INTERVAL: 1,
+	}))
+
+	originalSlogLogger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	defer slog.SetDefault(originalSlogLogger)
+
+	ctx := context.Background()
+	mp, _, err := newMeterProvider(ctx, nil, cfg)
+	require.NoError(t, err)
+	defer func() {
+		shutdownCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+		_ = mp.Shutdown(shutdownCtx)
+	}()
+
+	counter, err := mp.Meter("test").Int64Counter("orders.created")
+	require.NoError(t, err)
+	counter.Add(ctx, 1)
+
+	// The periodic reader exports every second, rather than after the 60 seconds of the default, without a shutdown
+	// flushing the metrics.
+	assert.Eventually(t, func() bool { return exports.Load() >= 2 }, 5*time.Second, 50*time.Millisecond, "The metrics should be exported every second")
+}
+
 func TestNewLoggerProvider_Success(t *testing.T) {
 	cfg := newDefaultCfg() // Defaults to stdout exporter
 	ctx := context.Background()
@@ -218,15 +327,22 @@ func TestNewLoggerProvider_Success(t *testing.T) {
 	originalOtelGlobalLP := otelglobal.GetLoggerProvider()
 	defer otelglobal.SetLoggerProvider(originalOtelGlobalLP) // Restore OTel global LP
 
-	lp, err := newLoggerProvider(ctx, res, cfg)
+	lp, err := newLoggerProvider(ctx, res, cfg, nil)
 	require.NoError(t, err, "newLoggerProvider should succeed")
 	require.NotNil(t, lp, "LoggerProvider should not be nil")
 
-	// Verify that the default slog logger was indeed changed by newLoggerProvider.
+	// Creating the provider alone must leave the default slog logger untouched.
 	logOutput.Reset()
-	slog.InfoContext(ctx, "Test message after newLoggerProvider slog.SetDefault")
-	assert.NotContains(t, logOutput.String(), "Test message after newLoggerProvider slog.SetDefault",
-		"Log message after newLoggerProvider's SetDefault should not be in old MemoryWriter because slog was reconfigured")
+	slog.InfoContext(ctx, "Test message after newLoggerProvider")
+	assert.Contains(t, logOutput.String(), "Test message after newLoggerProvider",
+		"newLoggerProvider should not replace the default slog logger")
+
+	// Bridging slog is the explicit step that replaces it.
+	bridgeSlog(ctx, cfg, lp)
+	logOutput.Reset()
+	slog.InfoContext(ctx, "Test message after bridgeSlog")
+	assert.NotContains(t, logOutput.String(), "Test message after bridgeSlog",
+		"Log message after bridgeSlog should not be in old MemoryWriter because slog was reconfigured")
 
 	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
@@ -276,7 +392,7 @@ func TestSetupOTelSDK_FeaturesDisabled(t *testing.T) {
 				slog.SetDefault(originalSlogLogger)
 			}()
 
-			shutdown, err := setupOTelSDK(ctx, finalCfg)
+			shutdown, err := setupOTelSDK(ctx, finalCfg, nil)
 			require.NoError(t, err)
 			defer shutdown(context.Background())
 
@@ -311,20 +427,140 @@ func TestSetupOTelSDK_FeaturesDisabled(t *testing.T) {
 	}
 }
 
-// startMockGRPCServer starts a minimal gRPC server on a random port.
-func startMockGRPCServer(t *testing.T) (addr string, stop func()) {
+func TestSetupOTelSDK_MixedProtocols(t *testing.T) {
+	grpcAddr, grpcMethods, stopGRPC := startMockGRPCServer(t)
+	defer stopGRPC()
+	httpPaths := make(chan string, 16)
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case httpPaths <- r.URL.Path:
+		default:
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer collector.Close()
+
+	emptyCfg := configura.NewConfigImpl()
+	err := configura.WriteConfiguration(emptyCfg, map[configura.Variable[bool]]bool{
+		OTEL_ENABLED:         true,
+		OTEL_TRACES_ENABLED:  true,
+		OTEL_METRICS_ENABLED: false,
+		OTEL_LOGS_ENABLED:    true,
+	})
+	require.NoError(t, err)
+	err = configura.WriteConfiguration(emptyCfg, map[configura.Variable[string]]string{
+		OTEL_EXPORTER_OTLP_TRACES_PROTOCOL: "grpc",
+		OTEL_EXPORTER_OTLP_TRACES_ENDPOINT: "http://" + grpcAddr,
+		OTEL_EXPORTER_OTLP_LOGS_PROTOCOL:   "http/protobuf",
+		OTEL_EXPORTER_OTLP_LOGS_ENDPOINT:   collector.URL + "/v1/logs",
+	})
+	require.NoError(t, err)
+	finalCfg := configura.Merge(newDefaultCfg(), emptyCfg)
+
+	originalSlogLogger := slog.Default()
+	originalTracerProvider := otel.GetTracerProvider()
+	originalLoggerProvider := otelglobal.GetLoggerProvider()
+	t.Cleanup(func() {
+		slog.SetDefault(originalSlogLogger)
+		otel.SetTracerProvider(originalTracerProvider)
+		otelglobal.SetLoggerProvider(originalLoggerProvider)
+	})
+
+	shutdown, err := setupOTelSDK(context.Background(), finalCfg, nil)
+	require.NoError(t, err, "Each signal should set up its exporter with its own protocol")
+	require.NotNil(t, shutdown)
+
+	_, span := otel.Tracer("test").Start(context.Background(), "span")
+	span.End()
+	slog.Info("log record")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, shutdown(ctx))
+
+	select {
+	case method := <-grpcMethods:
+		assert.Equal(t, "/opentelemetry.proto.collector.trace.v1.TraceService/Export", method, "Traces should be exported over gRPC")
+	default:
+		t.Fatal("No traces were exported over gRPC")
+	}
+	var paths []string
+	for len(httpPaths) > 0 {
+		paths = append(paths, <-httpPaths)
+	}
+	assert.Contains(t, paths, "/v1/logs", "Logs should be exported over HTTP")
+	assert.NotContains(t, paths, "/v1/traces", "Traces should not be exported over HTTP")
+}
+
+func TestSetupOTelSDK_InvalidProtocol(t *testing.T) {
+	ctx := context.Background()
+	emptyCfg := configura.NewConfigImpl()
+	err := configura.WriteConfiguration(emptyCfg, map[configura.Variable[bool]]bool{
+		OTEL_ENABLED:         true,
+		OTEL_TRACES_ENABLED:  true,
+		OTEL_METRICS_ENABLED: true,
+		OTEL_LOGS_ENABLED:    true,
+	})
+	require.NoError(t, err)
+	err = configura.WriteConfiguration(emptyCfg, map[configura.Variable[string]]string{
+		OTEL_EXPORTER_OTLP_ENDPOINT:         "http://localhost:4317",
+		OTEL_EXPORTER_OTLP_PROTOCOL:         "grpc",
+		OTEL_EXPORTER_OTLP_TRACES_PROTOCOL:  "gprc",
+		OTEL_EXPORTER_OTLP_METRICS_PROTOCOL: "",
+		OTEL_EXPORTER_OTLP_LOGS_PROTOCOL:    "htpp",
+	})
+	require.NoError(t, err)
+	finalCfg := configura.Merge(newDefaultCfg(), emptyCfg)
+
+	originalSlogLogger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	defer slog.SetDefault(originalSlogLogger)
+
+	originalTracerProvider := otel.GetTracerProvider()
+	originalMeterProvider := otel.GetMeterProvider()
+	originalLoggerProvider := otelglobal.GetLoggerProvider()
+
+	shutdown, err := setupOTelSDK(ctx, finalCfg, nil)
+	require.Error(t, err)
+	assert.Nil(t, shutdown, "No shutdown function should be returned when validation fails")
+	assert.ErrorIs(t, err, ErrInvalidOTLPProtocol)
+	assert.Contains(t, err.Error(), `traces="gprc"`)
+	assert.Contains(t, err.Error(), `logs="htpp"`)
+	assert.NotContains(t, err.Error(), "metrics=", "Metrics fall back to the valid default protocol")
+	assert.Contains(t, err.Error(), "allowed values: grpc, http, http/protobuf")
+
+	assert.Equal(t, originalTracerProvider, otel.GetTracerProvider(), "No exporter should have been created")
+	assert.Equal(t, originalMeterProvider, otel.GetMeterProvider(), "No exporter should have been created")
+	assert.Equal(t, originalLoggerProvider, otelglobal.GetLoggerProvider(), "No exporter should have been created")
+}
+
+// startMockGRPCServer starts a minimal gRPC server on a random port, acknowledging every call with an empty response
+// and sending the full method names of the calls on the returned channel.
+func startMockGRPCServer(t *testing.T) (addr string, methods <-chan string, stop func()) {
 	t.Helper()
 	lis, err := net.Listen("tcp", "localhost:0")
 	require.NoError(t, err, "Failed to listen on a port for mock gRPC server")
 
-	s := grpc.NewServer(grpc.Creds(insecure.NewCredentials()))
-	// No services registered, just needs to accept connections for exporter init/shutdown.
+	calls := make(chan string, 16)
+	s := grpc.NewServer(grpc.Creds(insecure.NewCredentials()), grpc.UnknownServiceHandler(func(_ any, stream grpc.ServerStream) error {
+		if method, ok := grpc.MethodFromServerStream(stream); ok {
+			select {
+			case calls <- method:
+			default:
+			}
+		}
+		// The export responses have no required fields, so an empty message stands in for all of them.
+		if err := stream.RecvMsg(&emptypb.Empty{}); err != nil {
+			return err
+		}
+		return stream.SendMsg(&emptypb.Empty{})
+	}))
 	go func() {
 		if errS := s.Serve(lis); errS != nil && errS != grpc.ErrServerStopped {
 			t.Logf("Mock gRPC server failed: %v", errS)
 		}
 	}()
-	return lis.Addr().String(), func() {
+	return lis.Addr().String(), calls, func() {
 		s.GracefulStop()
 	}
 }
@@ -341,3 +577,481 @@ func startMockHTTPServer(t *testing.T) (url string, stop func()) {
 		server.Close()
 	}
 }
+
+func TestInitializeResource_Attributes(t *testing.T) {
+	tests := []struct {
+		name        string
+		attributes  string
+		serviceName string
+		expected    map[string]string
+		absent      []string
+	}{
+		{
+			name:       "Parsed attributes",
+			attributes: "deployment.environment=prod,team=payments",
+			expected:   map[string]string{"deployment.environment": "prod", "team": "payments", "service.name": "ponrove"},
+		},
+		{
+			name:       "Whitespace is trimmed",
+			attributes: " deployment.environment = prod , team=payments ",
+			expected:   map[string]string{"deployment.environment": "prod", "team": "payments"},
+		},
+		{
+			name:       "Empty keys and values are skipped",
+			attributes: "team=,=prod,region,,zone=eu-west-1a",
+			expected:   map[string]string{"zone": "eu-west-1a"},
+			absent:     []string{"team", "region"},
+		},
+		{
+			name:       "Last duplicate wins",
+			attributes: "team=orders,team=payments",
+			expected:   map[string]string{"team": "payments"},
+		},
+		{
+			name:       "Percent-encoded values",
+			attributes: "owner=Team%20Payments%2C%20EU",
+			expected:   map[string]string{"owner": "Team Payments, EU"},
+		},
+		{
+			name:       "Service name from the attributes",
+			attributes: "service.name=orders",
+			expected:   map[string]string{"service.name": "orders"},
+		},
+		{
+			name:        "OTEL_SERVICE_NAME overrides the attributes",
+			attributes:  "service.name=orders",
+			serviceName: "payments",
+			expected:    map[string]string{"service.name": "payments"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := configura.NewConfigImpl()
+			err := configura.WriteConfiguration(cfg, map[configura.Variable[string]]string{
+				OTEL_RESOURCE_ATTRIBUTES: tc.attributes,
+				OTEL_SERVICE_NAME:        tc.serviceName,
+			})
+			require.NoError(t, err)
+
+			res, err := initializeResource(context.Background(), cfg)
+			require.NoError(t, err)
+			for key, expected := range tc.expected {
+				value, ok := res.Set().Value(attribute.Key(key))
+				if assert.True(t, ok, "Resource should have the %s attribute", key) {
+					assert.Equal(t, expected, value.AsString())
+				}
+			}
+			for _, key := range tc.absent {
+				_, ok := res.Set().Value(attribute.Key(key))
+				assert.False(t, ok, "Resource should not have the %s attribute", key)
+			}
+		})
+	}
+}
+
+func TestOTLPCompression(t *testing.T) {
+	tests := []struct {
+		name      string
+		global    string
+		signal    string
+		expected  bool
+		expectErr bool
+	}{
+		{name: "Unset", expected: false},
+		{name: "Global gzip", global: "gzip", expected: true},
+		{name: "Signal overrides global", global: "gzip", signal: "none", expected: false},
+		{name: "Signal gzip", signal: "GZIP", expected: true},
+		{name: "Unsupported", global: "zstd", expectErr: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := configura.NewConfigImpl()
+			err := configura.WriteConfiguration(cfg, map[configura.Variable[string]]string{
+				OTEL_EXPORTER_OTLP_COMPRESSION:      tc.global,
+				OTEL_EXPORTER_OTLP_LOGS_COMPRESSION: tc.signal,
+			})
+			require.NoError(t, err)
+
+			gzip, err := otlpCompression(cfg, OTEL_EXPORTER_OTLP_LOGS_COMPRESSION)
+			if tc.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, gzip)
+		})
+	}
+}
+
+func TestOTLPTimeout(t *testing.T) {
+	tests := []struct {
+		name     string
+		global   int64
+		signal   int64
+		expected time.Duration
+	}{
+		{name: "Unset", expected: 10 * time.Second},
+		{name: "Global", global: 5, expected: 5 * time.Second},
+		{name: "Signal overrides global", global: 5, signal: 2, expected: 2 * time.Second},
+		{name: "Signal", signal: 3, expected: 3 * time.Second},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := configura.NewConfigImpl()
+			err := configura.WriteConfiguration(cfg, map[configura.Variable[int64]]int64{
+				OTEL_EXPORTER_OTLP_TIMEOUT:         tc.global,
+				OTEL_EXPORTER_OTLP_METRICS_TIMEOUT: tc.signal,
+			})
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.expected, otlpTimeout(cfg, OTEL_EXPORTER_OTLP_METRICS_TIMEOUT))
+		})
+	}
+}
+
+func TestOTLPTimeout_FromEnvironment(t *testing.T) {
+	t.Setenv("OTEL_EXPORTER_OTLP_TIMEOUT", "5")
+	cfg := configura.NewConfigImpl()
+	configura.LoadEnvironment(cfg, OTEL_EXPORTER_OTLP_TIMEOUT, int64(0))
+	configura.LoadEnvironment(cfg, OTEL_EXPORTER_OTLP_TRACES_TIMEOUT, int64(0))
+
+	assert.Equal(t, 5*time.Second, otlpTimeout(cfg, OTEL_EXPORTER_OTLP_TRACES_TIMEOUT), "A bare integer is a number of seconds")
+}
+
+func TestNewLoggerProvider_GzipCompression(t *testing.T) {
+	encodings := make(chan string, 1)
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/logs" {
+			select {
+			case encodings <- r.Header.Get("Content-Encoding"):
+			default:
+			}
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer collector.Close()
+
+	cfg := configura.NewConfigImpl()
+	require.NoError(t, configura.WriteConfiguration(cfg, map[configura.Variable[bool]]bool{
+		OTEL_LOGS_ENABLED: true,
+	}))
+	require.NoError(t, configura.WriteConfiguration(cfg, map[configura.Variable[string]]string{
+		OTEL_EXPORTER_OTLP_LOGS_ENDPOINT:    collector.URL + "/v1/logs",
+		OTEL_EXPORTER_OTLP_LOGS_PROTOCOL:    "http/protobuf",
+		OTEL_EXPORTER_OTLP_LOGS_COMPRESSION: "gzip",
+	}))
+	require.NoError(t, configura.WriteConfiguration(cfg, map[configura.Variable[int64]]int64{
+		OTEL_EXPORTER_OTLP_LOGS_TIMEOUT: 5,
+	}))
+
+	originalSlogLogger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	defer slog.SetDefault(originalSlogLogger)
+
+	ctx := context.Background()
+	res, err := sdkresource.New(ctx, sdkresource.WithAttributes(semconv.ServiceName("test-logger-service")))
+	require.NoError(t, err)
+
+	lp, err := newLoggerProvider(ctx, res, cfg, nil)
+	require.NoError(t, err)
+
+	var record otellog.Record
+	record.SetBody(otellog.StringValue("compressed"))
+	lp.Logger("test").Emit(ctx, record)
+
+	shutdownCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	require.NoError(t, lp.Shutdown(shutdownCtx))
+
+	select {
+	case encoding := <-encodings:
+		assert.Equal(t, "gzip", encoding)
+	default:
+		t.Fatal("No log export reached the collector")
+	}
+}
+
+func TestRegisterOTelDefaults(t *testing.T) {
+	// The environment overrides the defaults, OpenTelemetry is disabled by default.
+	t.Setenv(string(OTEL_ENABLED), "true")
+	t.Setenv(string(OTEL_LOGS_ENABLED), "false")
+
+	cfg := configura.NewConfigImpl()
+	RegisterOTelDefaults(cfg)
+	require.NoError(t, cfg.ConfigurationKeysRegistered(RequiredOTelKeys()...), "All required keys should be registered")
+	assert.True(t, cfg.Bool(OTEL_ENABLED))
+	assert.True(t, cfg.Bool(OTEL_TRACES_ENABLED))
+	assert.False(t, cfg.Bool(OTEL_LOGS_ENABLED))
+	assert.Equal(t, int64(10), cfg.Int64(OTEL_EXPORTER_OTLP_TIMEOUT))
+
+	originalSlogLogger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	originalTracerProvider := otel.GetTracerProvider()
+	originalMeterProvider := otel.GetMeterProvider()
+	t.Cleanup(func() {
+		slog.SetDefault(originalSlogLogger)
+		otel.SetTracerProvider(originalTracerProvider)
+		otel.SetMeterProvider(originalMeterProvider)
+	})
+
+	shutdown, err := setupOTelSDK(context.Background(), cfg, nil)
+	require.NoError(t, err, "setupOTelSDK should succeed with only the defaults registered")
+	require.NotNil(t, shutdown)
+	assert.NoError(t, shutdown(context.Background()))
+}
+
+// recordingExporter is a log exporter keeping the bodies and attributes of the records it exports.
+type recordingExporter struct {
+	mu         sync.Mutex
+	bodies     []string
+	attributes []map[string]string
+}
+
+func (e *recordingExporter) Export(_ context.Context, records []sdklog.Record) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, record := range records {
+		e.bodies = append(e.bodies, record.Body().AsString())
+		attributes := make(map[string]string)
+		record.WalkAttributes(func(kv otellog.KeyValue) bool {
+			attributes[kv.Key] = kv.Value.String()
+			return true
+		})
+		e.attributes = append(e.attributes, attributes)
+	}
+	return nil
+}
+
+func (e *recordingExporter) Shutdown(context.Context) error   { return nil }
+func (e *recordingExporter) ForceFlush(context.Context) error { return nil }
+
+func (e *recordingExporter) Bodies() []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]string(nil), e.bodies...)
+}
+
+func TestBridgeSlog_StdoutAndMinLevel(t *testing.T) {
+	ctx := context.Background()
+	cfg := configura.NewConfigImpl()
+	require.NoError(t, configura.WriteConfiguration(cfg, map[configura.Variable[bool]]bool{
+		OTEL_LOGS_STDOUT: true,
+	}))
+	require.NoError(t, configura.WriteConfiguration(cfg, map[configura.Variable[string]]string{
+		OTEL_LOGS_MIN_LEVEL: "warn",
+	}))
+
+	stdout := &MemoryWriter{}
+	originalSlogLogger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(stdout, nil)))
+	t.Cleanup(func() { slog.SetDefault(originalSlogLogger) })
+
+	exporter := &recordingExporter{}
+	lp := sdklog.NewLoggerProvider(sdklog.WithProcessor(sdklog.NewSimpleProcessor(exporter)))
+	t.Cleanup(func() { _ = lp.Shutdown(context.Background()) })
+
+	bridgeSlog(ctx, cfg, lp)
+	slog.InfoContext(ctx, "info message")
+	slog.WarnContext(ctx, "warn message")
+
+	assert.Contains(t, stdout.String(), "info message", "Info should still be written to stdout")
+	assert.Contains(t, stdout.String(), "warn message", "Warn should still be written to stdout")
+	assert.NotContains(t, exporter.Bodies(), "info message", "Info is below OTEL_LOGS_MIN_LEVEL and should not be exported")
+	assert.Contains(t, exporter.Bodies(), "warn message", "Warn should be exported")
+}
+
+func TestBridgeSlog_ContextAttributes(t *testing.T) {
+	ctx := context.Background()
+	originalSlogLogger := slog.Default()
+	t.Cleanup(func() { slog.SetDefault(originalSlogLogger) })
+
+	exporter := &recordingExporter{}
+	lp := sdklog.NewLoggerProvider(sdklog.WithProcessor(sdklog.NewSimpleProcessor(exporter)))
+	t.Cleanup(func() { _ = lp.Shutdown(context.Background()) })
+	bridgeSlog(ctx, configura.NewConfigImpl(), lp)
+
+	// Attributes added during a request, as in the LogRequest middleware: on the logger in the context, and on the
+	// context itself.
+	reqCtx := slogctx.NewCtx(ctx, slog.Default())
+	logger := slogctx.FromCtx(reqCtx)
+	*logger = *logger.With("user_id", "42")
+	reqCtx = slogctx.Append(reqCtx, "tenant", "acme")
+	slogctx.FromCtx(reqCtx).InfoContext(reqCtx, "request processed", slog.String("method", "GET"))
+
+	exporter.mu.Lock()
+	defer exporter.mu.Unlock()
+	require.Equal(t, "request processed", exporter.bodies[len(exporter.bodies)-1])
+	assert.Equal(t, map[string]string{
+		"user_id": "42",
+		"tenant":  "acme",
+		"method":  "GET",
+	}, exporter.attributes[len(exporter.attributes)-1], "The context attributes should be exported with the record")
+}
+
+func TestOTLPEndpoint(t *testing.T) {
+	t.Setenv("PONRUNNER_TEST_OTEL_REGION", "eu-north-1")
+	tests := []struct {
+		name        string
+		endpoint    string
+		traces      string
+		expected    string
+		expectedErr string
+	}{
+		{name: "Literal", endpoint: "http://collector:4318", expected: "http://collector:4318"},
+		{name: "Indirected", endpoint: "https://${PONRUNNER_TEST_OTEL_REGION}.collector:4318", expected: "https://eu-north-1.collector:4318"},
+		{name: "Signal specific", endpoint: "http://collector:4318", traces: "http://${PONRUNNER_TEST_OTEL_REGION}:4318", expected: "http://eu-north-1:4318"},
+		{name: "Other syntax untouched", endpoint: "http://$PONRUNNER_TEST_OTEL_REGION:4318", expected: "http://$PONRUNNER_TEST_OTEL_REGION:4318"},
+		{
+			name:        "Unresolved",
+			endpoint:    "https://${PONRUNNER_TEST_OTEL_UNSET}.collector:4318",
+			expectedErr: "OTEL_EXPORTER_OTLP_ENDPOINT references unset environment variables PONRUNNER_TEST_OTEL_UNSET",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := configura.NewConfigImpl()
+			err := configura.WriteConfiguration(cfg, map[configura.Variable[string]]string{
+				OTEL_EXPORTER_OTLP_ENDPOINT:        tc.endpoint,
+				OTEL_EXPORTER_OTLP_TRACES_ENDPOINT: tc.traces,
+			})
+			require.NoError(t, err)
+
+			endpoint, err := otlpEndpoint(cfg, OTEL_EXPORTER_OTLP_TRACES_ENDPOINT)
+			if tc.expectedErr != "" {
+				assert.ErrorIs(t, err, ErrUnresolvedOTLPEndpoint)
+				assert.ErrorContains(t, err, tc.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, endpoint)
+		})
+	}
+}
+
+func TestSetupOTelSDK_UnresolvedEndpoint(t *testing.T) {
+	ctx := context.Background()
+	emptyCfg := configura.NewConfigImpl()
+	err := configura.WriteConfiguration(emptyCfg, map[configura.Variable[bool]]bool{
+		OTEL_ENABLED: true,
+	})
+	require.NoError(t, err)
+	err = configura.WriteConfiguration(emptyCfg, map[configura.Variable[string]]string{
+		OTEL_EXPORTER_OTLP_ENDPOINT: "http://${PONRUNNER_TEST_OTEL_UNSET}:4317",
+		OTEL_EXPORTER_OTLP_PROTOCOL: "grpc",
+	})
+	require.NoError(t, err)
+	finalCfg := configura.Merge(newDefaultCfg(), emptyCfg)
+
+	var buf strings.Builder
+	originalSlogLogger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, nil)))
+	defer slog.SetDefault(originalSlogLogger)
+
+	originalTracerProvider := otel.GetTracerProvider()
+
+	shutdown, err := setupOTelSDK(ctx, finalCfg, nil)
+	assert.Nil(t, shutdown, "No shutdown function should be returned when validation fails")
+	require.ErrorIs(t, err, ErrUnresolvedOTLPEndpoint)
+	assert.Equal(t, 1, strings.Count(err.Error(), "PONRUNNER_TEST_OTEL_UNSET"), "The shared endpoint should be reported once")
+	assert.Contains(t, buf.String(), "OpenTelemetry configuration is invalid")
+	assert.Equal(t, originalTracerProvider, otel.GetTracerProvider(), "No exporter should have been created")
+}
+
+func TestNewSpanLimits(t *testing.T) {
+	cfg := configura.NewConfigImpl()
+	err := configura.WriteConfiguration(cfg, map[configura.Variable[int64]]int64{
+		OTEL_ATTRIBUTE_VALUE_LENGTH_LIMIT: 8,
+		OTEL_ATTRIBUTE_COUNT_LIMIT:        2,
+	})
+	require.NoError(t, err)
+
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter), sdktrace.WithRawSpanLimits(newSpanLimits(cfg)))
+	defer func() { _ = tp.Shutdown(context.Background()) }()
+
+	_, span := tp.Tracer("test").Start(context.Background(), "span")
+	span.SetAttributes(
+		attribute.String("long", strings.Repeat("x", 100)),
+		attribute.String("short", "abc"),
+		attribute.String("dropped", "over the count limit"),
+	)
+	span.End()
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 1)
+	assert.Equal(t, []attribute.KeyValue{
+		attribute.String("long", "xxxxxxxx"),
+		attribute.String("short", "abc"),
+	}, spans[0].Attributes, "The long value should be truncated, and attributes over the count limit dropped")
+	assert.Equal(t, 1, spans[0].DroppedAttributes)
+}
+
+func TestNewSpanLimits_SDKDefaults(t *testing.T) {
+	assert.Equal(t, sdktrace.NewSpanLimits(), newSpanLimits(configura.NewConfigImpl()))
+}
+
+// retainingExporter is an in-memory span exporter keeping its spans on shutdown, for assertions once Start returned.
+type retainingExporter struct {
+	*tracetest.InMemoryExporter
+}
+
+// Shutdown keeps the exported spans.
+func (retainingExporter) Shutdown(context.Context) error { return nil }
+
+func TestStart_FlushesSpansOnShutdown(t *testing.T) {
+	// Not parallel, the OpenTelemetry providers and the default logger are global.
+	originalTracerProvider := otel.GetTracerProvider()
+	originalLogger := slog.Default()
+	t.Cleanup(func() {
+		otel.SetTracerProvider(originalTracerProvider)
+		slog.SetDefault(originalLogger)
+	})
+
+	cfg := configura.NewConfigImpl()
+	err := configura.WriteConfiguration(cfg, map[configura.Variable[bool]]bool{
+		OTEL_ENABLED:         true,
+		OTEL_TRACES_ENABLED:  true,
+		OTEL_METRICS_ENABLED: false,
+		OTEL_LOGS_ENABLED:    false,
+	})
+	require.NoError(t, err)
+	err = configura.WriteConfiguration(cfg, map[configura.Variable[string]]string{
+		SERVER_HOST: "127.0.0.1",
+	})
+	require.NoError(t, err)
+	err = configura.WriteConfiguration(cfg, map[configura.Variable[int64]]int64{
+		SERVER_PORT: 0,
+	})
+	require.NoError(t, err)
+
+	exporter := retainingExporter{tracetest.NewInMemoryExporter()}
+	server, err := StartAsync(context.Background(), configura.Merge(newDefaultCfg(), cfg), chi.NewRouter(), func(c configura.Config, r chi.Router, a huma.API) error {
+		r.Get("/last", func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte("ok"))
+		})
+		return nil
+	}, WithSpanExporter(exporter))
+	require.NoError(t, err)
+
+	// The request is served right before the shutdown, well within the batch timeout of the span processor.
+	resp, err := http.Get("http://" + server.Addr().String() + "/last")
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, server.Shutdown(ctx))
+	require.NoError(t, server.Wait())
+
+	var serverSpans int
+	for _, span := range exporter.GetSpans() {
+		if span.SpanKind == trace.SpanKindServer {
+			serverSpans++
+		}
+	}
+	assert.Equal(t, 1, serverSpans, "The span of the last request should be exported on shutdown")
+}
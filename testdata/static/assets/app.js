@@ -0,0 +1 @@
+document.getElementById("app").textContent = "hello";
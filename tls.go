@@ -0,0 +1,38 @@
+package ponrunner
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+
+	"github.com/ponrove/configura"
+)
+
+const (
+	SERVER_TLS_CERT_FILE configura.Variable[string] = "SERVER_TLS_CERT_FILE" // PEM certificate (chain) to serve HTTPS with, requires SERVER_TLS_KEY_FILE
+	SERVER_TLS_KEY_FILE  configura.Variable[string] = "SERVER_TLS_KEY_FILE"  // PEM private key of SERVER_TLS_CERT_FILE
+)
+
+// ErrIncompleteTLSConfig is returned by Start when only one of SERVER_TLS_CERT_FILE and SERVER_TLS_KEY_FILE is set.
+var ErrIncompleteTLSConfig = errors.New("incomplete TLS configuration")
+
+// newTLSConfig returns the TLS configuration of the server, with the certificate and key of SERVER_TLS_CERT_FILE and
+// SERVER_TLS_KEY_FILE, or nil to serve plain HTTP if neither is set. The key pair is loaded up front, so a missing or
+// invalid file fails Start before anything is served.
+func newTLSConfig(cfg configura.Config) (*tls.Config, error) {
+	certFile, keyFile := cfg.String(SERVER_TLS_CERT_FILE), cfg.String(SERVER_TLS_KEY_FILE)
+	switch {
+	case certFile == "" && keyFile == "":
+		return nil, nil
+	case certFile == "":
+		return nil, fmt.Errorf("%w: SERVER_TLS_KEY_FILE is set without SERVER_TLS_CERT_FILE", ErrIncompleteTLSConfig)
+	case keyFile == "":
+		return nil, fmt.Errorf("%w: SERVER_TLS_CERT_FILE is set without SERVER_TLS_KEY_FILE", ErrIncompleteTLSConfig)
+	}
+
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the TLS key pair: %w", err)
+	}
+	return &tls.Config{Certificates: []tls.Certificate{cert}}, nil
+}
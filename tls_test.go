@@ -0,0 +1,180 @@
+package ponrunner
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/go-chi/chi/v5"
+	"github.com/ponrove/configura"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeSelfSignedCert writes a self-signed certificate for localhost and its key to PEM files, and returns their paths
+// with a pool trusting the certificate.
+func writeSelfSignedCert(t *testing.T) (certFile, keyFile string, pool *x509.CertPool) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	certFile, keyFile = filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	pool = x509.NewCertPool()
+	pool.AddCert(cert)
+	return certFile, keyFile, pool
+}
+
+func TestNewTLSConfig(t *testing.T) {
+	t.Parallel()
+	certFile, keyFile, _ := writeSelfSignedCert(t)
+
+	tests := []struct {
+		name        string
+		certFile    string
+		keyFile     string
+		expectTLS   bool
+		expectedErr error
+	}{
+		{name: "Plain HTTP by default"},
+		{name: "Key pair", certFile: certFile, keyFile: keyFile, expectTLS: true},
+		{name: "Certificate only", certFile: certFile, expectedErr: ErrIncompleteTLSConfig},
+		{name: "Key only", keyFile: keyFile, expectedErr: ErrIncompleteTLSConfig},
+		{name: "Missing file", certFile: certFile, keyFile: filepath.Join(t.TempDir(), "missing.pem"), expectedErr: os.ErrNotExist},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := configura.NewConfigImpl()
+			err := configura.WriteConfiguration(cfg, map[configura.Variable[string]]string{
+				SERVER_TLS_CERT_FILE: tc.certFile,
+				SERVER_TLS_KEY_FILE:  tc.keyFile,
+			})
+			require.NoError(t, err)
+
+			tlsConfig, err := newTLSConfig(cfg)
+			if tc.expectedErr != nil {
+				assert.ErrorIs(t, err, tc.expectedErr)
+				assert.Nil(t, tlsConfig)
+				return
+			}
+			require.NoError(t, err)
+			if tc.expectTLS {
+				require.NotNil(t, tlsConfig)
+				assert.Len(t, tlsConfig.Certificates, 1)
+			} else {
+				assert.Nil(t, tlsConfig)
+			}
+		})
+	}
+}
+
+func TestStart_TLS(t *testing.T) {
+	t.Parallel()
+	certFile, keyFile, pool := writeSelfSignedCert(t)
+	freePort, err := getFreePort()
+	require.NoError(t, err, "Failed to get free port")
+
+	emptyCfg := configura.NewConfigImpl()
+	err = configura.WriteConfiguration(emptyCfg, map[configura.Variable[int64]]int64{
+		SERVER_PORT: int64(freePort),
+	})
+	require.NoError(t, err, "Failed to write configuration")
+	err = configura.WriteConfiguration(emptyCfg, map[configura.Variable[string]]string{
+		SERVER_TLS_CERT_FILE: certFile,
+		SERVER_TLS_KEY_FILE:  keyFile,
+	})
+	require.NoError(t, err, "Failed to write configuration")
+	finalCfg := configura.Merge(newDefaultCfg(), emptyCfg)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	startErrChan := make(chan error, 1)
+	go func() {
+		startErrChan <- Start(ctx, finalCfg, chi.NewRouter(), func(cfg configura.Config, r chi.Router, api huma.API) error {
+			r.Get("/secure", func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte(fmt.Sprintf("tls=%t", r.TLS != nil)))
+			})
+			return nil
+		})
+	}()
+
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}}
+	defer client.CloseIdleConnections()
+	url := fmt.Sprintf("https://localhost:%d/secure", freePort)
+	var resp *http.Response
+	require.Eventually(t, func() bool {
+		resp, err = client.Get(url)
+		return err == nil
+	}, 2*time.Second, 50*time.Millisecond, "server never started")
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "tls=true", string(body))
+
+	resp, err = http.Get(fmt.Sprintf("http://localhost:%d/secure", freePort))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode, "Plain HTTP should not be served")
+
+	cancel()
+	select {
+	case err := <-startErrChan:
+		assert.NoError(t, err, "Start should shut the TLS server down gracefully")
+	case <-time.After(3 * time.Second):
+		t.Fatal("Start did not exit after context cancellation")
+	}
+}
+
+func TestStart_IncompleteTLSConfigFailsFast(t *testing.T) {
+	t.Parallel()
+	certFile, _, _ := writeSelfSignedCert(t)
+	emptyCfg := configura.NewConfigImpl()
+	err := configura.WriteConfiguration(emptyCfg, map[configura.Variable[string]]string{
+		SERVER_TLS_CERT_FILE: certFile,
+	})
+	require.NoError(t, err, "Failed to write configuration")
+	finalCfg := configura.Merge(newDefaultCfg(), emptyCfg)
+
+	err = Start(context.Background(), finalCfg, chi.NewRouter(), func(cfg configura.Config, r chi.Router, api huma.API) error {
+		t.Error("Routes should not be registered with an invalid TLS configuration")
+		return nil
+	})
+	assert.ErrorIs(t, err, ErrIncompleteTLSConfig)
+	assert.ErrorContains(t, err, "SERVER_TLS_CERT_FILE is set without SERVER_TLS_KEY_FILE")
+}
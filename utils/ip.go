@@ -232,6 +232,27 @@ var defaultHeaders = []string{
 	"Http-Client-Ip",
 }
 
+// singleValueHeaders are the headers carrying a single IP address, set by the proxy in front of the service, rather than
+// a comma separated list of the addresses a request passed through, like X-Forwarded-For. Their value is parsed as a
+// whole, so a value with a comma is invalid instead of being split into addresses.
+var singleValueHeaders = map[string]struct{}{
+	"X-Real-Ip":                {},
+	"X-Client-Ip":              {},
+	"True-Client-Ip":           {},
+	"Cf-Connecting-Ip":         {},
+	"Http-X-Cluster-Client-Ip": {},
+	"Http-Client-Ip":           {},
+}
+
+// headerAddresses returns the addresses in the value of the header, a single address for the singleValueHeaders and a
+// comma separated list for any other header.
+func headerAddresses(header, value string) []string {
+	if _, ok := singleValueHeaders[http.CanonicalHeaderKey(header)]; ok {
+		return []string{value}
+	}
+	return strings.Split(value, ",")
+}
+
 // IPAddressFromRequest extracts the IP address from the request headers or remote address. Optionally checks specified
 // headers for the IP address, falling back to the remote address if no valid public IP is found.
 func IPAddressFromRequest(cfg configura.Config, checkHeaders []string, r *http.Request) string {
@@ -240,7 +261,7 @@ func IPAddressFromRequest(cfg configura.Config, checkHeaders []string, r *http.R
 	}
 
 	for _, h := range checkHeaders {
-		addresses := strings.Split(r.Header.Get(h), ",")
+		addresses := headerAddresses(h, r.Header.Get(h))
 		// march from right to left until we get a public address
 		// that will be the address right before our proxy.
 		for i := len(addresses) - 1; i >= 0; i-- {
@@ -274,3 +295,60 @@ func IPAddressFromRequest(cfg configura.Config, checkHeaders []string, r *http.R
 
 	return ""
 }
+
+// ParseTrustedProxies parses a comma separated list of CIDR ranges or single IP addresses (e.g.
+// "10.0.0.0/8,192.168.1.10") into a list of networks. Invalid entries are skipped.
+func ParseTrustedProxies(value string) []*net.IPNet {
+	var networks []*net.IPNet
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				continue
+			}
+			bits := 128
+			if ip.To4() != nil {
+				ip = ip.To4()
+				bits = 32
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			continue
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}
+
+// IsTrustedProxy checks if the peer address of a request (host:port, as found in http.Request.RemoteAddr) is within
+// one of the trusted networks.
+func IsTrustedProxy(remoteAddr string, trusted []*net.IPNet) bool {
+	if len(trusted) == 0 {
+		return false
+	}
+
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+
+	for _, network := range trusted {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
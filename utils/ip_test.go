@@ -310,6 +310,26 @@ func TestIPAddressFromRequest(t *testing.T) {
 			expectedIP:     "8.8.8.8",
 		},
 
+		{
+			name:           "X-Real-Ip: single value with a comma is not split",
+			requestHeaders: http.Header{"X-Real-Ip": {"8.8.8.8, 1.1.1.1"}},
+			remoteAddr:     "192.168.1.1:12345",
+			expectedIP:     "",
+		},
+		{
+			name:           "X-Forwarded-For: list with a comma is split",
+			requestHeaders: http.Header{"X-Forwarded-For": {"8.8.8.8, 1.1.1.1"}},
+			remoteAddr:     "192.168.1.1:12345",
+			expectedIP:     "1.1.1.1",
+		},
+		{
+			name:           "Custom headers: lowercase single value header is not split",
+			checkHeaders:   []string{"x-real-ip"},
+			requestHeaders: http.Header{"X-Real-Ip": {"10.0.0.1,8.8.8.8"}},
+			remoteAddr:     "192.168.1.1:12345",
+			expectedIP:     "",
+		},
+
 		// Header order (default: X-Forwarded-For before X-Real-Ip)
 		{
 			name: "Default header order: X-Forwarded-For (public) preferred over X-Real-Ip (public)",
@@ -429,3 +449,35 @@ func TestIPAddressFromRequest(t *testing.T) {
 		})
 	}
 }
+
+func TestIsTrustedProxy(t *testing.T) {
+	trusted := ParseTrustedProxies("10.0.0.0/8, 192.168.1.10, 2001:db8::/32, invalid, 300.0.0.1")
+	if len(trusted) != 3 {
+		t.Fatalf("ParseTrustedProxies returned %d networks, want 3 (invalid entries skipped)", len(trusted))
+	}
+
+	tests := []struct {
+		remoteAddr string
+		expected   bool
+	}{
+		{remoteAddr: "10.1.2.3:1234", expected: true},
+		{remoteAddr: "192.168.1.10:1234", expected: true},
+		{remoteAddr: "192.168.1.11:1234", expected: false},
+		{remoteAddr: "[2001:db8::1]:1234", expected: true},
+		{remoteAddr: "8.8.8.8:1234", expected: false},
+		{remoteAddr: "10.1.2.3", expected: true},
+		{remoteAddr: "not-an-ip:1234", expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.remoteAddr, func(t *testing.T) {
+			if got := IsTrustedProxy(tt.remoteAddr, trusted); got != tt.expected {
+				t.Errorf("IsTrustedProxy(%s) = %v, want %v", tt.remoteAddr, got, tt.expected)
+			}
+		})
+	}
+
+	if IsTrustedProxy("10.1.2.3:1234", nil) {
+		t.Errorf("IsTrustedProxy without trusted networks = true, want false")
+	}
+}
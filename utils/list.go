@@ -0,0 +1,17 @@
+package utils
+
+import "strings"
+
+// SplitCommaSeparated splits a comma separated configuration value (e.g. "/livez, /readyz") into its entries, with
+// surrounding whitespace trimmed. Empty entries are skipped.
+func SplitCommaSeparated(value string) []string {
+	var entries []string
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
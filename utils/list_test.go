@@ -0,0 +1,27 @@
+package utils
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSplitCommaSeparated(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		expected []string
+	}{
+		{name: "Empty value", value: "", expected: nil},
+		{name: "Single entry", value: "/livez", expected: []string{"/livez"}},
+		{name: "Whitespace is trimmed", value: " /livez , /readyz ", expected: []string{"/livez", "/readyz"}},
+		{name: "Empty entries are skipped", value: "/livez,,/readyz,", expected: []string{"/livez", "/readyz"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SplitCommaSeparated(tt.value); !slices.Equal(got, tt.expected) {
+				t.Errorf("SplitCommaSeparated(%q) = %v, want %v", tt.value, got, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package ponrunner
+
+import (
+	"encoding/json"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/ponrove/configura"
+)
+
+const (
+	SERVER_VERSION_PATH configura.Variable[string] = "SERVER_VERSION_PATH" // Path of the build and runtime info endpoint (e.g. /version), disabled by default
+)
+
+// BuildVersion and BuildCommit identify the build of the service, reported by the SERVER_VERSION_PATH endpoint. Set
+// them at build time, e.g. with -ldflags "-X github.com/ponrove/ponrunner.BuildVersion=v1.2.3". When unset, they are
+// read from the build info Go embeds in the binary, if any.
+var (
+	BuildVersion string
+	BuildCommit  string
+)
+
+// versionInfo is the body of the version endpoint.
+type versionInfo struct {
+	Service       string  `json:"service"`
+	Version       string  `json:"version"`
+	Commit        string  `json:"commit"`
+	GoVersion     string  `json:"go_version"`
+	UptimeSeconds float64 `json:"uptime_seconds"`
+}
+
+// buildVersion returns BuildVersion and BuildCommit, falling back to the module version and VCS revision of the
+// binary's build info.
+func buildVersion() (version, commit string) {
+	version, commit = BuildVersion, BuildCommit
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return version, commit
+	}
+	if version == "" && info.Main.Version != "(devel)" {
+		version = info.Main.Version
+	}
+	for _, setting := range info.Settings {
+		if commit == "" && setting.Key == "vcs.revision" {
+			commit = setting.Value
+		}
+	}
+	return version, commit
+}
+
+// registerVersionEndpoint registers the endpoint reporting the service name, build version and commit, Go version and
+// uptime as JSON at SERVER_VERSION_PATH, if set. The uptime is counted from the registration.
+func registerVersionEndpoint(cfg configura.Config, router chi.Router) {
+	path := cfg.String(SERVER_VERSION_PATH)
+	if path == "" {
+		return
+	}
+	started := time.Now()
+	version, commit := buildVersion()
+	router.Get(path, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(versionInfo{
+			Service:       configura.Fallback(cfg.String(OTEL_SERVICE_NAME), "ponrove"),
+			Version:       version,
+			Commit:        commit,
+			GoVersion:     runtime.Version(),
+			UptimeSeconds: time.Since(started).Seconds(),
+		})
+	})
+}
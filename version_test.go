@@ -0,0 +1,53 @@
+package ponrunner
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/ponrove/configura"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVersionEndpoint(t *testing.T) {
+	// Not parallel, the build info is global.
+	originalVersion, originalCommit := BuildVersion, BuildCommit
+	BuildVersion, BuildCommit = "v1.2.3", "abc123"
+	t.Cleanup(func() { BuildVersion, BuildCommit = originalVersion, originalCommit })
+
+	cfg := configura.NewConfigImpl()
+	err := configura.WriteConfiguration(cfg, map[configura.Variable[string]]string{
+		SERVER_VERSION_PATH: "/version",
+		OTEL_SERVICE_NAME:   "orders",
+	})
+	require.NoError(t, err)
+	router := chi.NewRouter()
+	registerVersionEndpoint(cfg, router)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/version", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+
+	var info map[string]any
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &info))
+	assert.Equal(t, "orders", info["service"])
+	assert.Equal(t, "v1.2.3", info["version"])
+	assert.Equal(t, "abc123", info["commit"])
+	assert.Equal(t, runtime.Version(), info["go_version"])
+	assert.Contains(t, info, "uptime_seconds")
+	assert.GreaterOrEqual(t, info["uptime_seconds"], 0.0)
+}
+
+func TestVersionEndpoint_DisabledByDefault(t *testing.T) {
+	router := chi.NewRouter()
+	registerVersionEndpoint(configura.NewConfigImpl(), router)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/version", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
@@ -0,0 +1,92 @@
+package ponrunner
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// worker is a background worker registered with RegisterWorker.
+type worker struct {
+	name string
+	run  func(ctx context.Context) error
+}
+
+var (
+	workersMu sync.Mutex
+	workers   []worker
+)
+
+// RegisterWorker registers a background worker, e.g. a poller, that runs for the lifetime of the server. Bundles call
+// it while their routes are registered; Start then launches each registered worker in its own goroutine with the
+// server context, and during shutdown cancels it and waits for it to return (up to SERVER_SHUTDOWN_TIMEOUT). Errors
+// returned by a worker are logged, they don't stop the server.
+func RegisterWorker(name string, run func(ctx context.Context) error) {
+	workersMu.Lock()
+	defer workersMu.Unlock()
+	workers = append(workers, worker{name: name, run: run})
+}
+
+// takeWorkers returns the registered workers, and clears the registry so they're only started once.
+func takeWorkers() []worker {
+	workersMu.Lock()
+	defer workersMu.Unlock()
+	registered := workers
+	workers = nil
+	return registered
+}
+
+// startWorkers launches the workers with a context derived from ctx. The returned function cancels the workers, and
+// waits for them to return within the timeout.
+func startWorkers(ctx context.Context, registered []worker) func(timeout time.Duration) {
+	workerCtx, cancel := context.WithCancel(ctx)
+	var wg sync.WaitGroup
+	for _, w := range registered {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			slog.InfoContext(workerCtx, "Starting worker", slog.String("worker", w.name))
+			if err := runWorker(workerCtx, w); err != nil {
+				slog.ErrorContext(workerCtx, "Worker stopped with an error", slog.String("worker", w.name), slog.Any("error", err))
+				return
+			}
+			slog.InfoContext(workerCtx, "Worker stopped", slog.String("worker", w.name))
+		}()
+	}
+
+	return func(timeout time.Duration) {
+		cancel()
+		if len(registered) == 0 {
+			return
+		}
+
+		done := make(chan struct{})
+		go func() {
+			wg.Wait()
+			close(done)
+		}()
+
+		select {
+		case <-done:
+			slog.Info("All workers stopped.")
+		case <-time.After(timeout):
+			slog.Warn("Timed out waiting for workers to stop.", slog.Duration("timeout", timeout))
+		}
+	}
+}
+
+// runWorker runs the worker, returning a panic as an error so one misbehaving worker doesn't crash the server.
+func runWorker(ctx context.Context, w worker) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("worker panicked: %v", p)
+		}
+	}()
+	err = w.run(ctx)
+	if err == context.Canceled && ctx.Err() != nil {
+		return nil // Stopping on cancellation is the expected way for a worker to return.
+	}
+	return err
+}
@@ -0,0 +1,100 @@
+package ponrunner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/go-chi/chi/v5"
+	"github.com/ponrove/configura"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStart_WorkerStopsOnShutdown(t *testing.T) {
+	// Not parallel, workers are registered globally.
+	freePort, err := getFreePort()
+	require.NoError(t, err, "Failed to get free port")
+
+	emptyCfg := configura.NewConfigImpl()
+	err = configura.WriteConfiguration(emptyCfg, map[configura.Variable[int64]]int64{
+		SERVER_PORT: int64(freePort),
+	})
+	require.NoError(t, err, "Failed to write configuration")
+	finalCfg := configura.Merge(newDefaultCfg(), emptyCfg)
+
+	started := make(chan struct{})
+	stopped := make(chan error, 1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	startErrChan := make(chan error, 1)
+	go func() {
+		startErrChan <- Start(ctx, finalCfg, chi.NewRouter(), func(cfg configura.Config, r chi.Router, a huma.API) error {
+			RegisterWorker("poller", func(ctx context.Context) error {
+				close(started)
+				<-ctx.Done()
+				stopped <- ctx.Err()
+				return ctx.Err()
+			})
+			return nil
+		})
+	}()
+
+	select {
+	case <-started:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Worker was not started")
+	}
+	require.Eventually(t, func() bool {
+		conn, err := net.Dial("tcp", fmt.Sprintf("localhost:%d", freePort))
+		if err != nil {
+			return false
+		}
+		conn.Close()
+		return true
+	}, 2*time.Second, 50*time.Millisecond, "server never started")
+
+	select {
+	case <-stopped:
+		t.Fatal("Worker should run until shutdown")
+	default:
+	}
+
+	cancel()
+	select {
+	case err := <-startErrChan:
+		assert.NoError(t, err, "Start should exit gracefully without error")
+	case <-time.After(3 * time.Second):
+		t.Fatal("Start did not exit after context cancellation")
+	}
+
+	select {
+	case err := <-stopped:
+		assert.ErrorIs(t, err, context.Canceled, "Worker context should be cancelled on shutdown")
+	default:
+		t.Fatal("Worker should have stopped before Start returned")
+	}
+}
+
+func TestStartWorkers_ErrorsAndPanicsAreContained(t *testing.T) {
+	t.Parallel()
+
+	returned := make(chan string, 2)
+	stop := startWorkers(context.Background(), []worker{
+		{name: "failing", run: func(ctx context.Context) error {
+			defer func() { returned <- "failing" }()
+			return errors.New("poll failed")
+		}},
+		{name: "panicking", run: func(ctx context.Context) error {
+			defer func() { returned <- "panicking" }()
+			panic("poller bug")
+		}},
+	})
+
+	assert.ElementsMatch(t, []string{"failing", "panicking"}, []string{<-returned, <-returned})
+	stop(time.Second)
+}